@@ -0,0 +1,117 @@
+// Package cache stores the outputs of tasks keyed by a hash of their inputs,
+// so that a task whose inputs have not changed can be skipped and its outputs restored,
+// on the machine that ran it or, with a remote backend, on any other.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Backend stores cache entries.
+type Backend interface {
+	// Get returns the entry stored under key, ok is false if there is none.
+	Get(ctx context.Context, key string) (r io.ReadCloser, ok bool, err error)
+	// Put stores an entry under key.
+	Put(ctx context.Context, key string, r io.Reader) error
+}
+
+// Mode is whether entries are only read from a Backend or also uploaded to it.
+type Mode int
+
+const (
+	// ModeReadOnly restores outputs from the cache but never stores them, for teammates sharing a cache filled by CI.
+	ModeReadOnly Mode = iota
+	// ModeUpload restores outputs from the cache and stores the outputs of tasks that ran.
+	ModeUpload
+)
+
+func (m Mode) String() string {
+	if m == ModeUpload {
+		return "upload"
+	}
+	return "read-only"
+}
+
+// ParseMode parses read-only or upload.
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "read-only", "":
+		return ModeReadOnly, nil
+	case "upload":
+		return ModeUpload, nil
+	}
+	return 0, fmt.Errorf("invalid cache mode %q should be (read-only, upload)", s)
+}
+
+// Open returns the Backend for location, which can be:
+//   - an http or https URL, entries are read with GET and stored with PUT.
+//   - an s3://bucket/prefix URL, using the AWS credentials in the environment.
+//   - a gs://bucket/prefix URL, using the OAuth access token in GOOGLE_OAUTH_ACCESS_TOKEN.
+//   - a directory.
+//
+// If XC_CACHE_TOKEN is set it is sent as a bearer token to HTTP backends.
+func Open(location string) (Backend, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// A path, including Windows paths such as C:\cache.
+		return Dir(location), nil
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTP{URL: strings.TrimSuffix(location, "/"), Token: os.Getenv("XC_CACHE_TOKEN")}, nil
+	case "s3":
+		return newS3(u.Host, strings.Trim(u.Path, "/"))
+	case "gs":
+		token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+		if token == "" {
+			return nil, errors.New("GOOGLE_OAUTH_ACCESS_TOKEN must be set to use a gs:// cache")
+		}
+		return &HTTP{URL: strings.TrimSuffix("https://storage.googleapis.com/"+u.Host+u.Path, "/"), Token: token}, nil
+	case "file":
+		return Dir(u.Path), nil
+	}
+	return nil, fmt.Errorf("unsupported cache location %q, should be a directory or an http, https, s3 or gs URL",
+		location)
+}
+
+// Dir is a Backend that stores entries as files in a directory.
+type Dir string
+
+// Get implements Backend.
+func (d Dir) Get(_ context.Context, key string) (io.ReadCloser, bool, error) {
+	f, err := os.Open(filepath.Join(string(d), key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return f, true, nil
+}
+
+// Put implements Backend, entries are written to a temporary file first so a partial entry is never read.
+func (d Dir) Put(_ context.Context, key string, r io.Reader) error {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(string(d), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(string(d), key))
+}
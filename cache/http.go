@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTP is a Backend that reads entries from URL/key with GET and stores them with PUT,
+// such as a bazel-remote or nginx WebDAV server.
+type HTTP struct {
+	URL string
+	// Token is sent as a bearer token if it is not empty.
+	Token string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// sign adds authentication to a request, instead of Token.
+	sign func(req *http.Request)
+}
+
+func (h *HTTP) do(req *http.Request) (*http.Response, error) {
+	switch {
+	case h.sign != nil:
+		h.sign(req)
+	case h.Token != "":
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	c := h.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return c.Do(req)
+}
+
+// Get implements Backend.
+func (h *HTTP) Get(ctx context.Context, key string) (io.ReadCloser, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL+"/"+key, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := h.do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, false, nil
+	case resp.StatusCode >= 300:
+		resp.Body.Close()
+		return nil, false, fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return resp.Body, true, nil
+}
+
+// Put implements Backend.
+func (h *HTTP) Put(ctx context.Context, key string, r io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, h.URL+"/"+key, r)
+	if err != nil {
+		return err
+	}
+	resp, err := h.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("PUT %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Package ci detects the CI system xc is running in and formats output for it.
+package ci
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Provider is a CI system that can fold sections of its logs.
+type Provider interface {
+	// Name is the name of the CI system.
+	Name() string
+	// Group writes the start of a collapsible section of the log titled title,
+	// calling end writes the end of the section.
+	Group(w io.Writer, title string) (end func())
+}
+
+// Detect returns the Provider of the CI system xc is running in, if it supports folding logs.
+func Detect() (Provider, bool) {
+	return detect(os.Getenv)
+}
+
+func detect(getenv func(string) string) (Provider, bool) {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return githubActions{}, true
+	case getenv("GITLAB_CI") == "true":
+		return &gitlab{now: time.Now}, true
+	case getenv("BUILDKITE") == "true":
+		return buildkite{}, true
+	}
+	return nil, false
+}
+
+// ciVars are set by CI systems that do not set CI to true.
+var ciVars = []string{
+	"GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "CIRCLECI", "JENKINS_URL", "TF_BUILD", "TEAMCITY_VERSION",
+}
+
+// Running reports whether xc is running in CI, because CI is set to anything but false, as most CI systems do,
+// or a variable of a CI system that does not set CI is present.
+func Running() bool {
+	return running(os.Getenv)
+}
+
+func running(getenv func(string) string) bool {
+	if v := getenv("CI"); v != "" {
+		on, err := strconv.ParseBool(v)
+		return err != nil || on
+	}
+	for _, v := range ciVars {
+		if getenv(v) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type githubActions struct{}
+
+func (githubActions) Name() string { return "GitHub Actions" }
+
+// Group uses workflow commands, groups cannot be nested so a group is ended by the start of the next.
+func (githubActions) Group(w io.Writer, title string) func() {
+	fmt.Fprintf(w, "::group::%s\n", title)
+	return func() {
+		fmt.Fprintln(w, "::endgroup::")
+	}
+}
+
+type gitlab struct {
+	now  func() time.Time
+	next atomic.Int64
+}
+
+func (*gitlab) Name() string { return "GitLab CI" }
+
+// Group writes collapsible section markers, section names must be unique in a job.
+func (g *gitlab) Group(w io.Writer, title string) func() {
+	name := fmt.Sprintf("xc_%d_%s", g.next.Add(1), sectionName(title))
+	fmt.Fprintf(w, "\x1b[0Ksection_start:%d:%s[collapsed=true]\r\x1b[0K%s\n", g.now().Unix(), name, title)
+	return func() {
+		fmt.Fprintf(w, "\x1b[0Ksection_end:%d:%s\r\x1b[0K\n", g.now().Unix(), name)
+	}
+}
+
+// sectionName replaces characters not allowed in GitLab section names.
+func sectionName(title string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, title)
+}
+
+type buildkite struct{}
+
+func (buildkite) Name() string { return "Buildkite" }
+
+// Group writes a collapsed group header, a group lasts until the next header so there is no end marker.
+func (buildkite) Group(w io.Writer, title string) func() {
+	fmt.Fprintf(w, "--- %s\n", title)
+	return func() {}
+}
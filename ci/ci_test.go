@@ -0,0 +1,93 @@
+package ci
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func env(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		env    map[string]string
+		expect string
+	}{
+		{env: map[string]string{"GITHUB_ACTIONS": "true", "CI": "true"}, expect: "GitHub Actions"},
+		{env: map[string]string{"GITLAB_CI": "true", "CI": "true"}, expect: "GitLab CI"},
+		{env: map[string]string{"BUILDKITE": "true", "CI": "true"}, expect: "Buildkite"},
+		{env: map[string]string{"CI": "true"}},
+		{env: map[string]string{}},
+	}
+	for _, tt := range tests {
+		p, ok := detect(env(tt.env))
+		if tt.expect == "" {
+			if ok {
+				t.Errorf("expected no provider for %v got %s", tt.env, p.Name())
+			}
+			continue
+		}
+		if !ok || p.Name() != tt.expect {
+			t.Errorf("expected %s for %v got %v", tt.expect, tt.env, p)
+		}
+	}
+}
+
+func TestRunning(t *testing.T) {
+	tests := []struct {
+		env    map[string]string
+		expect bool
+	}{
+		{env: map[string]string{"CI": "true"}, expect: true},
+		{env: map[string]string{"CI": "1"}, expect: true},
+		{env: map[string]string{"CI": "woodpecker"}, expect: true},
+		{env: map[string]string{"JENKINS_URL": "https://jenkins.example.com"}, expect: true},
+		{env: map[string]string{"CI": "false", "GITHUB_ACTIONS": "true"}},
+		{env: map[string]string{}},
+	}
+	for _, tt := range tests {
+		if got := running(env(tt.env)); got != tt.expect {
+			t.Errorf("expected %v for %v got %v", tt.expect, tt.env, got)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		expect   string
+	}{
+		{
+			name:     "github actions",
+			provider: githubActions{},
+			expect:   "::group::build linux\noutput\n::endgroup::\n",
+		},
+		{
+			name:     "gitlab",
+			provider: &gitlab{now: func() time.Time { return time.Unix(1700000000, 0) }},
+			expect: "\x1b[0Ksection_start:1700000000:xc_1_build_linux[collapsed=true]\r\x1b[0Kbuild linux\n" +
+				"output\n" +
+				"\x1b[0Ksection_end:1700000000:xc_1_build_linux\r\x1b[0K\n",
+		},
+		{
+			name:     "buildkite",
+			provider: buildkite{},
+			expect:   "--- build linux\noutput\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			end := tt.provider.Group(&buf, "build linux")
+			buf.WriteString("output\n")
+			end()
+			if buf.String() != tt.expect {
+				t.Fatalf("expected %q got %q", tt.expect, buf.String())
+			}
+		})
+	}
+}
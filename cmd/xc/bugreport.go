@@ -0,0 +1,188 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/result"
+	"github.com/joerdav/xc/run"
+	"github.com/joerdav/xc/tools"
+)
+
+var errBugReportUsage = errors.New("usage: xc report [-o file] <task> [inputs...]")
+
+// secretNameRe matches the names of environment variables whose values are masked in a report.
+var secretNameRe = regexp.MustCompile(
+	`(?i)token|secret|passw(or)?d|credential|private|auth|session|cookie|api_?key|access_?key|_key$`)
+
+// reportFileChars matches the characters of a task name that are replaced in the name of its report.
+var reportFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// xc report [-o file] <task> [inputs...]
+//
+// It bundles what is needed to reproduce a bug in a task into a tarball to attach to an issue.
+func bugReportCommand(ctx context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	out := fs.String("o", "", "the file to write the report to, xc-report-<task>-<time>.tar.gz by default")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errBugReportUsage
+	}
+	name := fs.Arg(0)
+	tasks, err := applyOverrides(tasks, name, cfg)
+	if err != nil {
+		return err
+	}
+	task, ok := tasks.Get(name)
+	if !ok {
+		return errTaskNotFound(cfg, tasks, dir, name)
+	}
+	runner, err := run.NewRunner(tasks, dir, runnerOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	env, err := runner.DeclaredEnvironment(name, fs.Args()[1:])
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	env, mask := maskEnv(env)
+	var definition bytes.Buffer
+	task.Display(&definition)
+	files := map[string]string{
+		"task.txt":   definition.String(),
+		"env.txt":    strings.Join(env, "\n") + "\n",
+		"tools.txt":  toolVersions(ctx, task),
+		"system.txt": systemInfo(ctx),
+	}
+	lastRun := result.LastRunDir(dir, name)
+	entries, err := os.ReadDir(lastRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "xc: warning: no run of task %s has been recorded, the report has no logs\n", name)
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(lastRun, e.Name()))
+		if err != nil {
+			return fmt.Errorf("xc: failed to read the last run: %w", err)
+		}
+		files["last-run/"+e.Name()] = string(b)
+	}
+	var bundle bytes.Buffer
+	if err = writeBugReport(&bundle, files, mask); err != nil {
+		return fmt.Errorf("xc: failed to write report: %w", err)
+	}
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("xc-report-%s-%s.tar.gz",
+			reportFileChars.ReplaceAllString(name, "_"), time.Now().Format("20060102T150405"))
+	}
+	if err = os.WriteFile(path, bundle.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	fmt.Printf("Wrote %s, check it for secrets before sharing it\n", path)
+	return nil
+}
+
+// maskEnv masks the values of env whose names look like they hold secrets,
+// mask replaces those values wherever else they appear, such as in the logs of the last run.
+// The values of secret references are masked in the logs when they are recorded, as they are not resolved here.
+func maskEnv(env []string) (masked []string, mask *strings.Replacer) {
+	var secrets []string
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		if !secretNameRe.MatchString(k) || v == "" {
+			masked = append(masked, e)
+			continue
+		}
+		masked = append(masked, k+"=****")
+		// Short values are too likely to match unrelated text to be replaced.
+		if len(v) >= 8 {
+			secrets = append(secrets, v)
+		}
+	}
+	// Longer secrets are replaced first, so that a secret containing another is masked whole.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	var pairs []string
+	for _, s := range secrets {
+		pairs = append(pairs, s, "****")
+	}
+	return masked, strings.NewReplacer(pairs...)
+}
+
+// toolVersions lists the version of xc, and of the tools a task uses.
+func toolVersions(ctx context.Context, task models.Task) string {
+	names := []string{"bash", "git"}
+	for _, rt := range task.RequiresTools {
+		if r, err := tools.Parse(rt); err == nil {
+			names = append(names, r.Name)
+		}
+	}
+	if len(task.Compose) > 0 {
+		names = append(names, "docker")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "xc: %s\n", getVersion())
+	checker := tools.NewChecker()
+	seen := map[string]bool{}
+	for _, n := range names {
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		v, err := checker.Version(ctx, n)
+		if err != nil {
+			v = err.Error()
+		}
+		fmt.Fprintf(&b, "%s: %s\n", n, v)
+	}
+	return b.String()
+}
+
+// systemInfo describes the operating system xc is running on.
+func systemInfo(ctx context.Context) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "os: %s\narch: %s\ncpus: %d\ngo: %s\nshell: %s\n",
+		runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), runtime.Version(), os.Getenv("SHELL"))
+	if out, err := exec.CommandContext(ctx, "uname", "-srvm").Output(); err == nil {
+		fmt.Fprintf(&b, "uname: %s\n", strings.TrimSpace(string(out)))
+	}
+	return b.String()
+}
+
+// writeBugReport writes files to w as a gzipped tarball, sorted by name, with secrets masked.
+func writeBugReport(w io.Writer, files map[string]string, mask *strings.Replacer) error {
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, n := range names {
+		content := mask.Replace(files[n])
+		if err := tw.WriteHeader(&tar.Header{Name: n, Mode: 0o644, Size: int64(len(content)), ModTime: now}); err != nil {
+			return err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
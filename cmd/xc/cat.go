@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+var errCatUsage = errors.New("usage: xc cat <task> [inputs...]")
+
+// xc cat <task> [inputs...]
+func catCommand(_ context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	if len(args) == 0 {
+		return errCatUsage
+	}
+	name := args[0]
+	tasks, err := applyOverrides(tasks, name, cfg)
+	if err != nil {
+		return err
+	}
+	if _, ok := tasks.Get(name); !ok {
+		return errTaskNotFound(cfg, tasks, dir, name)
+	}
+	runner, err := run.NewRunner(tasks, dir, runnerOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	script, err := runner.Script(name, args[1:])
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	fmt.Print(script)
+	return nil
+}
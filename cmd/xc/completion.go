@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var errCompletionUsage = errors.New("usage: xc completion install|uninstall [bash|zsh|fish|powershell]")
+
+// completionShells are the shells xc completion installs completion for.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// shellCompletion is where the completion script of a shell is installed.
+type shellCompletion struct {
+	shell  string
+	path   string
+	script string
+	// rc is a file the shell runs on start that must source path, if the shell does not load path itself.
+	rc     string
+	source string
+}
+
+// xc completion install|uninstall [bash|zsh|fish|powershell]
+//
+// It runs before the task file is parsed, so completion can be installed outside of a project.
+func completionCommand(args []string) error {
+	if len(args) == 0 || len(args) > 2 || (args[0] != "install" && args[0] != "uninstall") {
+		return errCompletionUsage
+	}
+	shell := detectShell()
+	if len(args) == 2 {
+		shell = args[1]
+	}
+	if shell == "" {
+		return fmt.Errorf("xc: the shell could not be detected, run xc completion %s <%s>",
+			args[0], strings.Join(completionShells, "|"))
+	}
+	bin, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if bin, err = filepath.Abs(bin); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	c, err := completionFor(shell, bin)
+	if err != nil {
+		return err
+	}
+	if args[0] == "uninstall" {
+		return c.uninstall()
+	}
+	return c.install()
+}
+
+// detectShell returns the shell xc is run from, or an empty string if it is not known.
+func detectShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		name := strings.TrimSuffix(filepath.Base(shell), ".exe")
+		for _, s := range completionShells {
+			if name == s {
+				return s
+			}
+		}
+		return ""
+	}
+	// PowerShell sets PSModulePath, and is the usual shell on Windows where SHELL is not set.
+	if os.Getenv("PSModulePath") != "" || runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return ""
+}
+
+func completionFor(shell, bin string) (shellCompletion, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return shellCompletion{}, fmt.Errorf("xc: %w", err)
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	quoted, err := syntax.Quote(bin, syntax.LangBash)
+	if err != nil {
+		return shellCompletion{}, fmt.Errorf("xc: %w", err)
+	}
+	c := shellCompletion{shell: shell}
+	switch shell {
+	case "bash":
+		// bash-completion loads the completion of a command from a file named after it when it is first completed.
+		c.path = filepath.Join(dataHome, "bash-completion", "completions", "xc")
+		c.script = fmt.Sprintf("complete -o default -C %s xc\n", quoted)
+	case "zsh":
+		c.path = filepath.Join(dataHome, "xc", "completion.zsh")
+		c.script = fmt.Sprintf("autoload -U +X bashcompinit && bashcompinit\ncomplete -o nospace -C %s xc\n", quoted)
+		zdotdir := os.Getenv("ZDOTDIR")
+		if zdotdir == "" {
+			zdotdir = home
+		}
+		c.rc = filepath.Join(zdotdir, ".zshrc")
+		path, _ := syntax.Quote(c.path, syntax.LangBash)
+		c.source = "source " + path
+	case "fish":
+		c.path = filepath.Join(configHome, "fish", "completions", "xc.fish")
+		c.script = fmt.Sprintf(`function __complete_xc
+    set -lx COMP_LINE (commandline -cp)
+    test -z (commandline -ct)
+    and set COMP_LINE "$COMP_LINE "
+    set -lx COMP_POINT (string length -- "$COMP_LINE")
+    %s
+end
+complete -f -c xc -a "(__complete_xc)"
+`, "'"+strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(bin)+"'")
+	case "powershell":
+		c.path = filepath.Join(dataHome, "xc", "completion.ps1")
+		if runtime.GOOS == "windows" {
+			c.path = filepath.Join(os.Getenv("LOCALAPPDATA"), "xc", "completion.ps1")
+		}
+		c.script = fmt.Sprintf(`Register-ArgumentCompleter -Native -CommandName xc -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $point = $cursorPosition - $commandAst.Extent.StartOffset
+    $env:COMP_LINE = $commandAst.ToString().PadRight($point).Substring(0, $point)
+    $env:COMP_POINT = $point
+    try {
+        & %[1]s | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        }
+    } finally {
+        Remove-Item Env:COMP_LINE, Env:COMP_POINT
+    }
+}
+`, powershellQuote(bin))
+		c.rc = powershellProfile(home)
+		c.source = ". " + powershellQuote(c.path)
+	default:
+		return c, fmt.Errorf("xc: completion is not supported for %s, use one of %s",
+			shell, strings.Join(completionShells, ", "))
+	}
+	return c, nil
+}
+
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// powershellProfile returns the profile PowerShell runs on start for the current user,
+// asking PowerShell if it is installed.
+func powershellProfile(home string) string {
+	for _, ps := range []string{"pwsh", "powershell"} {
+		if out, err := exec.Command(ps, "-NoLogo", "-NoProfile", "-Command", "$PROFILE").Output(); err == nil {
+			if p := strings.TrimSpace(string(out)); p != "" {
+				return p
+			}
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	}
+	return filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
+func (c shellCompletion) install() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("xc: failed to install completion: %w", err)
+	}
+	if err := os.WriteFile(c.path, []byte(c.script), 0o644); err != nil {
+		return fmt.Errorf("xc: failed to install completion: %w", err)
+	}
+	fmt.Printf("Installed %s completion in %s\n", c.shell, c.path)
+	if c.rc != "" {
+		b, err := os.ReadFile(c.rc)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("xc: failed to install completion: %w", err)
+		}
+		if !containsLine(b, c.source) {
+			if err = os.MkdirAll(filepath.Dir(c.rc), 0o755); err != nil {
+				return fmt.Errorf("xc: failed to install completion: %w", err)
+			}
+			if len(b) > 0 && !bytes.HasSuffix(b, []byte("\n")) {
+				b = append(b, '\n')
+			}
+			if err = os.WriteFile(c.rc, append(b, c.source+"\n"...), 0o644); err != nil {
+				return fmt.Errorf("xc: failed to install completion: %w", err)
+			}
+			fmt.Printf("Added %s to %s\n", c.source, c.rc)
+		}
+	}
+	fmt.Println("Completion is available in new shells.")
+	return nil
+}
+
+func (c shellCompletion) uninstall() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("xc: failed to uninstall completion: %w", err)
+	}
+	if c.rc != "" {
+		b, err := os.ReadFile(c.rc)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("xc: failed to uninstall completion: %w", err)
+		}
+		if containsLine(b, c.source) {
+			var kept []string
+			for _, line := range strings.SplitAfter(string(b), "\n") {
+				if strings.TrimSpace(line) != c.source {
+					kept = append(kept, line)
+				}
+			}
+			if err = os.WriteFile(c.rc, []byte(strings.Join(kept, "")), 0o644); err != nil {
+				return fmt.Errorf("xc: failed to uninstall completion: %w", err)
+			}
+		}
+	}
+	fmt.Printf("Uninstalled %s completion\n", c.shell)
+	return nil
+}
+
+func containsLine(b []byte, line string) bool {
+	for _, l := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectShell(t *testing.T) {
+	tests := []struct {
+		name     string
+		shell    string
+		expected string
+	}{
+		{name: "given a supported SHELL, should return it", shell: "/usr/bin/zsh", expected: "zsh"},
+		{name: "given an unsupported SHELL, should return nothing", shell: "/bin/tcsh"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHELL", tt.shell)
+			if got := detectShell(); got != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompletionCommandUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"add"}, {"install", "zsh", "bash"}} {
+		if err := completionCommand(args); !errors.Is(err, errCompletionUsage) {
+			t.Errorf("expected usage for %v, got %v", args, err)
+		}
+	}
+	if err := completionCommand([]string{"install", "tcsh"}); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
+
+func TestCompletionInstallZsh(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	t.Setenv("ZDOTDIR", "")
+	rc := filepath.Join(home, ".zshrc")
+	if err := os.WriteFile(rc, []byte("export EDITOR=vi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := completionFor("zsh", "/usr/local/bin/xc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Installing twice must only source the completion once.
+	for i := 0; i < 2; i++ {
+		if err = c.install(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script, err := os.ReadFile(filepath.Join(home, "data", "xc", "completion.zsh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedScript := "autoload -U +X bashcompinit && bashcompinit\ncomplete -o nospace -C /usr/local/bin/xc xc\n"
+	if string(script) != expectedScript {
+		t.Fatalf("expected script %q, got %q", expectedScript, script)
+	}
+	b, err := os.ReadFile(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRC := "export EDITOR=vi\nsource " + filepath.Join(home, "data", "xc", "completion.zsh") + "\n"
+	if string(b) != expectedRC {
+		t.Fatalf("expected .zshrc %q, got %q", expectedRC, b)
+	}
+	if err = c.uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(c.path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the script to be removed, got %v", err)
+	}
+	if b, err = os.ReadFile(rc); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "export EDITOR=vi\n" {
+		t.Fatalf("expected the source line to be removed from .zshrc, got %q", b)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/metrics"
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+	"github.com/joerdav/xc/schedule"
+)
+
+var errCronUsage = errors.New("usage: xc cron")
+
+type cronJob struct {
+	task     string
+	schedule schedule.Schedule
+}
+
+type cron struct {
+	tasks   models.Tasks
+	dir     string
+	opts    []run.Option
+	jobs    []cronJob
+	logger  *log.Logger
+	metrics *metrics.Registry
+	// approvals holds the tasks waiting to be approved over HTTP, it is nil unless -metrics-addr is set.
+	approvals *run.Approvals
+	mu        sync.Mutex
+	running   map[string]bool
+	wg        sync.WaitGroup
+}
+
+// xc cron
+func cronCommand(ctx context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	if len(args) > 0 {
+		return errCronUsage
+	}
+	c := &cron{
+		tasks:   tasks,
+		dir:     dir,
+		opts:    runnerOptions(cfg),
+		logger:  log.New(os.Stderr, "xc cron: ", log.LstdFlags),
+		metrics: metrics.NewRegistry(),
+		running: map[string]bool{},
+	}
+	for _, t := range tasks {
+		if t.Schedule == "" {
+			continue
+		}
+		s, err := schedule.Parse(t.Schedule)
+		if err != nil {
+			return fmt.Errorf("task %s: %w", t.Name, err)
+		}
+		c.jobs = append(c.jobs, cronJob{task: t.Name, schedule: s})
+		c.logger.Printf("scheduled %q at %q", t.Name, t.Schedule)
+	}
+	if len(c.jobs) == 0 {
+		return errors.New("no tasks have a schedule")
+	}
+	// Validate up front rather than on the first tick.
+	if _, err := run.NewRunner(tasks, dir, c.opts...); err != nil {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	if cfg.metricsAddr != "" {
+		if !cfg.approve {
+			c.approvals = run.NewApprovals()
+			c.opts = append(c.opts, run.WithApprover(run.ApproverFunc(func(ctx context.Context, t models.Task) error {
+				c.logger.Printf("%q is waiting for approval at /approvals: %s", t.Name, t.Approval)
+				return c.approvals.Approve(ctx, t)
+			})))
+		}
+		stop, err := c.serveMetrics(cfg.metricsAddr)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+	return c.run(ctx)
+}
+
+// serveMetrics exposes the metrics of the cron at /metrics on addr, and the tasks waiting for approval at /approvals.
+func (c *cron) serveMetrics(addr string) (stop func(), err error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", c.metrics)
+	if c.approvals != nil {
+		mux.Handle("/approvals", c.approvals)
+		mux.Handle("/approvals/", c.approvals)
+	}
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve metrics: %w", err)
+	}
+	c.logger.Printf("serving metrics at http://%s/metrics", ln.Addr())
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.logger.Printf("metrics server stopped: %v", err)
+		}
+	}()
+	return func() { _ = srv.Close() }, nil
+}
+
+// run blocks until ctx is cancelled, starting jobs as they become due.
+func (c *cron) run(ctx context.Context) error {
+	defer c.wg.Wait()
+	for {
+		var next time.Time
+		now := time.Now()
+		for _, j := range c.jobs {
+			n := j.schedule.Next(now)
+			if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+				next = n
+			}
+		}
+		if next.IsZero() {
+			return errors.New("no schedule will run again")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+		for _, j := range c.jobs {
+			if j.schedule.Matches(next) {
+				c.start(ctx, j.task)
+			}
+		}
+	}
+}
+
+// start runs a task in the background, unless the previous run of the task is still going.
+func (c *cron) start(ctx context.Context, task string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running[task] {
+		c.logger.Printf("skipping %q: previous run is still running", task)
+		return
+	}
+	c.running[task] = true
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer func() {
+			c.mu.Lock()
+			delete(c.running, task)
+			c.mu.Unlock()
+		}()
+		c.logger.Printf("running %q", task)
+		c.metrics.Started(task)
+		start := time.Now()
+		err := c.runTask(ctx, task)
+		c.metrics.Finished(task, time.Since(start), err)
+		if err != nil {
+			c.logger.Printf("%q failed after %s: %v", task, time.Since(start).Round(time.Millisecond), err)
+			return
+		}
+		c.logger.Printf("%q finished after %s", task, time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+func (c *cron) runTask(ctx context.Context, task string) error {
+	opts, flush := withTracing(c.opts)
+	defer flush()
+	runner, err := run.NewRunner(c.tasks, c.dir, opts...)
+	if err != nil {
+		return err
+	}
+	return runner.Run(ctx, task, nil)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+var errEnvUsage = errors.New("usage: xc env [-diff] <task> [inputs...]")
+
+// xc env [-diff] <task> [inputs...]
+func envCommand(_ context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	fs := flag.NewFlagSet("env", flag.ContinueOnError)
+	diff := fs.Bool("diff", false, "only print the variables that differ from the environment of xc")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errEnvUsage
+	}
+	name := fs.Arg(0)
+	tasks, err := applyOverrides(tasks, name, cfg)
+	if err != nil {
+		return err
+	}
+	if _, ok := tasks.Get(name); !ok {
+		return errTaskNotFound(cfg, tasks, dir, name)
+	}
+	runner, err := run.NewRunner(tasks, dir, runnerOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	env, err := runner.Environment(name, fs.Args()[1:])
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if !*diff {
+		for _, e := range env {
+			fmt.Println(e)
+		}
+		return nil
+	}
+	printEnvDiff(os.Environ(), env)
+	return nil
+}
+
+// printEnvDiff prints the variables of env that are not in ambient prefixed with +,
+// and those with a different value as the ambient value prefixed with - followed by the new value.
+func printEnvDiff(ambient, env []string) {
+	values := map[string]string{}
+	for _, e := range ambient {
+		k, v, _ := strings.Cut(e, "=")
+		values[k] = v
+	}
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		old, ok := values[k]
+		switch {
+		case !ok:
+			fmt.Printf("+%s\n", e)
+		case old != v:
+			fmt.Printf("-%s=%s\n+%s\n", k, old, e)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joerdav/xc/events"
+	"github.com/joerdav/xc/run"
+)
+
+// runStream is a stream of the events of a run, which is told when the run has finished.
+type runStream interface {
+	run.Observer
+	RunFinished(taskName string, inputs []string, runID string, err error)
+}
+
+// eventStream returns the stream of events set by -events and -events-fd for the run of the task
+// named by the first of args, with the rest as its inputs, or nil if -events is not set.
+func eventStream(cfg config, args []string) (runStream, error) {
+	switch cfg.events {
+	case "", "ndjson", "bep":
+	default:
+		return nil, fmt.Errorf("xc: invalid events format %q should be ndjson or bep", cfg.events)
+	}
+	if cfg.events == "" {
+		return nil, nil
+	}
+	var w io.Writer
+	switch cfg.eventsFD {
+	case 1:
+		w = os.Stdout
+	case 2:
+		w = os.Stderr
+	default:
+		f := os.NewFile(uintptr(cfg.eventsFD), "events")
+		if f == nil {
+			return nil, fmt.Errorf("xc: file descriptor %d is not open", cfg.eventsFD)
+		}
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("xc: file descriptor %d is not open", cfg.eventsFD)
+		}
+		w = f
+	}
+	if cfg.events == "bep" {
+		return events.NewBEP(w, args[0], args[1:]), nil
+	}
+	return events.NewStream(w), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+var errExecUsage = errors.New("usage: xc exec -- <command> [args...]")
+
+// xc exec -- <command> [args...]
+func execCommand(ctx context.Context, cfg config, _ models.Tasks, dir string, args []string) error {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return errExecUsage
+	}
+	if err := checkEnvOverrides(cfg.envOverrides); err != nil {
+		return err
+	}
+	// The command runs as the script of a task, so it gets the same environment as any other task.
+	task := models.Task{
+		Name:   "exec",
+		Script: "\"$@\"\n",
+		Dir:    cfg.dirOverride,
+		Env:    cfg.envOverrides,
+	}
+	opts, flush := withTracing(runnerOptions(cfg))
+	defer flush()
+	runner, err := run.NewRunner(models.Tasks{task}, dir, opts...)
+	if err != nil {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	if err = runner.Run(ctx, task.Name, args); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	return nil
+}
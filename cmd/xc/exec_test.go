@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config
+		args         []string
+		expectedFile string
+		expectedErr  bool
+	}{
+		{
+			name:        "given no command, should return the usage",
+			args:        []string{"--"},
+			expectedErr: true,
+		},
+		{
+			name:         "given a command, should run it in the directory of the task file",
+			args:         []string{"--", "touch", "marker"},
+			expectedFile: "marker",
+		},
+		{
+			name:         "given -dir, should run the command in the directory",
+			cfg:          config{dirOverride: "sub"},
+			args:         []string{"touch", "marker"},
+			expectedFile: filepath.Join("sub", "marker"),
+		},
+		{
+			name:         "given -env, should run the command with the env",
+			cfg:          config{envOverrides: stringsFlag{"NAME=from-env"}},
+			args:         []string{"--", "sh", "-c", `touch "$NAME"`},
+			expectedFile: "from-env",
+		},
+		{
+			name:        "given an -env that is not KEY=VALUE, should return an error",
+			cfg:         config{envOverrides: stringsFlag{"NAME"}},
+			args:        []string{"--", "touch", "marker"},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			tt.cfg.jobs = 1
+			err := execCommand(context.Background(), tt.cfg, nil, dir, tt.args)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedFile == "" {
+				return
+			}
+			if _, err := os.Stat(filepath.Join(dir, tt.expectedFile)); err != nil {
+				t.Fatalf("expected the command to create %s: %v", tt.expectedFile, err)
+			}
+		})
+	}
+}
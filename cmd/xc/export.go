@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/graph"
+	"github.com/joerdav/xc/models"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// exporters are the formats of xc export.
+var exporters = map[string]func(w io.Writer, cfg config, tasks models.Tasks, dir string, args []string) error{
+	"mermaid": exportMermaid,
+	"desktop": exportDesktop,
+	"direnv":  exportDirenv,
+}
+
+func exportUsage() error {
+	formats := make([]string, 0, len(exporters))
+	for f := range exporters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return fmt.Errorf("usage: xc export <%s>", strings.Join(formats, "|"))
+}
+
+// xc export <format>
+func exportCommand(_ context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	if len(args) == 0 {
+		return exportUsage()
+	}
+	export, ok := exporters[args[0]]
+	if !ok {
+		return exportUsage()
+	}
+	return export(os.Stdout, cfg, tasks, dir, args[1:])
+}
+
+var errExportMermaidUsage = errors.New("usage: xc export mermaid [-raw]")
+
+// exportMermaid writes a flowchart of every task in a mermaid code block.
+func exportMermaid(w io.Writer, _ config, tasks models.Tasks, _ string, args []string) error {
+	fs := flag.NewFlagSet("export mermaid", flag.ContinueOnError)
+	raw := fs.Bool("raw", false, "omit the surrounding markdown code block")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errExportMermaidUsage
+	}
+	g, err := graph.New(tasks, "")
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if !*raw {
+		fmt.Fprintln(w, "```mermaid")
+	}
+	if err := g.Mermaid(w); err != nil {
+		return err
+	}
+	if !*raw {
+		fmt.Fprintln(w, "```")
+	}
+	return nil
+}
+
+var errExportDesktopUsage = errors.New("usage: xc export desktop [-o dir] [-manifest] [task...]")
+
+// launcher is an entry of the manifest written by xc export desktop -manifest.
+type launcher struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Command     []string `json:"command"`
+	Dir         string   `json:"dir"`
+}
+
+// exportDesktop writes a desktop entry for each documented task, so it can be run from an application launcher,
+// or a manifest of the commands that run them for launchers that do not read desktop entries.
+// Without task names every task with a description is exported, except services and tasks that take inputs.
+func exportDesktop(w io.Writer, cfg config, tasks models.Tasks, dir string, args []string) error {
+	fs := flag.NewFlagSet("export desktop", flag.ContinueOnError)
+	out := fs.String("o", "", "the directory to write desktop entries to")
+	manifest := fs.Bool("manifest", false, "print a JSON manifest of launchers instead of writing desktop entries")
+	if err := fs.Parse(args); err != nil {
+		return errExportDesktopUsage
+	}
+	if cfg.filename == stdinFile {
+		return errors.New("xc: tasks read from stdin cannot be exported as launchers")
+	}
+	selected, err := launcherTasks(tasks, fs.Args())
+	if err != nil {
+		return err
+	}
+	bin, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	// The task file is found from the working directory unless it was given explicitly.
+	base := []string{bin}
+	if cfg.filename != "" {
+		file, err := filepath.Abs(cfg.filename)
+		if err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+		base = append(base, "-file", file)
+	}
+	if cfg.heading != "" {
+		base = append(base, "-heading", cfg.heading)
+	}
+	project := filepath.Base(dir)
+	launchers := make([]launcher, 0, len(selected))
+	for _, t := range selected {
+		launchers = append(launchers, launcher{
+			Name:        project + ": " + t.Name,
+			Description: strings.Join(t.Description, " "),
+			Command:     append(append([]string(nil), base...), t.Name),
+			Dir:         dir,
+		})
+	}
+	if *manifest {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(launchers)
+	}
+	if *out == "" {
+		if *out, err = applicationsDir(); err != nil {
+			return err
+		}
+	}
+	if err = os.MkdirAll(*out, 0o755); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	for i, l := range launchers {
+		path := filepath.Join(*out, desktopFileName(project, selected[i].Name))
+		if err = os.WriteFile(path, []byte(l.desktopEntry()), 0o644); err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+		fmt.Fprintln(w, path)
+	}
+	return nil
+}
+
+// launcherTasks returns the tasks named, or every documented task that can run without inputs if none are.
+func launcherTasks(tasks models.Tasks, names []string) (models.Tasks, error) {
+	if len(names) > 0 {
+		selected := make(models.Tasks, 0, len(names))
+		for _, name := range names {
+			t, ok := tasks.Get(name)
+			if !ok {
+				return nil, fmt.Errorf("xc: task %s not found", name)
+			}
+			selected = append(selected, t)
+		}
+		return selected, nil
+	}
+	var selected models.Tasks
+	for _, t := range tasks {
+		if len(t.Description) > 0 && len(t.Inputs) == 0 && !t.Service {
+			selected = append(selected, t)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("xc: no tasks have a description and run without inputs, name the tasks to export")
+	}
+	return selected, nil
+}
+
+// applicationsDir is where desktop entries of the current user are found, see the XDG base directory specification.
+func applicationsDir() (string, error) {
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return filepath.Join(d, "applications"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("xc: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "applications"), nil
+}
+
+// desktopFileName is a desktop file ID unique to the task, made of characters valid in one.
+func desktopFileName(project, task string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, "xc-"+project+"-"+task)
+	return id + ".desktop"
+}
+
+// desktopEntry returns a desktop entry that runs the task in a terminal.
+func (l launcher) desktopEntry() string {
+	args := make([]string, len(l.Command))
+	for i, a := range l.Command {
+		args[i] = desktopExecArg(a)
+	}
+	var b strings.Builder
+	fmt.Fprintln(&b, "[Desktop Entry]")
+	fmt.Fprintln(&b, "Type=Application")
+	fmt.Fprintln(&b, "Name="+desktopValue(l.Name))
+	if l.Description != "" {
+		fmt.Fprintln(&b, "Comment="+desktopValue(l.Description))
+	}
+	fmt.Fprintln(&b, "Exec="+desktopValue(strings.Join(args, " ")))
+	fmt.Fprintln(&b, "Path="+desktopValue(l.Dir))
+	fmt.Fprintln(&b, "Terminal=true")
+	fmt.Fprintln(&b, "Categories=Development;")
+	return b.String()
+}
+
+// desktopExecArg quotes an argument of the Exec key if it contains reserved characters,
+// and escapes the % of field codes.
+func desktopExecArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`).Replace(arg) + `"`
+}
+
+// desktopValue escapes a string value of a desktop entry key.
+func desktopValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(s)
+}
+
+var errExportDirenvUsage = errors.New("usage: xc export direnv [-o dir] [-prefix prefix] [task...]")
+
+// direnvHeader starts each wrapper written by xc export direnv, so that wrappers of removed tasks can be told apart
+// from other files in the same directory.
+const direnvHeader = "#!/bin/sh\n# Generated by xc export direnv, do not edit.\n"
+
+// exportDirenv writes a wrapper for each task, a script that runs it with xc from anywhere in the project,
+// and prints the lines of an .envrc that add the wrappers to PATH and load the env files of the task file.
+// It is meant to be run by direnv from an .envrc containing eval "$(xc export direnv)",
+// so the wrappers follow the tasks.
+// Without task names every task is exported, except those whose wrapper would hide a command on PATH.
+func exportDirenv(w io.Writer, cfg config, tasks models.Tasks, dir string, args []string) error {
+	fs := flag.NewFlagSet("export direnv", flag.ContinueOnError)
+	out := fs.String("o", "", "the directory to write wrappers to, .xc/bin in the directory of the task file by default")
+	prefix := fs.String("prefix", "", "prefix the names of the wrappers, such as x-, so they do not hide other commands")
+	if err := fs.Parse(args); err != nil {
+		return errExportDirenvUsage
+	}
+	if cfg.filename == stdinFile {
+		return errors.New("xc: tasks read from stdin cannot be exported to direnv")
+	}
+	selected := tasks
+	if fs.NArg() > 0 {
+		selected = make(models.Tasks, 0, fs.NArg())
+		for _, name := range fs.Args() {
+			t, ok := tasks.Get(name)
+			if !ok {
+				return fmt.Errorf("xc: task %s not found", name)
+			}
+			selected = append(selected, t)
+		}
+	}
+	bin, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	// The task file is always given, so that a wrapper run in a directory with its own README.md runs the same task.
+	file := filepath.Join(dir, "README.md")
+	if cfg.filename != "" {
+		if file, err = filepath.Abs(cfg.filename); err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+	}
+	base := []string{bin, "-file", file}
+	if cfg.heading != "" {
+		base = append(base, "-heading", cfg.heading)
+	}
+	binDir := *out
+	if binDir == "" {
+		binDir = filepath.Join(dir, ".xc", "bin")
+	}
+	if binDir, err = filepath.Abs(binDir); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if err = os.MkdirAll(binDir, 0o755); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if err = removeWrappers(binDir); err != nil {
+		return err
+	}
+	for _, t := range selected {
+		name := *prefix + commandName(t.Name)
+		// Named tasks are exported even if their wrapper hides another command, as they were asked for.
+		if path, err := exec.LookPath(name); err == nil && fs.NArg() == 0 {
+			fmt.Fprintf(os.Stderr, "xc: warning: task %s is not exported, as %s would hide %s, use -prefix\n",
+				t.Name, name, path)
+			continue
+		}
+		script, err := direnvWrapper(append(base[:len(base):len(base)], t.Name))
+		if err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+		//nolint:gosec // wrappers must be executable
+		if err = os.WriteFile(filepath.Join(binDir, name), []byte(script), 0o755); err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+	}
+	writeEnvrc(w, binDir, file, cfg.file.EnvFiles)
+	return nil
+}
+
+// commandName is the name of the wrapper of a task, with characters that are awkward in the name of a command replaced.
+func commandName(task string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:+", r) {
+			return r
+		}
+		return '-'
+	}, task)
+}
+
+// direnvWrapper returns a script that runs command, passing on its arguments as the inputs of the task.
+func direnvWrapper(command []string) (string, error) {
+	words := make([]string, len(command))
+	for i, c := range command {
+		q, err := syntax.Quote(c, syntax.LangPOSIX)
+		if err != nil {
+			return "", err
+		}
+		words[i] = q
+	}
+	return direnvHeader + "exec " + strings.Join(words, " ") + " \"$@\"\n", nil
+}
+
+// removeWrappers removes the wrappers written to dir by a previous xc export direnv, so that removed tasks go away.
+func removeWrappers(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if b, err := os.ReadFile(path); err != nil || !strings.HasPrefix(string(b), direnvHeader) {
+			continue
+		}
+		if err = os.Remove(path); err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeEnvrc writes the direnv stdlib calls that add the wrappers in binDir to PATH,
+// load the env files of the task file, and reload the environment when the task file or env files change.
+func writeEnvrc(w io.Writer, binDir, file string, envFiles []string) {
+	quote := func(s string) string {
+		q, _ := syntax.Quote(s, syntax.LangBash)
+		return q
+	}
+	fmt.Fprintf(w, "PATH_add %s\n", quote(binDir))
+	fmt.Fprintf(w, "watch_file %s\n", quote(file))
+	for _, f := range envFiles {
+		if !filepath.IsAbs(f) {
+			f, _ = filepath.Abs(f)
+		}
+		fmt.Fprintf(w, "dotenv_if_exists %s\n", quote(f))
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestExportMermaid(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build"},
+		{Name: "test", DependsOn: []string{"build"}},
+	}
+	graph := "flowchart TD\n" +
+		"    task_build[\"build\"]\n" +
+		"    task_test[\"test\"]\n" +
+		"    task_test --> task_build\n"
+	tests := []struct {
+		name        string
+		args        []string
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name:     "given no flags, should write the flowchart in a code block",
+			expected: "```mermaid\n" + graph + "```\n",
+		},
+		{
+			name:     "given -raw, should write the flowchart only",
+			args:     []string{"-raw"},
+			expected: graph,
+		},
+		{
+			name:        "given an argument, should return the usage",
+			args:        []string{"build"},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := exportMermaid(&b, config{}, tasks, "", tt.args)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if b.String() != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, b.String())
+			}
+		})
+	}
+}
+
+func TestLauncherTasks(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Description: []string{"Builds the app."}},
+		{Name: "deploy", Description: []string{"Deploys the app."}, Inputs: []string{"ENVIRONMENT"}},
+		{Name: "db", Description: []string{"Runs the database."}, Service: true},
+		{Name: "lint"},
+	}
+	tests := []struct {
+		name        string
+		tasks       models.Tasks
+		names       []string
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:     "given no names, should select documented tasks without inputs that are not services",
+			tasks:    tasks,
+			expected: []string{"build"},
+		},
+		{
+			name:     "given names, should select the named tasks",
+			tasks:    tasks,
+			names:    []string{"lint", "deploy"},
+			expected: []string{"lint", "deploy"},
+		},
+		{
+			name:        "given an unknown name, should return an error",
+			tasks:       tasks,
+			names:       []string{"unknown"},
+			expectedErr: true,
+		},
+		{
+			name:        "given no names and no documented tasks, should return an error",
+			tasks:       models.Tasks{{Name: "lint"}},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := launcherTasks(tt.tasks, tt.names)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			var got []string
+			for _, task := range selected {
+				got = append(got, task.Name)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDesktopEntry(t *testing.T) {
+	l := launcher{
+		Name:        "my app: build",
+		Description: "Builds the app\nfor 100% of platforms.",
+		Command:     []string{"/opt/my apps/xc", "-file", "/src/README.md", "build"},
+		Dir:         "/src",
+	}
+	expected := "[Desktop Entry]\n" +
+		"Type=Application\n" +
+		"Name=my app: build\n" +
+		`Comment=Builds the app\nfor 100% of platforms.` + "\n" +
+		`Exec="/opt/my apps/xc" -file /src/README.md build` + "\n" +
+		"Path=/src\n" +
+		"Terminal=true\n" +
+		"Categories=Development;\n"
+	if got := l.desktopEntry(); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if got := desktopExecArg(`50%$"`); got != `"50%%\$\""` {
+		t.Fatalf("expected the argument to be quoted and escaped, got %q", got)
+	}
+	if got := desktopFileName("my app", "build:all"); got != "xc-my_app-build_all.desktop" {
+		t.Fatalf("expected a valid desktop file ID, got %q", got)
+	}
+}
+
+func TestExportDirenv(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A wrapper of a removed task is removed, other files are kept.
+	if err := os.WriteFile(filepath.Join(bin, "old"), []byte(direnvHeader+"exec xc old\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "tool"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{{Name: "build all"}, {Name: "sh"}}
+	cfg := config{file: models.FileConfig{EnvFiles: []string{filepath.Join(dir, ".env")}}}
+	var b bytes.Buffer
+	if err := exportDirenv(&b, cfg, tasks, dir, []string{"-o", bin, "-prefix", "x-"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if expected := []string{"tool", "x-build-all", "x-sh"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected wrappers %v, got %v", expected, names)
+	}
+	script, err := os.ReadFile(filepath.Join(bin, "x-build-all"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedScript := direnvHeader + "exec " + exe + " -file " + filepath.Join(dir, "README.md") + " 'build all' \"$@\"\n"
+	if string(script) != expectedScript {
+		t.Fatalf("expected wrapper %q, got %q", expectedScript, script)
+	}
+	expectedEnvrc := "PATH_add " + bin + "\n" +
+		"watch_file " + filepath.Join(dir, "README.md") + "\n" +
+		"dotenv_if_exists " + filepath.Join(dir, ".env") + "\n"
+	if b.String() != expectedEnvrc {
+		t.Fatalf("expected envrc %q, got %q", expectedEnvrc, b.String())
+	}
+}
+
+func TestExportDirenvSkipsCommandsOnPath(t *testing.T) {
+	dir := t.TempDir()
+	tasks := models.Tasks{{Name: "build"}, {Name: "sh"}}
+	if err := exportDirenv(io.Discard, config{}, tasks, dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".xc", "bin", "build")); err != nil {
+		t.Fatalf("expected a wrapper for build: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".xc", "bin", "sh")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected no wrapper hiding sh, got %v", err)
+	}
+}
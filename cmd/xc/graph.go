@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joerdav/xc/graph"
+	"github.com/joerdav/xc/models"
+)
+
+var errGraphUsage = errors.New("usage: xc graph [-format text|dot|mermaid|svg] [task]")
+
+// xc graph [task]
+func graphCommand(_ context.Context, _ config, tasks models.Tasks, _ string, args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := fs.String("format", string(graph.FormatText), "output format: text, dot, mermaid or svg")
+	if err := fs.Parse(args); err != nil {
+		return errGraphUsage
+	}
+	if fs.NArg() > 1 {
+		return errGraphUsage
+	}
+	g, err := graph.New(tasks, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	return g.Render(os.Stdout, graph.Format(*format))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/terminal"
+)
+
+// printHelp writes the tasks with their inputs and a short description, followed by the usage of xc.
+func printHelp(w io.Writer, tasks models.Tasks) {
+	if len(tasks) > 0 {
+		fmt.Fprintln(w, "Tasks:")
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		for _, t := range tasks {
+			fmt.Fprintf(tw, "  %s\t%s\n", taskUsage(t), shortDescription(t))
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+	fmt.Fprint(w, usage)
+}
+
+// helpCommand writes how a task is run followed by its description, the values of its inputs, owner, docs,
+// required tasks, steps, script, stdin and deferred script.
+func helpCommand(tasks models.Tasks, name string) error {
+	t, ok := tasks.Get(name)
+	if !ok {
+		return fmt.Errorf("xc: task %q not found", name)
+	}
+	fmt.Printf("Usage: xc %s\n", taskUsage(t))
+	if len(t.Description) > 0 {
+		fmt.Println()
+		for _, d := range t.Description {
+			fmt.Printf("  %s\n", renderDescription(d))
+		}
+	}
+	if len(t.InputValues) > 0 || len(t.InputSources) > 0 {
+		fmt.Printf("\nInputs:\n")
+		for _, in := range t.Inputs {
+			var about []string
+			if values := t.InputValues[in]; len(values) > 0 {
+				about = append(about, strings.Join(values, ", "))
+			}
+			if sources := t.InputSources[in]; len(sources) > 0 {
+				about = append(about, "required, from "+strings.Join(sources, " or "))
+			}
+			if len(about) > 0 {
+				fmt.Printf("  %s: %s\n", in, strings.Join(about, "; "))
+			}
+		}
+	}
+	if t.Owner != "" {
+		fmt.Printf("\nOwner: %s\n", t.Owner)
+	}
+	if t.Docs != "" {
+		fmt.Printf("\nDocs: %s\n", renderDescription(t.Docs))
+	}
+	if t.Approval != "" {
+		fmt.Printf("\nApproval: %s\n", t.Approval)
+	}
+	if len(t.DependsOn) > 0 {
+		fmt.Printf("\nRequires: %s\n", strings.Join(t.DependsOn, ", "))
+	}
+	if len(t.Steps) > 0 {
+		fmt.Printf("\nSteps: %s\n", strings.Join(t.Steps, ", "))
+	}
+	if t.Script != "" {
+		script := t.Script
+		if terminal.Color(os.Stdout) {
+			script = terminal.Highlight(script, t.Language)
+		}
+		fmt.Printf("\nScript:\n")
+		for _, line := range strings.Split(strings.TrimSuffix(script, "\n"), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	if t.Stdin != "" {
+		fmt.Printf("\nStdin:\n")
+		for _, line := range strings.Split(strings.TrimSuffix(t.Stdin, "\n"), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	if t.Deferred != "" {
+		deferred := t.Deferred
+		if terminal.Color(os.Stdout) {
+			deferred = terminal.Highlight(deferred, t.DeferredLanguage)
+		}
+		fmt.Printf("\nDeferred:\n")
+		for _, line := range strings.Split(strings.TrimSuffix(deferred, "\n"), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	return nil
+}
+
+// renderDescription returns a line of the markdown description of a task as it should be displayed on stdout.
+func renderDescription(line string) string {
+	return terminal.Markdown(line, terminal.Color(os.Stdout))
+}
+
+// taskUsage returns how a task is run, such as `deploy <ENVIRONMENT>`.
+func taskUsage(t models.Task) string {
+	s := t.Name
+	for _, i := range t.Inputs {
+		s += " <" + i + ">"
+	}
+	return s
+}
+
+// shortDescription returns the first line of the description of a task,
+// or what it runs if it has no description, after its icon if it has one.
+func shortDescription(t models.Task) string {
+	var d string
+	switch {
+	case len(t.Description) > 0:
+		d = renderDescription(t.Description[0])
+	case len(t.Steps) > 0:
+		d = "Steps: " + strings.Join(t.Steps, ", ")
+	case len(t.DependsOn) > 0:
+		d = "Requires: " + strings.Join(t.DependsOn, ", ")
+	default:
+		d, _, _ = strings.Cut(strings.TrimSpace(t.Script), "\n")
+	}
+	if t.Icon != "" && !terminal.Accessible() {
+		return t.Icon + " " + d
+	}
+	return d
+}
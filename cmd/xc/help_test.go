@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestPrintHelp(t *testing.T) {
+	tests := []struct {
+		name     string
+		tasks    models.Tasks
+		expected string
+	}{
+		{
+			name: "given no tasks, should write the usage only",
+		},
+		{
+			name: "given tasks, should list them with their inputs and short description before the usage",
+			tasks: models.Tasks{
+				{Name: "deploy", Inputs: []string{"ENVIRONMENT"}, Description: []string{"Deploys the app.", "Needs credentials."}},
+				{Name: "ci", Steps: []string{"lint", "test"}},
+				{Name: "setup", DependsOn: []string{"deps"}},
+				{Name: "lint", Script: "golangci-lint run\necho done\n"},
+			},
+			expected: "Tasks:\n" +
+				"  deploy <ENVIRONMENT>   Deploys the app.\n" +
+				"  ci                     Steps: lint, test\n" +
+				"  setup                  Requires: deps\n" +
+				"  lint                   golangci-lint run\n" +
+				"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			printHelp(&b, tt.tasks)
+			got, ok := strings.CutSuffix(b.String(), usage)
+			if !ok {
+				t.Fatalf("expected the usage at the end, got %q", b.String())
+			}
+			if got != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
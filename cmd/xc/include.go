@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/joerdav/xc/index"
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/pkg"
+)
+
+// includeTasks adds the tasks of the files included by the task file in dir to tasks,
+// following the includes of included files. root is the directory tasks are run from.
+//
+// The directories of included tasks are relative to the file they are defined in.
+// If files is not nil the path of the file each included task is defined in is added to it.
+// Included files are parsed using ix, which may be nil.
+// Tasks with the same name as another task are added, the duplicates policy is applied by the caller.
+func includeTasks(
+	ix *index.Index,
+	tasks models.Tasks,
+	root, dir string,
+	includes []string,
+	seen map[string]bool,
+	files map[string]string,
+) (models.Tasks, error) {
+	for _, include := range includes {
+		if pkg.IsRemote(include) {
+			return nil, fmt.Errorf("xc error including %s: only the task file can include files from a URL", include)
+		}
+		path := include
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, include)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("xc error including %s: %w", include, err)
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		included, fc, err := parseFile(ix, path, "")
+		if err != nil {
+			return nil, fmt.Errorf("xc error including %s: %w", include, err)
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("xc error including %s: %w", include, err)
+		}
+		for _, t := range included {
+			t.Dir = includedDir(rel, t.Dir)
+			tasks = append(tasks, t)
+			if files != nil {
+				files[t.Name] = path
+			}
+		}
+		if tasks, err = includeTasks(ix, tasks, root, filepath.Dir(path), fc.Includes, seen, files); err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
+// includedDir returns the directory of a task in a file in rel, relative to the directory tasks are run from.
+func includedDir(rel, dir string) string {
+	switch {
+	case rel == ".":
+		return dir
+	case dir == "":
+		return rel
+	case filepath.IsAbs(dir), strings.HasPrefix(dir, "$"), strings.HasPrefix(dir, "~"):
+		return dir
+	}
+	return filepath.Join(rel, dir)
+}
+
+// splitIncludes returns the paths and the URLs in includes, files at a URL are installed by xc pkg install.
+func splitIncludes(includes []string) (local, remote []string) {
+	for _, include := range includes {
+		if pkg.IsRemote(include) {
+			remote = append(remote, include)
+		} else {
+			local = append(local, include)
+		}
+	}
+	return local, remote
+}
+
+// lockedTasks adds the tasks of the files the task file in dir includes from a URL, then those of the packages it uses,
+// installed by xc pkg install at the digests in its lock file and signed by its signers, to tasks.
+// Their tasks run from dir, as if they were defined in the task file.
+func lockedTasks(
+	ix *index.Index,
+	tasks models.Tasks,
+	dir string,
+	fc models.FileConfig,
+	seen map[string]bool,
+	files map[string]string,
+) (models.Tasks, error) {
+	_, remote := splitIncludes(fc.Includes)
+	if len(remote) == 0 && len(fc.Uses) == 0 {
+		return tasks, nil
+	}
+	lock, err := pkg.ReadLock(dir)
+	if err != nil {
+		return nil, fmt.Errorf("xc: %w", err)
+	}
+	signers, err := pkg.ReadSigners(dir, fc.Signers)
+	if err != nil {
+		return nil, fmt.Errorf("xc: %w", err)
+	}
+	var installed []pkg.Locked
+	for _, include := range remote {
+		l, ok := lock.Include(include)
+		if !ok || !l.Installed(dir) {
+			return nil, fmt.Errorf("xc: %s is not installed, run xc pkg install", include)
+		}
+		installed = append(installed, l)
+	}
+	for _, use := range fc.Uses {
+		l, ok := lock.Get(use)
+		if !ok || !l.Installed(dir) {
+			return nil, fmt.Errorf("xc: package %s is not installed, run xc pkg install", use)
+		}
+		installed = append(installed, l)
+	}
+	// Signers may have been added since they were installed, so they are verified before their tasks can be run,
+	// as are the installed files, which may have been changed since.
+	for _, l := range installed {
+		if err = l.Intact(dir); err != nil {
+			return nil, fmt.Errorf("xc: %w, run xc pkg install", err)
+		}
+		if !l.Verified(signers) {
+			return nil, fmt.Errorf("xc: %s has not been verified by a signer, run xc pkg install", l.Ref)
+		}
+	}
+	for _, l := range installed {
+		if tasks, err = includeTasks(ix, tasks, l.Dir(dir), l.Dir(dir), []string{l.File}, seen, files); err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
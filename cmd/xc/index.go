@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/joerdav/xc/index"
+	"github.com/joerdav/xc/models"
+)
+
+var errIndexUsage = errors.New("usage: xc index [-rebuild]")
+
+// xc index [-rebuild]
+func indexCommand(_ context.Context, cfg config, _ models.Tasks, _ string, args []string) error {
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	rebuild := fs.Bool("rebuild", false, "discard the index and parse every task file again")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errIndexUsage
+	}
+	if cfg.filename == stdinFile {
+		return errors.New("xc: tasks read from stdin cannot be indexed")
+	}
+	path, err := findTaskFile(cfg.filename)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	// Once the index exists it is used and updated each time the task file is parsed.
+	if ix := index.Open(dir, getVersion()); ix == nil || *rebuild {
+		if err = index.New(dir, getVersion()).Save(); err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+	}
+	tasks, _, _, err := tryParse(path, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Indexed %d tasks in %s\n", len(tasks), index.Path(dir))
+	return nil
+}
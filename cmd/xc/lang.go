@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+// languages returns the languages task descriptions are shown in, most preferred first:
+// the -lang flag, or the locale of the environment in the order gettext reads it.
+func languages(cfg config) []string {
+	if cfg.lang != "" {
+		return []string{cfg.lang}
+	}
+	var langs []string
+	for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l := os.Getenv(v); l != "" {
+			langs = append(langs, l)
+			break
+		}
+	}
+	// LANGUAGE is a list of preferred languages, which gettext ignores when the locale is C.
+	if len(langs) > 0 && models.NormalizeLanguage(langs[0]) != "" {
+		if list := os.Getenv("LANGUAGE"); list != "" {
+			langs = append(strings.Split(list, ":"), langs...)
+		}
+	}
+	return langs
+}
+
+// translatedLanguages returns the languages the descriptions of tasks have been translated into, sorted.
+func translatedLanguages(tasks models.Tasks) []string {
+	seen := map[string]bool{}
+	var langs []string
+	for _, t := range tasks {
+		for lang := range t.Translations {
+			if !seen[lang] {
+				seen[lang] = true
+				langs = append(langs, lang)
+			}
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
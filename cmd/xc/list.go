@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+var errListUsage = errors.New("usage: xc list [-s] [-long] [-json] [-sort name|file|duration] [-filter <pattern>]")
+
+// listFlags adds the flags that change how tasks are listed to fs, defaulting to the values already in cfg.
+func listFlags(fs *flag.FlagSet, cfg *config) {
+	fs.BoolVar(&cfg.short, "short", cfg.short, "list task names in a short format")
+	fs.BoolVar(&cfg.short, "s", cfg.short, "list task names in a short format")
+
+	fs.BoolVar(&cfg.long, "long", cfg.long, "list tasks with their inputs, requires, steps and last duration")
+	fs.BoolVar(&cfg.long, "l", cfg.long, "list tasks with their inputs, requires, steps and last duration")
+	fs.BoolVar(&cfg.json, "json", cfg.json, "list tasks as JSON")
+	fs.StringVar(&cfg.sort, "sort", cfg.sort, "sort listed tasks by name, file or duration")
+	fs.StringVar(&cfg.filter, "filter", cfg.filter, "only list tasks whose names match a glob pattern")
+}
+
+// xc list [flags]
+func listCommand(_ context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	listFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errListUsage
+	}
+	return listTasks(cfg, tasks, dir)
+}
+
+// listTasks prints the tasks matching -filter, in the order set by -sort, in the short, long, JSON or default format.
+func listTasks(cfg config, tasks models.Tasks, dir string) error {
+	tasks, err := filterTasks(tasks, cfg.filter)
+	if err != nil {
+		return err
+	}
+	durations := run.TaskDurations(dir)
+	if err = sortTasks(tasks, cfg.sort, durations); err != nil {
+		return err
+	}
+	if cfg.long {
+		printLong(tasks, durations)
+		printProfiles(cfg.file)
+		return nil
+	}
+	if cfg.json {
+		return printJSON(tasks)
+	}
+	printTasks(tasks, cfg.short)
+	if !cfg.short {
+		printProfiles(cfg.file)
+	}
+	return nil
+}
+
+// filterTasks returns the tasks whose names match pattern case insensitively, such as deploy-*.
+func filterTasks(tasks models.Tasks, pattern string) (models.Tasks, error) {
+	if pattern == "" {
+		return tasks, nil
+	}
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("xc: invalid filter %q: %w", pattern, err)
+	}
+	var filtered models.Tasks
+	for _, t := range tasks {
+		if ok, _ := path.Match(pattern, strings.ToLower(t.Name)); ok {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered, nil
+}
+
+// sortTasks sorts tasks by name, by the order they appear in the file,
+// or by how long their scripts took the last time they ran, longest first.
+func sortTasks(tasks models.Tasks, by string, durations map[string]time.Duration) error {
+	switch by {
+	case "", "file":
+	case "name":
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return strings.ToLower(tasks[i].Name) < strings.ToLower(tasks[j].Name)
+		})
+	case "duration":
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return durations[tasks[i].Name] > durations[tasks[j].Name]
+		})
+	default:
+		return fmt.Errorf("xc: invalid sort %q should be name, file or duration", by)
+	}
+	return nil
+}
+
+func printLong(tasks models.Tasks, durations map[string]time.Duration) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tINPUTS\tREQUIRES\tSTEPS\tLAST RUN\tDESCRIPTION")
+	for _, t := range tasks {
+		last := "-"
+		if d, ok := durations[t.Name]; ok {
+			last = d.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.Name, column(t.Inputs), column(t.DependsOn), column(t.Steps), last, shortDescription(t))
+	}
+	tw.Flush()
+}
+
+// listedTask is a task in the output of xc list -json.
+type listedTask struct {
+	Name         string              `json:"name"`
+	Line         int                 `json:"line"`
+	Description  []string            `json:"description,omitempty"`
+	Inputs       []string            `json:"inputs,omitempty"`
+	InputValues  map[string][]string `json:"inputValues,omitempty"`
+	InputSources map[string][]string `json:"inputSources,omitempty"`
+	Requires     []string            `json:"requires,omitempty"`
+	Steps        []string            `json:"steps,omitempty"`
+	Owner        string              `json:"owner,omitempty"`
+	Docs         string              `json:"docs,omitempty"`
+	Approval     string              `json:"approval,omitempty"`
+	Icon         string              `json:"icon,omitempty"`
+	Color        string              `json:"color,omitempty"`
+	Metadata     map[string]string   `json:"metadata,omitempty"`
+}
+
+func printJSON(tasks models.Tasks) error {
+	listed := make([]listedTask, 0, len(tasks))
+	for _, t := range tasks {
+		listed = append(listed, listedTask{
+			Name:         t.Name,
+			Line:         t.Line,
+			Description:  t.Description,
+			Inputs:       t.Inputs,
+			InputValues:  t.InputValues,
+			InputSources: t.InputSources,
+			Requires:     t.DependsOn,
+			Steps:        t.Steps,
+			Owner:        t.Owner,
+			Docs:         t.Docs,
+			Approval:     t.Approval,
+			Icon:         t.Icon,
+			Color:        t.Color,
+			Metadata:     t.Metadata,
+		})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(listed)
+}
+
+func column(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
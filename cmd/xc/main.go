@@ -6,17 +6,23 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime/debug"
+	"strconv"
 	"strings"
 
+	"github.com/joerdav/xc/ci"
+	"github.com/joerdav/xc/index"
 	"github.com/joerdav/xc/models"
 	"github.com/joerdav/xc/parser"
 	"github.com/joerdav/xc/run"
+	"github.com/joerdav/xc/terminal"
+	"github.com/joerdav/xc/tracing"
 	"github.com/posener/complete/v2"
 	"github.com/posener/complete/v2/install"
 	"github.com/posener/complete/v2/predict"
@@ -30,11 +36,42 @@ var ErrNoMarkdownFile = errors.New("no xc compatible markdown file found")
 
 type config struct {
 	version, help, short, display, complete, uncomplete bool
-	filename, heading                                   string
+	list, long, json                                    bool
+	keepTmp, noExpand, dryRun, resume, noNetwork        bool
+	noSandbox, noColor, submodules, worktrees           bool
+	detach, bell, summary, noGitignore, noDeps, approve bool
+	accessible, keepLastRun, explain                    bool
+	filename, heading, metricsAddr, changedSince        string
+	resultFile, sort, filter, events, duplicates        string
+	dirOverride, runOverride, profile, traceOut, record string
+	cache, cacheMode, lang, only, from, shard           string
+	envOverrides, reports                               stringsFlag
+	jobs, eventsFD                                      int
+	// file is the configuration in the front matter of the task file.
+	file models.FileConfig
 }
 
 var version = ""
 
+// commands are built-in subcommands of xc, a task with the same name takes precedence.
+var commands = map[string]func(ctx context.Context, cfg config, tasks models.Tasks, dir string, args []string) error{
+	"run":    runCommand,
+	"list":   listCommand,
+	"env":    envCommand,
+	"cat":    catCommand,
+	"up":     upCommand,
+	"state":  stateCommand,
+	"cron":   cronCommand,
+	"graph":  graphCommand,
+	"export": exportCommand,
+	"exec":   execCommand,
+	"search": searchCommand,
+	"stats":  statsCommand,
+	"index":  indexCommand,
+	"why":    whyCommand,
+	"report": bugReportCommand,
+}
+
 func main() {
 	if err := runMain(); err != nil {
 		fmt.Println(err.Error())
@@ -43,7 +80,7 @@ func main() {
 }
 
 func flags() config {
-	var cfg config
+	cfg := config{jobs: 1, eventsFD: 2}
 
 	log.SetFlags(0)
 	log.SetOutput(os.Stderr)
@@ -56,17 +93,24 @@ func flags() config {
 	flag.BoolVar(&cfg.help, "help", false, "show xc usage")
 	flag.BoolVar(&cfg.help, "h", false, "show xc usage")
 
-	flag.StringVar(&cfg.heading, "heading", "Tasks", "specify the heading for xc tasks")
-	flag.StringVar(&cfg.heading, "H", "Tasks", "specify the heading for xc tasks")
+	flag.StringVar(&cfg.heading, "heading", "", "specify the heading for xc tasks (default \"Tasks\")")
+	flag.StringVar(&cfg.heading, "H", "", "specify the heading for xc tasks (default \"Tasks\")")
+
+	flag.StringVar(&cfg.filename, "file", "", "specify a markdown file that contains tasks, or - to read from stdin")
+	flag.StringVar(&cfg.filename, "f", "", "specify a markdown file that contains tasks, or - to read from stdin")
+
+	flag.StringVar(&cfg.duplicates, "duplicates", "",
+		"which task to keep when tasks have the same name, error, first-wins or last-wins")
 
-	flag.StringVar(&cfg.filename, "file", "", "specify a markdown file that contains tasks")
-	flag.StringVar(&cfg.filename, "f", "", "specify a markdown file that contains tasks")
+	flag.StringVar(&cfg.lang, "lang", "",
+		"the language to show task descriptions in, such as fr, instead of that of the locale")
 
-	flag.BoolVar(&cfg.short, "short", false, "list task names in a short format")
-	flag.BoolVar(&cfg.short, "s", false, "list task names in a short format")
+	flag.BoolVar(&cfg.list, "list", false, "list tasks")
+	listFlags(flag.CommandLine, &cfg)
+	runFlags(flag.CommandLine, &cfg)
 
-	flag.BoolVar(&cfg.display, "d", false, "print the markdown code of a task rather than running it")
-	flag.BoolVar(&cfg.display, "display", false, "print the markdown code of a task rather than running it")
+	flag.StringVar(&cfg.metricsAddr, "metrics-addr", "",
+		"serve prometheus metrics and approvals at this address in cron mode")
 
 	flag.BoolVar(&cfg.complete, "complete", false, "install shell completion for xc")
 	flag.BoolVar(&cfg.uncomplete, "uncomplete", false, "uninstall shell completion for xc")
@@ -74,53 +118,140 @@ func flags() config {
 	return cfg
 }
 
-func parse(filename, heading string) (models.Tasks, string, error) {
-	if filename != "" {
-		return tryParse(filename, heading)
+func parse(cfg config) (models.Tasks, string, models.FileConfig, error) {
+	if cfg.filename != "" {
+		return tryParse(cfg.filename, cfg)
 	}
 	curr, err := filepath.Abs(filepath.Dir("."))
 	if err != nil {
-		return nil, "", fmt.Errorf("error getting current directory: %w", err)
+		return nil, "", models.FileConfig{}, fmt.Errorf("error getting current directory: %w", err)
 	}
-	return searchUpForFile(curr, heading)
+	return searchUpForFile(curr, cfg)
 }
 
-func searchUpForFile(curr, heading string) (models.Tasks, string, error) {
+func searchUpForFile(curr string, cfg config) (models.Tasks, string, models.FileConfig, error) {
 	rm := filepath.Join(curr, "README.md")
-	tasks, directory, err := tryParse(rm, heading)
+	tasks, directory, fc, err := tryParse(rm, cfg)
 	if err == nil {
-		return tasks, directory, nil
+		return tasks, directory, fc, nil
 	}
 	if err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, parser.ErrNoTasksHeading) {
-		return nil, "", err
+		return nil, "", fc, err
 	}
 	git := filepath.Join(curr, ".git")
 	_, err = os.Stat(git)
 	if err == nil {
-		return nil, "", ErrNoMarkdownFile
+		return nil, "", fc, ErrNoMarkdownFile
 	}
 	next := filepath.Dir(curr)
 	if strings.HasSuffix(next, string([]rune{filepath.Separator})) {
-		return nil, "", ErrNoMarkdownFile
+		return nil, "", fc, ErrNoMarkdownFile
 	}
-	return searchUpForFile(next, heading)
+	return searchUpForFile(next, cfg)
 }
 
-func tryParse(path, heading string) (models.Tasks, string, error) {
+// tryParse parses the tasks in the file at path and the files it includes,
+// using the index in the directory of path if there is one.
+// The env files in the returned config are made relative to the current directory.
+func tryParse(path string, cfg config) (models.Tasks, string, models.FileConfig, error) {
 	directory := filepath.Dir(path)
-	b, err := os.Open(path)
+	var ix *index.Index
+	if path != stdinFile {
+		ix = index.Open(directory, getVersion())
+	}
+	tasks, fc, err := parseFile(ix, path, cfg.heading)
 	if err != nil {
-		return nil, "", fmt.Errorf("xc error opening file: %w", err)
+		return nil, "", fc, err
 	}
-	p, err := parser.NewParser(b, heading)
+	abs, err := filepath.Abs(path)
 	if err != nil {
-		return nil, "", fmt.Errorf("xc parse error: %w", err)
+		return nil, "", fc, fmt.Errorf("xc error opening file: %w", err)
 	}
-	tasks, err := p.Parse()
+	seen := map[string]bool{abs: true}
+	local, _ := splitIncludes(fc.Includes)
+	tasks, err = includeTasks(ix, tasks, directory, directory, local, seen, nil)
 	if err != nil {
-		return nil, "", fmt.Errorf("xc parse error: %w", err)
+		return nil, "", fc, err
+	}
+	if tasks, err = lockedTasks(ix, tasks, directory, fc, seen, nil); err != nil {
+		return nil, "", fc, err
+	}
+	if err = ix.Save(); err != nil {
+		return nil, "", fc, fmt.Errorf("xc: %w", err)
+	}
+	if fc.Duplicates, err = duplicatePolicy(cfg, fc); err != nil {
+		return nil, "", fc, err
+	}
+	// Tasks with the same name as another are an error when they are run, so the other tasks can still be run.
+	tasks, _ = parser.Dedupe(tasks, fc.Duplicates)
+	// The env files are copied, as the config may be held by the index.
+	fc.EnvFiles = append([]string(nil), fc.EnvFiles...)
+	for i, f := range fc.EnvFiles {
+		if !filepath.IsAbs(f) {
+			fc.EnvFiles[i] = filepath.Join(directory, f)
+		}
+	}
+	return tasks, directory, fc, nil
+}
+
+// duplicatePolicy returns the policy for tasks with the same name,
+// set by -duplicates or the front matter of the task file.
+// It applies to the tasks of the task file and the files it includes, the policies of included files are not used.
+func duplicatePolicy(cfg config, fc models.FileConfig) (models.DuplicatePolicy, error) {
+	if cfg.duplicates == "" {
+		return fc.Duplicates, nil
+	}
+	policy, ok := models.ParseDuplicatePolicy(cfg.duplicates)
+	if !ok {
+		return 0, fmt.Errorf("xc: -duplicates %q should be (error, first-wins, last-wins)", cfg.duplicates)
+	}
+	return policy, nil
+}
+
+// stdinFile is the -file name that reads tasks from stdin, e.g. `curl ... | xc -f - setup`.
+const stdinFile = "-"
+
+// parseFile parses the tasks in the file at path, from ix if the file has not changed since it was indexed.
+func parseFile(ix *index.Index, path, heading string) (models.Tasks, models.FileConfig, error) {
+	parse := func(r io.Reader) (models.Tasks, models.FileConfig, error) {
+		return parseTasks(r, path, heading)
+	}
+	if path == stdinFile {
+		return parse(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, models.FileConfig{}, fmt.Errorf("xc error opening file: %w", err)
+	}
+	defer f.Close()
+	return ix.Parse(f, heading, parse)
+}
+
+// parseTasks parses the tasks in r, read from the file at path.
+func parseTasks(r io.Reader, path, heading string) (models.Tasks, models.FileConfig, error) {
+	p, err := parser.NewParser(r, heading)
+	if err != nil {
+		return nil, models.FileConfig{}, fmt.Errorf("xc parse error: %w", err)
+	}
+	// The version is checked before parsing tasks, as newer syntax may not parse.
+	if err = checkMinVersion(path, p.FileConfig().MinVersion); err != nil {
+		return nil, models.FileConfig{}, err
+	}
+	// A malformed task is kept with its parsing error, so the other tasks can still be run.
+	p.Lenient()
+	// Tasks with the same name are all kept, the duplicates policy is applied once the included files are parsed.
+	var tasks models.Tasks
+	for {
+		t, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, models.FileConfig{}, fmt.Errorf("xc parse error: %w", err)
+		}
+		tasks = append(tasks, t)
 	}
-	return tasks, directory, nil
+	return tasks, p.FileConfig(), nil
 }
 
 func printTasks(tasks models.Tasks, short bool) {
@@ -142,14 +273,30 @@ func printTasks(tasks models.Tasks, short bool) {
 func printTask(task models.Task, maxLen int) {
 	padLen := maxLen - len(task.Name)
 	pad := strings.Repeat(" ", padLen)
-	desc := task.Description
+	var desc []string
+	for _, d := range task.Description {
+		desc = append(desc, renderDescription(d))
+	}
 	if len(task.DependsOn) > 0 {
 		desc = append(desc, fmt.Sprintf("Requires:  %s", strings.Join(task.DependsOn, ", ")))
 	}
+	if len(task.Steps) > 0 {
+		desc = append(desc, fmt.Sprintf("Steps:  %s", strings.Join(task.Steps, ", ")))
+	}
+	if len(task.Foreach) > 0 {
+		desc = append(desc, fmt.Sprintf("Foreach:  %s", strings.Join(task.Foreach, ", ")))
+	}
 	if len(desc) == 0 {
 		desc = strings.Split(task.Script, "\n")
 	}
-	fmt.Printf("    %s%s  %s\n", task.Name, pad, desc[0])
+	if task.Icon != "" && !terminal.Accessible() {
+		desc[0] = task.Icon + " " + desc[0]
+	}
+	name := task.Name
+	if style, ok := terminal.Named(task.Color); ok && terminal.Color(os.Stdout) {
+		name = style.Paint(name)
+	}
+	fmt.Printf("    %s%s  %s\n", name, pad, desc[0])
 	for _, d := range desc[1:] {
 		fmt.Printf("    %s  %s\n", strings.Repeat(" ", maxLen), d)
 	}
@@ -165,52 +312,166 @@ func runMain() error {
 		cancel()
 	}()
 	cfg := flags()
+	if cfg.noColor {
+		terminal.DisableColor()
+	}
+	if cfg.accessible {
+		terminal.EnableAccessible()
+	}
 	if cfg.uncomplete {
 		return install.Uninstall("xc")
 	}
 	if cfg.complete {
 		return install.Install("xc")
 	}
-	tasks, dir, err := parse(cfg.filename, cfg.heading)
-	completion(tasks).Complete("xc")
-	// xc -version
-	if cfg.version {
+	tasks, dir, fc, err := parse(cfg)
+	tasks = tasks.Localize(languages(cfg))
+	cfg.file = fc
+	completion(tasks, fc).Complete("xc")
+	tav := flag.Args()
+	// xc -version / xc version
+	if cfg.version || isCommand(tasks, tav, "version") {
 		fmt.Printf("xc version: %s\n", getVersion())
 		return nil
 	}
-	// xc -h / xc -help
-	if cfg.help {
-		flag.Usage()
+	// xc -h / xc -help / xc help [task]
+	if cfg.help || isCommand(tasks, tav, "help") {
+		if len(tav) > 1 && err == nil {
+			return helpCommand(tasks, tav[1])
+		}
+		printHelp(os.Stdout, tasks)
 		return nil
 	}
+	// xc ci-validate
+	if isCommand(tasks, tav, "ci-validate") {
+		return ciValidate(ctx, cfg, tav[1:])
+	}
+	// xc completion install, which does not need a task file.
+	if isCommand(tasks, tav, "completion") {
+		return completionCommand(tav[1:])
+	}
+	// xc pkg install and xc update, which may be needed before the task file can be parsed.
+	if isCommand(tasks, tav, "pkg") {
+		return pkgCommand(ctx, cfg, tav[1:])
+	}
+	if isCommand(tasks, tav, "update") {
+		return updateCommand(ctx, cfg, tav[1:])
+	}
+	// xc -submodules task1 / xc -worktrees task1 from a directory without a task file.
+	if err != nil && (cfg.submodules || cfg.worktrees) && len(tav) > 0 && !cfg.list {
+		return runInRepos(ctx, cfg, ".", tav)
+	}
 	if err != nil {
 		return err
 	}
-	tav := flag.Args()
-	// xc
-	if len(tav) == 0 {
-		printTasks(tasks, cfg.short)
-		return nil
+	if _, err = profileEnv(cfg); err != nil {
+		return err
 	}
-	ta, ok := tasks.Get(tav[0])
-	if !ok {
-		fmt.Printf("task \"%s\" not found\n", tav[0])
+	for _, t := range tasks {
+		if t.ParsingError != "" {
+			fmt.Fprintf(os.Stderr, "xc: warning: task %s has a parsing error: %s\n", t.Name, t.ParsingError)
+		}
 	}
-	// xc -display task1
-	if cfg.display {
-		ta.Display(os.Stdout)
-		return nil
+	return dispatch(ctx, cfg, tasks, dir, tav)
+}
+
+// dispatch lists the tasks, runs the built-in command named by the first of args, or runs a task.
+func dispatch(ctx context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	// xc / xc -list
+	if len(args) == 0 || cfg.list {
+		return listTasks(cfg, tasks, dir)
+	}
+	if cmd, ok := commands[args[0]]; ok && isCommand(tasks, args, args[0]) {
+		return cmd(ctx, cfg, tasks, dir, args[1:])
 	}
 	// xc task1
-	runner, err := run.NewRunner(tasks, dir)
-	if err != nil {
-		return fmt.Errorf("xc parse error: %w", err)
+	return runTask(ctx, cfg, tasks, dir, args)
+}
+
+// isCommand returns true if args are for the built-in command name, rather than a task of the same name.
+func isCommand(tasks models.Tasks, args []string, name string) bool {
+	if len(args) == 0 || args[0] != name {
+		return false
 	}
-	err = runner.Run(ctx, tav[0], tav[1:])
-	if err != nil {
-		return fmt.Errorf("xc: %w", err)
+	_, ok := tasks.Get(name)
+	return !ok
+}
+
+func runnerOptions(cfg config) []run.Option {
+	var opts []run.Option
+	if cfg.keepTmp {
+		opts = append(opts, run.WithKeepTmp())
+	}
+	if cfg.noExpand {
+		opts = append(opts, run.WithoutExpansion())
+	}
+	if cfg.dryRun {
+		opts = append(opts, run.WithDryRun())
+	}
+	if cfg.resume {
+		opts = append(opts, run.WithResume())
+	}
+	if cfg.explain {
+		opts = append(opts, run.WithExplain())
+	}
+	if cfg.noNetwork {
+		opts = append(opts, run.WithoutNetwork())
+	}
+	if cfg.noSandbox {
+		opts = append(opts, run.WithoutSandbox())
+	}
+	if cfg.noDeps {
+		opts = append(opts, run.WithoutDependencies())
+	}
+	if only := onlyTasks(cfg); len(only) > 0 {
+		opts = append(opts, run.WithOnly(only...))
+	}
+	if cfg.from != "" {
+		opts = append(opts, run.WithFrom(cfg.from))
+	}
+	if cfg.detach {
+		opts = append(opts, run.WithDetachedServices())
+	}
+	if cfg.approve {
+		opts = append(opts, run.WithApprover(run.ApproveAll))
+	}
+	if cfg.file.Shell != "" {
+		opts = append(opts, run.WithShell(cfg.file.Shell))
+	}
+	if cfg.file.ShellOpts != nil {
+		opts = append(opts, run.WithShellOpts(cfg.file.ShellOpts))
+	}
+	if len(cfg.file.Wrapper) > 0 {
+		opts = append(opts, run.WithWrapper(cfg.file.Wrapper))
+	}
+	if len(cfg.file.EnvFiles) > 0 {
+		opts = append(opts, run.WithEnvFiles(cfg.file.EnvFiles...))
+	}
+	// An unknown profile has already been reported by runMain or runTask.
+	if env, err := profileEnv(cfg); err == nil && len(env) > 0 {
+		opts = append(opts, run.WithProfile(env...))
+	}
+	if cfg.jobs > 1 {
+		opts = append(opts, run.WithJobs(cfg.jobs))
+	}
+	if p, ok := ci.Detect(); ok {
+		opts = append(opts, run.WithOutputGroups(p))
+	}
+	return opts
+}
+
+// withTracing adds a tracing observer to opts if OpenTelemetry exporting is configured,
+// flush must be called once the run has finished to export the trace.
+func withTracing(opts []run.Option) (_ []run.Option, flush func()) {
+	tracer, ok := tracing.FromEnv()
+	if !ok {
+		return opts, func() {}
+	}
+	return append(opts, run.WithObserver(tracer)), func() {
+		if err := tracer.Flush(context.Background()); err != nil {
+			log.Printf("xc: %v", err)
+		}
 	}
-	return nil
 }
 
 func getVersion() string {
@@ -231,32 +492,207 @@ func getVersion() string {
 	return version
 }
 
-func completion(tasks models.Tasks) *complete.Command {
+func completion(tasks models.Tasks, fc models.FileConfig) *complete.Command {
 	return &complete.Command{
 		Flags: map[string]complete.Predictor{
-			"version": predict.Nothing,
-			"V":       predict.Nothing,
-			"h":       predict.Nothing,
-			"help":    predict.Nothing,
-			"f":       predict.Files("*.md"),
-			"file":    predict.Files("*.md"),
-			"s":       predict.Nothing,
-			"short":   predict.Nothing,
-			"d":       predict.Nothing,
-			"display": predict.Nothing,
-			"H":       predict.Nothing,
-			"heading": predict.Nothing,
+			"version":       predict.Nothing,
+			"V":             predict.Nothing,
+			"h":             predict.Nothing,
+			"help":          predict.Nothing,
+			"f":             predict.Files("*.md"),
+			"file":          predict.Files("*.md"),
+			"s":             predict.Nothing,
+			"list":          predict.Nothing,
+			"long":          predict.Nothing,
+			"l":             predict.Nothing,
+			"json":          predict.Nothing,
+			"sort":          predict.Set{"name", "file", "duration"},
+			"filter":        predict.Something,
+			"short":         predict.Nothing,
+			"d":             predict.Nothing,
+			"display":       predict.Nothing,
+			"H":             predict.Nothing,
+			"heading":       predict.Nothing,
+			"duplicates":    predict.Set{"error", "first-wins", "last-wins"},
+			"lang":          predict.Set(translatedLanguages(tasks)),
+			"keep-tmp":      predict.Nothing,
+			"no-expand":     predict.Nothing,
+			"dry-run":       predict.Nothing,
+			"n":             predict.Nothing,
+			"resume":        predict.Nothing,
+			"no-network":    predict.Nothing,
+			"no-sandbox":    predict.Nothing,
+			"no-color":      predict.Nothing,
+			"accessible":    predict.Nothing,
+			"submodules":    predict.Nothing,
+			"worktrees":     predict.Nothing,
+			"detach":        predict.Nothing,
+			"j":             predict.Something,
+			"jobs":          predict.Something,
+			"result-file":   predict.Files("*.json"),
+			"trace-out":     predict.Files("*.json"),
+			"record":        predict.Dirs("*"),
+			"report":        predict.Set{"junit=", "json="},
+			"metrics-addr":  predict.Nothing,
+			"dir":           predict.Dirs("*"),
+			"changed-since": predict.Something,
+			"cache":         predict.Dirs("*"),
+			"cache-mode":    predict.Set{"read-only", "upload"},
+			"no-gitignore":  predict.Nothing,
+			"explain":       predict.Nothing,
+			"no-deps":       predict.Nothing,
+			"approve":       predict.Nothing,
+			"only":          predict.Set(taskNames(tasks)),
+			"from":          predict.Set(taskNames(tasks)),
+			"shard":         predict.Something,
+			"env":           predict.Something,
+			"e":             predict.Something,
+			"run":           predict.Set{"always", "once"},
+			"profile":       predict.Set(profileNames(fc)),
+			"bell":          predict.Nothing,
+			"summary":       predict.Nothing,
+			"keep-last-run": predict.Nothing,
+			"events":        predict.Set{"ndjson", "bep"},
+			"events-fd":     predict.Something,
 		},
-		Sub: completeTasks(tasks),
+		Sub: completeTasks(tasks, fc),
 	}
 }
 
-func completeTasks(tasks models.Tasks) map[string]*complete.Command {
-	result := map[string]*complete.Command{}
+func taskNames(tasks models.Tasks) []string {
+	names := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func serviceNames(tasks models.Tasks) []string {
+	var names []string
+	for _, t := range tasks {
+		if t.Service {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+func completeTasks(tasks models.Tasks, fc models.FileConfig) map[string]*complete.Command {
+	_, remote := splitIncludes(fc.Includes)
+	result := map[string]*complete.Command{
+		"run": {Args: predict.Set(taskNames(tasks))},
+		"why": {Args: predict.Set(taskNames(tasks))},
+		"list": {Flags: map[string]complete.Predictor{
+			"s":      predict.Nothing,
+			"short":  predict.Nothing,
+			"l":      predict.Nothing,
+			"long":   predict.Nothing,
+			"json":   predict.Nothing,
+			"sort":   predict.Set{"name", "file", "duration"},
+			"filter": predict.Something,
+		}},
+		"help":        {Args: predict.Set(taskNames(tasks))},
+		"version":     {},
+		"ci-validate": {Flags: map[string]complete.Predictor{"shellcheck": predict.Nothing}},
+		"up":          {Args: predict.Set(serviceNames(tasks))},
+		"env": {
+			Flags: map[string]complete.Predictor{"diff": predict.Nothing},
+			Args:  predict.Set(taskNames(tasks)),
+		},
+		"cat":    {Args: predict.Set(taskNames(tasks))},
+		"state":  {Sub: map[string]*complete.Command{"clear": {}}},
+		"cron":   {},
+		"exec":   {Args: predict.Something},
+		"search": {Args: predict.Something},
+		"stats":  {},
+		"index":  {Flags: map[string]complete.Predictor{"rebuild": predict.Nothing}},
+		"report": {
+			Flags: map[string]complete.Predictor{"o": predict.Files("*.tar.gz")},
+			Args:  predict.Set(taskNames(tasks)),
+		},
+		"completion": {Sub: map[string]*complete.Command{
+			"install":   {Args: predict.Set(completionShells)},
+			"uninstall": {Args: predict.Set(completionShells)},
+		}},
+		"update": {Args: predict.Set(append(remote, fc.Uses...))},
+		"pkg": {Sub: map[string]*complete.Command{
+			"install": {},
+			"pack": {
+				Flags: map[string]complete.Predictor{"file": predict.Files("*.md"), "o": predict.Files("*.tar.gz")},
+				Args:  predict.Dirs("*"),
+			},
+			"publish": {
+				Flags: map[string]complete.Predictor{
+					"file":      predict.Files("*.md"),
+					"bundle":    predict.Files("*.tar.gz"),
+					"signature": predict.Files("*"),
+				},
+				Args: predict.Dirs("*"),
+			},
+		}},
+		"export": {Sub: map[string]*complete.Command{
+			"mermaid": {Flags: map[string]complete.Predictor{"raw": predict.Nothing}},
+			"desktop": {
+				Flags: map[string]complete.Predictor{"o": predict.Dirs("*"), "manifest": predict.Nothing},
+				Args:  predict.Set(taskNames(tasks)),
+			},
+			"direnv": {
+				Flags: map[string]complete.Predictor{"o": predict.Dirs("*"), "prefix": predict.Something},
+				Args:  predict.Set(taskNames(tasks)),
+			},
+		}},
+		"graph": {
+			Flags: map[string]complete.Predictor{"format": predict.Set{"text", "dot", "mermaid", "svg"}},
+			Args:  predict.Set(taskNames(tasks)),
+		},
+	}
 	for _, t := range tasks {
 		result[t.Name] = &complete.Command{
-			Args: predict.Something,
+			Args: predictInputs(t),
 		}
 	}
 	return result
 }
+
+// predictInputs predicts the input of t being completed from the values it accepts,
+// or the default value set by its env attribute.
+// Predictors are only given the word being completed, so the input is found from the command line.
+func predictInputs(t models.Task) complete.PredictFunc {
+	return func(string) []string {
+		i := inputIndex(t.Name, os.Getenv("COMP_LINE"), os.Getenv("COMP_POINT"))
+		if i < 0 || i >= len(t.Inputs) {
+			return nil
+		}
+		input := t.Inputs[i]
+		if values := t.InputValues[input]; len(values) > 0 {
+			return values
+		}
+		for _, e := range t.Env {
+			if k, v, ok := strings.Cut(e, "="); ok && k == input && !strings.Contains(v, "$") {
+				return []string{v}
+			}
+		}
+		return nil
+	}
+}
+
+// inputIndex returns the index of the input of the task name being completed in line, the command line
+// up to point, or -1 if the task is not in line.
+func inputIndex(name, line, point string) int {
+	if p, err := strconv.Atoi(point); err == nil && p >= 0 && p <= len(line) {
+		line = line[:p]
+	}
+	fields := strings.Fields(line)
+	for i := 1; i < len(fields); i++ {
+		if fields[i] != name {
+			continue
+		}
+		completed := len(fields) - i - 1
+		if !strings.HasSuffix(line, " ") {
+			// The last field is the word being completed.
+			completed--
+		}
+		return completed
+	}
+	return -1
+}
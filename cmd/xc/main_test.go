@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		tasks       models.Tasks
+		args        []string
+		expectedRan []string
+		expectedErr bool
+	}{
+		{
+			name:  "given no args, should list the tasks and run none",
+			tasks: models.Tasks{{Name: "build", Script: "touch build"}},
+		},
+		{
+			name:        "given a task name, should run the task",
+			tasks:       models.Tasks{{Name: "build", Script: "touch build"}},
+			args:        []string{"build"},
+			expectedRan: []string{"build"},
+		},
+		{
+			name:        "given the run command, should run the task",
+			tasks:       models.Tasks{{Name: "build", Script: "touch build"}},
+			args:        []string{"run", "build"},
+			expectedRan: []string{"build"},
+		},
+		{
+			name:  "given a built-in command, should run the command rather than a task",
+			tasks: models.Tasks{{Name: "build", Script: "touch build"}},
+			args:  []string{"graph", "build"},
+		},
+		{
+			name: "given a task with the name of a built-in command, should run the task",
+			tasks: models.Tasks{
+				{Name: "build", Script: "touch build"},
+				{Name: "graph", Script: "touch graph"},
+			},
+			args:        []string{"graph", "build"},
+			expectedRan: []string{"graph"},
+		},
+		{
+			name:        "given an unknown task, should return an error",
+			tasks:       models.Tasks{{Name: "build", Script: "touch build"}},
+			args:        []string{"unknown"},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := dispatch(context.Background(), config{jobs: 1, eventsFD: 2}, tt.tasks, dir, tt.args)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			ran := map[string]bool{}
+			for _, name := range tt.expectedRan {
+				ran[name] = true
+			}
+			for _, task := range tt.tasks {
+				_, err := os.Stat(filepath.Join(dir, task.Name))
+				if ran[task.Name] && err != nil {
+					t.Errorf("expected task %s to run: %v", task.Name, err)
+				}
+				if !ran[task.Name] && !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("expected task %s not to run", task.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestIsCommand(t *testing.T) {
+	tasks := models.Tasks{{Name: "build"}, {Name: "version"}}
+	tests := []struct {
+		name     string
+		args     []string
+		command  string
+		expected bool
+	}{
+		{name: "given no args, should not be the command", command: "help"},
+		{name: "given the command, should be the command", args: []string{"help", "build"}, command: "help", expected: true},
+		{name: "given another command, should not be the command", args: []string{"list"}, command: "help"},
+		{
+			name:    "given a task with the name of the command, should not be the command",
+			args:    []string{"version"},
+			command: "version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCommand(tasks, tt.args, tt.command); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTryParseStdin(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString("# Project\n\n## Tasks\n\n### build\n\n```sh\ngo build\n```\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() { os.Stdin = stdin })
+	tasks, dir, _, err := tryParse(stdinFile, config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "." {
+		t.Fatalf("expected tasks read from stdin to run in the current directory, got %q", dir)
+	}
+	if len(tasks) != 1 || tasks[0].Name != "build" || tasks[0].Script != "go build\n" {
+		t.Fatalf("expected the build task, got %v", tasks)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+// stringsFlag is a flag that can be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// applyOverrides replaces the attributes of the named task with those given as flags.
+// The flags are validated even if there is no task with the name, so that a mistake is not silently ignored.
+func applyOverrides(tasks models.Tasks, name string, cfg config) (models.Tasks, error) {
+	if cfg.dirOverride == "" && len(cfg.envOverrides) == 0 && cfg.runOverride == "" {
+		return tasks, nil
+	}
+	if err := checkEnvOverrides(cfg.envOverrides); err != nil {
+		return nil, err
+	}
+	var behaviour models.RequiredBehaviour
+	if cfg.runOverride != "" {
+		r, ok := models.ParseRequiredBehaviour(cfg.runOverride)
+		if !ok {
+			return nil, fmt.Errorf("run contains invalid behaviour %q should be (always, once)", cfg.runOverride)
+		}
+		behaviour = r
+	}
+	result := make(models.Tasks, len(tasks))
+	copy(result, tasks)
+	for i, t := range result {
+		if !strings.EqualFold(t.Name, name) {
+			continue
+		}
+		if cfg.dirOverride != "" {
+			t.Dir = cfg.dirOverride
+		}
+		t.Env = overrideEnv(t.Env, cfg.envOverrides)
+		if cfg.runOverride != "" {
+			t.RequiredBehaviour = behaviour
+		}
+		result[i] = t
+	}
+	return result, nil
+}
+
+// overrideEnv replaces the values in env with the overrides of the same name, in place so that values referring to
+// them are interpolated with the overrides. Overrides of names not in env are appended.
+func overrideEnv(env, overrides []string) []string {
+	result := make([]string, len(env), len(env)+len(overrides))
+	copy(result, env)
+	for _, o := range overrides {
+		name, _, _ := strings.Cut(o, "=")
+		found := false
+		for i, e := range result {
+			if k, _, _ := strings.Cut(e, "="); k == name {
+				result[i] = o
+				found = true
+			}
+		}
+		if !found {
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+func checkEnvOverrides(env []string) error {
+	for _, e := range env {
+		if !strings.Contains(e, "=") {
+			return fmt.Errorf("env %q should be in the form KEY=VALUE", e)
+		}
+	}
+	return nil
+}
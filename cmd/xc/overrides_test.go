@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Env: []string{"GOOS=linux", "OUT=bin/${GOOS}"}, Dir: "cmd"},
+		{Name: "test"},
+	}
+	tests := []struct {
+		name        string
+		task        string
+		cfg         config
+		expected    models.Tasks
+		expectedErr bool
+	}{
+		{
+			name:     "given no overrides, should return the tasks",
+			task:     "build",
+			expected: tasks,
+		},
+		{
+			name: "given overrides, should apply them to the named task",
+			task: "BUILD",
+			cfg:  config{dirOverride: "web", envOverrides: stringsFlag{"GOOS=darwin", "CGO_ENABLED=0"}, runOverride: "once"},
+			expected: models.Tasks{
+				{
+					Name:              "build",
+					Env:               []string{"GOOS=darwin", "OUT=bin/${GOOS}", "CGO_ENABLED=0"},
+					Dir:               "web",
+					RequiredBehaviour: models.RequiredBehaviourOnce,
+				},
+				{Name: "test"},
+			},
+		},
+		{
+			name:        "given a malformed env, should fail",
+			task:        "build",
+			cfg:         config{envOverrides: stringsFlag{"GOOS"}},
+			expectedErr: true,
+		},
+		{
+			name:        "given a malformed env and no task with the name, should fail",
+			task:        "missing",
+			cfg:         config{envOverrides: stringsFlag{"GOOS"}},
+			expectedErr: true,
+		},
+		{
+			name:        "given an invalid run behaviour and no task with the name, should fail",
+			task:        "missing",
+			cfg:         config{runOverride: "sometimes"},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyOverrides(tasks, tt.task, tt.cfg)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v got %v", tt.expectedErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %+v got %+v", tt.expected, got)
+			}
+			if !reflect.DeepEqual(tasks[0].Env, []string{"GOOS=linux", "OUT=bin/${GOOS}"}) {
+				t.Fatalf("expected the tasks not to be changed got %v", tasks[0].Env)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joerdav/xc/parser"
+	"github.com/joerdav/xc/pkg"
+)
+
+var errPkgUsage = errors.New("usage: xc pkg install | xc pkg pack [-file README.md] [-o xc-pkg.tar.gz] [dir] | " +
+	"xc pkg publish [-file README.md] [-bundle file] [-signature file] oci://<registry>/<repository>@<version> [dir]")
+
+// xc pkg install
+// xc pkg pack [-file README.md] [-o xc-pkg.tar.gz] [dir]
+// xc pkg publish [-file README.md] [-bundle file] [-signature file] oci://<registry>/<repository>@<version> [dir]
+//
+// It runs before the task file is parsed, as its tasks cannot be parsed until the packages it uses are installed.
+func pkgCommand(ctx context.Context, cfg config, args []string) error {
+	if len(args) == 0 {
+		return errPkgUsage
+	}
+	switch args[0] {
+	case "install":
+		if len(args) != 1 {
+			return errPkgUsage
+		}
+		return pkgInstall(ctx, cfg, false, nil)
+	case "pack":
+		return pkgPack(args[1:])
+	case "publish":
+		return pkgPublish(ctx, args[1:])
+	}
+	return errPkgUsage
+}
+
+// xc update [package-or-url...]
+func updateCommand(ctx context.Context, cfg config, args []string) error {
+	return pkgInstall(ctx, cfg, true, args)
+}
+
+// pkgInstall installs the packages and remote includes of the task file. If update is set those in refs,
+// or every one if refs is empty, are fetched again to lock their current digest.
+func pkgInstall(ctx context.Context, cfg config, update bool, refs []string) error {
+	if cfg.filename == stdinFile {
+		return errors.New("xc: tasks read from stdin cannot use packages")
+	}
+	path, err := findTaskFile(cfg.filename)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("xc error opening file: %w", err)
+	}
+	// Only the front matter is needed, packages are installed even if the file has no tasks of its own.
+	p, err := parser.NewParser(f, cfg.heading)
+	f.Close()
+	if err != nil && !errors.Is(err, parser.ErrNoTasksHeading) {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	fc := p.FileConfig()
+	_, remote := splitIncludes(fc.Includes)
+	dir := filepath.Dir(path)
+	lock, err := pkg.ReadLock(dir)
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if update {
+		for _, r := range refs {
+			_, isPackage := lock.Get(r)
+			if _, isInclude := lock.Include(r); !isPackage && !isInclude {
+				return fmt.Errorf("xc: %s is not in %s", r, pkg.LockPath(dir))
+			}
+		}
+		lock = lock.Without(refs...)
+	}
+	signers, err := pkg.ReadSigners(dir, fc.Signers)
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if lock, err = pkg.Install(ctx, dir, fc.Uses, remote, lock, signers); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if err = pkg.WriteLock(dir, lock); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	for _, l := range append(lock.Packages, lock.Includes...) {
+		fmt.Printf("Installed %s %s\n", l.Ref, l.Digest)
+	}
+	return nil
+}
+
+func pkgPack(args []string) error {
+	fs := flag.NewFlagSet("pack", flag.ContinueOnError)
+	file := fs.String("file", "README.md", "the task file of the package, relative to dir")
+	out := fs.String("o", "xc-pkg.tar.gz", "the file to write the bundle to")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 1 {
+		return errPkgUsage
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+	var bundle bytes.Buffer
+	if err := pkg.Pack(&bundle, dir, *file); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	if err := os.WriteFile(*out, bundle.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	fmt.Printf("Packed %s\n", *out)
+	return nil
+}
+
+func pkgPublish(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	file := fs.String("file", "README.md", "the task file of the package, relative to dir")
+	bundleFile := fs.String("bundle", "", "publish a bundle written by xc pkg pack rather than packing dir")
+	sigFile := fs.String("signature", "", "the minisign or cosign signature of the bundle")
+	if err := fs.Parse(args); err != nil || fs.NArg() < 1 || fs.NArg() > 2 || (*bundleFile != "" && fs.NArg() == 2) {
+		return errPkgUsage
+	}
+	if *sigFile != "" && *bundleFile == "" {
+		return errors.New("xc: -signature requires -bundle, the signature is of the bundle written by xc pkg pack")
+	}
+	ref, err := pkg.ParseRef(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	var bundle []byte
+	if *bundleFile != "" {
+		if bundle, err = os.ReadFile(*bundleFile); err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+		// A signature written by minisign or cosign next to the bundle is published with it.
+		for _, ext := range []string{".minisig", ".sig"} {
+			if _, err := os.Stat(*bundleFile + ext); *sigFile == "" && err == nil {
+				*sigFile = *bundleFile + ext
+			}
+		}
+	} else {
+		dir := "."
+		if fs.NArg() == 2 {
+			dir = fs.Arg(1)
+		}
+		var b bytes.Buffer
+		if err = pkg.Pack(&b, dir, *file); err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+		bundle = b.Bytes()
+	}
+	var sig []byte
+	if *sigFile != "" {
+		if sig, err = os.ReadFile(*sigFile); err != nil {
+			return fmt.Errorf("xc: %w", err)
+		}
+	}
+	digest, err := pkg.Publish(ctx, ref, bundle, sig)
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	fmt.Printf("Published %s %s\n", ref, digest)
+	return nil
+}
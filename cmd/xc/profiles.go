@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/parser"
+)
+
+// profileEnv returns the environment variables of the profile selected with -profile, matched case insensitively,
+// or nil if no profile is selected.
+func profileEnv(cfg config) ([]string, error) {
+	if cfg.profile == "" {
+		return nil, nil
+	}
+	for name, env := range cfg.file.Profiles {
+		if strings.EqualFold(name, cfg.profile) {
+			return env, nil
+		}
+	}
+	names := profileNames(cfg.file)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("xc: profile %q not found, there is no %s section", cfg.profile, parser.ProfilesHeading)
+	}
+	return nil, fmt.Errorf("xc: profile %q not found, the profiles are: %s", cfg.profile, strings.Join(names, ", "))
+}
+
+// profileNames returns the names of the profiles defined in the task file, sorted case insensitively.
+func profileNames(fc models.FileConfig) []string {
+	names := make([]string, 0, len(fc.Profiles))
+	for name := range fc.Profiles {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+	return names
+}
+
+// printProfiles prints the profiles defined in the task file after the list of tasks, if there are any.
+func printProfiles(fc models.FileConfig) {
+	if names := profileNames(fc); len(names) > 0 {
+		fmt.Printf("\nProfiles: %s\n", strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/joerdav/xc/result"
+)
+
+// reportFormats are the formats of -report, each writing a report of a run to a path.
+var reportFormats = map[string]func(r *result.Recorder, path, runID string, err error) error{
+	"json":  (*result.Recorder).WriteFile,
+	"junit": (*result.Recorder).WriteJUnitFile,
+}
+
+type report struct {
+	format, path string
+}
+
+// parseReports parses -report values of the form format=path, and -result-file.
+func parseReports(values []string, resultFile string) ([]report, error) {
+	var reports []report
+	for _, v := range values {
+		format, path, ok := strings.Cut(v, "=")
+		if _, known := reportFormats[format]; !ok || !known || path == "" {
+			return nil, fmt.Errorf("xc: invalid report %q should be junit=<path> or json=<path>", v)
+		}
+		reports = append(reports, report{format: format, path: path})
+	}
+	if resultFile != "" {
+		reports = append(reports, report{format: "json", path: resultFile})
+	}
+	return reports, nil
+}
+
+// writeReports writes each report of a run, failing to write a report does not fail the run.
+func writeReports(reports []report, r *result.Recorder, runID string, runErr error) {
+	for _, rep := range reports {
+		if err := reportFormats[rep.format](r, rep.path, runID, runErr); err != nil {
+			log.Printf("xc: %v", err)
+		}
+	}
+}
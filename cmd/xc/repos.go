@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/joerdav/xc/git"
+	"github.com/joerdav/xc/parser"
+	"github.com/joerdav/xc/terminal"
+)
+
+// repoResult is the outcome of running a task in one submodule or worktree.
+type repoResult struct {
+	dir string
+	// skipped is why the task did not run, if it did not.
+	skipped string
+	err     error
+}
+
+// runInRepos runs the task named by the first of args in every submodule, with -submodules,
+// or every worktree, with -worktrees, of the repository containing dir that defines it,
+// then prints a summary of the results.
+func runInRepos(ctx context.Context, cfg config, dir string, args []string) error {
+	if cfg.submodules && cfg.worktrees {
+		return errors.New("xc: -submodules and -worktrees cannot be used together")
+	}
+	find, kind := git.Submodules, "submodule"
+	if cfg.worktrees {
+		find, kind = git.Worktrees, "worktree"
+	}
+	dirs, err := find(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("xc: failed to find %ss: %w", kind, err)
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("xc: no %ss found", kind)
+	}
+	name := "README.md"
+	if cfg.filename != "" && cfg.filename != stdinFile {
+		name = filepath.Base(cfg.filename)
+	}
+	cfg.submodules, cfg.worktrees = false, false
+	var results []repoResult
+	for _, d := range dirs {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, runInRepo(ctx, cfg, filepath.Join(d, name), args))
+	}
+	return summariseRepos(results, kind)
+}
+
+func runInRepo(ctx context.Context, cfg config, path string, args []string) repoResult {
+	r := repoResult{dir: filepath.Dir(path)}
+	tasks, dir, fc, err := tryParse(path, cfg)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) || errors.Is(err, parser.ErrNoTasksHeading):
+		r.skipped = "no task file"
+		return r
+	case err != nil:
+		r.err = err
+		return r
+	}
+	if _, ok := tasks.Get(args[0]); !ok {
+		r.skipped = "task not defined"
+		return r
+	}
+	heading := "==> " + r.dir
+	if terminal.Color(os.Stdout) {
+		heading = terminal.Bold.Paint(heading)
+	}
+	fmt.Println(heading)
+	cfg.file = fc
+	r.err = runTask(ctx, cfg, tasks, dir, args)
+	if r.err != nil {
+		fmt.Fprintln(os.Stderr, r.err)
+	}
+	return r
+}
+
+// summariseRepos prints the result of each submodule or worktree, returning an error if the task failed in any.
+func summariseRepos(results []repoResult, kind string) error {
+	var failed, ran int
+	fmt.Println()
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case r.skipped != "":
+			status = "skipped, " + r.skipped
+		case r.err != nil:
+			status = "failed"
+			failed++
+		}
+		if r.skipped == "" {
+			ran++
+		}
+		fmt.Printf("  %s: %s\n", r.dir, status)
+	}
+	if ran == 0 {
+		return fmt.Errorf("xc: the task is not defined in any %s", kind)
+	}
+	if failed > 0 {
+		return fmt.Errorf("xc: the task failed in %d of %d %ss", failed, ran, kind)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joerdav/xc/cache"
+	"github.com/joerdav/xc/git"
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/progress"
+	"github.com/joerdav/xc/result"
+	"github.com/joerdav/xc/run"
+	"github.com/joerdav/xc/terminal"
+	"github.com/joerdav/xc/tracing"
+)
+
+var errRunUsage = errors.New("usage: xc run [flags] <task> [inputs...]")
+
+// runFlags adds the flags that change how a task is run to fs, defaulting to the values already in cfg
+// so that flags given before a subcommand are kept.
+func runFlags(fs *flag.FlagSet, cfg *config) {
+	fs.BoolVar(&cfg.display, "d", cfg.display, "print the markdown code of a task rather than running it")
+	fs.BoolVar(&cfg.display, "display", cfg.display, "print the markdown code of a task rather than running it")
+
+	fs.BoolVar(&cfg.keepTmp, "keep-tmp", cfg.keepTmp, "keep the temporary directory of each task after it has run")
+
+	fs.BoolVar(&cfg.noExpand, "no-expand", cfg.noExpand, "do not expand variables in env and dir attributes")
+
+	fs.BoolVar(&cfg.dryRun, "n", cfg.dryRun, "print the scripts of tasks rather than running them")
+	fs.BoolVar(&cfg.dryRun, "dry-run", cfg.dryRun, "print the scripts of tasks rather than running them")
+
+	fs.BoolVar(&cfg.noNetwork, "no-network", cfg.noNetwork, "run scripts without network access")
+
+	fs.BoolVar(&cfg.noColor, "no-color", cfg.noColor, "do not color output, the same as setting NO_COLOR")
+	fs.BoolVar(&cfg.accessible, "accessible", cfg.accessible,
+		"report progress in plain lines of text, without color or icons, the same as setting XC_ACCESSIBLE=1")
+
+	fs.BoolVar(&cfg.noSandbox, "no-sandbox", cfg.noSandbox,
+		"ignore the allow-paths, deny-paths and network attributes of tasks")
+
+	fs.BoolVar(&cfg.submodules, "submodules", cfg.submodules, "run the task in every submodule that defines it")
+	fs.BoolVar(&cfg.worktrees, "worktrees", cfg.worktrees, "run the task in every worktree that defines it")
+
+	fs.BoolVar(&cfg.detach, "detach", cfg.detach, "leave service tasks running once the run has finished")
+
+	fs.IntVar(&cfg.jobs, "j", cfg.jobs, "the number of scripts that may run at the same time")
+	fs.IntVar(&cfg.jobs, "jobs", cfg.jobs, "the number of scripts that may run at the same time")
+
+	fs.BoolVar(&cfg.resume, "resume", cfg.resume, "skip the tasks that succeeded in the last failed run of the task")
+
+	fs.StringVar(&cfg.changedSince, "changed-since", cfg.changedSince,
+		"only run tasks affected by files changed since this git ref")
+
+	fs.StringVar(&cfg.cache, "cache", cfg.cache,
+		"restore the outputs of tasks from this cache, a directory or an http, s3 or gs URL")
+	fs.StringVar(&cfg.cacheMode, "cache-mode", cfg.cacheMode, "whether to store outputs in the cache, read-only or upload")
+	fs.BoolVar(&cfg.noGitignore, "no-gitignore", cfg.noGitignore,
+		"hash the files ignored by .gitignore files as sources of tasks")
+	fs.BoolVar(&cfg.explain, "explain", cfg.explain,
+		"print the sources of each task added, removed or modified since its last successful run")
+
+	fs.StringVar(&cfg.resultFile, "result-file", cfg.resultFile, "write a JSON report of the run to this file")
+	fs.StringVar(&cfg.traceOut, "trace-out", cfg.traceOut, "write a Chrome trace of the run to this file")
+	fs.StringVar(&cfg.record, "record", cfg.record,
+		"keep a recording of the output of each task that fails in this directory")
+	fs.Var(&cfg.reports, "report", "write a report of the run, junit=<path> or json=<path>, can be repeated")
+	fs.BoolVar(&cfg.bell, "bell", cfg.bell, "ring the terminal bell once the run has finished")
+	fs.BoolVar(&cfg.summary, "summary", cfg.summary, "print a summary of the run once it has finished")
+	fs.BoolVar(&cfg.keepLastRun, "keep-last-run", cfg.keepLastRun, "keep the report and logs of the run for xc report")
+	fs.StringVar(&cfg.events, "events", cfg.events, "write a stream of events as the tasks run, ndjson or bep")
+	fs.IntVar(&cfg.eventsFD, "events-fd", cfg.eventsFD, "the file descriptor events are written to")
+
+	fs.BoolVar(&cfg.noDeps, "no-deps", cfg.noDeps, "run the task without the tasks it requires")
+	fs.StringVar(&cfg.only, "only", cfg.only, "only run these comma separated tasks of the run, and skip the others")
+	fs.StringVar(&cfg.from, "from", cfg.from, "start the run from this task, skipping the tasks that run before it")
+	fs.BoolVar(&cfg.approve, "approve", cfg.approve, "approve the tasks with an approval attribute without asking")
+	fs.StringVar(&cfg.shard, "shard", cfg.shard,
+		"run one shard of the leaf tasks of the run, such as 2/5 for the second of five")
+
+	fs.StringVar(&cfg.dirOverride, "dir", cfg.dirOverride, "override the directory of the task")
+	fs.Var(&cfg.envOverrides, "e", "set an environment variable of the task, KEY=VALUE, can be repeated")
+	fs.Var(&cfg.envOverrides, "env", "set an environment variable of the task, KEY=VALUE, can be repeated")
+	fs.StringVar(&cfg.runOverride, "run", cfg.runOverride, "override the run behaviour of the task, always or once")
+	fs.StringVar(&cfg.profile, "profile", cfg.profile,
+		"add the environment variables of a profile defined in the task file")
+}
+
+// xc run [flags] <task> [inputs...]
+func runCommand(ctx context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	runFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		return errRunUsage
+	}
+	if cfg.noColor {
+		terminal.DisableColor()
+	}
+	if cfg.accessible {
+		terminal.EnableAccessible()
+	}
+	if fs.NArg() == 0 {
+		return errRunUsage
+	}
+	return runTask(ctx, cfg, tasks, dir, fs.Args())
+}
+
+// runTask runs the task named by the first of args, with the rest as its inputs.
+func runTask(ctx context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	if cfg.submodules || cfg.worktrees {
+		return runInRepos(ctx, cfg, dir, args)
+	}
+	if _, err := profileEnv(cfg); err != nil {
+		return err
+	}
+	tasks, err := applyOverrides(tasks, args[0], cfg)
+	if err != nil {
+		return err
+	}
+	ta, ok := tasks.Get(args[0])
+	if !ok {
+		return errTaskNotFound(cfg, tasks, dir, args[0])
+	}
+	if err = checkSelected(cfg, tasks, dir); err != nil {
+		return err
+	}
+	// xc -display task1
+	if cfg.display {
+		if terminal.Color(os.Stdout) {
+			ta.Script = terminal.Highlight(ta.Script, ta.Language)
+			ta.Deferred = terminal.Highlight(ta.Deferred, ta.DeferredLanguage)
+		}
+		ta.Display(os.Stdout)
+		return nil
+	}
+	opts, flush := withTracing(runnerOptions(cfg))
+	defer flush()
+	skipOpts, err := skipOptions(ctx, cfg, dir)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, skipOpts...)
+	reports, err := parseReports(cfg.reports, cfg.resultFile)
+	if err != nil {
+		return err
+	}
+	recorder := result.NewRecorder(args[0], args[1:])
+	summary := cfg.summary || cfg.file.Summary
+	if cfg.record != "" {
+		if err = os.MkdirAll(cfg.record, 0o755); err != nil {
+			return fmt.Errorf("xc: failed to create recording directory: %w", err)
+		}
+		recorder.Record(cfg.record)
+	}
+	keepLastRun := (cfg.keepLastRun || cfg.file.KeepLastRun) && !cfg.dryRun
+	// The output of tasks is only captured when it is kept, as scripts then write to a pipe rather than the terminal.
+	if len(reports) > 0 || keepLastRun {
+		recorder.CaptureOutput()
+	}
+	opts = append(opts, run.WithObserver(recorder))
+	stream, err := eventStream(cfg, args)
+	if err != nil {
+		return err
+	}
+	if stream != nil {
+		opts = append(opts, run.WithObserver(stream))
+	}
+	if terminal.Accessible() {
+		reporter := progress.New(os.Stderr)
+		opts = append(opts, run.WithObserver(reporter))
+		defer reporter.Start(progress.DefaultInterval)()
+	}
+	var trace *tracing.ChromeTrace
+	if cfg.traceOut != "" {
+		trace = tracing.NewChromeTrace(args[0], args[1:])
+		opts = append(opts, run.WithObserver(trace))
+	}
+	runner, err := run.NewRunner(tasks, dir, opts...)
+	if err != nil {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	err = runner.Run(ctx, args[0], args[1:])
+	writeReports(reports, recorder, runner.RunID(), err)
+	if keepLastRun {
+		if werr := recorder.WriteLastRun(result.LastRunDir(dir, args[0]), runner.RunID(), err); werr != nil {
+			log.Printf("xc: %v", werr)
+		}
+	}
+	if trace != nil {
+		if werr := trace.WriteFile(cfg.traceOut); werr != nil {
+			log.Printf("xc: %v", werr)
+		}
+	}
+	if summary {
+		_ = result.WriteSummary(os.Stderr, recorder.Report(runner.RunID(), err))
+	} else if cfg.record != "" {
+		_ = result.WriteRecordings(os.Stderr, recorder.Report(runner.RunID(), err))
+	}
+	if cfg.bell || cfg.file.Bell {
+		fmt.Fprint(os.Stderr, "\a")
+	}
+	if stream != nil {
+		stream.RunFinished(args[0], args[1:], runner.RunID(), err)
+	}
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	return nil
+}
+
+// skipOptions returns the runner options that skip tasks: those not affected by the changes since -changed-since,
+// those whose outputs are in the -cache and those in other shards than -shard.
+func skipOptions(ctx context.Context, cfg config, dir string) ([]run.Option, error) {
+	var opts []run.Option
+	if cfg.shard != "" {
+		index, count, err := run.ParseShard(cfg.shard)
+		if err != nil {
+			return nil, fmt.Errorf("xc: %w", err)
+		}
+		opts = append(opts, run.WithShard(index, count))
+	}
+	if cfg.changedSince != "" {
+		files, err := git.ChangedFiles(ctx, dir, cfg.changedSince)
+		if err != nil {
+			return nil, fmt.Errorf("xc: failed to find changed files: %w", err)
+		}
+		opts = append(opts, run.WithChangedFiles(files))
+	}
+	if cfg.cache != "" {
+		mode, err := cache.ParseMode(cfg.cacheMode)
+		if err != nil {
+			return nil, fmt.Errorf("xc: %w", err)
+		}
+		b, err := cache.Open(cfg.cache)
+		if err != nil {
+			return nil, fmt.Errorf("xc: failed to open the cache: %w", err)
+		}
+		opts = append(opts, run.WithCache(b, mode))
+	}
+	if cfg.noGitignore {
+		opts = append(opts, run.WithoutGitignore())
+	}
+	return opts, nil
+}
+
+// onlyTasks returns the names of the tasks given to -only.
+func onlyTasks(cfg config) []string {
+	var names []string
+	for _, name := range strings.Split(cfg.only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkSelected returns an error if a task given to -only or -from is not defined.
+func checkSelected(cfg config, tasks models.Tasks, dir string) error {
+	names := onlyTasks(cfg)
+	if cfg.from != "" {
+		names = append(names, cfg.from)
+	}
+	for _, name := range names {
+		if _, ok := tasks.Get(name); !ok {
+			return errTaskNotFound(cfg, tasks, dir, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/parser"
+	"github.com/joerdav/xc/search"
+)
+
+var errSearchUsage = errors.New("usage: xc search <query>")
+
+// xc search <query>
+func searchCommand(_ context.Context, cfg config, _ models.Tasks, dir string, args []string) error {
+	if len(args) == 0 {
+		return errSearchUsage
+	}
+	query := strings.Join(args, " ")
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current directory: %w", err)
+	}
+	var matches []search.Match
+	err = walkTaskFiles(dir, cfg.heading, func(path string, src []byte, tasks models.Tasks) {
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			path = rel
+		}
+		matches = append(matches, search.File(path, src, tasks, query)...)
+	})
+	if err != nil {
+		return err
+	}
+	search.Sort(matches)
+	for _, m := range matches {
+		if m.Text == "" {
+			fmt.Printf("%s:%d: %s\n", m.File, m.Line, m.Task)
+			continue
+		}
+		fmt.Printf("%s:%d: %s: %s\n", m.File, m.Line, m.Task, m.Text)
+	}
+	return nil
+}
+
+// skipDirs are not searched for task files.
+var skipDirs = map[string]bool{"node_modules": true, "vendor": true}
+
+// walkTaskFiles calls fn for every markdown file under dir containing tasks under heading.
+func walkTaskFiles(dir, heading string, fn func(path string, src []byte, tasks models.Tasks)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(d.Name(), ".") || skipDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("xc error opening file: %w", err)
+		}
+		p, err := parser.NewParser(bytes.NewReader(src), heading)
+		if err != nil {
+			return nil
+		}
+		// Files that fail to parse, such as documentation containing examples, are skipped.
+		// Tasks with the same name as an earlier task are not searched.
+		p.Duplicates(models.DuplicatesFirstWins)
+		tasks, err := p.Parse()
+		if err != nil {
+			return nil
+		}
+		fn(path, src, tasks)
+		return nil
+	})
+}
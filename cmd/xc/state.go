@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+var errStateUsage = errors.New("usage: xc state clear")
+
+// xc state clear
+func stateCommand(_ context.Context, _ config, _ models.Tasks, dir string, args []string) error {
+	if len(args) != 1 || args[0] != "clear" {
+		return errStateUsage
+	}
+	if err := os.RemoveAll(run.StateDir(dir)); err != nil {
+		return fmt.Errorf("failed to clear state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/stats"
+)
+
+var errStatsUsage = errors.New("usage: xc stats")
+
+// xc stats
+func statsCommand(_ context.Context, _ config, tasks models.Tasks, _ string, args []string) error {
+	if len(args) != 0 {
+		return errStatsUsage
+	}
+	s, err := stats.Compute(tasks)
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	return s.Write(os.Stdout)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/search"
+)
+
+// errTaskNotFound returns an error for a task that does not exist, suggesting the closest task names.
+// If no task in the task file is close, tasks in other markdown files in the repository are suggested with their file.
+func errTaskNotFound(cfg config, tasks models.Tasks, dir, name string) error {
+	if s := search.Suggest(name, taskNames(tasks)); len(s) > 0 {
+		return fmt.Errorf("xc: task %q not found, did you mean '%s'?", name, s[0])
+	}
+	taskFile := cfg.filename
+	if taskFile == "" {
+		taskFile = filepath.Join(dir, "README.md")
+	}
+	taskFile, _ = filepath.Abs(taskFile)
+	cwd, _ := os.Getwd()
+	var best, bestFile string
+	_ = walkTaskFiles(repoRoot(dir), cfg.heading, func(path string, _ []byte, tasks models.Tasks) {
+		if best != "" {
+			return
+		}
+		if abs, _ := filepath.Abs(path); abs == taskFile {
+			return
+		}
+		if s := search.Suggest(name, taskNames(tasks)); len(s) > 0 {
+			best, bestFile = s[0], path
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				bestFile = rel
+			}
+		}
+	})
+	if best != "" {
+		return fmt.Errorf("xc: task %q not found, did you mean '%s'? (defined in %s)", name, best, bestFile)
+	}
+	return fmt.Errorf("xc: task %q not found", name)
+}
+
+// repoRoot returns the root of the git repository containing dir, or dir if it is not in one.
+func repoRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	for curr := abs; ; curr = filepath.Dir(curr) {
+		if _, err := os.Stat(filepath.Join(curr, ".git")); err == nil {
+			return curr
+		}
+		if filepath.Dir(curr) == curr {
+			return dir
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+// xc up [service...]
+func upCommand(ctx context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	runner, err := run.NewRunner(tasks, dir, runnerOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	if err = runner.Up(ctx, args); err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	return nil
+}
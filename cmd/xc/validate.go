@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joerdav/xc/lint"
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/parser"
+)
+
+var (
+	errInvalidTaskFile = errors.New("xc: the task file is invalid")
+	errValidateUsage   = errors.New("usage: xc ci-validate [-shellcheck]")
+)
+
+// ciValidate parses and lints the task file and the files it includes,
+// printing each issue as a GitHub Actions annotation.
+// With -shellcheck the scripts of the tasks are also checked with shellcheck.
+// It returns an error if the task file cannot be parsed or has an issue of error severity.
+//
+// xc ci-validate [-shellcheck]
+func ciValidate(ctx context.Context, cfg config, args []string) error {
+	fs := flag.NewFlagSet("ci-validate", flag.ContinueOnError)
+	shellcheck := fs.Bool("shellcheck", false, "check the scripts of the tasks with shellcheck")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errValidateUsage
+	}
+	path, err := findTaskFile(cfg.filename)
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current directory: %w", err)
+	}
+	display := func(path string) string {
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			return rel
+		}
+		return path
+	}
+	tasks, fc, err := parseFile(nil, path, cfg.heading)
+	if err == nil {
+		files := map[string]string{}
+		for _, t := range tasks {
+			files[t.Name] = path
+		}
+		dir := filepath.Dir(path)
+		abs, _ := filepath.Abs(path)
+		seen := map[string]bool{abs: true}
+		local, _ := splitIncludes(fc.Includes)
+		if tasks, err = includeTasks(nil, tasks, dir, dir, local, seen, files); err == nil {
+			tasks, err = lockedTasks(nil, tasks, dir, fc, seen, files)
+		}
+		if err == nil {
+			fc.Duplicates, err = duplicatePolicy(cfg, fc)
+		}
+		if err == nil {
+			// Tasks with the same name are reported by lint, unless the policy chooses between them.
+			if fc.Duplicates != models.DuplicatesError {
+				tasks, _ = parser.Dedupe(tasks, fc.Duplicates)
+			}
+			issues := lint.Check(tasks)
+			if *shellcheck {
+				scripts, err := lint.ShellCheck(ctx, tasks)
+				if err != nil {
+					return fmt.Errorf("xc: %w", err)
+				}
+				issues = append(issues, scripts...)
+			}
+			return reportIssues(issues, func(t string) string { return display(files[t]) })
+		}
+	}
+	// Errors from included files are reported on the task file as their line is in another file.
+	issue := lint.Issue{Severity: lint.SeverityError, Message: err.Error()}
+	var le *parser.LineError
+	if errors.As(err, &le) && len(fc.Includes) == 0 && len(fc.Uses) == 0 {
+		issue.Line = le.Line
+	}
+	return reportIssues([]lint.Issue{issue}, func(string) string { return display(path) })
+}
+
+// reportIssues prints issues as annotations followed by a summary, fileOf returns the file a task is defined in.
+func reportIssues(issues []lint.Issue, fileOf func(task string) string) error {
+	var errs, warnings int
+	for _, i := range issues {
+		fmt.Println(i.GitHubAnnotation(fileOf(i.Task)))
+		if i.Severity == lint.SeverityError {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+	fmt.Printf("%d errors, %d warnings\n", errs, warnings)
+	if errs > 0 {
+		return errInvalidTaskFile
+	}
+	return nil
+}
+
+// findTaskFile returns filename, or the README.md in the current directory or the closest parent directory with one.
+func findTaskFile(filename string) (string, error) {
+	if filename != "" {
+		return filename, nil
+	}
+	curr, err := filepath.Abs(".")
+	if err != nil {
+		return "", fmt.Errorf("error getting current directory: %w", err)
+	}
+	for {
+		rm := filepath.Join(curr, "README.md")
+		if _, err := os.Stat(rm); err == nil {
+			return rm, nil
+		}
+		if _, err := os.Stat(filepath.Join(curr, ".git")); err == nil || filepath.Dir(curr) == curr {
+			return "", ErrNoMarkdownFile
+		}
+		curr = filepath.Dir(curr)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/joerdav/xc/tools"
+)
+
+var versionRe = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(\S*)`)
+
+// checkMinVersion returns an error if this xc is older than min, the oldest version supported by the task file at path.
+// Development builds, without a version or with a pre-release version, are assumed to be new enough.
+func checkMinVersion(path, min string) error {
+	if min == "" {
+		return nil
+	}
+	m := versionRe.FindStringSubmatch(getVersion())
+	if m == nil || m[2] != "" {
+		return nil
+	}
+	if tools.Compare(m[1], min) < 0 {
+		return fmt.Errorf("xc: %s requires xc %s or later, this is xc %s: upgrade xc to use it", path, min, m[1])
+	}
+	return nil
+}
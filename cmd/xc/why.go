@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+	"github.com/joerdav/xc/terminal"
+)
+
+var errWhyUsage = errors.New("usage: xc why [flags] <task> [inputs...]")
+
+// xc why [flags] <task> [inputs...]
+//
+// It takes the same flags as xc run, as -changed-since, -cache and -resume change which tasks run.
+func whyCommand(ctx context.Context, cfg config, tasks models.Tasks, dir string, args []string) error {
+	fs := flag.NewFlagSet("why", flag.ContinueOnError)
+	runFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errWhyUsage
+	}
+	if cfg.noColor {
+		terminal.DisableColor()
+	}
+	if cfg.accessible {
+		terminal.EnableAccessible()
+	}
+	name := fs.Arg(0)
+	tasks, err := applyOverrides(tasks, name, cfg)
+	if err != nil {
+		return err
+	}
+	if _, ok := tasks.Get(name); !ok {
+		return errTaskNotFound(cfg, tasks, dir, name)
+	}
+	if err = checkSelected(cfg, tasks, dir); err != nil {
+		return err
+	}
+	opts, err := skipOptions(ctx, cfg, dir)
+	if err != nil {
+		return err
+	}
+	runner, err := run.NewRunner(tasks, dir, append(runnerOptions(cfg), opts...)...)
+	if err != nil {
+		return fmt.Errorf("xc parse error: %w", err)
+	}
+	reasons, err := runner.Why(ctx, name, fs.Args()[1:])
+	if err != nil {
+		return fmt.Errorf("xc: %w", err)
+	}
+	color := terminal.Color(os.Stdout)
+	for _, r := range reasons {
+		indent := strings.Repeat("  ", r.Depth)
+		verdict, style := "runs", terminal.Green
+		if !r.Run {
+			verdict, style = "skipped", terminal.Dim
+		}
+		if color {
+			verdict = style.Paint(verdict)
+		}
+		fmt.Printf("%s%s: %s\n", indent, r.Task, verdict)
+		for _, b := range r.Because {
+			fmt.Printf("%s  - %s\n", indent, b)
+		}
+	}
+	return nil
+}
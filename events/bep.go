@@ -0,0 +1,226 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+// BEP is a run.Observer that writes the events of a run in the Build Event Protocol of Bazel,
+// as the newline-delimited JSON written by bazel --build_event_json_file,
+// so the results of runs can be sent to the build result UIs and pipelines that read it.
+//
+// Each task is a target labelled //:<task>. Its output is sent in progress events,
+// and a task that is skipped is a target completed with an aborted event with the reason SKIPPED.
+type BEP struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	now      func() time.Time
+	start    time.Time
+	progress int
+	// runs counts how many times each task has started or been skipped, as each needs its own event ID.
+	runs map[string]int
+}
+
+var (
+	_ run.SkipObserver   = &BEP{}
+	_ run.OutputObserver = &BEP{}
+)
+
+// buildEvent is a BuildEvent message of the Build Event Protocol.
+type buildEvent struct {
+	ID          map[string]any   `json:"id"`
+	Children    []map[string]any `json:"children,omitempty"`
+	LastMessage bool             `json:"lastMessage,omitempty"`
+	// Payload is the field of the event named by the type of its ID, such as started.
+	Payload map[string]any `json:"-"`
+}
+
+func (e buildEvent) MarshalJSON() ([]byte, error) {
+	m := map[string]any{"id": e.ID}
+	if len(e.Children) > 0 {
+		m["children"] = e.Children
+	}
+	if e.LastMessage {
+		m["lastMessage"] = true
+	}
+	for k, v := range e.Payload {
+		m[k] = v
+	}
+	return json.Marshal(m)
+}
+
+// NewBEP returns a BEP that writes to w, starting with the events of the invocation of xc to run a task with inputs.
+func NewBEP(w io.Writer, taskName string, inputs []string) *BEP {
+	b := &BEP{enc: json.NewEncoder(w), now: time.Now, runs: map[string]int{}}
+	b.start = b.now()
+	wd, _ := os.Getwd()
+	pattern := map[string]any{"pattern": map[string]any{"pattern": append([]string{taskName}, inputs...)}}
+	b.send(buildEvent{
+		ID:       map[string]any{"started": map[string]any{}},
+		Children: []map[string]any{pattern, b.progressID(0), {"buildFinished": map[string]any{}}},
+		Payload: map[string]any{"started": map[string]any{
+			"uuid":             uuid(),
+			"startTimeMillis":  millis(b.start),
+			"startTime":        b.start.UTC().Format(time.RFC3339Nano),
+			"buildToolVersion": "xc",
+			"command":          "run",
+			"workingDirectory": wd,
+		}},
+	})
+	b.send(buildEvent{ID: pattern, Payload: map[string]any{"expanded": map[string]any{}}})
+	return b
+}
+
+func (b *BEP) send(e buildEvent) {
+	// A reader that has gone away must not stop the run.
+	_ = b.enc.Encode(e)
+}
+
+func (b *BEP) progressID(n int) map[string]any {
+	return map[string]any{"progress": map[string]any{"opaqueCount": n}}
+}
+
+// announce sends the next progress event, with stdout as its output, announcing ids and the progress event after it
+// unless last is set. b.mu must be held.
+func (b *BEP) announce(stdout string, last bool, ids ...map[string]any) {
+	id := b.progressID(b.progress)
+	b.progress++
+	if !last {
+		ids = append([]map[string]any{b.progressID(b.progress)}, ids...)
+	}
+	payload := map[string]any{}
+	if stdout != "" {
+		payload["stdout"] = stdout
+	}
+	b.send(buildEvent{ID: id, Children: ids, Payload: map[string]any{"progress": payload}})
+}
+
+// target announces and sends the events that configure a task, returning the ID of the event that completes it.
+// b.mu must be held.
+func (b *BEP) target(name string) map[string]any {
+	label := "//:" + name
+	b.runs[name]++
+	n := b.runs[name]
+	completed := map[string]any{"targetCompleted": map[string]any{
+		"label":         label,
+		"configuration": map[string]any{"id": "run-" + strconv.Itoa(n)},
+	}}
+	// A task that has already run is only configured once, each run completes it again.
+	if n > 1 {
+		b.announce("", false, completed)
+		return completed
+	}
+	configured := map[string]any{"targetConfigured": map[string]any{"label": label}}
+	b.announce("", false, configured)
+	b.send(buildEvent{
+		ID:       configured,
+		Children: []map[string]any{completed},
+		Payload:  map[string]any{"configured": map[string]any{"targetKind": "xc_task rule"}},
+	})
+	return completed
+}
+
+type bepTask struct {
+	id  map[string]any
+	out *lineWriter
+}
+
+// TaskStarted announces the task as a target.
+func (b *BEP) TaskStarted(ctx context.Context, t models.Task) context.Context {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := &bepTask{id: b.target(t.Name)}
+	st.out = &lineWriter{line: func(line string) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.announce(line+"\n", false)
+	}}
+	return context.WithValue(ctx, taskKey{}, st)
+}
+
+// TaskOutput returns a writer that sends each line written to it in a progress event.
+func (b *BEP) TaskOutput(ctx context.Context, _ models.Task) io.Writer {
+	st, ok := ctx.Value(taskKey{}).(*bepTask)
+	if !ok {
+		return nil
+	}
+	return st.out
+}
+
+// TaskSkipped sends the target of the task as aborted, with the reason SKIPPED.
+func (b *BEP) TaskSkipped(_ context.Context, t models.Task, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.target(t.Name)
+	aborted := map[string]any{"reason": "SKIPPED", "description": reason}
+	b.send(buildEvent{ID: id, Payload: map[string]any{"aborted": aborted}})
+}
+
+// TaskFinished sends the target of the task as completed.
+func (b *BEP) TaskFinished(ctx context.Context, t models.Task, err error) {
+	st, ok := ctx.Value(taskKey{}).(*bepTask)
+	if !ok {
+		return
+	}
+	st.out.Flush()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	completed := map[string]any{"success": err == nil}
+	if err != nil {
+		completed["failureDetail"] = map[string]any{"message": err.Error()}
+	}
+	b.send(buildEvent{ID: st.id, Payload: map[string]any{"completed": completed}})
+}
+
+// RunFinished sends the last events of the run of task with inputs, err is the error returned by the run.
+// The run ID is sent as the metadata XC_RUN_ID.
+func (b *BEP) RunFinished(_ string, _ []string, runID string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	metadata := map[string]any{"buildMetadata": map[string]any{}}
+	b.announce("", true, metadata)
+	b.send(buildEvent{ID: metadata, Payload: map[string]any{"buildMetadata": map[string]any{
+		"metadata": map[string]string{"XC_RUN_ID": runID},
+	}}})
+	exitCode := map[string]any{"name": "SUCCESS", "code": 0}
+	if err != nil {
+		exitCode = map[string]any{"name": "BUILD_FAILURE", "code": 1}
+	}
+	end := b.now()
+	b.send(buildEvent{
+		ID:          map[string]any{"buildFinished": map[string]any{}},
+		LastMessage: true,
+		Payload: map[string]any{"finished": map[string]any{
+			"overallSuccess":   err == nil,
+			"exitCode":         exitCode,
+			"finishTimeMillis": millis(end),
+			"finishTime":       end.UTC().Format(time.RFC3339Nano),
+		}},
+	})
+}
+
+// millis returns t in milliseconds since the epoch, as a string as int64 fields are in the JSON of protocol buffers.
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// uuid returns a random version 4 UUID, which identifies the invocation.
+func uuid() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := fmt.Sprintf("%x", b)
+	return strings.Join([]string{h[:8], h[8:12], h[12:16], h[16:20], h[20:]}, "-")
+}
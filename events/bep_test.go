@@ -0,0 +1,83 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+func TestBEP(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Script: "echo one", ShellOpts: []string{}},
+		{Name: "lint", Script: "lint", Language: "fake"},
+		{Name: "test", Script: "test", Language: "fake", DependsOn: []string{"build", "build"}, Steps: []string{"lint"}},
+	}
+	var out bytes.Buffer
+	bep := NewBEP(&out, "test", []string{"a"})
+	runner, err := run.NewRunner(tasks, t.TempDir(), run.WithObserver(bep), run.WithExecutor("fake", failingRunner{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := runner.Run(context.Background(), "test", []string{"a"})
+	if runErr == nil {
+		t.Fatal("expected the run to fail")
+	}
+	bep.RunFinished("test", []string{"a"}, runner.RunID(), runErr)
+
+	if !bytes.Contains(out.Bytes(), []byte(`"stdout":"one\n"`)) {
+		t.Fatalf("expected the output of build in a progress event got %s", out.String())
+	}
+	// Every event but the first must have been announced as a child of an earlier event,
+	// and every announced event must be sent.
+	announced := map[string]bool{}
+	var kinds []string
+	var last map[string]json.RawMessage
+	scanner := bufio.NewScanner(&out)
+	for i := 0; scanner.Scan(); i++ {
+		var e struct {
+			ID       json.RawMessage   `json:"id"`
+			Children []json.RawMessage `json:"children"`
+		}
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		if i > 0 && !announced[string(e.ID)] {
+			t.Fatalf("event %s was not announced", e.ID)
+		}
+		delete(announced, string(e.ID))
+		for _, c := range e.Children {
+			announced[string(c)] = true
+		}
+		last = nil
+		if err = json.Unmarshal(scanner.Bytes(), &last); err != nil {
+			t.Fatal(err)
+		}
+		for k := range last {
+			if k != "id" && k != "children" && k != "lastMessage" {
+				kinds = append(kinds, k)
+			}
+		}
+	}
+	if len(announced) > 0 {
+		t.Fatalf("events were announced but not sent: %v", announced)
+	}
+	if string(last["lastMessage"]) != "true" || string(last["finished"]) == "" {
+		t.Fatalf("expected the last event to finish the build got %v", last)
+	}
+	counts := map[string]int{}
+	for _, k := range kinds {
+		counts[k]++
+	}
+	if counts["started"] != 1 || counts["finished"] != 1 || counts["buildMetadata"] != 1 {
+		t.Fatalf("expected one started, finished and buildMetadata event got %v", kinds)
+	}
+	// build is required twice, so is completed twice.
+	if counts["configured"] != 3 || counts["completed"] != 4 || counts["aborted"] != 0 {
+		t.Fatalf("expected the three tasks to be configured and completed got %v", kinds)
+	}
+}
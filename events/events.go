@@ -0,0 +1,172 @@
+// Package events writes a stream of newline-delimited JSON events as tasks run,
+// for programs that embed xc to follow a run without parsing its output.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+// Type is the type of an event.
+type Type string
+
+const (
+	// TaskStarted is sent when a task starts, before the tasks it requires.
+	TaskStarted Type = "task_started"
+	// TaskOutput is sent for each line written by the script of a task.
+	TaskOutput Type = "task_output"
+	// TaskSkipped is sent when a task does not run, Reason says why.
+	TaskSkipped Type = "task_skipped"
+	// TaskFinished is sent when a task has finished, successfully or not.
+	TaskFinished Type = "task_finished"
+	// RunFinished is the last event of a run.
+	RunFinished Type = "run_finished"
+)
+
+// Event is a line of the stream. Fields that do not apply to the Type are omitted.
+type Event struct {
+	Type     Type      `json:"type"`
+	Time     time.Time `json:"time"`
+	Task     string    `json:"task"`
+	Inputs   []string  `json:"inputs,omitempty"`
+	RunID    string    `json:"runId,omitempty"`
+	Line     *string   `json:"line,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Success  *bool     `json:"success,omitempty"`
+	Duration *float64  `json:"durationSeconds,omitempty"`
+	ExitCode *int      `json:"exitCode,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Stream is a run.Observer that writes an Event to a writer for each task that starts,
+// writes output, is skipped or finishes.
+type Stream struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	now   func() time.Time
+}
+
+var (
+	_ run.SkipObserver   = &Stream{}
+	_ run.OutputObserver = &Stream{}
+)
+
+type taskKey struct{}
+
+// task is the state of a task that has started.
+type task struct {
+	name  string
+	start time.Time
+	out   *lineWriter
+}
+
+// NewStream returns a Stream that writes to w.
+func NewStream(w io.Writer) *Stream {
+	s := &Stream{enc: json.NewEncoder(w), now: time.Now}
+	s.start = s.now()
+	return s
+}
+
+func (s *Stream) send(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.Time = s.now()
+	// A reader that has gone away must not stop the run.
+	_ = s.enc.Encode(e)
+}
+
+// TaskStarted sends a task_started event.
+func (s *Stream) TaskStarted(ctx context.Context, t models.Task) context.Context {
+	st := &task{name: t.Name, start: s.now()}
+	st.out = &lineWriter{line: func(line string) {
+		s.send(Event{Type: TaskOutput, Task: st.name, Line: &line})
+	}}
+	s.send(Event{Type: TaskStarted, Task: t.Name})
+	return context.WithValue(ctx, taskKey{}, st)
+}
+
+// TaskOutput returns a writer that sends a task_output event for each line written to it.
+func (s *Stream) TaskOutput(ctx context.Context, _ models.Task) io.Writer {
+	st, ok := ctx.Value(taskKey{}).(*task)
+	if !ok {
+		return nil
+	}
+	return st.out
+}
+
+// TaskSkipped sends a task_skipped event.
+func (s *Stream) TaskSkipped(_ context.Context, t models.Task, reason string) {
+	s.send(Event{Type: TaskSkipped, Task: t.Name, Reason: reason})
+}
+
+// TaskFinished sends the last line of output of the task if it did not end in a newline,
+// followed by a task_finished event.
+func (s *Stream) TaskFinished(ctx context.Context, t models.Task, err error) {
+	e := Event{Type: TaskFinished, Task: t.Name}
+	if st, ok := ctx.Value(taskKey{}).(*task); ok {
+		st.out.Flush()
+		d := s.now().Sub(st.start).Seconds()
+		e.Duration = &d
+	}
+	s.send(finished(e, err))
+}
+
+// RunFinished sends a run_finished event for the run of task with inputs, err is the error returned by the run.
+func (s *Stream) RunFinished(taskName string, inputs []string, runID string, err error) {
+	d := s.now().Sub(s.start).Seconds()
+	s.send(finished(Event{Type: RunFinished, Task: taskName, Inputs: inputs, RunID: runID, Duration: &d}, err))
+}
+
+// finished sets the outcome of e from err.
+func finished(e Event, err error) Event {
+	success := err == nil
+	e.Success = &success
+	if err != nil {
+		e.Error = err.Error()
+		if code, ok := run.ExitCode(err); ok {
+			e.ExitCode = &code
+		}
+	}
+	return e
+}
+
+// lineWriter calls line for each complete line written to it, without the newline.
+// It is safe for concurrent use.
+type lineWriter struct {
+	mu   sync.Mutex
+	buf  []byte
+	line func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush calls line with what has been written since the last newline, if anything.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.line(string(w.buf))
+		w.buf = nil
+	}
+}
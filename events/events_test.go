@@ -0,0 +1,84 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+	"mvdan.cc/sh/v3/interp"
+)
+
+type failingRunner struct{}
+
+func (failingRunner) Execute(context.Context, string, []string, []string, string) error {
+	return interp.NewExitStatus(3)
+}
+
+func TestStream(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Script: "echo one\nprintf two", ShellOpts: []string{}},
+		{Name: "test", Script: "test", Language: "fake", DependsOn: []string{"build"}},
+	}
+	var out bytes.Buffer
+	stream := NewStream(&out)
+	runner, err := run.NewRunner(tasks, t.TempDir(), run.WithObserver(stream), run.WithExecutor("fake", failingRunner{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := runner.Run(context.Background(), "test", []string{"a"})
+	if runErr == nil {
+		t.Fatal("expected the run to fail")
+	}
+	stream.RunFinished("test", []string{"a"}, runner.RunID(), runErr)
+
+	var events []Event
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var e Event
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	expected := []struct {
+		typ     Type
+		task    string
+		line    string
+		success bool
+	}{
+		{typ: TaskStarted, task: "test"},
+		{typ: TaskStarted, task: "build"},
+		{typ: TaskOutput, task: "build", line: "one"},
+		{typ: TaskOutput, task: "build", line: "two"},
+		{typ: TaskFinished, task: "build", success: true},
+		{typ: TaskFinished, task: "test"},
+		{typ: RunFinished, task: "test"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events got %d: %s", len(expected), len(events), out.String())
+	}
+	for i, e := range expected {
+		got := events[i]
+		if got.Type != e.typ || got.Task != e.task || got.Time.IsZero() {
+			t.Fatalf("event %d: expected %s %s got %+v", i, e.typ, e.task, got)
+		}
+		if e.line != "" && (got.Line == nil || *got.Line != e.line) {
+			t.Fatalf("event %d: expected line %q got %v", i, e.line, got.Line)
+		}
+		if e.typ == TaskFinished || e.typ == RunFinished {
+			if got.Success == nil || *got.Success != e.success || got.Duration == nil {
+				t.Fatalf("event %d: expected success %v got %+v", i, e.success, got)
+			}
+		}
+	}
+	if code := events[5].ExitCode; code == nil || *code != 3 {
+		t.Fatalf("expected exit code 3 got %v", code)
+	}
+	if last := events[6]; last.RunID != runner.RunID() || len(last.Inputs) != 1 || last.Error == "" {
+		t.Fatalf("unexpected run_finished event %+v", last)
+	}
+}
@@ -0,0 +1,112 @@
+// Package git finds the files changed in a git repository.
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ChangedFiles returns the absolute paths of the files that differ between the working tree
+// of the repository containing dir and the merge base of ref and HEAD, including untracked files.
+func ChangedFiles(ctx context.Context, dir, ref string) ([]string, error) {
+	root, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+	base, err := git(ctx, dir, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := git(ctx, root, "diff", "--name-only", "-z", strings.TrimSpace(base))
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(ctx, root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	seen := map[string]bool{}
+	for _, f := range strings.Split(changed+untracked, "\x00") {
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		files = append(files, filepath.Join(root, filepath.FromSlash(f)))
+	}
+	return files, nil
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Submodules returns the absolute paths of the checked out submodules of the repository containing dir,
+// including nested submodules, in the order git lists them.
+func Submodules(ctx context.Context, dir string) ([]string, error) {
+	out, err := git(ctx, dir, "submodule", "foreach", "--quiet", "--recursive", `printf '%s/%s\0' "$toplevel" "$sm_path"`)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p != "" {
+			paths = append(paths, filepath.Clean(p))
+		}
+	}
+	return paths, nil
+}
+
+// Worktrees returns the absolute paths of the worktrees of the repository containing dir,
+// the main worktree first, excluding bare and prunable worktrees.
+func Worktrees(ctx context.Context, dir string) ([]string, error) {
+	out, err := git(ctx, dir, "worktree", "list", "--porcelain", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	// Each worktree is a list of NUL terminated attributes, ending with an empty one.
+	for _, wt := range strings.Split(out, "\x00\x00") {
+		var path string
+		usable := true
+		for _, attr := range strings.Split(wt, "\x00") {
+			name, value, _ := strings.Cut(attr, " ")
+			switch name {
+			case "worktree":
+				path = value
+			case "bare", "prunable":
+				usable = false
+			}
+		}
+		if path != "" && usable {
+			paths = append(paths, filepath.Clean(path))
+		}
+	}
+	return paths, nil
+}
+
+// Clone checks out the tag or branch ref of the repository at url into dir, without its history,
+// and returns the commit it points at.
+func Clone(ctx context.Context, url, ref, dir string) (string, error) {
+	if _, err := git(ctx, "", "clone", "--quiet", "--depth", "1", "--branch", ref, "--", url, dir); err != nil {
+		return "", err
+	}
+	commit, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(commit), nil
+}
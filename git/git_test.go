@@ -0,0 +1,147 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run(t, dir, "init", "-q", "-b", "main")
+	write(t, filepath.Join(dir, "a.go"), "a")
+	write(t, filepath.Join(dir, "b.go"), "b")
+	run(t, dir, "add", "-A")
+	run(t, dir, "commit", "-q", "-m", "initial")
+	run(t, dir, "checkout", "-q", "-b", "feature")
+	write(t, filepath.Join(dir, "svc", "c.go"), "c")
+	run(t, dir, "add", "-A")
+	run(t, dir, "commit", "-q", "-m", "add c")
+	write(t, filepath.Join(dir, "a.go"), "changed")
+	write(t, filepath.Join(dir, "new.txt"), "untracked")
+
+	files, err := ChangedFiles(context.Background(), filepath.Join(dir, "svc"), "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, f := range files {
+		r, err := filepath.Rel(root, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	sort.Strings(rel)
+	expected := "a.go,new.txt,svc/c.go"
+	if got := strings.Join(rel, ","); got != expected {
+		t.Fatalf("expected %s got %s", expected, got)
+	}
+	if _, err := ChangedFiles(context.Background(), dir, "missing-ref"); err == nil {
+		t.Fatal("expected error for a missing ref")
+	}
+}
+
+func TestSubmodulesAndWorktrees(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(tmp, "sub")
+	dir := filepath.Join(tmp, "top")
+	for _, d := range []string{sub, dir} {
+		write(t, filepath.Join(d, "README.md"), "tasks")
+		run(t, d, "init", "-q", "-b", "main")
+		run(t, d, "add", "-A")
+		run(t, d, "commit", "-q", "-m", "initial")
+	}
+	run(t, dir, "-c", "protocol.file.allow=always", "submodule", "add", "-q", sub, "libs/sub")
+	run(t, dir, "commit", "-q", "-m", "add sub")
+	run(t, dir, "worktree", "add", "-q", filepath.Join(tmp, "feature"))
+
+	submodules, err := Submodules(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := strings.Join(submodules, ","), filepath.Join(dir, "libs", "sub"); got != expected {
+		t.Fatalf("expected submodules %s got %s", expected, got)
+	}
+	worktrees, err := Worktrees(context.Background(), filepath.Join(dir, "libs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := strings.Join(worktrees, ","), dir+","+filepath.Join(tmp, "feature"); got != expected {
+		t.Fatalf("expected worktrees %s got %s", expected, got)
+	}
+}
+
+func TestClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmp := t.TempDir()
+	repo := filepath.Join(tmp, "repo")
+	write(t, filepath.Join(repo, "README.md"), "v1")
+	run(t, repo, "init", "-q", "-b", "main")
+	run(t, repo, "add", "-A")
+	run(t, repo, "commit", "-q", "-m", "v1")
+	run(t, repo, "tag", "v1")
+	write(t, filepath.Join(repo, "README.md"), "v2")
+	run(t, repo, "commit", "-q", "-am", "v2")
+
+	dir := filepath.Join(tmp, "clone")
+	commit, err := Clone(context.Background(), repo, "v1", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit) != 40 {
+		t.Fatalf("expected a commit hash got %q", commit)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "v1" {
+		t.Fatalf("expected the tagged README.md got %q", b)
+	}
+	if _, err = Clone(context.Background(), repo, "v3", filepath.Join(tmp, "missing")); err == nil {
+		t.Fatal("expected an error for a missing tag")
+	}
+}
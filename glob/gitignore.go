@@ -0,0 +1,71 @@
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Ignore matches slash separated paths against the patterns of .gitignore files.
+// The zero value ignores nothing.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	// pattern is anchored to the directory of the .gitignore file that contains it.
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// Add adds the patterns of a .gitignore file in the directory base, a slash separated path
+// relative to the paths passed to Ignored, or "" for the root.
+// Patterns added later take precedence, so files should be added from the root down.
+func (ig *Ignore) Add(base string, content string) {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Trailing spaces are ignored unless escaped.
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		// A pattern without a slash matches at any depth, otherwise it is relative to base.
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		r.pattern = path.Join(base, strings.TrimPrefix(line, "/"))
+		ig.rules = append(ig.rules, r)
+	}
+}
+
+// Ignored reports whether name, a file or a directory if dir is set, is ignored.
+// As in git, the contents of an ignored directory are not checked,
+// so directories should be tested before the files in them.
+func (ig *Ignore) Ignored(name string, dir bool) bool {
+	ignored := false
+	for _, r := range ig.rules {
+		if r.dirOnly && !dir {
+			continue
+		}
+		if match(strings.Split(r.pattern, "/"), strings.Split(name, "/")) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,50 @@
+// Package glob matches slash separated paths against patterns that can contain `**`.
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether name matches pattern.
+//
+// Patterns use the syntax of path.Match for each path element,
+// and the element `**` matches zero or more elements.
+// A pattern ending in / matches everything inside the directory.
+func Match(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	return match(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func match(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if match(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// Valid reports whether pattern is well formed.
+func Valid(pattern string) bool {
+	for _, p := range strings.Split(pattern, "/") {
+		if _, err := path.Match(p, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		expect        bool
+	}{
+		{"main.go", "main.go", true},
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/xc/main.go", true},
+		{"cmd/**", "cmd/xc/main.go", true},
+		{"cmd/**", "cmd", true},
+		{"cmd/", "cmd/xc/main.go", true},
+		{"cmd/", "cmdx/main.go", false},
+		{"doc/**/*.md", "doc/content/index.md", true},
+		{"doc/**/*.md", "doc/index.md", true},
+		{"doc/**/*.md", "docs/index.md", false},
+		{"go.{mod,sum}", "go.mod", false},
+		{"go.[ms]*", "go.sum", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.expect {
+			t.Errorf("Match(%q, %q)=%v want=%v", tt.pattern, tt.name, got, tt.expect)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	if !Valid("**/*.go") {
+		t.Error("expected **/*.go to be valid")
+	}
+	if Valid("[a-") {
+		t.Error("expected [a- to be invalid")
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	var ig Ignore
+	ig.Add("", "# comment\nnode_modules/\n*.log\n!keep.log\n/build\n\\#notes\n")
+	ig.Add("web", "dist\n/cache/\n")
+	tests := []struct {
+		name   string
+		dir    bool
+		expect bool
+	}{
+		{"node_modules", true, true},
+		{"web/node_modules", true, true},
+		{"node_modules", false, false},
+		{"debug.log", false, true},
+		{"web/debug.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"web/build", true, false},
+		{"#notes", false, true},
+		{"web/dist", false, true},
+		{"web/src/dist", true, true},
+		{"dist", true, false},
+		{"web/cache", true, true},
+		{"web/src/cache", true, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := ig.Ignored(tt.name, tt.dir); got != tt.expect {
+			t.Errorf("Ignored(%q, %v)=%v want=%v", tt.name, tt.dir, got, tt.expect)
+		}
+	}
+}
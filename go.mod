@@ -3,8 +3,13 @@ module github.com/joerdav/xc
 go 1.20
 
 require (
+	github.com/go-sql-driver/mysql v1.7.1
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/posener/complete/v2 v2.0.1-alpha.13
+	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
+	golang.org/x/term v0.3.0
 	mvdan.cc/sh/v3 v3.6.0
 )
 
@@ -14,5 +19,4 @@ require (
 	github.com/posener/script v1.1.5 // indirect
 	golang.org/x/sync v0.1.0 // indirect
 	golang.org/x/sys v0.3.0 // indirect
-	golang.org/x/term v0.3.0 // indirect
 )
@@ -0,0 +1,105 @@
+// Package graph builds the dependency graph of tasks and renders it in various formats.
+package graph
+
+import (
+	"fmt"
+
+	"github.com/joerdav/xc/models"
+)
+
+// Edge is a dependency of one task on another.
+type Edge struct {
+	From, To string
+	// Label is empty for required tasks and the position for steps, e.g. "step 1".
+	Label string
+}
+
+// Graph is a directed graph of tasks, edges point from a task to the tasks it runs.
+type Graph struct {
+	// Nodes are task names in the order they were first reached.
+	Nodes []string
+	Edges []Edge
+}
+
+// New returns the graph reachable from root, or of every task if root is empty.
+//
+// A task required with environment variables, e.g. `deploy with ENVIRONMENT=staging`,
+// is a separate node for each set of variables.
+func New(tasks models.Tasks, root string) (Graph, error) {
+	var g Graph
+	seen := map[string]bool{}
+	var visit func(d models.Dependency) (string, error)
+	visit = func(d models.Dependency) (string, error) {
+		t, ok := tasks.Get(d.Name)
+		if !ok {
+			return "", fmt.Errorf("task %s not found", d.Name)
+		}
+		d.Name = t.Name
+		node := d.Node()
+		if seen[node] {
+			return node, nil
+		}
+		seen[node] = true
+		g.Nodes = append(g.Nodes, node)
+		for _, entry := range t.DependsOn {
+			to, err := visitEntry(visit, entry)
+			if err != nil {
+				return "", err
+			}
+			g.Edges = append(g.Edges, Edge{From: node, To: to})
+		}
+		for i, entry := range t.Steps {
+			to, err := visitEntry(visit, entry)
+			if err != nil {
+				return "", err
+			}
+			g.Edges = append(g.Edges, Edge{From: node, To: to, Label: fmt.Sprintf("step %d", i+1)})
+		}
+		return node, nil
+	}
+	if root != "" {
+		_, err := visit(models.Dependency{Name: root})
+		return g, err
+	}
+	for _, t := range tasks {
+		if _, err := visit(models.Dependency{Name: t.Name}); err != nil {
+			return g, err
+		}
+	}
+	return g, nil
+}
+
+// visitEntry visits a requires or steps entry, ignoring any inputs.
+func visitEntry(visit func(models.Dependency) (string, error), entry string) (string, error) {
+	d, err := models.ParseDependency(entry)
+	if err != nil {
+		return "", err
+	}
+	return visit(models.Dependency{Name: d.Name, Env: d.Env})
+}
+
+// Children returns the edges from a node, in the order they are run.
+func (g Graph) Children(node string) []Edge {
+	var result []Edge
+	for _, e := range g.Edges {
+		if e.From == node {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Roots returns the nodes that no other node points to.
+func (g Graph) Roots() []string {
+	pointed := map[string]bool{}
+	for _, e := range g.Edges {
+		pointed[e.To] = true
+	}
+	var roots []string
+	for _, n := range g.Nodes {
+		if !pointed[n] {
+			roots = append(roots, n)
+		}
+	}
+	return roots
+}
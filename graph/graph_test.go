@@ -0,0 +1,160 @@
+package graph
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+var tasks = models.Tasks{
+	{Name: "release", DependsOn: []string{"setup"}, Steps: []string{"test", "build linux"}},
+	{Name: "setup", Script: "setup"},
+	{Name: "test", Script: "test", DependsOn: []string{"setup"}},
+	{Name: "build", Script: "build", Inputs: []string{"OS"}},
+	{Name: "lint", Script: "lint"},
+}
+
+func TestNew(t *testing.T) {
+	g, err := New(tasks, "Release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(g.Nodes, ",") != "release,setup,test,build" {
+		t.Fatalf("unexpected nodes %v", g.Nodes)
+	}
+	expected := []Edge{
+		{From: "test", To: "setup"},
+		{From: "release", To: "setup"},
+		{From: "release", To: "test", Label: "step 1"},
+		{From: "release", To: "build", Label: "step 2"},
+	}
+	if len(g.Edges) != len(expected) {
+		t.Fatalf("expected edges %v got %v", expected, g.Edges)
+	}
+	for _, e := range expected {
+		found := false
+		for _, ge := range g.Edges {
+			found = found || ge == e
+		}
+		if !found {
+			t.Fatalf("expected edge %v in %v", e, g.Edges)
+		}
+	}
+	all, err := New(tasks, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(all.Roots(), ",") != "release,lint" {
+		t.Fatalf("unexpected roots %v", all.Roots())
+	}
+	if _, err := New(tasks, "fake"); err == nil {
+		t.Fatal("expected error for a missing task")
+	}
+}
+
+func TestNewWithEnv(t *testing.T) {
+	g, err := New(models.Tasks{
+		{Name: "deploy-all", DependsOn: []string{
+			"deploy with ENVIRONMENT=staging",
+			"deploy with ENVIRONMENT=production",
+			"deploy with ENVIRONMENT=staging",
+		}},
+		{Name: "deploy", Script: "deploy", DependsOn: []string{"build"}},
+		{Name: "build", Script: "build"},
+	}, "deploy-all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "deploy-all,deploy with ENVIRONMENT=staging,build,deploy with ENVIRONMENT=production"
+	if got := strings.Join(g.Nodes, ","); got != expected {
+		t.Fatalf("expected nodes %s got %s", expected, got)
+	}
+	if len(g.Edges) != 5 {
+		t.Fatalf("expected 5 edges got %v", g.Edges)
+	}
+}
+
+func render(t *testing.T, f Format) string {
+	t.Helper()
+	g, err := New(tasks, "release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := g.Render(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestText(t *testing.T) {
+	expected := `release
+├── setup
+├── test (step 1)
+│   └── setup
+└── build (step 2)
+`
+	if got := render(t, FormatText); got != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	got := render(t, FormatDOT)
+	for _, expected := range []string{
+		"digraph xc {",
+		`"release" -> "setup";`,
+		`"release" -> "test" [label="step 1"];`,
+	} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("expected %s in:\n%s", expected, got)
+		}
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	got := render(t, FormatMermaid)
+	for _, expected := range []string{
+		"flowchart TD",
+		`task_release["release"]`,
+		"task_release --> task_setup",
+		"task_release -->|step 2| task_build",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("expected %s in:\n%s", expected, got)
+		}
+	}
+}
+
+func TestMermaidIDs(t *testing.T) {
+	g := Graph{Nodes: []string{"build-app", "build_app", "end"}}
+	ids := g.mermaidIDs()
+	if ids["build-app"] != "task_build_app" || ids["build_app"] != "task_build_app_2" || ids["end"] != "task_end" {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	got := render(t, FormatSVG)
+	if !strings.HasPrefix(got, "<svg") {
+		t.Fatalf("expected an svg got:\n%s", got)
+	}
+	for _, n := range []string{">release<", ">setup<", ">test<", ">build<", ">step 1<"} {
+		if !strings.Contains(got, n) {
+			t.Fatalf("expected %s in:\n%s", n, got)
+		}
+	}
+	g, _ := New(tasks, "release")
+	layers := g.layers()
+	if len(layers) != 3 || layers[2][0] != "setup" {
+		t.Fatalf("expected setup to be below test, got %v", layers)
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if err := (Graph{}).Render(&bytes.Buffer{}, "png"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,146 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an output format of a Graph.
+type Format string
+
+const (
+	// FormatText renders the graph as a tree for the terminal.
+	FormatText Format = "text"
+	// FormatDOT renders the graph in the Graphviz DOT language.
+	FormatDOT Format = "dot"
+	// FormatMermaid renders the graph as a Mermaid flowchart.
+	FormatMermaid Format = "mermaid"
+	// FormatSVG renders the graph as an SVG image.
+	FormatSVG Format = "svg"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{FormatText, FormatDOT, FormatMermaid, FormatSVG}
+
+// Render writes g to w in the given format.
+func (g Graph) Render(w io.Writer, f Format) error {
+	switch f {
+	case FormatText:
+		return g.Text(w)
+	case FormatDOT:
+		return g.DOT(w)
+	case FormatMermaid:
+		return g.Mermaid(w)
+	case FormatSVG:
+		return g.SVG(w)
+	}
+	return fmt.Errorf("unknown graph format %q", f)
+}
+
+// Text writes g as a tree, a task that appears more than once is only expanded the first time.
+func (g Graph) Text(w io.Writer) error {
+	var b strings.Builder
+	expanded := map[string]bool{}
+	var walk func(node, label, prefix, branch, indent string)
+	walk = func(node, label, prefix, branch, indent string) {
+		b.WriteString(prefix + branch + node)
+		if label != "" {
+			b.WriteString(" (" + label + ")")
+		}
+		children := g.Children(node)
+		if expanded[node] && len(children) > 0 {
+			b.WriteString(" ...\n")
+			return
+		}
+		b.WriteString("\n")
+		expanded[node] = true
+		for i, c := range children {
+			if i == len(children)-1 {
+				walk(c.To, c.Label, prefix+indent, "└── ", "    ")
+				continue
+			}
+			walk(c.To, c.Label, prefix+indent, "├── ", "│   ")
+		}
+	}
+	for _, r := range g.Roots() {
+		walk(r, "", "", "", "")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DOT writes g in the Graphviz DOT language.
+func (g Graph) DOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph xc {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "  %q;\n", n)
+	}
+	for _, e := range g.Edges {
+		if e.Label != "" {
+			fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From, e.To, e.Label)
+			continue
+		}
+		fmt.Fprintf(&b, "  %q -> %q;\n", e.From, e.To)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Mermaid writes g as a Mermaid flowchart.
+// Node IDs are derived from task names so they stay the same as tasks are added and removed.
+func (g Graph) Mermaid(w io.Writer) error {
+	var b strings.Builder
+	ids := g.mermaidIDs()
+	b.WriteString("flowchart TD\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "    %s[\"%s\"]\n", ids[n], mermaidEscape(n))
+	}
+	for _, e := range g.Edges {
+		if e.Label != "" {
+			fmt.Fprintf(&b, "    %s -->|%s| %s\n", ids[e.From], mermaidEscape(e.Label), ids[e.To])
+			continue
+		}
+		fmt.Fprintf(&b, "    %s --> %s\n", ids[e.From], ids[e.To])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidIDs maps task names to identifiers made of letters, digits and underscores.
+func (g Graph) mermaidIDs() map[string]string {
+	ids := map[string]string{}
+	used := map[string]bool{}
+	for _, n := range g.Nodes {
+		base := mermaidID(n)
+		id := base
+		for i := 2; used[id]; i++ {
+			id = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[id] = true
+		ids[n] = id
+	}
+	return ids
+}
+
+func mermaidID(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteRune('_')
+	}
+	// Some words, such as end, are reserved in mermaid.
+	return "task_" + b.String()
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "|", "#124;")
+
+func mermaidEscape(s string) string {
+	return mermaidEscaper.Replace(s)
+}
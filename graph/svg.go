@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+const (
+	svgCharWidth  = 8
+	svgPadding    = 12
+	svgNodeHeight = 32
+	svgGapX       = 24
+	svgGapY       = 56
+	svgMargin     = 16
+)
+
+type svgBox struct {
+	x, y, width int
+}
+
+// layers assigns each node a layer, the longest path from a root,
+// so that a task is always drawn above the tasks it runs.
+func (g Graph) layers() [][]string {
+	depth := map[string]int{}
+	var visit func(node string, d int, path map[string]bool)
+	visit = func(node string, d int, path map[string]bool) {
+		if path[node] {
+			return
+		}
+		if cd, ok := depth[node]; ok && cd >= d {
+			return
+		}
+		depth[node] = d
+		path[node] = true
+		for _, c := range g.Children(node) {
+			visit(c.To, d+1, path)
+		}
+		delete(path, node)
+	}
+	for _, r := range g.Roots() {
+		visit(r, 0, map[string]bool{})
+	}
+	var layers [][]string
+	for _, n := range g.Nodes {
+		d := depth[n]
+		for len(layers) <= d {
+			layers = append(layers, nil)
+		}
+		layers[d] = append(layers[d], n)
+	}
+	return layers
+}
+
+// SVG writes g as an SVG image, tasks are laid out in layers with required tasks below.
+func (g Graph) SVG(w io.Writer) error {
+	layers := g.layers()
+	boxes := map[string]svgBox{}
+	widths := make([]int, len(layers))
+	maxWidth := 0
+	for i, l := range layers {
+		for j, n := range l {
+			if j > 0 {
+				widths[i] += svgGapX
+			}
+			widths[i] += len([]rune(n))*svgCharWidth + 2*svgPadding
+		}
+		if widths[i] > maxWidth {
+			maxWidth = widths[i]
+		}
+	}
+	for i, l := range layers {
+		x := svgMargin + (maxWidth-widths[i])/2
+		for _, n := range l {
+			width := len([]rune(n))*svgCharWidth + 2*svgPadding
+			boxes[n] = svgBox{x: x, y: svgMargin + i*(svgNodeHeight+svgGapY), width: width}
+			x += width + svgGapX
+		}
+	}
+	width := maxWidth + 2*svgMargin
+	height := len(layers)*(svgNodeHeight+svgGapY) - svgGapY + 2*svgMargin
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	b.WriteString(`  <defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" ` +
+		`markerWidth="8" markerHeight="8" orient="auto-start-reverse">` +
+		`<path d="M 0 0 L 10 5 L 0 10 z"/></marker></defs>` + "\n")
+	b.WriteString(`  <g font-family="monospace" font-size="13">` + "\n")
+	for _, e := range g.Edges {
+		from, to := boxes[e.From], boxes[e.To]
+		x1, y1 := from.x+from.width/2, from.y+svgNodeHeight
+		x2, y2 := to.x+to.width/2, to.y
+		fmt.Fprintf(&b, `    <line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black" marker-end="url(#arrow)"/>`+"\n",
+			x1, y1, x2, y2)
+		if e.Label != "" {
+			fmt.Fprintf(&b, `    <text x="%d" y="%d" text-anchor="middle" font-size="11">%s</text>`+"\n",
+				(x1+x2)/2, (y1+y2)/2, html.EscapeString(e.Label))
+		}
+	}
+	for _, n := range g.Nodes {
+		box := boxes[n]
+		fmt.Fprintf(&b, `    <rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="white" stroke="black"/>`+"\n",
+			box.x, box.y, box.width, svgNodeHeight)
+		fmt.Fprintf(&b, `    <text x="%d" y="%d" text-anchor="middle" dominant-baseline="central">%s</text>`+"\n",
+			box.x+box.width/2, box.y+svgNodeHeight/2, html.EscapeString(n))
+	}
+	b.WriteString("  </g>\n</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,150 @@
+// Package index keeps the tasks parsed from task files in .xc/index, so that unchanged files are not parsed again
+// when xc starts, such as on every shell completion in a repository with many task files.
+package index
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+// Path returns the path of the index of the task files in dir.
+func Path(dir string) string {
+	return filepath.Join(dir, ".xc", "index", "tasks.json")
+}
+
+// ParseFunc parses the tasks in the source of a task file.
+type ParseFunc func(src io.Reader) (models.Tasks, models.FileConfig, error)
+
+// Index maps task files to the tasks parsed from them.
+// A nil *Index is valid and parses every file.
+type Index struct {
+	path    string
+	version string
+	files   map[string]entry
+	changed bool
+}
+
+type file struct {
+	// Version is the version of xc that wrote the index, as another version may parse files differently.
+	Version string
+	Files   map[string]entry
+}
+
+type entry struct {
+	ModTime time.Time
+	Size    int64
+	// Hash is the sha256 of the file, so a file that is modified without changing is not parsed again.
+	Hash   string
+	Tasks  models.Tasks
+	Config models.FileConfig
+}
+
+// New returns an empty index of the task files in dir, which replaces any existing index when saved.
+func New(dir, version string) *Index {
+	return &Index{path: Path(dir), version: version, files: map[string]entry{}, changed: true}
+}
+
+// Open returns the index of the task files in dir, or nil if there is none.
+// An index that cannot be read, or was written by another version of xc, is replaced when saved.
+func Open(dir, version string) *Index {
+	b, err := os.ReadFile(Path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	ix := New(dir, version)
+	var f file
+	if err == nil && json.Unmarshal(b, &f) == nil && f.Version == version && f.Files != nil {
+		ix.files, ix.changed = f.Files, false
+	}
+	return ix
+}
+
+// Len returns the number of task files in the index.
+func (ix *Index) Len() int {
+	if ix == nil {
+		return 0
+	}
+	return len(ix.files)
+}
+
+// Parse returns the tasks in f under heading, from the index if f has not changed since it was indexed,
+// otherwise from parse, adding them to the index.
+// A file is unchanged if its modification time and size are the same, or the sha256 of its contents is.
+func (ix *Index) Parse(f *os.File, heading string, parse ParseFunc) (models.Tasks, models.FileConfig, error) {
+	if ix == nil {
+		return parse(f)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, models.FileConfig{}, fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	abs, err := filepath.Abs(f.Name())
+	if err != nil {
+		return nil, models.FileConfig{}, fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	key := abs + "#" + heading
+	e, ok := ix.files[key]
+	if ok && e.ModTime.Equal(info.ModTime()) && e.Size == info.Size() {
+		return e.Tasks, e.Config, nil
+	}
+	src, err := io.ReadAll(f)
+	if err != nil {
+		return nil, models.FileConfig{}, fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	sum := sha256.Sum256(src)
+	hash := hex.EncodeToString(sum[:])
+	if !ok || e.Hash != hash {
+		if e.Tasks, e.Config, err = parse(bytes.NewReader(src)); err != nil {
+			return nil, models.FileConfig{}, err
+		}
+		e.Hash = hash
+	}
+	e.ModTime, e.Size = info.ModTime(), info.Size()
+	ix.files[key] = e
+	ix.changed = true
+	return e.Tasks, e.Config, nil
+}
+
+// Save writes the index if it has changed since it was opened.
+// It is written to a temporary file then renamed, so concurrent runs of xc never read a partial index.
+func (ix *Index) Save() error {
+	if ix == nil || !ix.changed {
+		return nil
+	}
+	b, err := json.Marshal(file{Version: ix.version, Files: ix.files})
+	if err != nil {
+		return fmt.Errorf("failed to encode the index: %w", err)
+	}
+	dir := filepath.Dir(ix.path)
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create the index directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "tasks-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), ix.path); err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	ix.changed = false
+	return nil
+}
@@ -0,0 +1,113 @@
+package index
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "README.md")
+	if err := os.WriteFile(path, []byte("one"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parses := 0
+	parse := func(r io.Reader) (models.Tasks, models.FileConfig, error) {
+		parses++
+		b, err := io.ReadAll(r)
+		return models.Tasks{{Name: string(b)}}, models.FileConfig{}, err
+	}
+	parseWith := func(ix *Index) string {
+		t.Helper()
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		tasks, _, err := ix.Parse(f, "", parse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ix.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return tasks[0].Name
+	}
+
+	if Open(dir, "v1") != nil {
+		t.Fatal("expected no index")
+	}
+	parseWith(nil)
+	parses = 0
+	if _, err := os.Stat(Path(dir)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no index to be written got %v", err)
+	}
+	if err := New(dir, "v1").Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		change  func()
+		version string
+		want    string
+		parses  int
+	}{
+		{name: "an empty index parses the file", version: "v1", want: "one", parses: 1},
+		{name: "an unchanged file is not parsed", version: "v1", want: "one", parses: 1},
+		{
+			name: "a touched file with the same contents is not parsed",
+			change: func() {
+				later := time.Now().Add(time.Hour)
+				if err := os.Chtimes(path, later, later); err != nil {
+					t.Fatal(err)
+				}
+			},
+			version: "v1", want: "one", parses: 1,
+		},
+		{
+			name: "a changed file is parsed",
+			change: func() {
+				if err := os.WriteFile(path, []byte("two"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			version: "v1", want: "two", parses: 2,
+		},
+		{name: "another version of xc parses the file", version: "v2", want: "two", parses: 3},
+	}
+	for _, s := range steps {
+		if s.change != nil {
+			s.change()
+		}
+		if got := parseWith(Open(dir, s.version)); got != s.want || parses != s.parses {
+			t.Fatalf("%s: want %q with %d parses got %q with %d", s.name, s.want, s.parses, got, parses)
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Dir(Path(dir)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(Path(dir), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ix := Open(dir, "v1")
+	if ix == nil || ix.Len() != 0 {
+		t.Fatalf("expected an empty index got %v", ix)
+	}
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if ix = Open(dir, "v1"); ix == nil || ix.changed {
+		t.Fatalf("expected the index to be replaced got %v", ix)
+	}
+}
@@ -0,0 +1,129 @@
+// Package interpolate expands variables in attribute values.
+//
+// The grammar is:
+//
+//	$$                 a literal $
+//	$NAME              the value of NAME, or empty if NAME is not set
+//	${NAME}            the value of NAME, or empty if NAME is not set
+//	${NAME:-default}   the value of NAME, or default if NAME is not set or empty
+//	${NAME-default}    the value of NAME, or default if NAME is not set
+//
+// NAME starts with a letter or underscore, followed by letters, digits or underscores.
+// The default is itself expanded. A $ followed by anything else, such as $(command), is left as it is.
+package interpolate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lookup returns the value of a variable, ok is false if it is not set.
+type Lookup func(name string) (value string, ok bool)
+
+// EnvLookup returns a Lookup over a list of KEY=VALUE pairs, later pairs take precedence.
+func EnvLookup(env []string) Lookup {
+	return func(name string) (string, bool) {
+		for i := len(env) - 1; i >= 0; i-- {
+			k, v, found := strings.Cut(env[i], "=")
+			if found && k == name {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// Expand expands the variables in s.
+func Expand(s string, lookup Lookup) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end, err := closingBrace(s, i+2)
+			if err != nil {
+				return "", err
+			}
+			v, err := expandBraced(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			v, _ := lookup(s[i+1 : j])
+			b.WriteString(v)
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the brace closing the expression starting at start,
+// braces of nested expressions in a default are skipped.
+func closingBrace(s string, start int) (int, error) {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '$':
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated ${ in %q", s)
+}
+
+func expandBraced(expr string, lookup Lookup) (string, error) {
+	i := 0
+	for i < len(expr) && isNameChar(expr[i]) {
+		i++
+	}
+	name, rest := expr[:i], expr[i:]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("invalid variable name in ${%s}", expr)
+	}
+	v, ok := lookup(name)
+	switch {
+	case rest == "":
+		return v, nil
+	case strings.HasPrefix(rest, ":-"):
+		if ok && v != "" {
+			return v, nil
+		}
+		return Expand(rest[2:], lookup)
+	case strings.HasPrefix(rest, "-"):
+		if ok {
+			return v, nil
+		}
+		return Expand(rest[1:], lookup)
+	}
+	return "", fmt.Errorf("unsupported expression ${%s}", expr)
+}
@@ -0,0 +1,51 @@
+package interpolate
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	lookup := EnvLookup([]string{"NAME=world", "EMPTY=", "NAME2=first", "NAME2=second"})
+	tests := []struct {
+		name      string
+		in        string
+		expected  string
+		expectErr bool
+	}{
+		{name: "given no variables, should not change", in: "plain value", expected: "plain value"},
+		{name: "given a variable, should expand", in: "hello $NAME!", expected: "hello world!"},
+		{name: "given a braced variable, should expand", in: "${NAME}wide", expected: "worldwide"},
+		{name: "given an unset variable, should be empty", in: "[$UNSET]", expected: "[]"},
+		{name: "given a repeated key, the last wins", in: "$NAME2", expected: "second"},
+		{name: "given an escaped dollar, should be literal", in: "$$NAME costs $$5", expected: "$NAME costs $5"},
+		{name: "given a default for an unset variable, should use default", in: "${UNSET:-fallback}", expected: "fallback"},
+		{name: "given a default for an empty variable, should use default", in: "${EMPTY:-fallback}", expected: "fallback"},
+		{name: "given a default for a set variable, should use value", in: "${NAME:-fallback}", expected: "world"},
+		{
+			name:     "given an unset-only default for an empty variable, should be empty",
+			in:       "[${EMPTY-fallback}]",
+			expected: "[]",
+		},
+		{
+			name:     "given an unset-only default for an unset variable, should use default",
+			in:       "${UNSET-fallback}",
+			expected: "fallback",
+		},
+		{name: "given a nested default, should expand it", in: "${UNSET:-${NAME}-x}", expected: "world-x"},
+		{name: "given a command substitution, should be left alone", in: "$(echo hi)", expected: "$(echo hi)"},
+		{name: "given a trailing dollar, should be literal", in: "cost$", expected: "cost$"},
+		{name: "given an unterminated brace, should fail", in: "${NAME", expectErr: true},
+		{name: "given an invalid name, should fail", in: "${1ABC}", expectErr: true},
+		{name: "given an unsupported operator, should fail", in: "${NAME:=x}", expectErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.in, lookup)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+			if got != tt.expected {
+				t.Fatalf("expected %q got %q", tt.expected, got)
+			}
+		})
+	}
+}
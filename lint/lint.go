@@ -0,0 +1,182 @@
+// Package lint checks tasks for mistakes that would otherwise only be found when they run.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/parser"
+	"github.com/joerdav/xc/search"
+)
+
+// Severity is how serious an Issue is, only errors should fail a check.
+type Severity string
+
+const (
+	// SeverityError is an Issue that stops a task from running.
+	SeverityError Severity = "error"
+	// SeverityWarning is an Issue that does not stop a task from running.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem with a task.
+type Issue struct {
+	Task     string
+	Line     int
+	Severity Severity
+	Message  string
+}
+
+// Check returns the issues in tasks, in the order of the tasks:
+//   - Tasks defined more than once.
+//   - Tasks that require, or have steps, that are invalid or do not exist.
+//   - Tasks that require themselves, directly or through other tasks.
+//   - Tasks without a description, as a warning.
+//   - Tasks that are both ci-only and local-only, or require a task that only runs where they do not.
+//   - Services with a healthcheck that are not restarted on-unhealthy, or the other way round, as a warning.
+//   - Attributes that are neither built in nor registered with parser.RegisterAttribute, as a warning.
+func Check(tasks models.Tasks) []Issue {
+	var issues []Issue
+	report := func(t models.Task, s Severity, format string, args ...any) {
+		issues = append(issues, Issue{Task: t.Name, Line: t.Line, Severity: s, Message: fmt.Sprintf(format, args...)})
+	}
+	seen := map[string]bool{}
+	names := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		names = append(names, t.Name)
+	}
+	cycles := findCycles(tasks)
+	for _, t := range tasks {
+		if seen[strings.ToLower(t.Name)] {
+			report(t, SeverityError, "task %s is defined more than once", t.Name)
+		}
+		seen[strings.ToLower(t.Name)] = true
+		if t.ParsingError != "" {
+			report(t, SeverityError, "task %s has a parsing error: %s", t.Name, t.ParsingError)
+		}
+		for _, entry := range append(t.DependsOn[:len(t.DependsOn):len(t.DependsOn)], t.Steps...) {
+			d, err := models.ParseDependency(entry)
+			if err != nil {
+				report(t, SeverityError, "task %s has an invalid dependency: %v", t.Name, err)
+				continue
+			}
+			if dep, ok := tasks.Get(d.Name); ok {
+				switch {
+				case t.LocalOnly && dep.CIOnly:
+					report(t, SeverityError, "task %s is local-only but requires %s, which only runs in CI", t.Name, dep.Name)
+				case t.CIOnly && dep.LocalOnly:
+					report(t, SeverityError, "task %s is ci-only but requires %s, which only runs locally", t.Name, dep.Name)
+				}
+				continue
+			}
+			if s := search.Suggest(d.Name, names); len(s) > 0 {
+				report(t, SeverityError, "task %s requires %s, which does not exist, did you mean '%s'?", t.Name, d.Name, s[0])
+				continue
+			}
+			report(t, SeverityError, "task %s requires %s, which does not exist", t.Name, d.Name)
+		}
+		if cycle, ok := cycles[t.Name]; ok {
+			report(t, SeverityError, "task %s has a circular dependency: %s", t.Name, strings.Join(cycle, " -> "))
+		}
+		if len(t.Description) == 0 {
+			report(t, SeverityWarning, "task %s has no description", t.Name)
+		}
+		if t.CIOnly && t.LocalOnly {
+			report(t, SeverityError, "task %s is both ci-only and local-only, so it can never run", t.Name)
+		}
+		switch {
+		case t.Healthcheck != "" && t.Restart != models.RestartOnUnhealthy:
+			report(t, SeverityWarning, "task %s has a healthcheck, which is only run with restart: on-unhealthy", t.Name)
+		case t.Healthcheck == "" && t.Restart == models.RestartOnUnhealthy:
+			report(t, SeverityWarning, "task %s is restarted on-unhealthy, but has no healthcheck", t.Name)
+		}
+		for _, name := range unknownAttributes(t) {
+			if s := search.Suggest(name, parser.BuiltInAttributes()); len(s) > 0 {
+				report(t, SeverityWarning, "task %s has an unknown attribute %s, did you mean '%s'?", t.Name, name, s[0])
+				continue
+			}
+			report(t, SeverityWarning, "task %s has an unknown attribute %s", t.Name, name)
+		}
+	}
+	return issues
+}
+
+// unknownAttributes returns the names of the attributes in the Metadata of t that are not registered, sorted.
+func unknownAttributes(t models.Task) []string {
+	registered := map[string]bool{}
+	for _, name := range parser.RegisteredAttributes() {
+		registered[name] = true
+	}
+	var names []string
+	for name := range t.Metadata {
+		if !registered[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findCycles returns the circular dependencies in tasks, keyed by the task each was found from.
+func findCycles(tasks models.Tasks) map[string][]string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	cycles := map[string][]string{}
+	var path []string
+	var visit func(t models.Task)
+	visit = func(t models.Task) {
+		state[t.Name] = visiting
+		path = append(path, t.Name)
+		for _, entry := range append(t.DependsOn[:len(t.DependsOn):len(t.DependsOn)], t.Steps...) {
+			d, err := models.ParseDependency(entry)
+			if err != nil {
+				continue
+			}
+			next, ok := tasks.Get(d.Name)
+			if !ok {
+				continue
+			}
+			switch state[next.Name] {
+			case visiting:
+				for i, name := range path {
+					if name == next.Name {
+						cycles[t.Name] = append(append([]string{}, path[i:]...), next.Name)
+					}
+				}
+			case 0:
+				visit(next)
+			}
+		}
+		path = path[:len(path)-1]
+		state[t.Name] = done
+	}
+	for _, t := range tasks {
+		if state[t.Name] == 0 {
+			visit(t)
+		}
+	}
+	return cycles
+}
+
+// GitHubAnnotation returns the issue as a GitHub Actions workflow command,
+// which is shown as an annotation on the line of file in pull requests, or on file if Line is 0.
+func (i Issue) GitHubAnnotation(file string) string {
+	props := "file=" + escapeProperty(file)
+	if i.Line > 0 {
+		props += fmt.Sprintf(",line=%d", i.Line)
+	}
+	return fmt.Sprintf("::%s %s,title=xc::%s", i.Severity, props, escapeData(i.Message))
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
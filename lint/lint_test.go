@@ -0,0 +1,85 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestCheck(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Line: 3, Description: []string{"Build."}, Script: "go build"},
+		{Name: "test", Line: 8, Description: []string{"Test."}, DependsOn: []string{"biuld"}},
+		{Name: "a", Line: 12, Description: []string{"A."}, DependsOn: []string{"b"}},
+		{Name: "b", Line: 16, Description: []string{"B."}, Steps: []string{"a"}},
+		{Name: "lint", Line: 20, Script: "golangci-lint run"},
+		{Name: "Build", Line: 24, Description: []string{"Again."}, Script: "go build"},
+		{Name: "deploy", Line: 28, Description: []string{"Deploy."}, DependsOn: []string{"build with"}},
+		{
+			Name: "release", Line: 32, Description: []string{"Release."}, Script: "goreleaser",
+			Metadata: map[string]string{"team": "a", "requries": "b"},
+		},
+		{
+			Name: "emulator", Line: 36, Description: []string{"Emulator."}, Script: "emulator",
+			Service: true, Restart: models.RestartOnUnhealthy,
+		},
+		{Name: "publish", Line: 40, Description: []string{"Publish."}, Script: "publish", CIOnly: true},
+		{Name: "preview", Line: 44, Description: []string{"Preview."}, DependsOn: []string{"publish"}, LocalOnly: true},
+		{Name: "nowhere", Line: 48, Description: []string{"Nowhere."}, Script: "true", CIOnly: true, LocalOnly: true},
+	}
+	expected := []Issue{
+		{
+			Task: "test", Line: 8, Severity: SeverityError,
+			Message: "task test requires biuld, which does not exist, did you mean 'build'?",
+		},
+		{Task: "b", Line: 16, Severity: SeverityError, Message: "task b has a circular dependency: a -> b -> a"},
+		{Task: "lint", Line: 20, Severity: SeverityWarning, Message: "task lint has no description"},
+		{Task: "Build", Line: 24, Severity: SeverityError, Message: "task Build is defined more than once"},
+		{Task: "deploy", Line: 28, Severity: SeverityError},
+		{
+			Task: "release", Line: 32, Severity: SeverityWarning,
+			Message: "task release has an unknown attribute requries, did you mean 'requires'?",
+		},
+		{Task: "release", Line: 32, Severity: SeverityWarning, Message: "task release has an unknown attribute team"},
+		{
+			Task: "emulator", Line: 36, Severity: SeverityWarning,
+			Message: "task emulator is restarted on-unhealthy, but has no healthcheck",
+		},
+		{
+			Task: "preview", Line: 44, Severity: SeverityError,
+			Message: "task preview is local-only but requires publish, which only runs in CI",
+		},
+		{
+			Task: "nowhere", Line: 48, Severity: SeverityError,
+			Message: "task nowhere is both ci-only and local-only, so it can never run",
+		},
+	}
+	issues := Check(tasks)
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues got %d: %v", len(expected), len(issues), issues)
+	}
+	for i, issue := range issues {
+		e := expected[i]
+		if e.Message == "" {
+			e.Message = issue.Message
+		}
+		if issue != e {
+			t.Errorf("issue %d want=%+v got=%+v", i, e, issue)
+		}
+	}
+}
+
+func TestGitHubAnnotation(t *testing.T) {
+	issue := Issue{Line: 12, Severity: SeverityError, Message: "100% broken\nsee docs"}
+	got := issue.GitHubAnnotation("docs/a,b.md")
+	expected := "::error file=docs/a%2Cb.md,line=12,title=xc::100%25 broken%0Asee docs"
+	if got != expected {
+		t.Errorf("want=%q got=%q", expected, got)
+	}
+	issue.Line = 0
+	got = issue.GitHubAnnotation("README.md")
+	expected = "::error file=README.md,title=xc::100%25 broken%0Asee docs"
+	if got != expected {
+		t.Errorf("want=%q got=%q", expected, got)
+	}
+}
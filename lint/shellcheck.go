@@ -0,0 +1,117 @@
+package lint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+// ErrShellCheckNotFound is returned by ShellCheck if shellcheck is not installed.
+var ErrShellCheckNotFound = errors.New("shellcheck is not installed, see https://www.shellcheck.net")
+
+// shellCheckComment is a comment in the json1 output of shellcheck.
+type shellCheckComment struct {
+	Line    int    `json:"line"`
+	Level   string `json:"level"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ShellCheck checks the shell scripts of tasks with shellcheck, returning an issue for each comment it makes,
+// at the line of the task file the comment is on. Comments at the error level are errors, the others warnings.
+//
+// Scripts in code blocks without a language, or tagged sh, bash or shell, are checked as bash scripts
+// unless their shebang names another shell, scripts with the shebang of another interpreter are not checked.
+func ShellCheck(ctx context.Context, tasks models.Tasks) ([]Issue, error) {
+	path, err := exec.LookPath("shellcheck")
+	if err != nil {
+		return nil, ErrShellCheckNotFound
+	}
+	var issues []Issue
+	for _, t := range tasks {
+		shell, ok := scriptShell(t)
+		if !ok {
+			continue
+		}
+		comments, err := shellCheck(ctx, path, t.Script, shell)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check task %s: %w", t.Name, err)
+		}
+		for _, c := range comments {
+			line := t.Line
+			if c.Line >= 1 && c.Line <= len(t.ScriptLines) {
+				line = t.ScriptLines[c.Line-1]
+			}
+			severity := SeverityWarning
+			if c.Level == "error" {
+				severity = SeverityError
+			}
+			issues = append(issues, Issue{
+				Task:     t.Name,
+				Line:     line,
+				Severity: severity,
+				Message:  fmt.Sprintf("task %s: SC%d: %s", t.Name, c.Code, c.Message),
+			})
+		}
+	}
+	return issues, nil
+}
+
+// scriptShell returns the shell shellcheck checks the script of a task as, ok is false if it is not a shell script.
+func scriptShell(t models.Task) (shell string, ok bool) {
+	switch t.Language {
+	case "", "sh", "bash", "shell":
+	default:
+		return "", false
+	}
+	if strings.TrimSpace(t.Script) == "" {
+		return "", false
+	}
+	first, _, _ := strings.Cut(t.Script, "\n")
+	interpreter, ok := strings.CutPrefix(first, "#!")
+	if !ok {
+		// The built-in shell runs bash scripts.
+		return "bash", true
+	}
+	fields := strings.Fields(interpreter)
+	if len(fields) == 0 {
+		return "", false
+	}
+	name := fields[0][strings.LastIndex(fields[0], "/")+1:]
+	if name == "env" && len(fields) > 1 {
+		name = fields[1]
+	}
+	switch name {
+	case "sh", "bash", "dash", "ksh":
+		return name, true
+	}
+	return "", false
+}
+
+// shellCheck runs shellcheck on a script, returning its comments.
+func shellCheck(ctx context.Context, path, script, shell string) ([]shellCheckComment, error) {
+	cmd := exec.CommandContext(ctx, path, "--format=json1", "--shell="+shell, "-")
+	cmd.Stdin = strings.NewReader(script)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// shellcheck exits with 1 when it has comments.
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	var out struct {
+		Comments []shellCheckComment `json:"comments"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("failed to read the output of shellcheck: %w", err)
+	}
+	return out.Comments, nil
+}
@@ -0,0 +1,58 @@
+package lint
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestScriptShell(t *testing.T) {
+	tests := []struct {
+		name     string
+		task     models.Task
+		expected string
+		ok       bool
+	}{
+		{name: "no shebang", task: models.Task{Script: "echo hi"}, expected: "bash", ok: true},
+		{name: "sh code block", task: models.Task{Language: "sh", Script: "echo hi"}, expected: "bash", ok: true},
+		{name: "sh shebang", task: models.Task{Script: "#!/bin/sh\necho hi"}, expected: "sh", ok: true},
+		{name: "env shebang", task: models.Task{Script: "#!/usr/bin/env dash\necho hi"}, expected: "dash", ok: true},
+		{name: "other interpreter", task: models.Task{Script: "#!/usr/bin/env python\nprint(1)"}},
+		{name: "other language", task: models.Task{Language: "python", Script: "print(1)"}},
+		{name: "no script", task: models.Task{DependsOn: []string{"a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell, ok := scriptShell(tt.task)
+			if shell != tt.expected || ok != tt.ok {
+				t.Fatalf("expected %q, %v got %q, %v", tt.expected, tt.ok, shell, ok)
+			}
+		})
+	}
+}
+
+func TestShellCheck(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Line: 3, Script: "go build\necho $1\n", ScriptLines: []int{8, 9}},
+		{Name: "lint", Line: 12, Script: "golangci-lint run\n", ScriptLines: []int{17}},
+	}
+	issues, err := ShellCheck(context.Background(), tasks)
+	if _, lookErr := exec.LookPath("shellcheck"); lookErr != nil {
+		if !errors.Is(err, ErrShellCheckNotFound) {
+			t.Fatalf("expected %v got %v", ErrShellCheckNotFound, err)
+		}
+		t.Skip("shellcheck is not installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("expected 1 issue got %v", issues)
+	}
+	if issues[0].Task != "build" || issues[0].Line != 9 || issues[0].Severity != SeverityWarning {
+		t.Fatalf("expected a warning on line 9 of build got %+v", issues[0])
+	}
+}
@@ -0,0 +1,125 @@
+// Package metrics records task runs and exposes them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type taskMetrics struct {
+	runs, failures int
+	running        int
+	durationSum    float64
+	lastSuccess    time.Time
+}
+
+// Registry records the runs of tasks, it is safe for concurrent use.
+type Registry struct {
+	mu    sync.Mutex
+	tasks map[string]*taskMetrics
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{tasks: map[string]*taskMetrics{}}
+}
+
+func (r *Registry) task(name string) *taskMetrics {
+	t, ok := r.tasks[name]
+	if !ok {
+		t = &taskMetrics{}
+		r.tasks[name] = t
+	}
+	return t
+}
+
+// Started records that a task has started running.
+func (r *Registry) Started(task string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.task(task).running++
+}
+
+// Finished records that a task has finished running, err is nil if the run succeeded.
+func (r *Registry) Finished(task string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.task(task)
+	t.running--
+	t.runs++
+	t.durationSum += d.Seconds()
+	if err != nil {
+		t.failures++
+		return
+	}
+	t.lastSuccess = time.Now()
+}
+
+type metric struct {
+	name, help, kind string
+	value            func(*taskMetrics) (float64, bool)
+}
+
+var exposed = []metric{
+	{
+		name: "xc_task_runs_total", help: "Number of completed task runs.", kind: "counter",
+		value: func(t *taskMetrics) (float64, bool) { return float64(t.runs), true },
+	},
+	{
+		name: "xc_task_failures_total", help: "Number of failed task runs.", kind: "counter",
+		value: func(t *taskMetrics) (float64, bool) { return float64(t.failures), true },
+	},
+	{
+		name: "xc_task_duration_seconds_total", help: "Total time spent running a task.", kind: "counter",
+		value: func(t *taskMetrics) (float64, bool) { return t.durationSum, true },
+	},
+	{
+		name: "xc_task_running", help: "Number of runs of a task in progress.", kind: "gauge",
+		value: func(t *taskMetrics) (float64, bool) { return float64(t.running), true },
+	},
+	{
+		name: "xc_task_last_success_timestamp_seconds", help: "Unix time of the last successful run.", kind: "gauge",
+		value: func(t *taskMetrics) (float64, bool) {
+			return float64(t.lastSuccess.UnixNano()) / float64(time.Second), !t.lastSuccess.IsZero()
+		},
+	},
+}
+
+// Write writes the metrics in the Prometheus text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.tasks))
+	for n := range r.tasks {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, m := range exposed {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+			return err
+		}
+		for _, n := range names {
+			v, ok := m.value(r.tasks[n])
+			if !ok {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s{task=\"%s\"} %v\n", m.name, labelEscaper.Replace(n), v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ServeHTTP implements http.Handler.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.Write(w)
+}
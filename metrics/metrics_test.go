@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Started("build")
+	r.Finished("build", 2*time.Second, nil)
+	r.Started("build")
+	r.Finished("build", time.Second, errors.New("failed"))
+	r.Started(`de"ploy`)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	res, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(b)
+	for _, expected := range []string{
+		"# TYPE xc_task_runs_total counter",
+		`xc_task_runs_total{task="build"} 2`,
+		`xc_task_failures_total{task="build"} 1`,
+		`xc_task_duration_seconds_total{task="build"} 3`,
+		`xc_task_running{task="build"} 0`,
+		`xc_task_running{task="de\"ploy"} 1`,
+		`xc_task_last_success_timestamp_seconds{task="build"}`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected metrics to contain %s, got:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, `xc_task_last_success_timestamp_seconds{task="de\"ploy"}`) {
+		t.Error("expected no last success for a task that has not succeeded")
+	}
+}
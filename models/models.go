@@ -1,22 +1,91 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/shlex"
 )
 
 // Task represents a parsed Task.
+//
+// Line is the line number of the heading of the Task in its file,
+// and ScriptLines holds the line number in its file of each line of the Script.
+// Deferred is the script of a code block marked deferred, which runs after the Script even if it fails,
+// and Stdin is the content of a code block marked stdin, which the Script reads on its standard input.
+// Metadata holds the values of attributes that are not built in, keyed by their lower case name.
+// Translations holds the description of the Task in other languages, keyed by a lower case language tag
+// such as fr or pt-br, from attributes such as `Description[fr]: Construit le binaire.`.
+// InputValues holds the values an input accepts, keyed by the input, if they are listed after it such as
+// `Inputs: ENVIRONMENT (staging|production)`.
+// InputSources holds the environment variables an input with a contract is read from,
+// in order and starting with the input, keyed by the input,
+// such as `Inputs: AWS_REGION (required, from: AWS_REGION|AWS_DEFAULT_REGION)`.
 type Task struct {
-	Name              string
-	Description       []string
-	Script            string
+	Name        string
+	Description []string
+	Script      string
+	// Language is the info string of the code block containing the Script, e.g. sh.
+	Language          string
 	Dir               string
 	Env               []string
 	DependsOn         []string
+	Steps             []string
 	Inputs            []string
+	Schedule          string
+	Foreach           []string
+	RequiresTools     []string
+	AssertOutputs     []OutputAssertion
+	ForeachParallel   bool
 	ParsingError      string
 	RequiredBehaviour RequiredBehaviour
+	Line              int
+	ScriptLines       []int
+	Deferred          string
+	DeferredLanguage  string
+	Stdin             string
+	InputValues       map[string][]string
+	InputSources      map[string][]string
+	Sources           []string
+	ConcurrencyGroup  string
+	Priority          int
+	NoNetwork         bool
+	AllowPaths        []string
+	DenyPaths         []string
+	Umask             string
+	User              string
+	Nice              int
+	IONice            string
+	ShellOpts         []string
+	Wrapper           []string
+	Service           bool
+	ReadyWhen         string
+	Healthcheck       string
+	Compose           []string
+	Restart           RestartPolicy
+	Stop              string
+	StopSignal        string
+	StopTimeout       time.Duration
+	Owner             string
+	Docs              string
+	// Approval is the question asked before the scripts of the Task run, they only run if it is approved.
+	Approval     string
+	Icon         string
+	Color        string
+	WSL          bool
+	CIOnly       bool
+	LocalOnly    bool
+	Metadata     map[string]string
+	Translations map[string][]string
+	// Override is set for a task that replaces another task with the same name.
+	Override bool
 }
 
 // Display writes a Task as Markdown.
@@ -26,10 +95,27 @@ func (t Task) Display(w io.Writer) {
 		fmt.Fprintln(w, d)
 		fmt.Fprintln(w)
 	}
+	langs := make([]string, 0, len(t.Translations))
+	for lang := range t.Translations {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		for _, d := range t.Translations[lang] {
+			fmt.Fprintf(w, "Description[%s]: %s\n", lang, d)
+		}
+		fmt.Fprintln(w)
+	}
 	if len(t.DependsOn) > 0 {
 		fmt.Fprintln(w, "Requires:", strings.Join(t.DependsOn, ", "))
 		fmt.Fprintln(w)
 	}
+	for i, s := range t.Steps {
+		fmt.Fprintf(w, "%d. %s\n", i+1, s)
+	}
+	if len(t.Steps) > 0 {
+		fmt.Fprintln(w)
+	}
 	if t.Dir != "" {
 		fmt.Fprintln(w, "Directory:", t.Dir)
 		fmt.Fprintln(w)
@@ -39,21 +125,302 @@ func (t Task) Display(w io.Writer) {
 		fmt.Fprintln(w)
 	}
 	if len(t.Inputs) > 0 {
-		fmt.Fprintln(w, "Inputs:", strings.Join(t.Inputs, ", "))
+		inputs := make([]string, len(t.Inputs))
+		for i, in := range t.Inputs {
+			inputs[i] = in
+			if c := t.InputContract(in); c != "" {
+				inputs[i] += " (" + c + ")"
+			}
+		}
+		fmt.Fprintln(w, "Inputs:", strings.Join(inputs, ", "))
+		fmt.Fprintln(w)
+	}
+	if len(t.Sources) > 0 {
+		fmt.Fprintln(w, "Sources:", strings.Join(t.Sources, ", "))
+		fmt.Fprintln(w)
+	}
+	if len(t.RequiresTools) > 0 {
+		fmt.Fprintln(w, "Requires-Tools:", strings.Join(t.RequiresTools, ", "))
+		fmt.Fprintln(w)
+	}
+	if len(t.AssertOutputs) > 0 {
+		outputs := make([]string, len(t.AssertOutputs))
+		for i, o := range t.AssertOutputs {
+			outputs[i] = o.String()
+		}
+		fmt.Fprintln(w, "Assert-Outputs:", strings.Join(outputs, ", "))
+		fmt.Fprintln(w)
+	}
+	if len(t.Foreach) > 0 {
+		fmt.Fprintln(w, "Foreach:", strings.Join(t.Foreach, ", "))
+		fmt.Fprintln(w)
+	}
+	if t.ForeachParallel {
+		fmt.Fprintln(w, "Foreach-Parallel: true")
+		fmt.Fprintln(w)
+	}
+	if t.ConcurrencyGroup != "" {
+		fmt.Fprintln(w, "Concurrency-Group:", t.ConcurrencyGroup)
+		fmt.Fprintln(w)
+	}
+	if t.NoNetwork {
+		fmt.Fprintln(w, "Network: false")
+		fmt.Fprintln(w)
+	}
+	if len(t.AllowPaths) > 0 {
+		fmt.Fprintln(w, "Allow-Paths:", strings.Join(t.AllowPaths, ", "))
+		fmt.Fprintln(w)
+	}
+	if len(t.DenyPaths) > 0 {
+		fmt.Fprintln(w, "Deny-Paths:", strings.Join(t.DenyPaths, ", "))
+		fmt.Fprintln(w)
+	}
+	if t.ShellOpts != nil {
+		opts := strings.Join(t.ShellOpts, ", ")
+		if opts == "" {
+			opts = "none"
+		}
+		fmt.Fprintln(w, "Shell-Opts:", opts)
+		fmt.Fprintln(w)
+	}
+	if t.Wrapper != nil {
+		wrapper := strings.Join(t.Wrapper, " ")
+		if wrapper == "" {
+			wrapper = "none"
+		}
+		fmt.Fprintln(w, "Wrapper:", wrapper)
+		fmt.Fprintln(w)
+	}
+	if t.Umask != "" {
+		fmt.Fprintln(w, "Umask:", t.Umask)
+		fmt.Fprintln(w)
+	}
+	if t.User != "" {
+		fmt.Fprintln(w, "User:", t.User)
+		fmt.Fprintln(w)
+	}
+	if t.Nice != 0 {
+		fmt.Fprintln(w, "Nice:", t.Nice)
+		fmt.Fprintln(w)
+	}
+	if t.IONice != "" {
+		fmt.Fprintln(w, "IONice:", t.IONice)
+		fmt.Fprintln(w)
+	}
+	if t.Priority != 0 {
+		fmt.Fprintln(w, "Priority:", t.Priority)
+		fmt.Fprintln(w)
+	}
+	if t.Service {
+		fmt.Fprintln(w, "Service: true")
+		fmt.Fprintln(w)
+	}
+	if t.WSL {
+		fmt.Fprintln(w, "WSL: true")
+		fmt.Fprintln(w)
+	}
+	if t.CIOnly {
+		fmt.Fprintln(w, "CI-Only: true")
+		fmt.Fprintln(w)
+	}
+	if t.LocalOnly {
+		fmt.Fprintln(w, "Local-Only: true")
+		fmt.Fprintln(w)
+	}
+	if t.Override {
+		fmt.Fprintln(w, "Override: true")
+		fmt.Fprintln(w)
+	}
+	if t.ReadyWhen != "" {
+		fmt.Fprintln(w, "Ready-When:", t.ReadyWhen)
+		fmt.Fprintln(w)
+	}
+	if t.Healthcheck != "" {
+		fmt.Fprintln(w, "Healthcheck:", t.Healthcheck)
+		fmt.Fprintln(w)
+	}
+	if len(t.Compose) > 0 {
+		fmt.Fprintln(w, "Compose:", strings.Join(t.Compose, ", "))
+		fmt.Fprintln(w)
+	}
+	if t.Restart != RestartNo {
+		fmt.Fprintln(w, "Restart:", t.Restart)
+		fmt.Fprintln(w)
+	}
+	if t.Stop != "" {
+		fmt.Fprintln(w, "Stop:", t.Stop)
+		fmt.Fprintln(w)
+	}
+	if t.StopSignal != "" {
+		fmt.Fprintln(w, "Stop-Signal:", t.StopSignal)
+		fmt.Fprintln(w)
+	}
+	if t.StopTimeout != 0 {
+		fmt.Fprintln(w, "Stop-Timeout:", t.StopTimeout)
+		fmt.Fprintln(w)
+	}
+	if t.Schedule != "" {
+		fmt.Fprintln(w, "Schedule:", t.Schedule)
+		fmt.Fprintln(w)
+	}
+	if t.Owner != "" {
+		fmt.Fprintln(w, "Owner:", t.Owner)
+		fmt.Fprintln(w)
+	}
+	if t.Approval != "" {
+		fmt.Fprintln(w, "Approval:", t.Approval)
+		fmt.Fprintln(w)
+	}
+	if t.Docs != "" {
+		fmt.Fprintln(w, "Docs:", t.Docs)
+		fmt.Fprintln(w)
+	}
+	if t.Icon != "" {
+		fmt.Fprintln(w, "Icon:", t.Icon)
+		fmt.Fprintln(w)
+	}
+	if t.Color != "" {
+		fmt.Fprintln(w, "Color:", t.Color)
+		fmt.Fprintln(w)
+	}
+	keys := make([]string, 0, len(t.Metadata))
+	for k := range t.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %s\n", k, t.Metadata[k])
 		fmt.Fprintln(w)
 	}
 	fmt.Fprintln(w, "Run:", t.RequiredBehaviour)
 	fmt.Fprintln(w)
 	if len(t.Script) > 0 {
-		fmt.Fprintln(w, "```")
+		fmt.Fprintln(w, "```"+t.Language)
 		fmt.Fprintln(w, t.Script)
 		fmt.Fprintln(w, "```")
 	}
+	if len(t.Stdin) > 0 {
+		fmt.Fprintln(w, "```stdin")
+		fmt.Fprint(w, t.Stdin)
+		fmt.Fprintln(w, "```")
+	}
+	if len(t.Deferred) > 0 {
+		fmt.Fprintln(w, "```"+strings.TrimSpace(t.DeferredLanguage+" deferred"))
+		fmt.Fprintln(w, t.Deferred)
+		fmt.Fprintln(w, "```")
+	}
+}
+
+// InputContract returns what is listed in parentheses after an input in the inputs attribute,
+// such as staging|production or required, from: AWS_REGION|AWS_DEFAULT_REGION, or "" if nothing is.
+func (t Task) InputContract(input string) string {
+	var parts []string
+	if values := t.InputValues[input]; len(values) > 0 {
+		parts = append(parts, strings.Join(values, "|"))
+	}
+	if sources := t.InputSources[input]; len(sources) > 0 {
+		parts = append(parts, "required")
+		if len(sources) > 1 {
+			parts = append(parts, "from: "+strings.Join(sources, "|"))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FileConfig is the configuration of a task file, set in YAML front matter at the top of the file.
+//
+//	---
+//	min-xc-version: 0.9.0
+//	heading: Tasks
+//	shell: bash -euo pipefail
+//	env-files: [.env]
+//	includes:
+//	  - services/api/README.md
+//	---
+type FileConfig struct {
+	// MinVersion is the oldest version of xc that supports the file, such as 0.9.0.
+	MinVersion string
+	// Heading is the heading of the tasks section.
+	Heading string
+	// Shell is the command that runs scripts without a shebang, instead of the built-in shell.
+	Shell string
+	// EnvFiles are dotenv files loaded into the environment of every task.
+	EnvFiles []string
+	// Includes are other task files whose tasks are available alongside those in this file.
+	Includes []string
+	// Uses are packages of shared tasks, such as org/common-tasks@v2, whose tasks are available
+	// alongside those in this file once installed with xc pkg install.
+	Uses []string
+	// Signers are public keys, or files containing them, one of which must have signed each package
+	// and file included from a URL before it is installed.
+	Signers []string
+	// ShellOpts are the shell options scripts run with, unless a task sets its own, nil if not set.
+	ShellOpts []string
+	// Wrapper is the command the shell scripts of tasks run inside, such as nix develop -c,
+	// unless a task sets its own, nil if not set.
+	Wrapper []string
+	// NoIndentedCode stops code blocks indented by 4 spaces being parsed as scripts, only fenced code blocks are.
+	NoIndentedCode bool
+	// Bell rings the terminal bell once a run has finished.
+	Bell bool
+	// Summary prints a summary of each run once it has finished: its duration, how many tasks ran,
+	// were skipped and failed, and the cache hit rate.
+	Summary bool
+	// KeepLastRun keeps the report and logs of the last run of each task for xc report.
+	KeepLastRun bool
+	// Duplicates decides which task is kept when more than one task has the same name.
+	Duplicates DuplicatePolicy
+	// Profiles are named sets of environment variables, defined in the Profiles section of the file
+	// rather than the front matter.
+	Profiles map[string][]string
 }
 
 // Tasks is an alias type for []Task
 type Tasks []Task
 
+// Localize returns the tasks with the description of each replaced by its translation into the first of langs
+// it has been translated into, such as fr_FR.UTF-8 or pt-BR. A language with a region also matches a translation
+// into the language alone, so fr-ca uses the fr description if there is no fr-ca one.
+func (ts Tasks) Localize(langs []string) Tasks {
+	var tags []string
+	for _, lang := range langs {
+		tag := NormalizeLanguage(lang)
+		if tag == "" {
+			continue
+		}
+		tags = append(tags, tag)
+		if base, _, ok := strings.Cut(tag, "-"); ok {
+			tags = append(tags, base)
+		}
+	}
+	if len(tags) == 0 || len(ts) == 0 {
+		return ts
+	}
+	localized := make(Tasks, len(ts))
+	for i, t := range ts {
+		for _, tag := range tags {
+			if d, ok := t.Translations[tag]; ok {
+				t.Description = d
+				break
+			}
+		}
+		localized[i] = t
+	}
+	return localized
+}
+
+// NormalizeLanguage returns a language tag or locale, such as fr_FR.UTF-8, as a lower case tag such as fr-fr,
+// or an empty string for the C and POSIX locales.
+func NormalizeLanguage(lang string) string {
+	lang, _, _ = strings.Cut(lang, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	lang = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	if lang == "c" || lang == "posix" {
+		return ""
+	}
+	return lang
+}
+
 // Get returns a task by name, case insensitively.
 func (ts Tasks) Get(tsname string) (task Task, ok bool) {
 	for _, t := range ts {
@@ -95,3 +462,355 @@ func ParseRequiredBehaviour(s string) (RequiredBehaviour, bool) {
 		return 0, false
 	}
 }
+
+// RestartPolicy is when `xc up` restarts a service task that has exited.
+// The default is RestartNo.
+type RestartPolicy int
+
+const (
+	// RestartNo never restarts a service.
+	RestartNo RestartPolicy = iota
+	// RestartOnFailure restarts a service that exits with an error.
+	RestartOnFailure
+	// RestartAlways restarts a service whenever it exits.
+	RestartAlways
+	// RestartOnUnhealthy restarts a service whose healthcheck fails, or that exits with an error.
+	RestartOnUnhealthy
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	case RestartOnUnhealthy:
+		return "on-unhealthy"
+	}
+	return "no"
+}
+
+func ParseRestartPolicy(s string) (RestartPolicy, bool) {
+	switch strings.ToLower(s) {
+	case "no":
+		return RestartNo, true
+	case "on-failure":
+		return RestartOnFailure, true
+	case "always":
+		return RestartAlways, true
+	case "on-unhealthy":
+		return RestartOnUnhealthy, true
+	default:
+		return 0, false
+	}
+}
+
+// DuplicatePolicy decides which task is kept when more than one task has the same name,
+// in a file or across the files it includes. The default is DuplicatesError.
+type DuplicatePolicy int
+
+const (
+	// DuplicatesError makes more than one task with the same name an error.
+	DuplicatesError DuplicatePolicy = iota
+	// DuplicatesFirstWins keeps the first task with a name.
+	DuplicatesFirstWins
+	// DuplicatesLastWins keeps the last task with a name, so later tasks override earlier ones.
+	DuplicatesLastWins
+)
+
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case DuplicatesFirstWins:
+		return "first-wins"
+	case DuplicatesLastWins:
+		return "last-wins"
+	}
+	return "error"
+}
+
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, bool) {
+	switch strings.ToLower(s) {
+	case "error":
+		return DuplicatesError, true
+	case "first-wins":
+		return DuplicatesFirstWins, true
+	case "last-wins":
+		return DuplicatesLastWins, true
+	default:
+		return 0, false
+	}
+}
+
+const (
+	// PriorityHigh is the priority of a task marked high.
+	PriorityHigh = 1
+	// PriorityLow is the priority of a task marked low.
+	PriorityLow = -1
+)
+
+// ParsePriority parses high, low or an integer, tasks with a higher priority start first
+// when more tasks are ready to run than can run at once. The default is 0.
+func ParsePriority(s string) (int, error) {
+	switch strings.ToLower(s) {
+	case "high":
+		return PriorityHigh, nil
+	case "low":
+		return PriorityLow, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid priority %q should be (high, low) or an integer", s)
+	}
+	return n, nil
+}
+
+// shellOpts are the options that can be set with `set -o` in scripts run by the shell.
+var shellOpts = map[string]bool{
+	"allexport": true, "errexit": true, "noglob": true, "nounset": true, "pipefail": true, "xtrace": true,
+}
+
+// ParseShellOpts parses shell options such as errexit, pipefail and xtrace, or none for no options.
+func ParseShellOpts(values []string) ([]string, error) {
+	opts := []string{}
+	for _, v := range values {
+		v = strings.ToLower(v)
+		if v == "none" {
+			continue
+		}
+		if !shellOpts[v] {
+			return nil, fmt.Errorf("invalid shell option %q should be "+
+				"(allexport, errexit, noglob, nounset, pipefail, xtrace, none)", v)
+		}
+		opts = append(opts, v)
+	}
+	return opts, nil
+}
+
+// ParseWrapper parses the command that wraps the scripts of a task, such as nix develop -c, into its words,
+// none is an empty wrapper so that a task can opt out of the wrapper of the task file.
+func ParseWrapper(s string) []string {
+	if strings.EqualFold(s, "none") {
+		return []string{}
+	}
+	return strings.Fields(s)
+}
+
+// ParseUmask parses an octal file mode creation mask, such as 022 or 0077.
+func ParseUmask(s string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o777 {
+		return 0, fmt.Errorf("invalid umask %q should be an octal number between 000 and 777", s)
+	}
+	return os.FileMode(n), nil
+}
+
+// stopSignals are the signals a service task can be stopped with.
+var stopSignals = []string{"SIGINT", "SIGTERM", "SIGQUIT", "SIGHUP", "SIGKILL"}
+
+// ParseStopSignal parses the signal a service task is stopped with, such as SIGTERM or TERM,
+// returning its name with the SIG prefix.
+func ParseStopSignal(s string) (string, error) {
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	for _, sig := range stopSignals {
+		if sig == name {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid stop signal %q should be (%s)", s, strings.Join(stopSignals, ", "))
+}
+
+// ParseNice parses the niceness added to the processes of a task, an integer between -20 and 19.
+func ParseNice(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -20 || n > 19 {
+		return 0, fmt.Errorf("invalid nice %q should be an integer between -20 and 19", s)
+	}
+	return n, nil
+}
+
+// IO scheduling classes of the processes of a task.
+const (
+	IONiceRealtime   = "realtime"
+	IONiceBestEffort = "best-effort"
+	IONiceIdle       = "idle"
+)
+
+// ParseIONice parses the IO scheduling class of the processes of a task, idle, or best-effort or realtime
+// with an optional priority between 0, the highest, and 7, such as best-effort:7.
+// level is -1 if no priority is given.
+func ParseIONice(s string) (class string, level int, err error) {
+	class, l, hasLevel := strings.Cut(strings.ToLower(s), ":")
+	invalid := fmt.Errorf("invalid ionice %q should be idle, best-effort[:0-7] or realtime[:0-7]", s)
+	switch class {
+	case IONiceRealtime, IONiceBestEffort:
+	case IONiceIdle:
+		if hasLevel {
+			return "", 0, invalid
+		}
+	default:
+		return "", 0, invalid
+	}
+	if !hasLevel {
+		return class, -1, nil
+	}
+	if level, err = strconv.Atoi(l); err != nil || level < 0 || level > 7 {
+		return "", 0, invalid
+	}
+	return class, level, nil
+}
+
+// ReadyWhen is the condition for a service task to be ready, either when a line of its output matches Pattern,
+// or when Command exits successfully.
+type ReadyWhen struct {
+	Pattern *regexp.Regexp
+	Command string
+}
+
+// ParseReadyWhen parses a regular expression between slashes, such as /listening on :\d+/, or a command.
+func ParseReadyWhen(s string) (ReadyWhen, error) {
+	if len(s) < 2 || !strings.HasPrefix(s, "/") || !strings.HasSuffix(s, "/") {
+		if s == "" {
+			return ReadyWhen{}, errors.New("ready-when should be a command or a /regular expression/")
+		}
+		return ReadyWhen{Command: s}, nil
+	}
+	re, err := regexp.Compile(s[1 : len(s)-1])
+	if err != nil {
+		return ReadyWhen{}, fmt.Errorf("invalid pattern %s: %w", s, err)
+	}
+	return ReadyWhen{Pattern: re}, nil
+}
+
+// ParseCompose splits the entries of the compose attribute of a task into docker compose files,
+// those ending in .yml or .yaml, and the names of the services in them, if there are none every service is required.
+func ParseCompose(entries []string) (files, services []string) {
+	for _, e := range entries {
+		if strings.HasSuffix(e, ".yml") || strings.HasSuffix(e, ".yaml") {
+			files = append(files, e)
+			continue
+		}
+		services = append(services, e)
+	}
+	return files, services
+}
+
+// DefaultHealthcheckInterval is how often the healthcheck of a service task is run if no interval is given.
+const DefaultHealthcheckInterval = 10 * time.Second
+
+// Healthcheck is a command that succeeds while a service task is healthy, run every Interval once it is ready.
+type Healthcheck struct {
+	Command  string
+	Interval time.Duration
+}
+
+var healthcheckEveryRe = regexp.MustCompile(`^(.*?)\s+every\s+(\S+)$`)
+
+// ParseHealthcheck parses a command optionally followed by how often it is run,
+// such as `curl -sf localhost:8080` every 5s.
+func ParseHealthcheck(s string) (Healthcheck, error) {
+	hc := Healthcheck{Command: s, Interval: DefaultHealthcheckInterval}
+	if m := healthcheckEveryRe.FindStringSubmatch(s); m != nil {
+		d, err := time.ParseDuration(m[2])
+		if err != nil || d <= 0 {
+			return Healthcheck{}, fmt.Errorf("invalid healthcheck interval %q should be such as 10s", m[2])
+		}
+		hc.Command, hc.Interval = m[1], d
+	}
+	if hc.Command = strings.Trim(hc.Command, "` \"'"); hc.Command == "" {
+		return Healthcheck{}, errors.New("healthcheck should be a command, optionally followed by every <interval>")
+	}
+	return hc, nil
+}
+
+// OutputAssertion is a file that must exist after a task has run.
+type OutputAssertion struct {
+	Path string
+	// NonEmpty requires the file to have a size greater than zero.
+	NonEmpty bool
+	// Newer requires the file to have been modified while the task ran.
+	Newer bool
+}
+
+func (o OutputAssertion) String() string {
+	s := o.Path
+	if o.NonEmpty {
+		s += " non-empty"
+	}
+	if o.Newer {
+		s += " newer"
+	}
+	return s
+}
+
+// ParseOutputAssertion parses a path followed by any of the conditions non-empty and newer.
+func ParseOutputAssertion(s string) (o OutputAssertion, err error) {
+	fs := strings.Fields(s)
+	if len(fs) == 0 {
+		return o, fmt.Errorf("output has no path")
+	}
+	o.Path = fs[0]
+	for _, c := range fs[1:] {
+		switch strings.ToLower(c) {
+		case "non-empty":
+			o.NonEmpty = true
+		case "newer":
+			o.Newer = true
+		default:
+			return o, fmt.Errorf("output %s has invalid condition %q should be (non-empty, newer)", o.Path, c)
+		}
+	}
+	return o, nil
+}
+
+// Dependency is an entry in the requires or steps of a Task:
+// the name of a task, its inputs and the environment variables it is run with.
+//
+//	deploy production with REGION=eu-west-1 DRY_RUN=true
+type Dependency struct {
+	Name   string
+	Inputs []string
+	Env    []string
+}
+
+// ParseDependency parses an entry in the requires or steps of a Task.
+func ParseDependency(entry string) (d Dependency, err error) {
+	fs, err := shlex.Split(entry)
+	if err != nil {
+		return d, fmt.Errorf("invalid task %q: %w", entry, err)
+	}
+	if len(fs) == 0 {
+		return d, fmt.Errorf("missing task name")
+	}
+	d.Name = fs[0]
+	args := fs[1:]
+	for i, f := range args {
+		if f != "with" {
+			continue
+		}
+		d.Env = args[i+1:]
+		args = args[:i]
+		if len(d.Env) == 0 {
+			return d, fmt.Errorf("%s has no environment variables after with", d.Name)
+		}
+		break
+	}
+	d.Inputs = args
+	for _, e := range d.Env {
+		if !strings.Contains(e, "=") {
+			return d, fmt.Errorf("%s has invalid environment variable %q should be KEY=VALUE", d.Name, e)
+		}
+	}
+	return d, nil
+}
+
+// Node identifies a task run with its environment, the same task run with different
+// environment variables is a different node in the dependency graph.
+func (d Dependency) Node() string {
+	if len(d.Env) == 0 {
+		return d.Name
+	}
+	return d.Name + " with " + strings.Join(d.Env, " ")
+}
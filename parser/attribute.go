@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// unknownAttributeRe matches the names of attributes that are neither built in nor registered,
+// such as team or sla. They are lower case, other lines containing a colon, such as `Note: ...`,
+// are part of the description.
+var unknownAttributeRe = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// AttributeFunc parses the value of a custom attribute, returning the value stored in the Metadata of the task
+// or an error if the value is invalid.
+type AttributeFunc func(value string) (string, error)
+
+var (
+	customAttributesMu sync.RWMutex
+	customAttributes   = map[string]AttributeFunc{}
+)
+
+// RegisterAttribute adds a custom attribute, such as team, to the attributes recognised by the parser.
+// The value of the attribute is passed to fn and the result is stored in the Metadata of the task, keyed by the
+// lower case name, so programs embedding xc can add attributes without changing the parser.
+//
+// RegisterAttribute panics if fn is nil, or if name is empty, is a built-in attribute or is already registered.
+func RegisterAttribute(name string, fn AttributeFunc) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if fn == nil {
+		panic("parser: RegisterAttribute fn is nil")
+	}
+	if _, ok := attMap[key]; ok || key == "" {
+		panic(fmt.Sprintf("parser: RegisterAttribute called with a built-in attribute name %q", name))
+	}
+	customAttributesMu.Lock()
+	defer customAttributesMu.Unlock()
+	if _, ok := customAttributes[key]; ok {
+		panic(fmt.Sprintf("parser: RegisterAttribute called twice for %q", name))
+	}
+	customAttributes[key] = fn
+}
+
+// BuiltInAttributes returns the names of the attributes built in to xc, sorted.
+func BuiltInAttributes() []string {
+	names := make([]string, 0, len(attMap))
+	for name := range attMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisteredAttributes returns the names of the registered custom attributes, sorted.
+func RegisteredAttributes() []string {
+	customAttributesMu.RLock()
+	defer customAttributesMu.RUnlock()
+	names := make([]string, 0, len(customAttributes))
+	for name := range customAttributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func customAttribute(name string) (AttributeFunc, bool) {
+	customAttributesMu.RLock()
+	defer customAttributesMu.RUnlock()
+	fn, ok := customAttributes[name]
+	return fn, ok
+}
+
+// parseCustomAttribute parses the value, rest, of the custom attribute name into the Metadata of the current task.
+func (p *parser) parseCustomAttribute(name string, fn AttributeFunc, rest string) error {
+	if _, ok := p.currTask.Metadata[name]; ok {
+		return fmt.Errorf("%s appears more than once for %s", name, p.currTask.Name)
+	}
+	v, err := fn(strings.Trim(p.parseAttributeContinuation(rest), trimValues))
+	if err != nil {
+		return fmt.Errorf("%s is invalid for %s: %w", name, p.currTask.Name, err)
+	}
+	if p.currTask.Metadata == nil {
+		p.currTask.Metadata = map[string]string{}
+	}
+	p.currTask.Metadata[name] = v
+	return nil
+}
+
+// parseUnknownAttribute parses a line such as `team: platform` with a name that is neither built in nor registered
+// into the Metadata of the current task, reporting whether the line is such an attribute.
+// Repeated attributes are joined with a comma.
+func (p *parser) parseUnknownAttribute(name, rest string) bool {
+	v := strings.Trim(rest, trimValues)
+	if !unknownAttributeRe.MatchString(name) || v == "" || strings.HasPrefix(v, "//") {
+		return false
+	}
+	v = strings.Trim(p.parseAttributeContinuation(rest), trimValues)
+	if p.currTask.Metadata == nil {
+		p.currTask.Metadata = map[string]string{}
+	}
+	if prev, ok := p.currTask.Metadata[name]; ok {
+		v = prev + ", " + v
+	}
+	p.currTask.Metadata[name] = v
+	return true
+}
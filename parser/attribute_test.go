@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterAttribute(t *testing.T) {
+	RegisterAttribute("Tier", func(value string) (string, error) {
+		if !strings.HasPrefix(value, "@") {
+			return "", errors.New("should start with @")
+		}
+		return value, nil
+	})
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## deploy
+tier: `+"`@platform`"+`
+requires: build
+`), "tasks")
+	tasks, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tasks[0].Metadata["tier"]; got != "@platform" {
+		t.Fatalf("Metadata[tier]=%s, want=@platform", got)
+	}
+	if got := RegisteredAttributes(); len(got) != 1 || got[0] != "tier" {
+		t.Fatalf("RegisteredAttributes=%v, want=[tier]", got)
+	}
+	p, _ = NewParser(strings.NewReader("Tier: platform"), "tasks")
+	if _, err := p.parseAttribute(); err == nil {
+		t.Fatal("expected error got nil")
+	}
+	p, _ = NewParser(strings.NewReader("Tier: @a\nTier: @b"), "tasks")
+	if _, err := p.parseAttribute(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.parseAttribute(); err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestRegisterAttributeBuiltIn(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	RegisterAttribute("requires", func(value string) (string, error) { return value, nil })
+}
+
+func TestUnknownAttribute(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## deploy
+Deploys the release, see https://example.com.
+Note: it takes a few minutes.
+**team:** platform
+sla: 99.9%,
+  business hours
+sla: weekdays
+Release notes: are published.
+`+"```"+`
+./deploy.sh
+`+"```"+`
+`), "tasks")
+	tasks, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"team": "platform", "sla": "99.9%, business hours, weekdays"}
+	if len(tasks[0].Metadata) != len(expected) {
+		t.Fatalf("Metadata=%v, want=%v", tasks[0].Metadata, expected)
+	}
+	for k, v := range expected {
+		if tasks[0].Metadata[k] != v {
+			t.Fatalf("Metadata[%s]=%s, want=%s", k, tasks[0].Metadata[k], v)
+		}
+	}
+	got := strings.Join(tasks[0].Description, "|")
+	expectedDescription := "Deploys the release, see https://example.com.|Note: it takes a few minutes.|" +
+		"Release notes: are published."
+	if got != expectedDescription {
+		t.Fatalf("Description=%s", got)
+	}
+}
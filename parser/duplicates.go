@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+// Dedupe returns tasks with a single task for each name, compared case insensitively.
+// A task with Override set replaces the other tasks with its name, otherwise, or if more than one task
+// with the name has Override set, the task kept is chosen by policy.
+// The task kept takes the place of the first task with its name.
+//
+// With DuplicatesError the first task is kept with its ParsingError set, so it cannot be run,
+// and an error is returned for each task with the name of an earlier one.
+func Dedupe(tasks models.Tasks, policy models.DuplicatePolicy) (models.Tasks, []*LineError) {
+	overridden := map[string]bool{}
+	for _, t := range tasks {
+		if t.Override {
+			overridden[strings.ToLower(t.Name)] = true
+		}
+	}
+	var errs []*LineError
+	deduped := make(models.Tasks, 0, len(tasks))
+	index := map[string]int{}
+	for _, t := range tasks {
+		name := strings.ToLower(t.Name)
+		i, ok := index[name]
+		if !ok {
+			index[name] = len(deduped)
+			deduped = append(deduped, t)
+			continue
+		}
+		switch {
+		case overridden[name] && !t.Override:
+		case overridden[name] && !deduped[i].Override:
+			deduped[i] = t
+		case policy == models.DuplicatesFirstWins:
+		case policy == models.DuplicatesLastWins:
+			deduped[i] = t
+		default:
+			err := &LineError{Line: t.Line, Err: fmt.Errorf("task %s is defined more than once", t.Name)}
+			errs = append(errs, err)
+			if deduped[i].ParsingError == "" {
+				deduped[i].ParsingError = err.Err.Error()
+			}
+		}
+	}
+	return deduped, errs
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestDedupe(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Script: "first", Line: 1},
+		{Name: "test", Script: "test", Line: 5},
+		{Name: "Build", Script: "second", Line: 9},
+		{Name: "build", Script: "third", Line: 13},
+	}
+	tests := []struct {
+		policy      models.DuplicatePolicy
+		expected    []string
+		errLines    []int
+		parsingErrs []string
+	}{
+		{
+			policy:      models.DuplicatesError,
+			expected:    []string{"build: first", "test: test"},
+			errLines:    []int{9, 13},
+			parsingErrs: []string{"task Build is defined more than once", ""},
+		},
+		{
+			policy:      models.DuplicatesFirstWins,
+			expected:    []string{"build: first", "test: test"},
+			parsingErrs: []string{"", ""},
+		},
+		{
+			policy:      models.DuplicatesLastWins,
+			expected:    []string{"build: third", "test: test"},
+			parsingErrs: []string{"", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			deduped, errs := Dedupe(tasks, tt.policy)
+			var got, parsingErrs []string
+			for _, ta := range deduped {
+				got = append(got, ta.Name+": "+ta.Script)
+				parsingErrs = append(parsingErrs, ta.ParsingError)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected tasks %v got %v", tt.expected, got)
+			}
+			if !reflect.DeepEqual(parsingErrs, tt.parsingErrs) {
+				t.Fatalf("expected parsing errors %q got %q", tt.parsingErrs, parsingErrs)
+			}
+			var lines []int
+			for _, err := range errs {
+				lines = append(lines, err.Line)
+			}
+			if !reflect.DeepEqual(lines, tt.errLines) {
+				t.Fatalf("expected errors on lines %v got %v", tt.errLines, lines)
+			}
+		})
+	}
+	if tasks[0].ParsingError != "" {
+		t.Fatal("expected the tasks passed to Dedupe to be unchanged")
+	}
+}
+
+func TestDedupeOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		tasks    models.Tasks
+		expected string
+		errs     int
+	}{
+		{
+			name: "given an override before the task, should keep the override",
+			tasks: models.Tasks{
+				{Name: "build", Script: "local", Override: true},
+				{Name: "build", Script: "shared"},
+			},
+			expected: "local",
+		},
+		{
+			name: "given an override after the tasks, should keep the override",
+			tasks: models.Tasks{
+				{Name: "build", Script: "shared"},
+				{Name: "Build", Script: "other"},
+				{Name: "build", Script: "local", Override: true},
+			},
+			expected: "local",
+		},
+		{
+			name: "given two overrides, should use the policy",
+			tasks: models.Tasks{
+				{Name: "build", Script: "shared"},
+				{Name: "build", Script: "first", Override: true},
+				{Name: "build", Script: "second", Override: true},
+			},
+			expected: "first",
+			errs:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deduped, errs := Dedupe(tt.tasks, models.DuplicatesError)
+			if len(deduped) != 1 || deduped[0].Script != tt.expected {
+				t.Fatalf("expected the %s task got %+v", tt.expected, deduped)
+			}
+			if len(errs) != tt.errs {
+				t.Fatalf("expected %d errors got %v", tt.errs, errs)
+			}
+		})
+	}
+}
+
+func TestParseDuplicates(t *testing.T) {
+	in := "## Tasks\n### build\n```\nfirst\n```\n### build\n```\nsecond\n```\n"
+	p, err := NewParser(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Parse(); err == nil || err.Error() != "task build is defined more than once" {
+		t.Fatalf("expected a duplicate task error got %v", err)
+	}
+
+	p, err = NewParser(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Lenient()
+	tasks, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ParsingError == "" || len(p.Warnings()) != 1 || p.Warnings()[0].Line != 6 {
+		t.Fatalf("expected the task with a parsing error and a warning on line 6 got %+v, %v", tasks, p.Warnings())
+	}
+
+	p, err = NewParser(strings.NewReader(in), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Duplicates(models.DuplicatesLastWins)
+	if tasks, err = p.Parse(); err != nil || len(tasks) != 1 || tasks[0].Script != "second\n" {
+		t.Fatalf("expected the second build task got %+v, %v", tasks, err)
+	}
+}
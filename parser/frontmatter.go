@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+const frontMatterDelimiter = "---"
+
+var versionRe = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// parseFrontMatter reads YAML front matter if the first line of the file is ---.
+//
+// Only the top level keys of models.FileConfig are read, other keys are ignored
+// as front matter is often used by static site generators.
+func (p *parser) parseFrontMatter() (c models.FileConfig, err error) {
+	p.scan()
+	if strings.TrimRight(p.nextLine, " \t") != frontMatterDelimiter {
+		return c, nil
+	}
+	p.scan()
+	start := p.currentLineNo
+	var lines []string
+	for p.scan() {
+		if strings.TrimRight(p.currentLine, " \t") == frontMatterDelimiter {
+			return parseFrontMatterYAML(lines, start+1)
+		}
+		lines = append(lines, p.currentLine)
+	}
+	return c, fmt.Errorf("front matter starting on line %d is not closed with %s", start, frontMatterDelimiter)
+}
+
+// parseFrontMatterYAML parses the subset of YAML used by models.FileConfig: scalars,
+// flow lists such as [a, b] and block lists of items starting with -. firstLine is the line number of lines[0].
+func parseFrontMatterYAML(lines []string, firstLine int) (c models.FileConfig, err error) {
+	var key string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(stripYAMLComment(line))
+		if trimmed == "" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "-"); ok && (item == "" || item[0] == ' ') {
+			list := frontMatterList(&c, key)
+			if list == nil {
+				continue
+			}
+			*list = append(*list, unquoteYAML(strings.TrimSpace(item)))
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// Nested values of keys that are not part of the config.
+			continue
+		}
+		k, v, found := strings.Cut(trimmed, ":")
+		if !found {
+			return c, fmt.Errorf("invalid front matter on line %d: %q", firstLine+i, line)
+		}
+		key = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		switch key {
+		case "heading":
+			c.Heading = unquoteYAML(v)
+		case "shell":
+			c.Shell = unquoteYAML(v)
+		case "wrapper":
+			c.Wrapper = models.ParseWrapper(unquoteYAML(v))
+		case "indented-code":
+			b, err := strconv.ParseBool(unquoteYAML(v))
+			if err != nil {
+				return c, fmt.Errorf("invalid front matter on line %d: indented-code %q should be (true, false)", firstLine+i, v)
+			}
+			c.NoIndentedCode = !b
+		case "bell", "summary", "keep-last-run":
+			b, err := strconv.ParseBool(unquoteYAML(v))
+			if err != nil {
+				return c, fmt.Errorf("invalid front matter on line %d: %s %q should be (true, false)", firstLine+i, key, v)
+			}
+			switch key {
+			case "bell":
+				c.Bell = b
+			case "summary":
+				c.Summary = b
+			default:
+				c.KeepLastRun = b
+			}
+		case "duplicates":
+			d, ok := models.ParseDuplicatePolicy(unquoteYAML(v))
+			if !ok {
+				return c, fmt.Errorf("invalid front matter on line %d: duplicates %q should be (error, first-wins, last-wins)",
+					firstLine+i, v)
+			}
+			c.Duplicates = d
+		case "min-xc-version":
+			c.MinVersion = strings.TrimPrefix(unquoteYAML(v), "v")
+			if !versionRe.MatchString(c.MinVersion) {
+				return c, fmt.Errorf("invalid front matter on line %d: min-xc-version %q should be a version such as 0.9.0",
+					firstLine+i, v)
+			}
+		default:
+			list := frontMatterList(&c, key)
+			if list == nil {
+				continue
+			}
+			if !strings.HasPrefix(v, "[") {
+				*list = append(*list, unquoteYAML(v))
+				continue
+			}
+			if !strings.HasSuffix(v, "]") {
+				return c, fmt.Errorf("invalid front matter on line %d: %s is missing ]", firstLine+i, key)
+			}
+			for _, item := range strings.Split(v[1:len(v)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*list = append(*list, unquoteYAML(item))
+				}
+			}
+		}
+	}
+	if c.ShellOpts != nil {
+		var values []string
+		for _, o := range c.ShellOpts {
+			for _, v := range strings.Split(o, ",") {
+				values = append(values, strings.TrimSpace(v))
+			}
+		}
+		if c.ShellOpts, err = models.ParseShellOpts(values); err != nil {
+			return c, fmt.Errorf("invalid front matter: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// frontMatterList returns the list in c set by key, or nil if key is not a list.
+func frontMatterList(c *models.FileConfig, key string) *[]string {
+	switch key {
+	case "env-files", "env_files":
+		return &c.EnvFiles
+	case "includes", "include":
+		return &c.Includes
+	case "uses":
+		return &c.Uses
+	case "signers":
+		return &c.Signers
+	case "shell-opts":
+		return &c.ShellOpts
+	}
+	return nil
+}
+
+func stripYAMLComment(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return ""
+	}
+	if i := strings.Index(line, " #"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+func unquoteYAML(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	switch {
+	case s[0] == '"' && s[len(s)-1] == '"':
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	case s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestFrontMatter(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		heading      string
+		expected     models.FileConfig
+		expectTask   string
+		expectTaskLn int
+		expectErr    bool
+	}{
+		{
+			name: "given front matter, should parse the config",
+			in: `---
+title: "Project" # used by the docs site
+menu: { main: { weight: 1 } }
+params:
+  - not: config
+min-xc-version: v0.9.1
+shell: 'bash -euo pipefail'
+env-files: [.env, ".env.local"]
+includes:
+  - services/api/README.md
+  - "services/web/README.md"
+uses: [org/common-tasks@v2]
+signers:
+  - keys/org.pub
+bell: true
+summary: "true"
+keep-last-run: true
+---
+# Tasks
+## build
+` + "```\ngo build\n```\n",
+			expected: models.FileConfig{
+				MinVersion:  "0.9.1",
+				Shell:       "bash -euo pipefail",
+				EnvFiles:    []string{".env", ".env.local"},
+				Includes:    []string{"services/api/README.md", "services/web/README.md"},
+				Uses:        []string{"org/common-tasks@v2"},
+				Signers:     []string{"keys/org.pub"},
+				Bell:        true,
+				Summary:     true,
+				KeepLastRun: true,
+			},
+			expectTask:   "build",
+			expectTaskLn: 20,
+		},
+		{
+			name: "given a heading in the front matter, should use it",
+			in: `---
+heading: Scripts
+---
+# Tasks
+## ignored
+# Scripts
+## build
+` + "```\ngo build\n```\n",
+			expected:     models.FileConfig{Heading: "Scripts"},
+			expectTask:   "build",
+			expectTaskLn: 7,
+		},
+		{
+			name: "given a heading to NewParser, should take precedence over the front matter",
+			in: `---
+heading: Scripts
+---
+# Tasks
+## build
+` + "```\ngo build\n```\n",
+			heading:      "tasks",
+			expected:     models.FileConfig{Heading: "Scripts"},
+			expectTask:   "build",
+			expectTaskLn: 5,
+		},
+		{
+			name:         "given no front matter, should parse tasks",
+			in:           "# Tasks\n## build\n```\ngo build\n```\n",
+			expectTask:   "build",
+			expectTaskLn: 2,
+		},
+		{
+			name:         "given shell-opts, should parse them",
+			in:           "---\nshell-opts: errexit, pipefail\n---\n# Tasks\n## build\n```\ngo build\n```\n",
+			expected:     models.FileConfig{ShellOpts: []string{"errexit", "pipefail"}},
+			expectTask:   "build",
+			expectTaskLn: 5,
+		},
+		{
+			name:         "given a wrapper, should parse its words",
+			in:           "---\nwrapper: \"nix develop -c\"\n---\n# Tasks\n## build\n```\ngo build\n```\n",
+			expected:     models.FileConfig{Wrapper: []string{"nix", "develop", "-c"}},
+			expectTask:   "build",
+			expectTaskLn: 5,
+		},
+		{
+			name:      "given an invalid shell option, should fail",
+			in:        "---\nshell-opts: [errexit, verbose]\n---\n# Tasks\n",
+			expectErr: true,
+		},
+		{
+			name: "given a duplicates policy, should parse it",
+			in: `---
+duplicates: last-wins
+---
+# Tasks
+## build
+` + "```\ngo build\n```\n" + `
+## build
+` + "```\ngo build ./...\n```\n",
+			expected:     models.FileConfig{Duplicates: models.DuplicatesLastWins},
+			expectTask:   "build",
+			expectTaskLn: 10,
+		},
+		{
+			name:      "given an invalid duplicates policy, should fail",
+			in:        "---\nduplicates: override\n---\n# Tasks\n",
+			expectErr: true,
+		},
+		{
+			name:      "given an invalid summary, should fail",
+			in:        "---\nsummary: always\n---\n# Tasks\n",
+			expectErr: true,
+		},
+		{
+			name:      "given an invalid min-xc-version, should fail",
+			in:        "---\nmin-xc-version: latest\n---\n# Tasks\n",
+			expectErr: true,
+		},
+		{
+			name:      "given unclosed front matter, should fail",
+			in:        "---\nshell: bash\n# Tasks\n",
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewParser(strings.NewReader(tt.in), tt.heading)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(p.FileConfig(), tt.expected) {
+				t.Fatalf("expected %+v got %+v", tt.expected, p.FileConfig())
+			}
+			tasks, err := p.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != 1 || tasks[0].Name != tt.expectTask || tasks[0].Line != tt.expectTaskLn {
+				t.Fatalf("expected task %s on line %d got %+v", tt.expectTask, tt.expectTaskLn, tasks)
+			}
+		})
+	}
+}
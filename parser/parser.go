@@ -5,48 +5,193 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/joerdav/xc/glob"
 	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/schedule"
+	"github.com/joerdav/xc/terminal"
+	"github.com/joerdav/xc/tools"
 )
 
 // ErrNoTasksHeading is returned if the markdown contains no xc block
 var ErrNoTasksHeading = errors.New("no xc block found")
 
+// ProfilesHeading is the heading of the section that defines named sets of environment variables,
+// selected with xc -profile.
+const ProfilesHeading = "Profiles"
+
+// DefaultHeading is the heading of the tasks section if neither NewParser nor the front matter of the file set one.
+const DefaultHeading = "Tasks"
+
+// LineError is returned by Parse for an error at a line of the file.
+type LineError struct {
+	// Line is the 1-based line number the error was found at.
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
 const trimValues = "_*` "
+
+// trimPatterns is used for values where `*` and `_` are meaningful, such as globs and cron expressions.
+const trimPatterns = "` \"'"
 const codeBlockStarter = "```"
 
+// deferredInfo marks a code block, such as ```sh deferred, as the deferred script of a task
+// which runs after its script even if the script fails or is interrupted.
+const deferredInfo = "deferred"
+
+// stdinInfo marks a code block, such as ```yaml stdin, as the standard input of the script of a task.
+const stdinInfo = "stdin"
+
 type parser struct {
 	scanner               *bufio.Scanner
-	tasks                 models.Tasks
 	currTask              models.Task
+	currSteps             []step
 	rootHeadingLevel      int
 	nextLine, currentLine string
-	reachedEnd            bool
+	// previousLine is the line before currentLine.
+	previousLine string
+	// lastText is the last non-blank line of the description of the current task.
+	lastText string
+	// nextLineNo and currentLineNo are the 1-based line numbers of nextLine and currentLine.
+	nextLineNo, currentLineNo int
+	reachedEnd                bool
+	// consumedEnd is set once the last line has been read past.
+	consumedEnd bool
+	// pending are lines to return from scan before those from the scanner, see rewind.
+	pending []string
+	// done is set once Next has returned the last task or an error.
+	done   bool
+	config models.FileConfig
+	// lenient is set by Lenient, warnings are the errors it has recovered from.
+	lenient  bool
+	warnings []*LineError
 }
 
+// Lenient makes the parser recover from errors in a task, such as an invalid attribute or a code block
+// that is not ended, rather than returning them, so that one malformed task does not stop the others being parsed.
+// The task is returned with its ParsingError set, so it cannot be run, and the error is kept as a warning.
+// Errors in the front matter or profiles, and errors reading the file, are still returned.
+func (p *parser) Lenient() {
+	p.lenient = true
+}
+
+// Duplicates sets the policy for tasks with the same name, in place of the one in the front matter of the file.
+func (p *parser) Duplicates(policy models.DuplicatePolicy) {
+	p.config.Duplicates = policy
+}
+
+// Warnings returns the errors a lenient parser has recovered from so far.
+func (p *parser) Warnings() []*LineError {
+	return p.warnings
+}
+
+// warn reports whether a lenient parser can recover from err, found at line,
+// keeping it as a warning and as the ParsingError of the current task if so.
+func (p *parser) warn(err error, line int) bool {
+	if !p.lenient {
+		return false
+	}
+	var le *LineError
+	if !errors.As(err, &le) {
+		le = &LineError{Line: line, Err: err}
+	}
+	p.warnings = append(p.warnings, le)
+	if p.currTask.ParsingError == "" {
+		p.currTask.ParsingError = le.Err.Error()
+	}
+	return true
+}
+
+// Parse parses the remaining tasks, returning those parsed before an error along with the error.
+// Tasks with the same name are kept according to the duplicates policy of the file, see Dedupe.
 func (p *parser) Parse() (tasks models.Tasks, err error) {
-	ok := true
-	for ok {
-		ok, err = p.parseTask()
-		if err != nil || !ok {
+	for {
+		t, err := p.Next()
+		if errors.Is(err, io.EOF) {
 			break
 		}
+		if err != nil {
+			return tasks, err
+		}
+		tasks = append(tasks, t)
 	}
-	tasks = p.tasks
-	return
+	tasks, errs := Dedupe(tasks, p.config.Duplicates)
+	for _, err := range errs {
+		if !p.lenient {
+			return tasks, err
+		}
+		p.warnings = append(p.warnings, err)
+	}
+	return tasks, nil
+}
+
+// Next parses the next task, returning io.EOF once there are no more tasks.
+// Tasks with the same name as an earlier task are returned, as the duplicates policy is applied by Parse.
+// Tasks are not kept by the parser, so large files can be read one task at a time without holding every task in memory.
+// The FileConfig is complete once Next has returned io.EOF, as a Profiles section may follow the tasks.
+func (p *parser) Next() (models.Task, error) {
+	for !p.done {
+		more, err := p.parseTask()
+		p.done = err != nil || !more
+		task, found := p.currTask, p.currTask.Name != ""
+		// A profiles section may follow the tasks section.
+		if err == nil && p.done && !p.consumedEnd {
+			if _, err = p.findHeading(""); errors.Is(err, ErrNoTasksHeading) {
+				err = nil
+			}
+		}
+		if err != nil {
+			p.done = true
+			var le *LineError
+			if !errors.As(err, &le) {
+				err = &LineError{Line: p.currentLineNo, Err: err}
+			}
+			return models.Task{}, err
+		}
+		if found {
+			return task, nil
+		}
+	}
+	return models.Task{}, io.EOF
+}
+
+// FileConfig returns the configuration in the front matter of the file.
+func (p *parser) FileConfig() models.FileConfig {
+	return p.config
 }
 
 func (p *parser) scan() bool {
 	if p.reachedEnd {
+		p.consumedEnd = true
 		return false
 	}
+	p.previousLine = p.currentLine
 	p.currentLine = p.nextLine
+	p.currentLineNo = p.nextLineNo
+	if len(p.pending) > 0 {
+		p.nextLine, p.pending = p.pending[0], p.pending[1:]
+		p.nextLineNo++
+		return true
+	}
 	if !p.scanner.Scan() {
 		p.reachedEnd = true
 		return true
 	}
 	p.nextLine = p.scanner.Text()
+	p.nextLineNo++
 	return true
 }
 
@@ -130,17 +275,147 @@ const (
 	// AttrubuteTypeRun sets the tasks requiredBehaviour, can be always or once.
 	// Default is always
 	AttributeTypeRun
+	// AttributeTypeSchedule sets a cron expression at which `xc cron` runs the task.
+	AttributeTypeSchedule
+	// AttributeTypeForeach sets a list of items or glob patterns, the task is run once
+	// for each item with XC_ITEM set to the item.
+	AttributeTypeForeach
+	// AttributeTypeForeachParallel sets whether the items of a foreach task run in parallel.
+	AttributeTypeForeachParallel
+	// AttributeTypeRequiresTools sets the binaries, optionally with a minimum version,
+	// that must be installed for a Task to run.
+	AttributeTypeRequiresTools
+	// AttributeTypeAssertOutputs sets the files that must exist after a Task has run.
+	AttributeTypeAssertOutputs
+	// AttributeTypeSources sets glob patterns of the files a Task depends on,
+	// used to skip tasks that are not affected by a change.
+	AttributeTypeSources
+	// AttributeTypeConcurrencyGroup sets a name shared by Tasks that must never run at the same time,
+	// such as Tasks that use the same database.
+	AttributeTypeConcurrencyGroup
+	// AttributeTypePriority sets which Tasks start first when more are ready to run
+	// than can run at once, can be high, low or an integer. Default is 0.
+	AttributeTypePriority
+	// AttributeTypeNetwork sets whether a Task has network access, if false its scripts
+	// run in a sandbox without network access. Default is true.
+	AttributeTypeNetwork
+	// AttributeTypeService sets whether a Task is a service, such as a database, that runs in the background
+	// while the Tasks that require it run, and is stopped at the end of the run. Default is false.
+	AttributeTypeService
+	// AttributeTypeReadyWhen sets when a service Task is ready for the Tasks that require it to start,
+	// either a command that succeeds once it is ready or a /regular expression/ matching a line of its output.
+	AttributeTypeReadyWhen
+	// AttributeTypeHealthcheck sets a command that succeeds while a service Task is healthy,
+	// run by `xc up` once it is ready, optionally followed by how often it is run such as every 5s.
+	// Default interval is 10s.
+	AttributeTypeHealthcheck
+	// AttributeTypeCompose sets the docker compose services a Task requires, and optionally the compose files that define
+	// them such as compose.dev.yaml, every service if none are named. They are started before its scripts run
+	// and removed at the end of the run.
+	AttributeTypeCompose
+	// AttributeTypeRestart sets when `xc up` restarts a service Task that has exited, can be no, on-failure, on-unhealthy
+	// or always. Default is no.
+	AttributeTypeRestart
+	// AttributeTypeStop sets a command that stops a service Task, run when it is stopped instead of sending it a signal.
+	AttributeTypeStop
+	// AttributeTypeStopSignal sets the signal a service Task is stopped with, such as SIGTERM. Default is SIGINT.
+	AttributeTypeStopSignal
+	// AttributeTypeStopTimeout sets how long a service Task has to exit once it is asked to stop, before it is killed,
+	// such as 10s. Default is 2s.
+	AttributeTypeStopTimeout
+	// AttributeTypeAllowPaths sets the only paths the scripts of a Task may write to, relative to its directory,
+	// they run in a sandbox in which everything else is read-only.
+	AttributeTypeAllowPaths
+	// AttributeTypeDenyPaths sets paths the scripts of a Task may neither read nor write, relative to its directory.
+	AttributeTypeDenyPaths
+	// AttributeTypeUmask sets the octal file mode creation mask of the scripts of a Task, such as 022.
+	AttributeTypeUmask
+	// AttributeTypeUser sets the user, as a name or uid[:gid], that the scripts of a Task run as,
+	// typically to drop privileges when xc runs as root in a container.
+	AttributeTypeUser
+	// AttributeTypeNice sets the niceness added to the processes of a Task, between -20 and 19,
+	// so that a resource-hungry Task such as a file watcher does not slow down the others. Default is 0.
+	AttributeTypeNice
+	// AttributeTypeIONice sets the IO scheduling class of the processes of a Task, idle, best-effort or realtime,
+	// with an optional priority between 0 and 7 such as best-effort:7. Only applied on Linux.
+	AttributeTypeIONice
+	// AttributeTypeShellOpts sets the shell options the scripts of a Task run with, such as errexit, pipefail and xtrace,
+	// or none. Default is the shell-opts of the front matter, or errexit and xtrace.
+	AttributeTypeShellOpts
+	// AttributeTypeWrapper sets a command the shell scripts of a Task run inside, such as nix develop -c
+	// or devcontainer exec --workspace-folder ., or none. Default is the wrapper of the front matter, or none.
+	AttributeTypeWrapper
+	// AttributeTypeOwner sets who to contact about a Task, such as a team or an email address.
+	AttributeTypeOwner
+	// AttributeTypeApproval sets a question that must be approved before the scripts of a Task run,
+	// such as a deployment, the run waits for the answer.
+	AttributeTypeApproval
+	// AttributeTypeDocs sets a link to the documentation of a Task, such as a runbook.
+	AttributeTypeDocs
+	// AttributeTypeOverride sets whether a Task replaces another Task with the same name,
+	// such as one from a shared file of tasks that is included. Default is false.
+	AttributeTypeOverride
+	// AttributeTypeIcon sets an emoji or other short text shown next to a Task when tasks are listed,
+	// to tell categories of tasks apart at a glance.
+	AttributeTypeIcon
+	// AttributeTypeColor sets the color of the name of a Task when tasks are listed and of its prefix in `xc up`,
+	// one of red, green, yellow, blue, magenta or cyan.
+	AttributeTypeColor
+	// AttributeTypeWSL sets whether the shell scripts of a Task run inside the Windows Subsystem for Linux
+	// when xc runs on Windows, it has no effect on other systems. Default is false.
+	AttributeTypeWSL
+	// AttributeTypeCIOnly sets whether a Task only runs in CI, such as a deploy to production,
+	// running it anywhere else fails. Default is false.
+	AttributeTypeCIOnly
+	// AttributeTypeLocalOnly sets whether a Task never runs in CI, such as a task that resets a local database,
+	// running it in CI fails. Default is false.
+	AttributeTypeLocalOnly
 )
 
 var attMap = map[string]AttributeType{
-	"req":         AttributeTypeReq,
-	"requires":    AttributeTypeReq,
-	"env":         AttributeTypeEnv,
-	"environment": AttributeTypeEnv,
-	"dir":         AttributeTypeDir,
-	"directory":   AttributeTypeDir,
-	"inputs":      AttributeTypeInp,
-	"run":         AttributeTypeRun,
+	"req":               AttributeTypeReq,
+	"requires":          AttributeTypeReq,
+	"env":               AttributeTypeEnv,
+	"environment":       AttributeTypeEnv,
+	"dir":               AttributeTypeDir,
+	"directory":         AttributeTypeDir,
+	"inputs":            AttributeTypeInp,
+	"run":               AttributeTypeRun,
+	"schedule":          AttributeTypeSchedule,
+	"foreach":           AttributeTypeForeach,
+	"foreach-parallel":  AttributeTypeForeachParallel,
+	"requires-tools":    AttributeTypeRequiresTools,
+	"assert-outputs":    AttributeTypeAssertOutputs,
+	"sources":           AttributeTypeSources,
+	"concurrency-group": AttributeTypeConcurrencyGroup,
+	"priority":          AttributeTypePriority,
+	"network":           AttributeTypeNetwork,
+	"service":           AttributeTypeService,
+	"ready-when":        AttributeTypeReadyWhen,
+	"healthcheck":       AttributeTypeHealthcheck,
+	"compose":           AttributeTypeCompose,
+	"restart":           AttributeTypeRestart,
+	"stop":              AttributeTypeStop,
+	"stop-signal":       AttributeTypeStopSignal,
+	"stop-timeout":      AttributeTypeStopTimeout,
+	"allow-paths":       AttributeTypeAllowPaths,
+	"deny-paths":        AttributeTypeDenyPaths,
+	"umask":             AttributeTypeUmask,
+	"user":              AttributeTypeUser,
+	"nice":              AttributeTypeNice,
+	"ionice":            AttributeTypeIONice,
+	"shell-opts":        AttributeTypeShellOpts,
+	"wrapper":           AttributeTypeWrapper,
+	"owner":             AttributeTypeOwner,
+	"docs":              AttributeTypeDocs,
+	"approval":          AttributeTypeApproval,
+	"override":          AttributeTypeOverride,
+	"icon":              AttributeTypeIcon,
+	"color":             AttributeTypeColor,
+	"colour":            AttributeTypeColor,
+	"wsl":               AttributeTypeWSL,
+	"ci-only":           AttributeTypeCIOnly,
+	"local-only":        AttributeTypeLocalOnly,
 }
 
 func (p *parser) parseAttribute() (bool, error) {
@@ -148,23 +423,70 @@ func (p *parser) parseAttribute() (bool, error) {
 	if !found {
 		return false, nil
 	}
-	ty, ok := attMap[strings.ToLower(strings.Trim(a, trimValues))]
+	name := strings.ToLower(strings.Trim(a, trimValues))
+	if m := translationRe.FindStringSubmatch(name); m != nil {
+		p.parseTranslation(models.NormalizeLanguage(m[1]), rest)
+		p.scan()
+		return true, nil
+	}
+	ty, ok := attMap[name]
 	if !ok {
-		return false, nil
+		if fn, ok := customAttribute(name); ok {
+			if err := p.parseCustomAttribute(name, fn, rest); err != nil {
+				return false, err
+			}
+			p.scan()
+			return true, nil
+		}
+		// Unknown attributes are matched case sensitively, so that prose such as `Note: ...` stays in the description.
+		if !p.parseUnknownAttribute(strings.Trim(a, trimValues), rest) {
+			return false, nil
+		}
+		p.scan()
+		return true, nil
+	}
+	var vs []string
+	if listAttributes[ty] && strings.Trim(rest, trimValues) == "" {
+		vs = p.parseAttributeList()
+	} else {
+		rest = p.parseAttributeContinuation(rest)
+		vs = strings.Split(rest, ",")
+		if ty == AttributeTypeInp {
+			// The contract of an input may contain commas, such as AWS_REGION (required, from: AWS_DEFAULT_REGION).
+			vs = splitOutsideParens(rest)
+		}
 	}
 	switch ty {
 	case AttributeTypeInp:
-		vs := strings.Split(rest, ",")
 		for _, v := range vs {
-			p.currTask.Inputs = append(p.currTask.Inputs, strings.Trim(v, trimValues))
+			in, err := parseInput(v)
+			if err != nil {
+				return false, fmt.Errorf("inputs is invalid for %s: %w", p.currTask.Name, err)
+			}
+			p.currTask.Inputs = append(p.currTask.Inputs, in.name)
+			if len(in.sources) > 0 {
+				if p.currTask.InputSources == nil {
+					p.currTask.InputSources = map[string][]string{}
+				}
+				p.currTask.InputSources[in.name] = in.sources
+			}
+			if len(in.values) == 0 {
+				continue
+			}
+			if p.currTask.InputValues == nil {
+				p.currTask.InputValues = map[string][]string{}
+			}
+			p.currTask.InputValues[in.name] = in.values
 		}
 	case AttributeTypeReq:
-		vs := strings.Split(rest, ",")
 		for _, v := range vs {
-			p.currTask.DependsOn = append(p.currTask.DependsOn, strings.Trim(v, trimValues))
+			v = strings.Trim(v, trimValues)
+			if _, err := models.ParseDependency(v); err != nil {
+				return false, fmt.Errorf("requires is invalid for %s: %w", p.currTask.Name, err)
+			}
+			p.currTask.DependsOn = append(p.currTask.DependsOn, v)
 		}
 	case AttributeTypeEnv:
-		vs := strings.Split(rest, ",")
 		for _, v := range vs {
 			p.currTask.Env = append(p.currTask.Env, strings.Trim(v, trimValues))
 		}
@@ -181,48 +503,567 @@ func (p *parser) parseAttribute() (bool, error) {
 			return false, fmt.Errorf("run contains invalid behaviour %q should be (always, once): %s", s, p.currTask.Name)
 		}
 		p.currTask.RequiredBehaviour = r
+	case AttributeTypeSchedule:
+		s := strings.Trim(rest, trimPatterns)
+		if _, err := schedule.Parse(s); err != nil {
+			return false, fmt.Errorf("schedule is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.Schedule = s
+	case AttributeTypeForeach:
+		vs := strings.Split(rest, ",")
+		for _, v := range vs {
+			p.currTask.Foreach = append(p.currTask.Foreach, strings.Trim(v, trimPatterns))
+		}
+	case AttributeTypeForeachParallel:
+		s := strings.Trim(rest, trimValues)
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("foreach-parallel contains invalid value %q should be (true, false): %s",
+				s, p.currTask.Name)
+		}
+		p.currTask.ForeachParallel = b
+	case AttributeTypeRequiresTools:
+		vs := strings.Split(rest, ",")
+		for _, v := range vs {
+			v = strings.Trim(v, trimValues)
+			if _, err := tools.Parse(v); err != nil {
+				return false, fmt.Errorf("requires-tools is invalid for %s: %w", p.currTask.Name, err)
+			}
+			p.currTask.RequiresTools = append(p.currTask.RequiresTools, v)
+		}
+	case AttributeTypeAssertOutputs:
+		vs := strings.Split(rest, ",")
+		for _, v := range vs {
+			o, err := models.ParseOutputAssertion(strings.Trim(v, trimValues))
+			if err != nil {
+				return false, fmt.Errorf("assert-outputs is invalid for %s: %w", p.currTask.Name, err)
+			}
+			p.currTask.AssertOutputs = append(p.currTask.AssertOutputs, o)
+		}
+	case AttributeTypeSources:
+		vs := strings.Split(rest, ",")
+		for _, v := range vs {
+			v = strings.Trim(v, trimPatterns)
+			if !glob.Valid(v) {
+				return false, fmt.Errorf("sources contains invalid pattern %q: %s", v, p.currTask.Name)
+			}
+			p.currTask.Sources = append(p.currTask.Sources, v)
+		}
+	case AttributeTypeConcurrencyGroup:
+		if p.currTask.ConcurrencyGroup != "" {
+			return false, fmt.Errorf("concurrency-group appears more than once for %s", p.currTask.Name)
+		}
+		p.currTask.ConcurrencyGroup = strings.Trim(rest, trimValues)
+	case AttributeTypePriority:
+		n, err := models.ParsePriority(strings.Trim(rest, trimValues))
+		if err != nil {
+			return false, fmt.Errorf("priority is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.Priority = n
+	case AttributeTypeNetwork:
+		s := strings.Trim(rest, trimValues)
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("network contains invalid value %q should be (true, false): %s", s, p.currTask.Name)
+		}
+		p.currTask.NoNetwork = !b
+	case AttributeTypeService:
+		s := strings.Trim(rest, trimValues)
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("service contains invalid value %q should be (true, false): %s", s, p.currTask.Name)
+		}
+		p.currTask.Service = b
+	case AttributeTypeWSL:
+		s := strings.Trim(rest, trimValues)
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("wsl contains invalid value %q should be (true, false): %s", s, p.currTask.Name)
+		}
+		p.currTask.WSL = b
+	case AttributeTypeCIOnly:
+		s := strings.Trim(rest, trimValues)
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("ci-only contains invalid value %q should be (true, false): %s", s, p.currTask.Name)
+		}
+		p.currTask.CIOnly = b
+	case AttributeTypeLocalOnly:
+		s := strings.Trim(rest, trimValues)
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("local-only contains invalid value %q should be (true, false): %s", s, p.currTask.Name)
+		}
+		p.currTask.LocalOnly = b
+	case AttributeTypeOverride:
+		s := strings.Trim(rest, trimValues)
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("override contains invalid value %q should be (true, false): %s", s, p.currTask.Name)
+		}
+		p.currTask.Override = b
+	case AttributeTypeReadyWhen:
+		s := strings.Trim(rest, trimPatterns)
+		if _, err := models.ParseReadyWhen(s); err != nil {
+			return false, fmt.Errorf("ready-when is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.ReadyWhen = s
+	case AttributeTypeHealthcheck:
+		s := strings.TrimSpace(rest)
+		if _, err := models.ParseHealthcheck(s); err != nil {
+			return false, fmt.Errorf("healthcheck is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.Healthcheck = s
+	case AttributeTypeCompose:
+		for _, v := range strings.Split(rest, ",") {
+			if v = strings.Trim(v, trimPatterns); v == "" {
+				return false, fmt.Errorf("compose contains an empty service: %s", p.currTask.Name)
+			}
+			p.currTask.Compose = append(p.currTask.Compose, v)
+		}
+	case AttributeTypeRestart:
+		s := strings.Trim(rest, trimValues)
+		rp, ok := models.ParseRestartPolicy(s)
+		if !ok {
+			return false, fmt.Errorf("restart contains invalid policy %q should be (no, on-failure, on-unhealthy, always): %s",
+				s, p.currTask.Name)
+		}
+		p.currTask.Restart = rp
+	case AttributeTypeStop:
+		s := strings.Trim(rest, trimPatterns)
+		if s == "" {
+			return false, fmt.Errorf("stop contains an empty command: %s", p.currTask.Name)
+		}
+		p.currTask.Stop = s
+	case AttributeTypeStopSignal:
+		sig, err := models.ParseStopSignal(strings.Trim(rest, trimValues))
+		if err != nil {
+			return false, fmt.Errorf("stop-signal is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.StopSignal = sig
+	case AttributeTypeStopTimeout:
+		s := strings.Trim(rest, trimValues)
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return false, fmt.Errorf("stop-timeout contains invalid duration %q should be such as 10s: %s", s, p.currTask.Name)
+		}
+		p.currTask.StopTimeout = d
+	case AttributeTypeAllowPaths:
+		for _, v := range strings.Split(rest, ",") {
+			if v = strings.Trim(v, trimPatterns); v == "" {
+				return false, fmt.Errorf("allow-paths contains an empty path: %s", p.currTask.Name)
+			}
+			p.currTask.AllowPaths = append(p.currTask.AllowPaths, v)
+		}
+	case AttributeTypeDenyPaths:
+		for _, v := range strings.Split(rest, ",") {
+			if v = strings.Trim(v, trimPatterns); v == "" {
+				return false, fmt.Errorf("deny-paths contains an empty path: %s", p.currTask.Name)
+			}
+			p.currTask.DenyPaths = append(p.currTask.DenyPaths, v)
+		}
+	case AttributeTypeUmask:
+		s := strings.Trim(rest, trimValues)
+		if _, err := models.ParseUmask(s); err != nil {
+			return false, fmt.Errorf("umask is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.Umask = s
+	case AttributeTypeUser:
+		s := strings.Trim(rest, trimValues)
+		if s == "" || strings.ContainsAny(s, " \t") {
+			return false, fmt.Errorf("user contains invalid value %q should be a name or uid[:gid]: %s", s, p.currTask.Name)
+		}
+		p.currTask.User = s
+	case AttributeTypeNice:
+		n, err := models.ParseNice(strings.Trim(rest, trimValues))
+		if err != nil {
+			return false, fmt.Errorf("nice is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.Nice = n
+	case AttributeTypeIONice:
+		s := strings.Trim(rest, trimValues)
+		if _, _, err := models.ParseIONice(s); err != nil {
+			return false, fmt.Errorf("ionice is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.IONice = strings.ToLower(s)
+	case AttributeTypeShellOpts:
+		var values []string
+		for _, v := range strings.Split(rest, ",") {
+			values = append(values, strings.Trim(v, trimValues))
+		}
+		opts, err := models.ParseShellOpts(values)
+		if err != nil {
+			return false, fmt.Errorf("shell-opts is invalid for %s: %w", p.currTask.Name, err)
+		}
+		p.currTask.ShellOpts = opts
+	case AttributeTypeWrapper:
+		s := strings.Trim(rest, trimValues)
+		if s == "" {
+			return false, fmt.Errorf("wrapper is empty for %s, should be a command or none", p.currTask.Name)
+		}
+		p.currTask.Wrapper = models.ParseWrapper(s)
+	case AttributeTypeOwner:
+		if p.currTask.Owner != "" {
+			return false, fmt.Errorf("owner appears more than once for %s", p.currTask.Name)
+		}
+		p.currTask.Owner = strings.Trim(rest, trimValues)
+	case AttributeTypeApproval:
+		if p.currTask.Approval != "" {
+			return false, fmt.Errorf("approval appears more than once for %s", p.currTask.Name)
+		}
+		p.currTask.Approval = strings.Trim(rest, trimValues)
+		if p.currTask.Approval == "" {
+			return false, fmt.Errorf("approval is empty for %s, it should be the question asked", p.currTask.Name)
+		}
+	case AttributeTypeDocs:
+		if p.currTask.Docs != "" {
+			return false, fmt.Errorf("docs appears more than once for %s", p.currTask.Name)
+		}
+		p.currTask.Docs = strings.Trim(rest, trimPatterns)
+	case AttributeTypeIcon:
+		if p.currTask.Icon != "" {
+			return false, fmt.Errorf("icon appears more than once for %s", p.currTask.Name)
+		}
+		p.currTask.Icon = strings.Trim(rest, trimValues)
+	case AttributeTypeColor:
+		s := strings.ToLower(strings.Trim(rest, trimValues))
+		if _, ok := terminal.Named(s); !ok {
+			return false, fmt.Errorf("color contains invalid value %q should be (%s): %s",
+				s, strings.Join(terminal.ColorNames(), ", "), p.currTask.Name)
+		}
+		p.currTask.Color = s
 	}
 	p.scan()
 	return true, nil
 }
 
+// translationRe matches the name of an attribute holding a translated description, such as description[pt-br].
+var translationRe = regexp.MustCompile(`^description\[([a-z]{2,8}(?:[-_][a-z0-9]{1,8})*)\]$`)
+
+// parseTranslation adds a line of the description of the current task in lang.
+func (p *parser) parseTranslation(lang, rest string) {
+	if p.currTask.Translations == nil {
+		p.currTask.Translations = map[string][]string{}
+	}
+	// The description is markdown, only the emphasis closing the attribute name, as in **Description[fr]:**, is trimmed.
+	d := strings.TrimSpace(strings.TrimLeft(p.parseAttributeContinuation(rest), "*_ "))
+	p.currTask.Translations[lang] = append(p.currTask.Translations[lang], d)
+}
+
+// input is an input of a task parsed from the inputs attribute.
+type input struct {
+	name string
+	// values are the values the input accepts, if they are listed.
+	values []string
+	// sources are the environment variables the input is read from, set if it has a contract.
+	sources []string
+}
+
+// envNameRe matches the names of environment variables.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseInput parses an input, such as ENVIRONMENT (staging|production)
+// or AWS_REGION (required, from: AWS_REGION|AWS_DEFAULT_REGION).
+// The parentheses hold the values the input accepts and its contract:
+// required, and from: the environment variables it is read from.
+func parseInput(s string) (in input, err error) {
+	name, rest, found := strings.Cut(s, "(")
+	in.name = strings.Trim(name, trimValues)
+	if !found {
+		return in, nil
+	}
+	rest, ok := strings.CutSuffix(strings.TrimRight(rest, trimValues), ")")
+	if !ok {
+		return input{}, fmt.Errorf("the values of input %s have no closing parenthesis", in.name)
+	}
+	var required bool
+	for _, item := range strings.Split(rest, ",") {
+		item = strings.Trim(item, trimValues)
+		key, list, isFrom := strings.Cut(item, ":")
+		switch {
+		case strings.EqualFold(item, "required"):
+			required = true
+		case isFrom && strings.EqualFold(strings.TrimSpace(key), "from"):
+			if in.sources != nil {
+				return input{}, fmt.Errorf("input %s has more than one from", in.name)
+			}
+			in.sources = []string{in.name}
+			for _, v := range strings.Split(list, "|") {
+				if v = strings.Trim(v, trimPatterns); v == "" || v == in.name {
+					continue
+				}
+				if !envNameRe.MatchString(v) {
+					return input{}, fmt.Errorf("input %s is read from %q, which is not the name of an environment variable",
+						in.name, v)
+				}
+				in.sources = append(in.sources, v)
+			}
+		case item == "":
+		default:
+			if in.values != nil {
+				return input{}, fmt.Errorf("input %s has more than one list of values, separate them with |", in.name)
+			}
+			in.values = []string{}
+			for _, v := range strings.Split(item, "|") {
+				if v = strings.Trim(v, trimPatterns); v != "" {
+					in.values = append(in.values, v)
+				}
+			}
+		}
+	}
+	if required && in.sources == nil {
+		in.sources = []string{in.name}
+	}
+	if len(in.values) == 0 && in.sources == nil {
+		return input{}, fmt.Errorf("input %s has no values between its parentheses", in.name)
+	}
+	return in, nil
+}
+
+// splitOutsideParens splits s at the commas that are not between parentheses.
+func splitOutsideParens(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// listAttributes are the attributes that can be written as a bullet list below the attribute name, such as:
+//
+//	**Env:**
+//	- REGION=eu-west-1
+//	- STAGE=prod
+var listAttributes = map[AttributeType]bool{
+	AttributeTypeInp: true,
+	AttributeTypeReq: true,
+	AttributeTypeEnv: true,
+}
+
+// parseAttributeList returns the items of the bullet list below an attribute without a value,
+// each item is a single value so it may contain commas. Blank lines before the list are skipped.
+func (p *parser) parseAttributeList() []string {
+	for !p.reachedEnd && strings.TrimSpace(p.nextLine) == "" {
+		p.scan()
+	}
+	var items []string
+	for !p.reachedEnd {
+		item, ok := cutBulletItem(p.nextLine)
+		if !ok {
+			break
+		}
+		p.scan()
+		items = append(items, item)
+	}
+	return items
+}
+
+// parseAttributeContinuation returns the value of an attribute, rest, joined with the lines that continue it,
+// those after a line ending in a backslash and indented lines directly below the attribute.
+func (p *parser) parseAttributeContinuation(rest string) string {
+	for !p.reachedEnd {
+		trimmed := strings.TrimRight(rest, " \t")
+		continued := strings.HasSuffix(trimmed, "\\")
+		if !continued && (strings.TrimSpace(p.nextLine) == "" || !strings.ContainsAny(p.nextLine[:1], " \t")) {
+			break
+		}
+		if continued {
+			trimmed = strings.TrimSuffix(trimmed, "\\")
+		}
+		p.scan()
+		rest = trimmed + " " + strings.TrimSpace(p.currentLine)
+	}
+	return rest
+}
+
 func (p *parser) parseCodeBlock() error {
 	t := p.currentLine
 	if len(t) < 3 || t[:3] != codeBlockStarter {
 		return nil
 	}
-	if len(p.currTask.Script) > 0 {
-		return fmt.Errorf("command block already exists for task %s", p.currTask.Name)
+	var language, role string
+	for i, f := range strings.Fields(strings.Trim(t, "`")) {
+		switch {
+		case strings.EqualFold(f, deferredInfo), strings.EqualFold(f, stdinInfo):
+			role = strings.ToLower(f)
+		case i == 0:
+			language = strings.ToLower(f)
+		}
+	}
+	var script *string
+	switch role {
+	case deferredInfo:
+		script = &p.currTask.Deferred
+		p.currTask.DeferredLanguage = language
+	case stdinInfo:
+		script = &p.currTask.Stdin
+	default:
+		script = &p.currTask.Script
+		p.currTask.Language = language
+	}
+	if len(*script) > 0 {
+		err := fmt.Errorf("command block already exists for task %s", p.currTask.Name)
+		if role != "" {
+			err = fmt.Errorf("%s block already exists for task %s", role, p.currTask.Name)
+		}
+		if !p.warn(err, p.currentLineNo) {
+			return err
+		}
+		// The block is read past and discarded.
+		script = new(string)
 	}
+	start, fence := p.currentLineNo, p.currentLine
+	// lines are kept by a lenient parser, to rewind to if the block is not ended.
+	var lines []string
 	var ended bool
 	for p.scan() {
 		if len(p.currentLine) >= 3 && p.currentLine[:3] == codeBlockStarter {
 			ended = true
 			break
 		}
-		if strings.TrimSpace(p.currentLine) != "" {
-			p.currTask.Script += p.currentLine + "\n"
+		if p.lenient {
+			lines = append(lines, p.currentLine)
+		}
+		// Blank lines are kept in stdin, as they may be meaningful to the script reading it.
+		if role == stdinInfo || strings.TrimSpace(p.currentLine) != "" {
+			*script += p.currentLine + "\n"
+			if script == &p.currTask.Script {
+				p.currTask.ScriptLines = append(p.currTask.ScriptLines, p.currentLineNo)
+			}
 		}
 	}
 	if !ended {
-		return fmt.Errorf("command block in task %s was not ended", p.currTask.Name)
+		err := fmt.Errorf("command block in task %s was not ended", p.currTask.Name)
+		if !p.warn(err, start) {
+			return err
+		}
+		// The fence is stray, the lines after it are parsed again as they may contain other tasks.
+		*script = ""
+		if script == &p.currTask.Script {
+			p.currTask.ScriptLines = nil
+		}
+		p.rewind(start, fence, lines)
+		return nil
 	}
 	p.scan()
 	return nil
 }
 
+// rewind moves the parser back to the line after line n, given the line and the lines that followed it.
+func (p *parser) rewind(n int, line string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	p.currentLine, p.currentLineNo = line, n
+	p.nextLine, p.nextLineNo = lines[0], n+1
+	p.pending = lines[1:]
+	p.reachedEnd, p.consumedEnd = false, false
+	p.scan()
+}
+
+// parseIndentedCodeBlock parses a code block indented by 4 spaces or a tab as the script of the current task,
+// if it has none and indented code is not disabled in the front matter. It reports whether a block was parsed.
+//
+// As in CommonMark, the block must follow a blank line and indented lines that continue a list item are not code.
+func (p *parser) parseIndentedCodeBlock() bool {
+	if p.config.NoIndentedCode || len(p.currTask.Script) > 0 || strings.TrimSpace(p.previousLine) != "" {
+		return false
+	}
+	if _, ok := cutIndent(p.currentLine); !ok || strings.TrimSpace(p.currentLine) == "" {
+		return false
+	}
+	if _, indented := cutIndent(p.lastText); indented || isListItem(p.lastText) {
+		return false
+	}
+	for {
+		line, ok := cutIndent(p.currentLine)
+		if !ok && strings.TrimSpace(p.currentLine) != "" {
+			return true
+		}
+		if strings.TrimSpace(line) != "" {
+			p.currTask.Script += line + "\n"
+			p.currTask.ScriptLines = append(p.currTask.ScriptLines, p.currentLineNo)
+		}
+		if !p.scan() {
+			return true
+		}
+	}
+}
+
+// cutIndent returns line without the indentation of an indented code block, 4 spaces or a tab,
+// ok is false if it is not indented.
+func cutIndent(line string) (string, bool) {
+	if rest, ok := strings.CutPrefix(line, "\t"); ok {
+		return rest, true
+	}
+	return strings.CutPrefix(line, "    ")
+}
+
+func isListItem(line string) bool {
+	if _, ok := cutBulletItem(line); ok {
+		return true
+	}
+	_, ok := parseOrderedListItem(line)
+	return ok
+}
+
+// cutBulletItem returns the text of a bullet list item starting with -, * or +.
+func cutBulletItem(line string) (string, bool) {
+	t := strings.TrimSpace(line)
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if item, ok := strings.CutPrefix(t, marker); ok {
+			return item, true
+		}
+	}
+	return "", false
+}
+
+// step is an ordered list item in a task description,
+// it becomes one of the Task Steps if the task has no script.
+type step struct {
+	name        string
+	description int
+}
+
+func parseOrderedListItem(line string) (string, bool) {
+	n, rest, found := strings.Cut(strings.TrimSpace(line), ". ")
+	if !found || n == "" || strings.Trim(n, "0123456789") != "" {
+		return "", false
+	}
+	item := strings.Trim(rest, trimValues)
+	return item, item != ""
+}
+
 func (p *parser) findTaskHeading() (heading string, done bool, err error) {
 	for {
-		tok, level, text := p.parseHeading(true)
+		p.currTask.Line = p.currentLineNo
+		tok, level, text := p.parseHeading(false)
 		if !tok || level > p.rootHeadingLevel+1 {
 			if !p.scan() {
-				return "", false, fmt.Errorf("failed to read file: %w", p.scanner.Err())
+				if err := p.scanner.Err(); err != nil {
+					return "", false, fmt.Errorf("failed to read file: %w", err)
+				}
+				return "", true, nil
 			}
 			continue
 		}
 		if level <= p.rootHeadingLevel {
 			return "", true, nil
 		}
+		p.parseHeading(true)
 		return strings.Trim(text, trimValues), false, nil
 	}
 }
@@ -231,18 +1072,39 @@ func (p *parser) parseTaskBody() (bool, error) {
 	for {
 		ok, err := p.parseAttribute()
 		if err != nil {
-			return false, err
+			if !p.warn(err, p.currentLineNo) {
+				return false, err
+			}
+			// The attribute is skipped.
+			if !p.scan() {
+				return false, nil
+			}
+			continue
 		}
-		if p.reachedEnd {
+		if p.consumedEnd {
 			return false, nil
 		}
 		if ok {
 			continue
 		}
+		block := strings.HasPrefix(p.currentLine, codeBlockStarter)
 		err = p.parseCodeBlock()
 		if err != nil {
 			return false, err
 		}
+		if p.consumedEnd {
+			return false, nil
+		}
+		if block {
+			// The line after the block may start another block, such as a deferred block.
+			continue
+		}
+		if p.parseIndentedCodeBlock() {
+			if p.consumedEnd {
+				return false, nil
+			}
+			continue
+		}
 		tok, level, _ := p.parseHeading(false)
 		if tok && level <= p.rootHeadingLevel {
 			return false, nil
@@ -251,7 +1113,11 @@ func (p *parser) parseTaskBody() (bool, error) {
 			return true, nil
 		}
 		if strings.TrimSpace(p.currentLine) != "" {
-			p.currTask.Description = append(p.currTask.Description, strings.Trim(p.currentLine, trimValues))
+			p.lastText = p.currentLine
+			if s, ok := parseOrderedListItem(p.currentLine); ok {
+				p.currSteps = append(p.currSteps, step{name: s, description: len(p.currTask.Description)})
+			}
+			p.currTask.Description = append(p.currTask.Description, strings.TrimSpace(p.currentLine))
 		}
 		if !p.scan() {
 			return false, nil
@@ -259,8 +1125,28 @@ func (p *parser) parseTaskBody() (bool, error) {
 	}
 }
 
+// useSteps moves the ordered list items of a task without a script
+// out of its description and into its Steps.
+func (p *parser) useSteps() {
+	if len(p.currTask.Script) > 0 || len(p.currSteps) < 1 {
+		return
+	}
+	description := p.currTask.Description[:0:0]
+	for i, d := range p.currTask.Description {
+		if len(p.currSteps) > 0 && p.currSteps[0].description == i {
+			p.currTask.Steps = append(p.currTask.Steps, p.currSteps[0].name)
+			p.currSteps = p.currSteps[1:]
+			continue
+		}
+		description = append(description, d)
+	}
+	p.currTask.Description = description
+}
+
 func (p *parser) parseTask() (ok bool, err error) {
 	p.currTask = models.Task{}
+	p.currSteps = nil
+	p.lastText = ""
 	heading, done, err := p.findTaskHeading()
 	if err != nil || done {
 		return
@@ -270,26 +1156,106 @@ func (p *parser) parseTask() (ok bool, err error) {
 	if err != nil {
 		return
 	}
-	if len(p.currTask.Script) < 1 && len(p.currTask.DependsOn) < 1 {
-		err = fmt.Errorf("task %s has no commands or required tasks", p.currTask.Name)
-		return
+	p.useSteps()
+	if len(p.currTask.Script) < 1 && len(p.currTask.DependsOn) < 1 && len(p.currTask.Steps) < 1 &&
+		p.currTask.ParsingError == "" {
+		err = &LineError{
+			Line: p.currTask.Line,
+			Err:  fmt.Errorf("task %s has no commands, steps or required tasks", p.currTask.Name),
+		}
+		if p.warn(err, p.currTask.Line) {
+			err = nil
+		}
 	}
-	p.tasks = append(p.tasks, p.currTask)
 	return
 }
 
 // NewParser will read from r until it finds a valid xc heading block.
 // If no block is found an error is returned.
+// If heading is empty the heading set in the front matter of the file is used, or DefaultHeading.
 func NewParser(r io.Reader, heading string) (p parser, err error) {
 	p.scanner = bufio.NewScanner(r)
-	for p.scan() {
-		ok, level, text := p.parseHeading(true)
-		if !ok || !strings.EqualFold(strings.TrimSpace(text), strings.TrimSpace(heading)) {
-			continue
-		}
-		p.rootHeadingLevel = level
+	if p.config, err = p.parseFrontMatter(); err != nil {
 		return
 	}
-	err = ErrNoTasksHeading
+	if heading == "" {
+		heading = p.config.Heading
+	}
+	if heading == "" {
+		heading = DefaultHeading
+	}
+	if !p.scan() {
+		err = ErrNoTasksHeading
+		return
+	}
+	p.rootHeadingLevel, err = p.findHeading(heading)
 	return
 }
+
+// findHeading reads from the current line until it finds heading, returning its level, or ErrNoTasksHeading
+// if heading is empty or not found. Profiles sections found on the way are parsed into the FileConfig.
+func (p *parser) findHeading(heading string) (int, error) {
+	for {
+		ok, level, text := p.parseHeading(false)
+		text = strings.TrimSpace(text)
+		switch {
+		case ok && strings.EqualFold(text, ProfilesHeading):
+			p.parseHeading(true)
+			if err := p.parseProfiles(level); err != nil {
+				return 0, err
+			}
+			if p.consumedEnd {
+				return 0, ErrNoTasksHeading
+			}
+			continue
+		case ok && heading != "" && strings.EqualFold(text, strings.TrimSpace(heading)):
+			p.parseHeading(true)
+			return level, nil
+		}
+		if !p.scan() {
+			return 0, ErrNoTasksHeading
+		}
+	}
+}
+
+// parseProfiles parses a profiles section with a heading at level, each profile is a heading
+// one level below followed by env attributes:
+//
+//	## Profiles
+//	### staging
+//	Env: API_URL=https://staging.example.com, REPLICAS=2
+//
+// It returns at the next heading at or above level.
+func (p *parser) parseProfiles(level int) error {
+	if p.config.Profiles == nil {
+		p.config.Profiles = map[string][]string{}
+	}
+	var name string
+	for !p.consumedEnd {
+		ok, l, text := p.parseHeading(false)
+		switch {
+		case ok && l <= level:
+			return nil
+		case ok && l == level+1:
+			name = strings.Trim(text, trimValues)
+			if _, exists := p.config.Profiles[name]; exists {
+				return &LineError{Line: p.currentLineNo, Err: fmt.Errorf("profile %s is defined more than once", name)}
+			}
+			p.config.Profiles[name] = []string{}
+			p.parseHeading(true)
+			continue
+		case name != "":
+			p.currTask = models.Task{Name: "profile " + name}
+			isAttribute, err := p.parseAttribute()
+			if err != nil {
+				return &LineError{Line: p.currentLineNo, Err: err}
+			}
+			if isAttribute {
+				p.config.Profiles[name] = append(p.config.Profiles[name], p.currTask.Env...)
+				continue
+			}
+		}
+		p.scan()
+	}
+	return nil
+}
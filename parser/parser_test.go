@@ -5,8 +5,11 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
+	"io"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/joerdav/xc/models"
 )
@@ -28,6 +31,9 @@ func assertTask(t *testing.T, expected, actual models.Task) {
 	if expected.Script != actual.Script {
 		t.Fatalf("script want=%q got=%q", expected.Script, actual.Script)
 	}
+	if expected.Language != actual.Language {
+		t.Fatalf("language want=%q got=%q", expected.Language, actual.Language)
+	}
 	if expected.Dir != actual.Dir {
 		t.Fatalf("dir want=%q got=%q", expected.Dir, actual.Dir)
 	}
@@ -40,6 +46,9 @@ func assertTask(t *testing.T, expected, actual models.Task) {
 	if strings.Join(expected.Inputs, ",") != strings.Join(actual.Inputs, ",") {
 		t.Fatalf("inputs want=%v got=%v", expected.Inputs, actual.Inputs)
 	}
+	if strings.Join(expected.Steps, ",") != strings.Join(actual.Steps, ",") {
+		t.Fatalf("steps want=%v got=%v", expected.Steps, actual.Steps)
+	}
 }
 
 func TestParseFile(t *testing.T) {
@@ -83,6 +92,48 @@ echo "Hello, world2!"
 	}
 }
 
+func TestNext(t *testing.T) {
+	p, err := NewParser(strings.NewReader(s), "Tasks")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for {
+		task, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		names = append(names, task.Name)
+	}
+	if got := strings.Join(names, ","); got != "list,list2,hello,all-lists" {
+		t.Fatalf("tasks want=%q got=%q", "list,list2,hello,all-lists", got)
+	}
+	if _, err := p.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF after the last task got %v", err)
+	}
+}
+
+func TestNextError(t *testing.T) {
+	in := "## Tasks\n### ok\n```\nok\n```\n### empty\n### after\n```\nafter\n```\n"
+	p, err := NewParser(strings.NewReader(in), "Tasks")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if task, err := p.Next(); err != nil || task.Name != "ok" {
+		t.Fatalf("expected the ok task got %q, %v", task.Name, err)
+	}
+	var le *LineError
+	if _, err := p.Next(); !errors.As(err, &le) {
+		t.Fatalf("expected a LineError got %v", err)
+	}
+	if _, err := p.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF after an error got %v", err)
+	}
+}
+
 func TestParseFileNoTasks(t *testing.T) {
 	_, err := NewParser(strings.NewReader(e), "tasks")
 	if !errors.Is(err, ErrNoTasksHeading) {
@@ -107,6 +158,210 @@ func TestInvalidRun(t *testing.T) {
 	}
 }
 
+func TestTaskLines(t *testing.T) {
+	p, err := NewParser(strings.NewReader(s), "Tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{"list": 12, "list2": 19, "hello": 29, "all-lists": 43}
+	for _, task := range tasks {
+		if task.Line != expected[task.Name] {
+			t.Errorf("%s line want=%d got=%d", task.Name, expected[task.Name], task.Line)
+		}
+		if lines := strings.Count(task.Script, "\n"); len(task.ScriptLines) != lines {
+			t.Errorf("%s expected %d script lines got %v", task.Name, lines, task.ScriptLines)
+		}
+	}
+}
+
+func TestScriptLines(t *testing.T) {
+	in := "# Tasks\n\n## build\n\n```\ngo build\n\ngo test\n```\n\n## lint\n\n    golangci-lint run\n"
+	p, err := NewParser(strings.NewReader(in), "Tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]int{"build": {6, 8}, "lint": {13}}
+	for _, task := range tasks {
+		if !reflect.DeepEqual(task.ScriptLines, expected[task.Name]) {
+			t.Errorf("%s script lines want=%v got=%v", task.Name, expected[task.Name], task.ScriptLines)
+		}
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	tests := []struct {
+		name, md string
+		line     int
+	}{
+		{
+			name: "invalid attribute",
+			md:   "# Tasks\n\n## build\n\nrun: never\n\n```\ngo build\n```\n",
+			line: 5,
+		},
+		{
+			name: "task without commands",
+			md:   "# Tasks\n\n## build\n\n```\ngo build\n```\n\n## empty\n\nNothing here.\n",
+			line: 9,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewParser(strings.NewReader(tt.md), "Tasks")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = p.Parse()
+			var le *LineError
+			if !errors.As(err, &le) {
+				t.Fatalf("expected a LineError got: %v", err)
+			}
+			if le.Line != tt.line {
+				t.Errorf("line want=%d got=%d", tt.line, le.Line)
+			}
+		})
+	}
+}
+
+func TestInvalidSchedule(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("schedule: every day"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidRequiresTools(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("requires-tools: docker>=latest"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidAssertOutputs(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("assert-outputs: dist/app fresh"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidPriority(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("priority: urgent"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidReadyWhen(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("ready-when: /listening on [/"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidRestart(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("restart: sometimes"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidColor(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("color: chartreuse"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidOverride(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("override: always"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidUmask(t *testing.T) {
+	for _, in := range []string{"umask: 0999", "umask: 1777", "umask: rwx"} {
+		p, _ := NewParser(strings.NewReader(in), "tasks")
+		if _, err := p.parseAttribute(); err == nil {
+			t.Fatalf("expected error for %q got nil", in)
+		}
+	}
+}
+
+func TestInvalidStop(t *testing.T) {
+	invalid := []string{
+		"stop-signal: SIGUSR3", "stop-timeout: soon", "stop-timeout: -1s",
+		"healthcheck: ``", "healthcheck: curl -sf localhost every often", "compose: db,,redis",
+	}
+	for _, in := range invalid {
+		p, _ := NewParser(strings.NewReader(in), "tasks")
+		if _, err := p.parseAttribute(); err == nil {
+			t.Fatalf("expected error for %q got nil", in)
+		}
+	}
+}
+
+func TestInvalidNice(t *testing.T) {
+	invalid := []string{"nice: 20", "nice: -21", "nice: low", "ionice: fast", "ionice: idle:7", "ionice: best-effort:8"}
+	for _, in := range invalid {
+		p, _ := NewParser(strings.NewReader(in), "tasks")
+		if _, err := p.parseAttribute(); err == nil {
+			t.Fatalf("expected error for %q got nil", in)
+		}
+	}
+}
+
+func TestShellOptsNone(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("shell-opts: none"), "tasks")
+	if _, err := p.parseAttribute(); err != nil {
+		t.Fatal(err)
+	}
+	if p.currTask.ShellOpts == nil || len(p.currTask.ShellOpts) != 0 {
+		t.Fatalf("expected no shell options got %#v", p.currTask.ShellOpts)
+	}
+	p, _ = NewParser(strings.NewReader("shell-opts: errexit, verbose"), "tasks")
+	if _, err := p.parseAttribute(); err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestWrapperNone(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("wrapper: none"), "tasks")
+	if _, err := p.parseAttribute(); err != nil {
+		t.Fatal(err)
+	}
+	if p.currTask.Wrapper == nil || len(p.currTask.Wrapper) != 0 {
+		t.Fatalf("expected no wrapper got %#v", p.currTask.Wrapper)
+	}
+	p, _ = NewParser(strings.NewReader("wrapper: ``"), "tasks")
+	if _, err := p.parseAttribute(); err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestInvalidRequiresWith(t *testing.T) {
+	p, _ := NewParser(strings.NewReader("requires: deploy with staging"), "tasks")
+	_, err := p.parseAttribute()
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
 func TestCommandlessTask(t *testing.T) {
 	p, _ := NewParser(strings.NewReader(`
 # Tasks
@@ -131,6 +386,201 @@ requires: some-task
 	}
 }
 
+func TestStepsTask(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## release
+Release in order.
+
+1. test
+2. `+"`build linux`"+`
+3. publish
+`), "tasks")
+	_, err := p.parseTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTask(t, models.Task{
+		Name:        "release",
+		Description: []string{"Release in order."},
+		Steps:       []string{"test", "build linux", "publish"},
+	}, p.currTask)
+}
+
+func TestOrderedListWithScriptIsDescription(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## release
+1. first
+`+codeBlockStarter+`
+some code
+`+codeBlockStarter+`
+`), "tasks")
+	_, err := p.parseTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTask(t, models.Task{
+		Name:        "release",
+		Description: []string{"1. first"},
+		Script:      "some code\n",
+	}, p.currTask)
+}
+
+func TestCodeBlockLanguage(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## health
+`+codeBlockStarter+`HTTP title="check"
+GET http://localhost/health
+`+codeBlockStarter+`
+`), "tasks")
+	_, err := p.parseTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTask(t, models.Task{
+		Name:     "health",
+		Script:   "GET http://localhost/health\n",
+		Language: "http",
+	}, p.currTask)
+}
+
+func TestIndentedCodeBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		expected models.Task
+	}{
+		{
+			name: "given an indented code block, should parse it as the script",
+			in: "# Tasks\n## build\nBuild the binary.\n\n    go vet ./...\n\n\tgo build -o app .\n\n" +
+				"## test\n```\ngo test ./...\n```\n",
+			expected: models.Task{
+				Name:        "build",
+				Description: []string{"Build the binary."},
+				Script:      "go vet ./...\ngo build -o app .\n",
+			},
+		},
+		{
+			name: "given an indented code block at the end of the file, should parse it as the script",
+			in:   "# Tasks\n## build\nRequires: test\n\n      cd cmd\n    go build",
+			expected: models.Task{
+				Name:      "build",
+				DependsOn: []string{"test"},
+				Script:    "  cd cmd\ngo build\n",
+			},
+		},
+		{
+			name: "given an indented line continuing a paragraph, should not parse it as the script",
+			in:   "# Tasks\n## build\nBuild the\n    binary.\n```\ngo build\n```\n",
+			expected: models.Task{
+				Name:        "build",
+				Description: []string{"Build the", "binary."},
+				Script:      "go build\n",
+			},
+		},
+		{
+			name: "given an indented paragraph in a list item, should not parse it as the script",
+			in:   "# Tasks\n## release\n1. test\n\n    Runs every test.\n\n2. publish\n",
+			expected: models.Task{
+				Name:        "release",
+				Description: []string{"Runs every test."},
+				Steps:       []string{"test", "publish"},
+			},
+		},
+		{
+			name: "given indented code is disabled, should not parse it as the script",
+			in:   "---\nindented-code: false\n---\n# Tasks\n## build\n\n    go build\n\n```\ngo vet\n```\n",
+			expected: models.Task{
+				Name:        "build",
+				Description: []string{"go build"},
+				Script:      "go vet\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewParser(strings.NewReader(tt.in), "tasks")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = p.parseTask(); err != nil {
+				t.Fatal(err)
+			}
+			tt.expected.Line = p.currTask.Line
+			assertTask(t, tt.expected, p.currTask)
+		})
+	}
+}
+
+func TestMultilineAttribute(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## deploy
+Env: REGION=eu-west-1, \
+STAGE=prod,
+  BUCKET=releases
+Requires: build,
+	test
+Deploys the release.
+`+"```"+`
+./deploy.sh
+`+"```"+`
+`), "tasks")
+	tasks, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTask(t, models.Task{
+		Name:        "deploy",
+		Description: []string{"Deploys the release."},
+		Script:      "./deploy.sh\n",
+		DependsOn:   []string{"build", "test"},
+	}, tasks[0])
+	if got := strings.Join(tasks[0].Env, ","); got != "REGION=eu-west-1,STAGE=prod,BUCKET=releases" {
+		t.Fatalf("Env=%s, want=REGION=eu-west-1,STAGE=prod,BUCKET=releases", got)
+	}
+}
+
+func TestListAttribute(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## deploy
+**Env:**
+- REGION=eu-west-1
+- TAGS=a,b
+
+**Requires:**
+
+* build
+* test
+
+Inputs:
++ `+"`VERSION`"+`
+Deploys the release.
+
+- not an attribute
+`+"```"+`
+./deploy.sh
+`+"```"+`
+`), "tasks")
+	tasks, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTask(t, models.Task{
+		Name:        "deploy",
+		Description: []string{"Deploys the release.", "- not an attribute"},
+		Script:      "./deploy.sh\n",
+		DependsOn:   []string{"build", "test"},
+		Inputs:      []string{"VERSION"},
+	}, tasks[0])
+	if got := strings.Join(tasks[0].Env, ";"); got != "REGION=eu-west-1;TAGS=a,b" {
+		t.Fatalf("Env=%s, want=REGION=eu-west-1;TAGS=a,b", got)
+	}
+}
+
 func TestHeadingCaseInsensitive(t *testing.T) {
 	tests := []struct {
 		mdHeading, parserHeading string
@@ -180,6 +630,59 @@ some code
 	}
 }
 
+func TestDeferredCodeBlock(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## integration
+`+codeBlockStarter+`sh
+docker compose up -d
+go test ./...
+`+codeBlockStarter+`
+`+codeBlockStarter+`sh deferred
+docker compose down
+`+codeBlockStarter+`
+`), "tasks")
+	_, err := p.parseTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTask(t, models.Task{
+		Name:     "integration",
+		Script:   "docker compose up -d\ngo test ./...\n",
+		Language: "sh",
+	}, p.currTask)
+	if p.currTask.Deferred != "docker compose down\n" || p.currTask.DeferredLanguage != "sh" {
+		t.Fatalf("Deferred=%q DeferredLanguage=%q", p.currTask.Deferred, p.currTask.DeferredLanguage)
+	}
+	p, _ = NewParser(strings.NewReader("# Tasks\n## a\n```deferred\na\n```\n```deferred\nb\n```"), "tasks")
+	if _, err = p.parseTask(); err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
+
+func TestStdinCodeBlock(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`
+# Tasks
+## apply
+`+codeBlockStarter+`yaml stdin
+replicas: 3
+
+image: app
+`+codeBlockStarter+`
+`+codeBlockStarter+`
+kubectl apply -f -
+`+codeBlockStarter+`
+`), "tasks")
+	_, err := p.parseTask()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTask(t, models.Task{Name: "apply", Script: "kubectl apply -f -\n"}, p.currTask)
+	if p.currTask.Stdin != "replicas: 3\n\nimage: app\n" {
+		t.Fatalf("Stdin=%q", p.currTask.Stdin)
+	}
+}
+
 func TestMultipleCodeBlocks(t *testing.T) {
 	p, _ := NewParser(strings.NewReader("```\ncode\n```"), "tasks")
 	p.currTask.Script = "an existing script"
@@ -189,16 +692,130 @@ func TestMultipleCodeBlocks(t *testing.T) {
 	}
 }
 
+func TestInputValues(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		expectInputs []string
+		expectValues map[string][]string
+		expectFrom   map[string][]string
+		expectErr    bool
+	}{
+		{
+			name:         "given inputs without values, should not set values",
+			in:           "Inputs: ENVIRONMENT, VERSION",
+			expectInputs: []string{"ENVIRONMENT", "VERSION"},
+		},
+		{
+			name:         "given an input with values, should parse them",
+			in:           "Inputs: ENVIRONMENT (staging | `production`), VERSION",
+			expectInputs: []string{"ENVIRONMENT", "VERSION"},
+			expectValues: map[string][]string{"ENVIRONMENT": {"staging", "production"}},
+		},
+		{
+			name:      "given values without a closing parenthesis, should fail",
+			in:        "Inputs: ENVIRONMENT (staging|production",
+			expectErr: true,
+		},
+		{
+			name:      "given empty values, should fail",
+			in:        "Inputs: ENVIRONMENT ( | )",
+			expectErr: true,
+		},
+		{
+			name:         "given a required input, should read it from itself",
+			in:           "Inputs: AWS_REGION (required), VERSION",
+			expectInputs: []string{"AWS_REGION", "VERSION"},
+			expectFrom:   map[string][]string{"AWS_REGION": {"AWS_REGION"}},
+		},
+		{
+			name:         "given an input read from other variables, should list them after itself",
+			in:           "Inputs: AWS_REGION (required, from: AWS_REGION|`AWS_DEFAULT_REGION`), STAGE (dev|prod, required)",
+			expectInputs: []string{"AWS_REGION", "STAGE"},
+			expectValues: map[string][]string{"STAGE": {"dev", "prod"}},
+			expectFrom: map[string][]string{
+				"AWS_REGION": {"AWS_REGION", "AWS_DEFAULT_REGION"},
+				"STAGE":      {"STAGE"},
+			},
+		},
+		{
+			name:      "given an input read from an invalid name, should fail",
+			in:        "Inputs: AWS_REGION (from: AWS-REGION)",
+			expectErr: true,
+		},
+		{
+			name:      "given two lists of values, should fail",
+			in:        "Inputs: STAGE (dev, prod)",
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := NewParser(strings.NewReader(tt.in), "tasks")
+			_, err := p.parseAttribute()
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v got %v", tt.expectErr, err)
+			}
+			if tt.expectErr {
+				return
+			}
+			if !reflect.DeepEqual(p.currTask.Inputs, tt.expectInputs) {
+				t.Fatalf("Inputs=%v, want=%v", p.currTask.Inputs, tt.expectInputs)
+			}
+			if !reflect.DeepEqual(p.currTask.InputValues, tt.expectValues) {
+				t.Fatalf("InputValues=%v, want=%v", p.currTask.InputValues, tt.expectValues)
+			}
+			if !reflect.DeepEqual(p.currTask.InputSources, tt.expectFrom) {
+				t.Fatalf("InputSources=%v, want=%v", p.currTask.InputSources, tt.expectFrom)
+			}
+		})
+	}
+}
+
 func TestParseAttribute(t *testing.T) {
 	tests := []struct {
-		name            string
-		in              string
-		expectNotOk     bool
-		expectEnv       string
-		expectDir       string
-		expectDependsOn string
-		expectInputs    string
-		expectBehaviour models.RequiredBehaviour
+		name              string
+		in                string
+		expectNotOk       bool
+		expectEnv         string
+		expectDir         string
+		expectDependsOn   string
+		expectInputs      string
+		expectSchedule    string
+		expectForeach     string
+		expectParallel    bool
+		expectTools       string
+		expectOutputs     string
+		expectSources     string
+		expectGroup       string
+		expectPriority    int
+		expectNoNetwork   bool
+		expectService     bool
+		expectOverride    bool
+		expectReadyWhen   string
+		expectRestart     models.RestartPolicy
+		expectStop        string
+		expectHealthcheck string
+		expectSignal      string
+		expectTimeout     time.Duration
+		expectAllow       string
+		expectDeny        string
+		expectUmask       string
+		expectUser        string
+		expectNice        int
+		expectIONice      string
+		expectShellOpts   string
+		expectCompose     string
+		expectWrapper     string
+		expectOwner       string
+		expectApproval    string
+		expectDocs        string
+		expectIcon        string
+		expectColor       string
+		expectWSL         bool
+		expectCIOnly      bool
+		expectLocalOnly   bool
+		expectBehaviour   models.RequiredBehaviour
 	}{
 		{
 			name:      "given a basic Env, should parse",
@@ -295,6 +912,196 @@ func TestParseAttribute(t *testing.T) {
 			in:              "run: _*`once`*_",
 			expectBehaviour: models.RequiredBehaviourOnce,
 		},
+		{
+			name:           "given a schedule, should parse",
+			in:             "schedule: */5 * * * *",
+			expectSchedule: "*/5 * * * *",
+		},
+		{
+			name:           "given a quoted schedule, should parse",
+			in:             `Schedule: "0 9 * * mon-fri"`,
+			expectSchedule: "0 9 * * mon-fri",
+		},
+		{
+			name:          "given a foreach glob, should parse",
+			in:            "foreach: `services/*/`",
+			expectForeach: "services/*/",
+		},
+		{
+			name:          "given a foreach list, should parse",
+			in:            "Foreach: api, web",
+			expectForeach: "api,web",
+		},
+		{
+			name:           "given foreach-parallel, should parse",
+			in:             "foreach-parallel: true",
+			expectParallel: true,
+		},
+		{
+			name:        "given requires-tools, should parse",
+			in:          "requires-tools: `docker>=24`, node",
+			expectTools: "docker>=24,node",
+		},
+		{
+			name:          "given assert-outputs, should parse",
+			in:            "assert-outputs: `dist/app non-empty newer`, dist/app.sha256",
+			expectOutputs: "dist/app non-empty newer,dist/app.sha256",
+		},
+		{
+			name:          "given sources, should parse",
+			in:            "sources: `**/*.go`, go.mod",
+			expectSources: "**/*.go,go.mod",
+		},
+		{
+			name:        "given concurrency-group, should parse",
+			in:          "concurrency-group: `database`",
+			expectGroup: "database",
+		},
+		{
+			name:           "given a named priority, should parse",
+			in:             "priority: high",
+			expectPriority: models.PriorityHigh,
+		},
+		{
+			name:           "given a numeric priority, should parse",
+			in:             "Priority: `-5`",
+			expectPriority: -5,
+		},
+		{
+			name:            "given network false, should parse",
+			in:              "network: false",
+			expectNoNetwork: true,
+		},
+		{
+			name:          "given service true, should parse",
+			in:            "service: true",
+			expectService: true,
+		},
+		{
+			name:           "given override true, should parse",
+			in:             "override: true",
+			expectOverride: true,
+		},
+		{
+			name:            "given a ready-when pattern, should parse",
+			in:              "ready-when: `/listening on :\\d+/`",
+			expectReadyWhen: "/listening on :\\d+/",
+		},
+		{
+			name:            "given a ready-when command, should parse",
+			in:              "ready-when: pg_isready -h localhost",
+			expectReadyWhen: "pg_isready -h localhost",
+		},
+		{
+			name:          "given a restart policy, should parse",
+			in:            "restart: on-failure",
+			expectRestart: models.RestartOnFailure,
+		},
+		{
+			name:        "given allow-paths, should parse",
+			in:          "allow-paths: `.`, `~/.cache/go-build`",
+			expectAllow: ".,~/.cache/go-build",
+		},
+		{
+			name:       "given deny-paths, should parse",
+			in:         "Deny-Paths: .env, secrets/",
+			expectDeny: ".env,secrets/",
+		},
+		{
+			name:        "given a umask, should parse",
+			in:          "umask: `0077`",
+			expectUmask: "0077",
+		},
+		{
+			name:       "given a user, should parse",
+			in:         "User: 1000:1000",
+			expectUser: "1000:1000",
+		},
+		{
+			name:              "given a healthcheck, should parse",
+			in:                "healthcheck: `curl -sf localhost:8080/health` every 5s",
+			expectHealthcheck: "`curl -sf localhost:8080/health` every 5s",
+		},
+		{
+			name:          "given a wrapper, should parse",
+			in:            "wrapper: `nix develop .#ci -c`",
+			expectWrapper: "nix,develop,.#ci,-c",
+		},
+		{
+			name:          "given compose services, should parse",
+			in:            "compose: compose.dev.yaml, db, `redis`",
+			expectCompose: "compose.dev.yaml,db,redis",
+		},
+		{
+			name:       "given stop, should parse",
+			in:         "stop: `docker compose down`",
+			expectStop: "docker compose down",
+		},
+		{
+			name:         "given stop-signal, should parse",
+			in:           "Stop-Signal: term",
+			expectSignal: "SIGTERM",
+		},
+		{
+			name:          "given stop-timeout, should parse",
+			in:            "stop-timeout: 10s",
+			expectTimeout: 10 * time.Second,
+		},
+		{
+			name:       "given nice, should parse",
+			in:         "nice: `10`",
+			expectNice: 10,
+		},
+		{
+			name:         "given ionice, should parse",
+			in:           "IONice: Best-Effort:7",
+			expectIONice: "best-effort:7",
+		},
+		{
+			name:            "given shell-opts, should parse",
+			in:              "shell-opts: errexit, `pipefail`, XTRACE",
+			expectShellOpts: "errexit,pipefail,xtrace",
+		},
+		{
+			name:        "given an owner, should parse",
+			in:          "**Owner:** `@platform-team`",
+			expectOwner: "@platform-team",
+		},
+		{
+			name:           "given an approval, should parse",
+			in:             "Approval: _Deploy to production?_",
+			expectApproval: "Deploy to production?",
+		},
+		{
+			name:       "given docs, should parse",
+			in:         "Docs: https://wiki.example.com/runbooks/deploy_api",
+			expectDocs: "https://wiki.example.com/runbooks/deploy_api",
+		},
+		{
+			name:       "given an icon, should parse",
+			in:         "Icon: 🚀",
+			expectIcon: "🚀",
+		},
+		{
+			name:        "given a color, should parse",
+			in:          "Colour: `Red`",
+			expectColor: "red",
+		},
+		{
+			name:      "given wsl, should parse",
+			in:        "WSL: true",
+			expectWSL: true,
+		},
+		{
+			name:         "given ci-only, should parse",
+			in:           "CI-Only: true",
+			expectCIOnly: true,
+		},
+		{
+			name:            "given local-only, should parse",
+			in:              "local-only: `true`",
+			expectLocalOnly: true,
+		},
 		{
 			name:        "given env with no colon, should not parse",
 			in:          "env _*`my:attribute_*`",
@@ -334,6 +1141,112 @@ func TestParseAttribute(t *testing.T) {
 			if tt.expectDir != "" && p.currTask.Dir != tt.expectDir {
 				t.Fatalf("Dir=%s, want=%s", p.currTask.Dir, tt.expectDir)
 			}
+			if p.currTask.Schedule != tt.expectSchedule {
+				t.Fatalf("Schedule=%s, want=%s", p.currTask.Schedule, tt.expectSchedule)
+			}
+			if got := strings.Join(p.currTask.Foreach, ","); got != tt.expectForeach {
+				t.Fatalf("Foreach=%s, want=%s", got, tt.expectForeach)
+			}
+			if got := strings.Join(p.currTask.RequiresTools, ","); got != tt.expectTools {
+				t.Fatalf("RequiresTools=%s, want=%s", got, tt.expectTools)
+			}
+			var outputs []string
+			for _, o := range p.currTask.AssertOutputs {
+				outputs = append(outputs, o.String())
+			}
+			if got := strings.Join(outputs, ","); got != tt.expectOutputs {
+				t.Fatalf("AssertOutputs=%s, want=%s", got, tt.expectOutputs)
+			}
+			if got := strings.Join(p.currTask.Sources, ","); got != tt.expectSources {
+				t.Fatalf("Sources=%s, want=%s", got, tt.expectSources)
+			}
+			if p.currTask.ConcurrencyGroup != tt.expectGroup {
+				t.Fatalf("ConcurrencyGroup=%s, want=%s", p.currTask.ConcurrencyGroup, tt.expectGroup)
+			}
+			if p.currTask.Priority != tt.expectPriority {
+				t.Fatalf("Priority=%d, want=%d", p.currTask.Priority, tt.expectPriority)
+			}
+			if p.currTask.NoNetwork != tt.expectNoNetwork {
+				t.Fatalf("NoNetwork=%v, want=%v", p.currTask.NoNetwork, tt.expectNoNetwork)
+			}
+			if p.currTask.Service != tt.expectService {
+				t.Fatalf("Service=%v, want=%v", p.currTask.Service, tt.expectService)
+			}
+			if p.currTask.Override != tt.expectOverride {
+				t.Fatalf("Override=%v, want=%v", p.currTask.Override, tt.expectOverride)
+			}
+			if p.currTask.ReadyWhen != tt.expectReadyWhen {
+				t.Fatalf("ReadyWhen=%s, want=%s", p.currTask.ReadyWhen, tt.expectReadyWhen)
+			}
+			if p.currTask.Restart != tt.expectRestart {
+				t.Fatalf("Restart=%s, want=%s", p.currTask.Restart, tt.expectRestart)
+			}
+			if got := strings.Join(p.currTask.AllowPaths, ","); got != tt.expectAllow {
+				t.Fatalf("AllowPaths=%s, want=%s", got, tt.expectAllow)
+			}
+			if got := strings.Join(p.currTask.DenyPaths, ","); got != tt.expectDeny {
+				t.Fatalf("DenyPaths=%s, want=%s", got, tt.expectDeny)
+			}
+			if got := strings.Join(p.currTask.ShellOpts, ","); got != tt.expectShellOpts {
+				t.Fatalf("ShellOpts=%s, want=%s", got, tt.expectShellOpts)
+			}
+			if got := strings.Join(p.currTask.Wrapper, ","); got != tt.expectWrapper {
+				t.Fatalf("Wrapper=%s, want=%s", got, tt.expectWrapper)
+			}
+			if got := strings.Join(p.currTask.Compose, ","); got != tt.expectCompose {
+				t.Fatalf("Compose=%s, want=%s", got, tt.expectCompose)
+			}
+			if p.currTask.Umask != tt.expectUmask {
+				t.Fatalf("Umask=%s, want=%s", p.currTask.Umask, tt.expectUmask)
+			}
+			if p.currTask.Healthcheck != tt.expectHealthcheck {
+				t.Fatalf("Healthcheck=%s, want=%s", p.currTask.Healthcheck, tt.expectHealthcheck)
+			}
+			if p.currTask.Stop != tt.expectStop {
+				t.Fatalf("Stop=%s, want=%s", p.currTask.Stop, tt.expectStop)
+			}
+			if p.currTask.StopSignal != tt.expectSignal {
+				t.Fatalf("StopSignal=%s, want=%s", p.currTask.StopSignal, tt.expectSignal)
+			}
+			if p.currTask.StopTimeout != tt.expectTimeout {
+				t.Fatalf("StopTimeout=%s, want=%s", p.currTask.StopTimeout, tt.expectTimeout)
+			}
+			if p.currTask.User != tt.expectUser {
+				t.Fatalf("User=%s, want=%s", p.currTask.User, tt.expectUser)
+			}
+			if p.currTask.Nice != tt.expectNice {
+				t.Fatalf("Nice=%d, want=%d", p.currTask.Nice, tt.expectNice)
+			}
+			if p.currTask.IONice != tt.expectIONice {
+				t.Fatalf("IONice=%s, want=%s", p.currTask.IONice, tt.expectIONice)
+			}
+			if p.currTask.Owner != tt.expectOwner {
+				t.Fatalf("Owner=%s, want=%s", p.currTask.Owner, tt.expectOwner)
+			}
+			if p.currTask.Approval != tt.expectApproval {
+				t.Fatalf("Approval=%s, want=%s", p.currTask.Approval, tt.expectApproval)
+			}
+			if p.currTask.Docs != tt.expectDocs {
+				t.Fatalf("Docs=%s, want=%s", p.currTask.Docs, tt.expectDocs)
+			}
+			if p.currTask.Icon != tt.expectIcon {
+				t.Fatalf("Icon=%s, want=%s", p.currTask.Icon, tt.expectIcon)
+			}
+			if p.currTask.Color != tt.expectColor {
+				t.Fatalf("Color=%s, want=%s", p.currTask.Color, tt.expectColor)
+			}
+			if p.currTask.WSL != tt.expectWSL {
+				t.Fatalf("WSL=%v, want=%v", p.currTask.WSL, tt.expectWSL)
+			}
+			if p.currTask.CIOnly != tt.expectCIOnly {
+				t.Fatalf("CIOnly=%v, want=%v", p.currTask.CIOnly, tt.expectCIOnly)
+			}
+			if p.currTask.LocalOnly != tt.expectLocalOnly {
+				t.Fatalf("LocalOnly=%v, want=%v", p.currTask.LocalOnly, tt.expectLocalOnly)
+			}
+			if p.currTask.ForeachParallel != tt.expectParallel {
+				t.Fatalf("ForeachParallel=%v, want=%v", p.currTask.ForeachParallel, tt.expectParallel)
+			}
 			if p.currTask.RequiredBehaviour != tt.expectBehaviour {
 				t.Fatalf("got=%q, want=%q", p.currTask.RequiredBehaviour, tt.expectBehaviour)
 			}
@@ -374,3 +1287,234 @@ echo "Hello, world2!"
 		}
 	}
 }
+
+func TestTranslations(t *testing.T) {
+	p, _ := NewParser(strings.NewReader(`## Tasks
+### build
+Builds the binary.
+Description[fr]: Construit le binaire.
+**Description[pt_BR]:** Compila o
+  binário.
+`+"```\ngo build\n```\n"), "")
+	tasks, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{"fr": {"Construit le binaire."}, "pt-br": {"Compila o binário."}}
+	if !reflect.DeepEqual(tasks[0].Translations, expected) {
+		t.Fatalf("Translations=%v, want=%v", tasks[0].Translations, expected)
+	}
+	if !reflect.DeepEqual(tasks[0].Description, []string{"Builds the binary."}) {
+		t.Fatalf("Description=%v, want=[Builds the binary.]", tasks[0].Description)
+	}
+	for langs, expected := range map[string]string{
+		"fr_CA.UTF-8": "Construit le binaire.",
+		"pt-BR":       "Compila o binário.",
+		"de:fr":       "Construit le binaire.",
+		"C":           "Builds the binary.",
+		"de":          "Builds the binary.",
+	} {
+		if got := tasks.Localize(strings.Split(langs, ":"))[0].Description[0]; got != expected {
+			t.Fatalf("Localize(%s)=%s, want=%s", langs, got, expected)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		expected  map[string][]string
+		expectErr bool
+	}{
+		{
+			name: "given profiles before the tasks, should parse them",
+			in: `# Project
+## Profiles
+### dev
+Env: API_URL=http://localhost:8080
+### staging
+Deploy to the staging cluster.
+
+Env: API_URL=https://staging.example.com, REPLICAS=2
+## Tasks
+### deploy
+` + "```\ndeploy\n```\n",
+			expected: map[string][]string{
+				"dev":     {"API_URL=http://localhost:8080"},
+				"staging": {"API_URL=https://staging.example.com", "REPLICAS=2"},
+			},
+		},
+		{
+			name: "given profiles after the tasks, should parse them",
+			in: `## Tasks
+### deploy
+` + "```\ndeploy\n```\n" + `
+## Profiles
+### prod
+Environment:
+- API_URL=https://example.com
+- REPLICAS=5
+## License
+MIT
+`,
+			expected: map[string][]string{
+				"prod": {"API_URL=https://example.com", "REPLICAS=5"},
+			},
+		},
+		{
+			name: "given a profile defined twice, should fail",
+			in: `## Profiles
+### dev
+Env: A=1
+### dev
+Env: A=2
+## Tasks
+### deploy
+` + "```\ndeploy\n```\n",
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewParser(strings.NewReader(tt.in), "")
+			if err == nil {
+				var tasks models.Tasks
+				tasks, err = p.Parse()
+				if err == nil && (len(tasks) != 1 || tasks[0].Name != "deploy" || tasks[0].Script != "deploy\n") {
+					t.Fatalf("expected the deploy task got %v", tasks)
+				}
+			}
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v got %v", tt.expectErr, err)
+			}
+			if !tt.expectErr && !reflect.DeepEqual(p.FileConfig().Profiles, tt.expected) {
+				t.Fatalf("expected %v got %v", tt.expected, p.FileConfig().Profiles)
+			}
+		})
+	}
+}
+
+func TestLenient(t *testing.T) {
+	type task struct {
+		name, script, parsingError string
+	}
+	tests := []struct {
+		name     string
+		in       string
+		expected []task
+		warnings []int
+	}{
+		{
+			name: "given an invalid attribute, should skip it",
+			in: `## Tasks
+### build
+Run: never
+Dir: ./src
+` + "```\nmake\n```\n" + `
+### test
+` + "```\ngo test\n```\n",
+			expected: []task{
+				{
+					name:         "build",
+					script:       "make\n",
+					parsingError: "run contains invalid behaviour \"never\" should be (always, once): build",
+				},
+				{name: "test", script: "go test\n"},
+			},
+			warnings: []int{3},
+		},
+		{
+			name: "given a code block that is not ended, should parse the tasks after it",
+			in: `## Tasks
+### build
+` + "```\nmake\n" + `
+### test
+Requires: build
+`,
+			expected: []task{
+				{name: "build", parsingError: "command block in task build was not ended"},
+				{name: "test"},
+			},
+			warnings: []int{3},
+		},
+		{
+			name: "given a second command block, should ignore it",
+			in: `## Tasks
+### build
+` + "```\nmake\n```\n```\nmake install\n```\n" + `
+### test
+` + "```\ngo test\n```\n",
+			expected: []task{
+				{name: "build", script: "make\n", parsingError: "command block already exists for task build"},
+				{name: "test", script: "go test\n"},
+			},
+			warnings: []int{6},
+		},
+		{
+			name: "given a task without commands, should keep it",
+			in: `## Tasks
+### empty
+### test
+` + "```\ngo test\n```\n",
+			expected: []task{
+				{name: "empty", parsingError: "task empty has no commands, steps or required tasks"},
+				{name: "test", script: "go test\n"},
+			},
+			warnings: []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewParser(strings.NewReader(tt.in), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Lenient()
+			tasks, err := p.Parse()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []task
+			for _, ta := range tasks {
+				got = append(got, task{name: ta.Name, script: ta.Script, parsingError: ta.ParsingError})
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("tasks want=%+v got=%+v", tt.expected, got)
+			}
+			var lines []int
+			for _, w := range p.Warnings() {
+				lines = append(lines, w.Line)
+			}
+			if !reflect.DeepEqual(lines, tt.warnings) {
+				t.Fatalf("warning lines want=%v got=%v", tt.warnings, lines)
+			}
+		})
+	}
+}
+
+func FuzzLenient(f *testing.F) {
+	f.Add(s)
+	f.Add("## Tasks\n### a\n```\nmake\n### b\nRequires: a\n")
+	f.Add("## Tasks\n### a\nRun: never\n\n    make\n```\nmake\n")
+	f.Fuzz(func(t *testing.T, in string) {
+		p, err := NewParser(strings.NewReader(in), "")
+		if err != nil {
+			return
+		}
+		p.Lenient()
+		tasks, err := p.Parse()
+		if err != nil {
+			// Only errors in profiles are returned, as the input is too small to fail to read.
+			if !strings.Contains(err.Error(), "profile") {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return
+		}
+		for _, ta := range tasks {
+			if ta.Script == "" && len(ta.DependsOn) == 0 && len(ta.Steps) == 0 && ta.ParsingError == "" {
+				t.Fatalf("task %q has nothing to run and no parsing error", ta.Name)
+			}
+		}
+	})
+}
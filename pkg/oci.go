@@ -0,0 +1,301 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	artifactType      = "application/vnd.xc.pkg.v1"
+	bundleMediaType   = "application/vnd.xc.pkg.bundle.v1.tar+gzip"
+	// signatureMediaType is a minisign or cosign signature of the bundle.
+	signatureMediaType = "application/vnd.xc.pkg.signature.v1"
+	emptyMediaType     = "application/vnd.oci.empty.v1+json"
+)
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	ArtifactType  string       `json:"artifactType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// registry is a repository in an OCI registry, accessed with the distribution API.
+type registry struct {
+	base   string
+	repo   string
+	client *http.Client
+	// username and password are sent to the registry, or its token service, when it asks for credentials.
+	username, password string
+	token              string
+}
+
+// newRegistry returns the repository at loc, such as ghcr.io/org/common-tasks.
+// Registries on localhost are accessed over http, others over https.
+func newRegistry(loc string) (*registry, error) {
+	host, repo, ok := strings.Cut(loc, "/")
+	if !ok || host == "" || repo == "" {
+		return nil, fmt.Errorf("invalid OCI reference %q, expected oci://registry/repository", loc)
+	}
+	scheme := "https"
+	if h := strings.Split(host, ":")[0]; h == "localhost" || h == "127.0.0.1" {
+		scheme = "http"
+	}
+	return &registry{
+		base:     scheme + "://" + host + "/v2/" + repo,
+		repo:     repo,
+		client:   http.DefaultClient,
+		username: os.Getenv("XC_REGISTRY_USERNAME"),
+		password: os.Getenv("XC_REGISTRY_PASSWORD"),
+	}, nil
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// do sends the request built by newReq, authenticating and sending it again if the registry asks for credentials.
+func (r *registry) do(newReq func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newReq()
+	if err != nil {
+		return nil, err
+	}
+	r.authorize(req)
+	resp, err := r.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || r.token != "" {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if err = r.login(req.Context(), challenge); err != nil {
+		return nil, err
+	}
+	if req, err = newReq(); err != nil {
+		return nil, err
+	}
+	r.authorize(req)
+	return r.client.Do(req)
+}
+
+func (r *registry) authorize(req *http.Request) {
+	switch {
+	case r.token != "":
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	case r.username != "":
+		req.SetBasicAuth(r.username, r.password)
+	}
+}
+
+// login gets a bearer token from the token service named by a WWW-Authenticate challenge.
+func (r *registry) login(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("the registry requires credentials, set XC_REGISTRY_USERNAME and XC_REGISTRY_PASSWORD")
+	}
+	attrs := map[string]string{}
+	for _, p := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		attrs[k] = strings.Trim(v, `"`)
+	}
+	u, err := url.Parse(attrs["realm"])
+	if err != nil || attrs["realm"] == "" {
+		return fmt.Errorf("invalid registry authentication challenge %q", challenge)
+	}
+	q := u.Query()
+	for _, k := range []string{"service", "scope"} {
+		if attrs[k] != "" {
+			q.Set(k, attrs[k])
+		}
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("GET %s: %s", u.Redacted(), resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return fmt.Errorf("failed to read the registry token: %w", err)
+	}
+	if r.token = tok.Token; r.token == "" {
+		r.token = tok.AccessToken
+	}
+	if r.token == "" {
+		return fmt.Errorf("the registry token service returned no token")
+	}
+	return nil
+}
+
+// pull returns the bundle tagged version, its digest and its signature, which is empty if it is not signed.
+func (r *registry) pull(ctx context.Context, version string) (bundle []byte, digest string, sig []byte, err error) {
+	resp, err := r.do(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.base+"/manifests/"+version, nil)
+		if err == nil {
+			req.Header.Set("Accept", manifestMediaType)
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, "", nil, fmt.Errorf("GET %s/manifests/%s: %s", r.base, version, resp.Status)
+	}
+	var m manifest
+	if err = json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read the manifest of %s:%s: %w", r.repo, version, err)
+	}
+	for _, l := range m.Layers {
+		switch l.MediaType {
+		case bundleMediaType:
+			bundle, err = r.blob(ctx, l.Digest)
+			digest = l.Digest
+		case signatureMediaType:
+			sig, err = r.blob(ctx, l.Digest)
+		}
+		if err != nil {
+			return nil, "", nil, err
+		}
+	}
+	if digest == "" {
+		return nil, "", nil, fmt.Errorf("%s:%s is not an xc package", r.repo, version)
+	}
+	return bundle, digest, sig, nil
+}
+
+func (r *registry) blob(ctx context.Context, digest string) ([]byte, error) {
+	resp, err := r.do(func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, r.base+"/blobs/"+digest, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET %s/blobs/%s: %s", r.base, digest, resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if got := digestOf(b); got != digest {
+		return nil, fmt.Errorf("blob %s has digest %s", digest, got)
+	}
+	return b, nil
+}
+
+// push uploads bundle, and its signature if sig is not empty, and tags it version, returning its digest.
+func (r *registry) push(ctx context.Context, version string, bundle, sig []byte) (string, error) {
+	empty := []byte("{}")
+	config := descriptor{MediaType: emptyMediaType, Digest: digestOf(empty), Size: int64(len(empty))}
+	if err := r.upload(ctx, config.Digest, empty); err != nil {
+		return "", err
+	}
+	var layers []descriptor
+	for _, l := range []struct {
+		mediaType string
+		data      []byte
+	}{{bundleMediaType, bundle}, {signatureMediaType, sig}} {
+		if len(l.data) == 0 {
+			continue
+		}
+		d := descriptor{MediaType: l.mediaType, Digest: digestOf(l.data), Size: int64(len(l.data))}
+		if err := r.upload(ctx, d.Digest, l.data); err != nil {
+			return "", err
+		}
+		layers = append(layers, d)
+	}
+	m, err := json.Marshal(manifest{
+		SchemaVersion: 2,
+		MediaType:     manifestMediaType,
+		ArtifactType:  artifactType,
+		Config:        config,
+		Layers:        layers,
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.do(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, r.base+"/manifests/"+version, bytes.NewReader(m))
+		if err == nil {
+			req.Header.Set("Content-Type", manifestMediaType)
+		}
+		return req, err
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("PUT %s/manifests/%s: %s", r.base, version, resp.Status)
+	}
+	return layers[0].Digest, nil
+}
+
+// upload stores a blob with a monolithic upload.
+func (r *registry) upload(ctx context.Context, digest string, data []byte) error {
+	resp, err := r.do(func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, r.base+"/blobs/uploads/", nil)
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s/blobs/uploads/: %s", r.base, resp.Status)
+	}
+	loc, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("the registry returned no upload location")
+	}
+	q := loc.Query()
+	q.Set("digest", digest)
+	loc.RawQuery = q.Encode()
+	resp, err = r.do(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, loc.String(), bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("PUT %s: %s", loc.Redacted(), resp.Status)
+	}
+	return nil
+}
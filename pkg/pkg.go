@@ -0,0 +1,451 @@
+// Package pkg publishes and installs packages of shared tasks: versioned bundles of markdown task files
+// stored in an OCI registry or at a git tag, which a task file uses with the uses key of its front matter.
+// Installed packages are extracted to .xc/pkg and locked to their digest in .xc/lock.
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/cache"
+	"github.com/joerdav/xc/git"
+)
+
+// MetadataFile is the name of the metadata of a package, at the root of its bundle or repository.
+const MetadataFile = "xc-pkg.json"
+
+// Metadata describes a package.
+type Metadata struct {
+	// File is the task file of the package, relative to its root, README.md if empty.
+	File string `json:"file,omitempty"`
+}
+
+// Ref is a version of a package, written source@version such as org/common-tasks@v2.
+// The source is an OCI repository prefixed with oci://, a git URL or path,
+// or org/name for the GitHub repository github.com/org/name.
+type Ref struct {
+	Source  string
+	Version string
+}
+
+// ParseRef parses a reference to a version of a package.
+func ParseRef(s string) (Ref, error) {
+	i := strings.LastIndex(s, "@")
+	if i <= 0 || i == len(s)-1 || strings.ContainsAny(s[i+1:], "/:") {
+		return Ref{}, fmt.Errorf("invalid package %q, expected source@version such as org/common-tasks@v2", s)
+	}
+	return Ref{Source: s[:i], Version: s[i+1:]}, nil
+}
+
+func (r Ref) String() string {
+	return r.Source + "@" + r.Version
+}
+
+// OCI returns the registry repository of the package, if it is stored in an OCI registry.
+func (r Ref) OCI() (string, bool) {
+	return strings.CutPrefix(r.Source, "oci://")
+}
+
+// GitURL returns the URL of the git repository of a package that is not stored in an OCI registry.
+func (r Ref) GitURL() string {
+	s := r.Source
+	if strings.Contains(s, "://") || strings.HasPrefix(s, "git@") || filepath.IsAbs(s) || strings.HasPrefix(s, ".") {
+		return s
+	}
+	host := "github.com/"
+	if first, _, _ := strings.Cut(s, "/"); strings.Contains(first, ".") {
+		host = ""
+	}
+	return "https://" + host + strings.TrimSuffix(s, ".git") + ".git"
+}
+
+// Locked is an installed package or remote task file.
+type Locked struct {
+	// Ref is the package as it is written in the uses key, or the URL of a remote task file.
+	Ref string `json:"ref"`
+	// URL is the location it was fetched from.
+	URL string `json:"url"`
+	// Digest is the sha256 digest of the bundle of an OCI package or of a remote task file,
+	// or git: and the commit of a git package.
+	Digest string `json:"digest"`
+	// File is the task file of the package, relative to its directory.
+	File string `json:"file"`
+	// Signer is the ID of the signer whose signature was verified when it was installed, if signers were required.
+	Signer string `json:"signer,omitempty"`
+	// Files is the sha256 digest of the installed files, which are checked against it before their tasks are loaded.
+	Files string `json:"files,omitempty"`
+}
+
+// Dir returns the directory the package or file is installed in, under the .xc directory of dir.
+func (l Locked) Dir(dir string) string {
+	return filepath.Join(dir, ".xc", "pkg", strings.ReplaceAll(l.Digest, ":", "-"))
+}
+
+// Installed reports whether the package or file is installed under dir.
+func (l Locked) Installed(dir string) bool {
+	_, err := os.Stat(filepath.Join(l.Dir(dir), l.File))
+	return err == nil
+}
+
+// Intact returns an error if the installed files of the package or file are not those that were installed,
+// as they have been changed since or were installed by a version of xc that did not record their digest.
+func (l Locked) Intact(dir string) error {
+	if l.Files == "" {
+		return fmt.Errorf("the digest of the files of %s is not locked", l.Ref)
+	}
+	digest, err := filesDigest(l.Dir(dir))
+	if err != nil {
+		return fmt.Errorf("failed to check the files of %s: %w", l.Ref, err)
+	}
+	if digest != l.Files {
+		return fmt.Errorf("the files of %s have changed since it was installed", l.Ref)
+	}
+	return nil
+}
+
+// filesDigest returns the sha256 digest of the paths and contents of the files in dir.
+func filesDigest(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(b))
+		h.Write(b)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verified reports whether the package or file was signed by one of signers, or if there are none.
+func (l Locked) Verified(signers []Signer) bool {
+	if len(signers) == 0 {
+		return true
+	}
+	for _, s := range signers {
+		if s.ID == l.Signer {
+			return true
+		}
+	}
+	return false
+}
+
+// Lock is the list of packages and remote task files installed for a task file, stored in .xc/lock.
+type Lock struct {
+	Packages []Locked `json:"packages"`
+	// Includes are the task files included from a URL, their Ref and URL are the URL.
+	Includes []Locked `json:"includes,omitempty"`
+}
+
+// LockPath returns the path of the lock file of the task file in dir.
+func LockPath(dir string) string {
+	return filepath.Join(dir, ".xc", "lock")
+}
+
+// ReadLock reads the lock file of the task file in dir, it is empty if there is none.
+func ReadLock(dir string) (Lock, error) {
+	var l Lock
+	b, err := os.ReadFile(LockPath(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return l, fmt.Errorf("failed to read %s: %w", LockPath(dir), err)
+	}
+	if err = json.Unmarshal(b, &l); err != nil {
+		return l, fmt.Errorf("failed to read %s: %w", LockPath(dir), err)
+	}
+	return l, nil
+}
+
+// WriteLock writes the lock file of the task file in dir.
+func WriteLock(dir string, l Lock) error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(LockPath(dir)), 0o755); err != nil {
+		return fmt.Errorf("failed to write %s: %w", LockPath(dir), err)
+	}
+	if err = os.WriteFile(LockPath(dir), append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", LockPath(dir), err)
+	}
+	return nil
+}
+
+// Without returns the lock without the packages and remote task files in refs, or without any if refs is empty,
+// so that they are locked to their current digest when installed.
+func (l Lock) Without(refs ...string) Lock {
+	var next Lock
+	if len(refs) == 0 {
+		return next
+	}
+	remove := map[string]bool{}
+	for _, r := range refs {
+		remove[r] = true
+	}
+	for _, p := range l.Packages {
+		if !remove[p.Ref] {
+			next.Packages = append(next.Packages, p)
+		}
+	}
+	for _, f := range l.Includes {
+		if !remove[f.Ref] {
+			next.Includes = append(next.Includes, f)
+		}
+	}
+	return next
+}
+
+// Get returns the locked version of the package written ref in the uses key.
+func (l Lock) Get(ref string) (Locked, bool) {
+	return find(l.Packages, ref)
+}
+
+// Include returns the locked version of the task file included from url.
+func (l Lock) Include(url string) (Locked, bool) {
+	return find(l.Includes, url)
+}
+
+func find(locked []Locked, ref string) (Locked, bool) {
+	for _, l := range locked {
+		if l.Ref == ref {
+			return l, true
+		}
+	}
+	return Locked{}, false
+}
+
+// Install installs the packages in uses and the remote task files in includes under dir, returning the lock to write.
+// A package or file in lock is installed at its locked digest, it is an error if it now has another,
+// those that are not are fetched and locked to their current digest.
+// If there are signers each must be signed by one of them, the signature is checked before it is installed.
+// Packages and files no longer used are removed from the lock.
+func Install(ctx context.Context, dir string, uses, includes []string, lock Lock, signers []Signer) (Lock, error) {
+	var next Lock
+	var err error
+	if next.Packages, err = install(ctx, dir, uses, lock.Packages, signers, fetchPackage); err != nil {
+		return next, err
+	}
+	if next.Includes, err = install(ctx, dir, includes, lock.Includes, signers, fetchFile); err != nil {
+		return next, err
+	}
+	return next, nil
+}
+
+type fetchFunc func(ctx context.Context, dir, ref string, signers []Signer) (Locked, error)
+
+func install(
+	ctx context.Context,
+	dir string,
+	refs []string,
+	locked []Locked,
+	signers []Signer,
+	fetch fetchFunc,
+) ([]Locked, error) {
+	var installed []Locked
+	for _, ref := range refs {
+		l, ok := find(locked, ref)
+		if ok && l.Installed(dir) && l.Intact(dir) == nil && l.Verified(signers) {
+			installed = append(installed, l)
+			continue
+		}
+		got, err := fetch(ctx, dir, ref, signers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to install %s: %w", ref, err)
+		}
+		if ok && got.Digest != l.Digest {
+			return nil, fmt.Errorf("%s is now %s rather than %s in %s, run xc update to use it",
+				ref, got.Digest, l.Digest, LockPath(dir))
+		}
+		installed = append(installed, got)
+	}
+	sort.SliceStable(installed, func(i, j int) bool { return installed[i].Ref < installed[j].Ref })
+	return installed, nil
+}
+
+// fetchPackage downloads the package and extracts it under dir.
+func fetchPackage(ctx context.Context, dir, use string, signers []Signer) (Locked, error) {
+	ref, err := ParseRef(use)
+	if err != nil {
+		return Locked{}, err
+	}
+	root := filepath.Join(dir, ".xc", "pkg")
+	if err = os.MkdirAll(root, 0o755); err != nil {
+		return Locked{}, err
+	}
+	tmp, err := os.MkdirTemp(root, "tmp-")
+	if err != nil {
+		return Locked{}, err
+	}
+	defer os.RemoveAll(tmp)
+	l := Locked{Ref: ref.String()}
+	src := filepath.Join(tmp, "src")
+	if repo, ok := ref.OCI(); ok {
+		r, err := newRegistry(repo)
+		if err != nil {
+			return l, err
+		}
+		bundle, digest, sig, err := r.pull(ctx, ref.Version)
+		if err != nil {
+			return l, err
+		}
+		if len(signers) > 0 {
+			if l.Signer, err = verify(bundle, sig, signers); err != nil {
+				return l, err
+			}
+		}
+		if err = cache.Unpack(bytes.NewReader(bundle), src); err != nil {
+			return l, fmt.Errorf("failed to extract the bundle: %w", err)
+		}
+		l.URL, l.Digest = "oci://"+repo+":"+ref.Version, digest
+	} else {
+		if len(signers) > 0 {
+			return l, errors.New("packages in git cannot be verified, publish it to an OCI registry with a signature")
+		}
+		l.URL = ref.GitURL()
+		commit, err := git.Clone(ctx, l.URL, ref.Version, src)
+		if err != nil {
+			return l, err
+		}
+		if err = os.RemoveAll(filepath.Join(src, ".git")); err != nil {
+			return l, err
+		}
+		l.Digest = "git:" + commit
+	}
+	md, err := readMetadata(src)
+	if err != nil {
+		return l, err
+	}
+	l.File = filepath.ToSlash(md.File)
+	if _, err = os.Stat(filepath.Join(src, md.File)); err != nil {
+		return l, fmt.Errorf("the package has no task file %s", md.File)
+	}
+	if l.Files, err = filesDigest(src); err != nil {
+		return l, err
+	}
+	if l.Installed(dir) && l.Intact(dir) == nil {
+		return l, nil
+	}
+	if err = os.RemoveAll(l.Dir(dir)); err != nil {
+		return l, err
+	}
+	return l, os.Rename(src, l.Dir(dir))
+}
+
+func readMetadata(dir string) (Metadata, error) {
+	md := Metadata{File: "README.md"}
+	b, err := os.ReadFile(filepath.Join(dir, MetadataFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return md, nil
+	}
+	if err != nil {
+		return md, err
+	}
+	if err = json.Unmarshal(b, &md); err != nil {
+		return md, fmt.Errorf("failed to read %s: %w", MetadataFile, err)
+	}
+	if md.File == "" {
+		md.File = "README.md"
+	}
+	if !filepath.IsLocal(filepath.FromSlash(md.File)) {
+		return md, fmt.Errorf("the task file %s of the package is outside of it", md.File)
+	}
+	md.File = filepath.FromSlash(md.File)
+	return md, nil
+}
+
+// Pack writes a bundle of the markdown files in dir and their metadata to w, file being the task file of the package.
+// The bundle can then be signed, before it is published.
+func Pack(w io.Writer, dir, file string) error {
+	if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+		return fmt.Errorf("the package has no task file %s", file)
+	}
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != dir && (d.Name() == ".git" || d.Name() == ".xc") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".md") {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "xc-pkg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	md, err := json.MarshalIndent(Metadata{File: filepath.ToSlash(file)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(tmp, MetadataFile), md, 0o644); err != nil {
+		return err
+	}
+	// The markdown files are copied next to the metadata, so that they are packed at the root of the bundle.
+	for _, p := range paths {
+		b, err := os.ReadFile(filepath.Join(dir, p))
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Join(tmp, filepath.Dir(p)), 0o755); err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(tmp, p), b, 0o644); err != nil {
+			return err
+		}
+	}
+	return cache.Pack(w, tmp, append([]string{MetadataFile}, paths...))
+}
+
+// Publish pushes a bundle written by Pack to the OCI repository of ref, tagged with its version,
+// with its signature if sig is not empty. It returns the digest of the bundle.
+// Packages stored in git are published by pushing a tag.
+func Publish(ctx context.Context, ref Ref, bundle, sig []byte) (string, error) {
+	repo, ok := ref.OCI()
+	if !ok {
+		return "", fmt.Errorf("%s is not an OCI repository, git packages are published by pushing the tag %s",
+			ref.Source, ref.Version)
+	}
+	if len(bundle) == 0 {
+		return "", errors.New("the bundle is empty")
+	}
+	r, err := newRegistry(repo)
+	if err != nil {
+		return "", err
+	}
+	return r.push(ctx, ref.Version, bundle, sig)
+}
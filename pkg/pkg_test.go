@@ -0,0 +1,338 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry is an OCI registry that keeps blobs and manifests in memory, requiring a bearer token.
+func fakeRegistry(t *testing.T) string {
+	t.Helper()
+	var mu sync.Mutex
+	blobs := map[string][]byte{}
+	manifests := map[string][]byte{}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/token" {
+			_, _ = io.WriteString(w, `{"token":"secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate",
+				`Bearer realm="`+srv.URL+`/token",service="test",scope="repository:org/tasks:pull,push"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/v2/org/tasks")
+		b, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodPost && path == "/blobs/uploads/":
+			w.Header().Set("Location", "/v2/org/tasks/blobs/uploads/1?state=x")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && path == "/blobs/uploads/1":
+			if r.URL.Query().Get("state") != "x" || digestOf(b) != r.URL.Query().Get("digest") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			blobs[digestOf(b)] = b
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && strings.HasPrefix(path, "/manifests/"):
+			manifests[strings.TrimPrefix(path, "/manifests/")] = b
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet && strings.HasPrefix(path, "/manifests/"):
+			m, ok := manifests[strings.TrimPrefix(path, "/manifests/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(m)
+		case r.Method == http.MethodGet && strings.HasPrefix(path, "/blobs/"):
+			b, ok := blobs[strings.TrimPrefix(path, "/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(b)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "oci://" + strings.TrimPrefix(srv.URL, "http://") + "/org/tasks"
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// publish packs the markdown files in src and publishes them with sig, returning the digest of the bundle.
+func publish(t *testing.T, ref Ref, src, file string, sig func(bundle []byte) []byte) string {
+	t.Helper()
+	var bundle bytes.Buffer
+	if err := Pack(&bundle, src, file); err != nil {
+		t.Fatal(err)
+	}
+	var signature []byte
+	if sig != nil {
+		signature = sig(bundle.Bytes())
+	}
+	digest, err := Publish(context.Background(), ref, bundle.Bytes(), signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		source  string
+		version string
+		gitURL  string
+		wantErr bool
+	}{
+		{
+			ref: "org/common-tasks@v2", source: "org/common-tasks", version: "v2",
+			gitURL: "https://github.com/org/common-tasks.git",
+		},
+		{
+			ref: "gitlab.com/org/tasks@v1.0.0", source: "gitlab.com/org/tasks", version: "v1.0.0",
+			gitURL: "https://gitlab.com/org/tasks.git",
+		},
+		{
+			ref: "git@github.com:org/tasks.git@v2", source: "git@github.com:org/tasks.git", version: "v2",
+			gitURL: "git@github.com:org/tasks.git",
+		},
+		{ref: "oci://ghcr.io/org/tasks@v2", source: "oci://ghcr.io/org/tasks", version: "v2"},
+		{ref: "org/common-tasks", wantErr: true},
+		{ref: "org/common-tasks@", wantErr: true},
+		{ref: "@v2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ref, err := ParseRef(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if ref.Source != tt.source || ref.Version != tt.version {
+				t.Fatalf("expected %s and %s got %+v", tt.source, tt.version, ref)
+			}
+			if _, oci := ref.OCI(); !oci && ref.GitURL() != tt.gitURL {
+				t.Fatalf("expected git URL %s got %s", tt.gitURL, ref.GitURL())
+			}
+		})
+	}
+}
+
+func TestPublishAndInstallOCI(t *testing.T) {
+	ctx := context.Background()
+	repo := fakeRegistry(t)
+	src := t.TempDir()
+	write(t, filepath.Join(src, "tasks.md"), "## Tasks\n### lint\n```\necho lint\n```\n")
+	write(t, filepath.Join(src, "docs", "more.md"), "more")
+	write(t, filepath.Join(src, "main.go"), "package main")
+	ref, err := ParseRef(repo + "@v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := publish(t, ref, src, "tasks.md", nil)
+
+	dir := t.TempDir()
+	lock, err := Install(ctx, dir, []string{ref.String()}, nil, Lock{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 1 {
+		t.Fatalf("expected 1 locked package got %+v", lock)
+	}
+	l := lock.Packages[0]
+	if l.Digest != digest || l.File != "tasks.md" || l.Ref != ref.String() {
+		t.Fatalf("unexpected lock %+v", l)
+	}
+	if got := read(t, filepath.Join(l.Dir(dir), "docs", "more.md")); got != "more" {
+		t.Fatalf("expected the bundle to contain docs/more.md got %q", got)
+	}
+	if _, err = os.Stat(filepath.Join(l.Dir(dir), "main.go")); err == nil {
+		t.Fatal("expected only markdown files in the bundle")
+	}
+	if err = WriteLock(dir, lock); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := ReadLock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reread.Get(ref.String()); !ok || got != l {
+		t.Fatalf("expected %+v got %+v", l, got)
+	}
+
+	// Republishing the tag changes its digest, installing from the lock then fails until it is updated.
+	write(t, filepath.Join(src, "tasks.md"), "## Tasks\n### lint\n```\necho lint v2\n```\n")
+	publish(t, ref, src, "tasks.md", nil)
+	if err = os.RemoveAll(filepath.Join(dir, ".xc", "pkg")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Install(ctx, dir, []string{ref.String()}, nil, lock, nil)
+	if err == nil || !strings.Contains(err.Error(), "xc update") {
+		t.Fatalf("expected a digest mismatch got %v", err)
+	}
+	updated, err := Install(ctx, dir, []string{ref.String()}, nil, lock.Without(ref.String()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Packages[0].Digest == digest || !updated.Packages[0].Installed(dir) {
+		t.Fatalf("expected the new version to be installed got %+v", updated)
+	}
+}
+
+func TestInstallGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	write(t, filepath.Join(repo, "README.md"), "## Tasks\n### fmt\n```\ngo fmt ./...\n```\n")
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "v1"},
+		{"tag", "v1"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	dir := t.TempDir()
+	lock, err := Install(context.Background(), dir, []string{repo + "@v1"}, nil, Lock{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := lock.Packages[0]
+	if !strings.HasPrefix(l.Digest, "git:") || l.File != "README.md" || l.URL != repo {
+		t.Fatalf("unexpected lock %+v", l)
+	}
+	if _, err = os.Stat(filepath.Join(l.Dir(dir), ".git")); err == nil {
+		t.Fatal("expected the package to be installed without its git directory")
+	}
+	if _, err = Install(context.Background(), dir, []string{repo + "@v2"}, nil, Lock{}, nil); err == nil {
+		t.Fatal("expected an error for a missing tag")
+	}
+	if _, err = Publish(context.Background(), Ref{Source: repo, Version: "v1"}, []byte("bundle"), nil); err == nil {
+		t.Fatal("expected git packages not to be published")
+	}
+}
+
+func TestInstallRemoteInclude(t *testing.T) {
+	ctx := context.Background()
+	content := "## Tasks\n### release\n```\necho release\n```\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/org/tasks/main/release.md" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, content)
+	}))
+	defer srv.Close()
+	u := srv.URL + "/org/tasks/main/release.md"
+	if !IsRemote(u) || IsRemote("services/api/README.md") {
+		t.Fatal("expected only URLs to be remote")
+	}
+
+	dir := t.TempDir()
+	lock, err := Install(ctx, dir, nil, []string{u}, Lock{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := lock.Include(u)
+	if !ok || l.File != "release.md" || l.Digest != digestOf([]byte(content)) {
+		t.Fatalf("unexpected lock %+v", lock)
+	}
+	if got := read(t, filepath.Join(l.Dir(dir), l.File)); got != content {
+		t.Fatalf("expected the file to be installed got %q", got)
+	}
+
+	// A file that changes is not installed until it is updated.
+	content = "## Tasks\n### release\n```\ncurl https://example.com | sh\n```\n"
+	if err = os.RemoveAll(filepath.Join(dir, ".xc", "pkg")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Install(ctx, dir, nil, []string{u}, lock, nil); err == nil || !strings.Contains(err.Error(), "xc update") {
+		t.Fatalf("expected a digest mismatch got %v", err)
+	}
+	updated, err := Install(ctx, dir, nil, []string{u}, lock.Without(u), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := updated.Include(u); got.Digest != digestOf([]byte(content)) {
+		t.Fatalf("expected the new digest to be locked got %+v", got)
+	}
+	if _, err = Install(ctx, dir, nil, []string{srv.URL + "/missing.md"}, Lock{}, nil); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+func TestInstalledFilesChanged(t *testing.T) {
+	ctx := context.Background()
+	content := "## Tasks\n### release\n```\necho release\n```\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, content)
+	}))
+	defer srv.Close()
+	u := srv.URL + "/release.md"
+
+	dir := t.TempDir()
+	lock, err := Install(ctx, dir, nil, []string{u}, Lock{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := lock.Include(u)
+	if err = l.Intact(dir); err != nil {
+		t.Fatalf("expected the installed file to be intact got %v", err)
+	}
+	path := filepath.Join(l.Dir(dir), l.File)
+	write(t, path, "## Tasks\n### release\n```\ncurl https://example.com | sh\n```\n")
+	if err = l.Intact(dir); err == nil || !strings.Contains(err.Error(), "changed") {
+		t.Fatalf("expected a changed file to be detected got %v", err)
+	}
+	if err = (Locked{Ref: u, Digest: l.Digest, File: l.File}).Intact(dir); err == nil {
+		t.Fatal("expected a file installed without the digest of its files not to be intact")
+	}
+	// Installing again replaces the changed file.
+	if _, err = Install(ctx, dir, nil, []string{u}, lock, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, path); got != content {
+		t.Fatalf("expected the file to be installed again got %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IsRemote reports whether an include is the URL of a task file rather than a path.
+func IsRemote(include string) bool {
+	return strings.HasPrefix(include, "https://") || strings.HasPrefix(include, "http://")
+}
+
+// fetchFile downloads the task file at rawURL under dir.
+// If there are signers its signature is downloaded from the same URL with .minisig appended, or .sig for cosign.
+func fetchFile(ctx context.Context, dir, rawURL string, signers []Signer) (Locked, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Locked{}, err
+	}
+	b, found, err := get(ctx, rawURL)
+	if err != nil {
+		return Locked{}, err
+	}
+	if !found {
+		return Locked{}, fmt.Errorf("GET %s: %s", u.Redacted(), http.StatusText(http.StatusNotFound))
+	}
+	file := path.Base(u.Path)
+	if file == "/" || file == "." || !strings.EqualFold(path.Ext(file), ".md") {
+		file = "README.md"
+	}
+	l := Locked{Ref: rawURL, URL: u.Redacted(), Digest: digestOf(b), File: file}
+	if len(signers) > 0 {
+		var sig []byte
+		for _, ext := range []string{".minisig", ".sig"} {
+			if sig, found, err = get(ctx, rawURL+ext); err != nil {
+				return l, err
+			} else if found {
+				break
+			}
+		}
+		if l.Signer, err = verify(b, sig, signers); err != nil {
+			return l, err
+		}
+	}
+	root := filepath.Join(dir, ".xc", "pkg")
+	if err = os.MkdirAll(root, 0o755); err != nil {
+		return l, err
+	}
+	tmp, err := os.MkdirTemp(root, "tmp-")
+	if err != nil {
+		return l, err
+	}
+	defer os.RemoveAll(tmp)
+	if err = os.WriteFile(filepath.Join(tmp, file), b, 0o644); err != nil {
+		return l, err
+	}
+	if l.Files, err = filesDigest(tmp); err != nil {
+		return l, err
+	}
+	if l.Installed(dir) && l.Intact(dir) == nil {
+		return l, nil
+	}
+	if err = os.RemoveAll(l.Dir(dir)); err != nil {
+		return l, err
+	}
+	return l, os.Rename(tmp, l.Dir(dir))
+}
+
+// get returns the body of rawURL, or false if it is not found.
+func get(ctx context.Context, rawURL string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, nil
+	case resp.StatusCode >= 300:
+		return nil, false, fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	return b, err == nil, err
+}
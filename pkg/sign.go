@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Signer is a public key that packages and remote task files must be signed with,
+// either a minisign public key or a PEM encoded public key used with cosign sign-blob.
+type Signer struct {
+	// ID is the key ID of a minisign key, or the start of the sha256 fingerprint of a PEM key.
+	ID        string
+	minisign  ed25519.PublicKey
+	keyID     [8]byte
+	publicKey crypto.PublicKey
+}
+
+// ParseSigner parses a minisign public key, such as RWQf6LRCGA9i5..., the contents of a minisign .pub file,
+// or a PEM encoded ECDSA, Ed25519 or RSA public key.
+func ParseSigner(key string) (Signer, error) {
+	key = strings.TrimSpace(key)
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Signer{}, fmt.Errorf("invalid public key: %w", err)
+		}
+		switch pub.(type) {
+		case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+		default:
+			return Signer{}, fmt.Errorf("unsupported public key type %T", pub)
+		}
+		sum := sha256.Sum256(block.Bytes)
+		return Signer{ID: hex.EncodeToString(sum[:8]), publicKey: pub}, nil
+	}
+	// A minisign .pub file has an untrusted comment before the key.
+	lines := strings.Split(key, "\n")
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(b) != 42 || string(b[:2]) != "Ed" {
+		return Signer{}, errors.New("invalid public key, expected a minisign or PEM public key")
+	}
+	s := Signer{minisign: ed25519.PublicKey(b[10:])}
+	copy(s.keyID[:], b[2:10])
+	s.ID = fmt.Sprintf("%016X", binary.LittleEndian.Uint64(s.keyID[:]))
+	return s, nil
+}
+
+// ReadSigners parses the signers of a task file in dir, each a public key or the path of a file containing one.
+func ReadSigners(dir string, signers []string) ([]Signer, error) {
+	var parsed []Signer
+	for _, key := range signers {
+		if s, err := ParseSigner(key); err == nil {
+			parsed = append(parsed, s)
+			continue
+		}
+		path := key
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("signer %s is neither a public key nor a file containing one: %w", key, err)
+		}
+		s, err := ParseSigner(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("signer %s: %w", key, err)
+		}
+		parsed = append(parsed, s)
+	}
+	return parsed, nil
+}
+
+// verify returns the ID of the signer that signed data with sig, a minisign signature or a base64 encoded
+// cosign signature, or an error if none of signers did.
+func verify(data, sig []byte, signers []Signer) (string, error) {
+	if len(sig) == 0 {
+		return "", errors.New("it is not signed")
+	}
+	for _, s := range signers {
+		var ok bool
+		if s.minisign != nil {
+			ok = s.verifyMinisign(data, sig)
+		} else {
+			ok = s.verifyCosign(data, sig)
+		}
+		if ok {
+			return s.ID, nil
+		}
+	}
+	return "", errors.New("its signature is not from a trusted signer")
+}
+
+// verifyMinisign verifies a minisign signature: an untrusted comment, the signature, a trusted comment
+// and a signature of the signature and trusted comment.
+// The signature is of the BLAKE2b-512 hash of data if its algorithm is ED, or of data itself for legacy Ed signatures.
+func (s Signer) verifyMinisign(data, sig []byte) bool {
+	lines := strings.Split(strings.TrimSpace(string(sig)), "\n")
+	if len(lines) != 4 {
+		return false
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(b) != 74 || !bytes.Equal(b[2:10], s.keyID[:]) {
+		return false
+	}
+	msg := data
+	switch string(b[:2]) {
+	case "ED":
+		sum := blake2b.Sum512(data)
+		msg = sum[:]
+	case "Ed":
+	default:
+		return false
+	}
+	if !ed25519.Verify(s.minisign, msg, b[10:]) {
+		return false
+	}
+	comment, ok := strings.CutPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+	if !ok {
+		return false
+	}
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	return err == nil && ed25519.Verify(s.minisign, append(b[10:74:74], comment...), global)
+}
+
+// verifyCosign verifies a signature written by cosign sign-blob: the base64 encoded signature of the sha256 of data.
+func (s Signer) verifyCosign(data, sig []byte) bool {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(data)
+	switch pub := s.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(pub, sum[:], b)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, data, b)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], b) == nil
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package pkg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignKey is a minisign key pair, its public key encoded as in a minisign .pub file.
+type minisignKey struct {
+	id      [8]byte
+	private ed25519.PrivateKey
+	public  string
+}
+
+func newMinisignKey(t *testing.T, id byte) minisignKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := minisignKey{id: [8]byte{id, 1, 2, 3, 4, 5, 6, 7}, private: priv}
+	k.public = "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), k.id[:]...), pub...)) + "\n"
+	return k
+}
+
+// sign returns a minisign signature of data, prehashed unless legacy is set.
+func (k minisignKey) sign(data []byte, legacy bool) []byte {
+	alg, msg := "ED", data
+	if legacy {
+		alg = "Ed"
+	} else {
+		sum := blake2b.Sum512(data)
+		msg = sum[:]
+	}
+	sig := ed25519.Sign(k.private, msg)
+	comment := "timestamp:1700000000\tfile:bundle.tar.gz"
+	global := ed25519.Sign(k.private, append(append([]byte(nil), sig...), comment...))
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte(alg), k.id[:]...), sig...)) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+func TestVerify(t *testing.T) {
+	data := []byte("## Tasks\n### lint\n```\necho lint\n```\n")
+	trusted := newMinisignKey(t, 1)
+	other := newMinisignKey(t, 2)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosignSig := []byte(base64.StdEncoding.EncodeToString(ecSig))
+
+	var signers []Signer
+	for _, key := range []string{trusted.public, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))} {
+		s, err := ParseSigner(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, s)
+	}
+	tampered := trusted.sign(data, false)
+	tampered[len(tampered)-5] ^= 1
+
+	tests := []struct {
+		name     string
+		data     []byte
+		sig      []byte
+		expected string
+	}{
+		{name: "minisign", data: data, sig: trusted.sign(data, false), expected: signers[0].ID},
+		{name: "legacy minisign", data: data, sig: trusted.sign(data, true), expected: signers[0].ID},
+		{name: "cosign", data: data, sig: cosignSig, expected: signers[1].ID},
+		{name: "untrusted minisign key", data: data, sig: other.sign(data, false)},
+		{name: "modified data", data: append([]byte("#"), data...), sig: trusted.sign(data, false)},
+		{name: "modified trusted comment signature", data: data, sig: tampered},
+		{name: "modified cosign data", data: data[1:], sig: cosignSig},
+		{name: "unsigned", data: data},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := verify(tt.data, tt.sig, signers)
+			if tt.expected == "" {
+				if err == nil {
+					t.Fatalf("expected an error, verified by %s", id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != tt.expected {
+				t.Fatalf("expected signer %s got %s", tt.expected, id)
+			}
+		})
+	}
+}
+
+func TestReadSigners(t *testing.T) {
+	dir := t.TempDir()
+	k := newMinisignKey(t, 3)
+	write(t, filepath.Join(dir, "keys", "org.pub"), k.public)
+	inline := strings.Split(strings.TrimSpace(newMinisignKey(t, 4).public), "\n")[1]
+	signers, err := ReadSigners(dir, []string{"keys/org.pub", inline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signers) != 2 || signers[0].ID != "0706050403020103" || signers[1].ID != "0706050403020104" {
+		t.Fatalf("unexpected signers %+v", signers)
+	}
+	if _, err = ReadSigners(dir, []string{"keys/missing.pub"}); err == nil {
+		t.Fatal("expected an error for a missing key")
+	}
+}
+
+func TestInstallSigned(t *testing.T) {
+	ctx := context.Background()
+	key := newMinisignKey(t, 5)
+	signer, err := ParseSigner(key.public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers := []Signer{signer}
+	repo := fakeRegistry(t)
+	src := t.TempDir()
+	write(t, filepath.Join(src, "README.md"), "## Tasks\n### lint\n```\necho lint\n```\n")
+	signed := Ref{Source: repo, Version: "v1"}
+	unsigned := Ref{Source: repo, Version: "v2"}
+	publish(t, signed, src, "README.md", func(b []byte) []byte { return key.sign(b, false) })
+	publish(t, unsigned, src, "README.md", nil)
+
+	content := []byte("## Tasks\n### release\n```\necho release\n```\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/release.md", "/unsigned.md":
+			_, _ = w.Write(content)
+		case "/release.md.minisig":
+			_, _ = w.Write(key.sign(content, false))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	lock, err := Install(ctx, dir, []string{signed.String()}, []string{srv.URL + "/release.md"}, Lock{}, signers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range append(lock.Packages, lock.Includes...) {
+		if l.Signer != signer.ID || !l.Verified(signers) {
+			t.Fatalf("expected %s to be verified by %s", l.Ref, signer.ID)
+		}
+	}
+	_, err = Install(ctx, dir, []string{unsigned.String()}, nil, Lock{}, signers)
+	if err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Fatalf("expected an unsigned package to be rejected got %v", err)
+	}
+	_, err = Install(ctx, dir, nil, []string{srv.URL + "/unsigned.md"}, Lock{}, signers)
+	if err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Fatalf("expected an unsigned file to be rejected got %v", err)
+	}
+	other, err := ParseSigner(newMinisignKey(t, 6).public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Install(ctx, dir, []string{signed.String()}, nil, lock, []Signer{other})
+	if err == nil || !strings.Contains(err.Error(), "trusted signer") {
+		t.Fatalf("expected a package installed before the signer changed to be verified again got %v", err)
+	}
+	_, err = Install(ctx, dir, []string{os.TempDir() + "@v1"}, nil, Lock{}, signers)
+	if err == nil || !strings.Contains(err.Error(), "git") {
+		t.Fatalf("expected git packages to be rejected got %v", err)
+	}
+	// Without signers the unsigned package can be installed.
+	if _, err = Install(ctx, dir, []string{unsigned.String()}, nil, Lock{}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package progress reports the progress of a run as plain lines of text, without colors or control sequences,
+// so that it can be followed with a screen reader.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+// DefaultInterval is how often the tasks that are still running are listed.
+const DefaultInterval = 30 * time.Second
+
+// Reporter is a run.Observer that writes a line when a task starts, finishes or is skipped,
+// and once started lists the tasks that are still running at an interval, so that long silences are explained.
+type Reporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	now     func() time.Time
+	running []*task
+}
+
+var _ run.SkipObserver = &Reporter{}
+
+type task struct {
+	name  string
+	start time.Time
+}
+
+type taskKey struct{}
+
+// New returns a Reporter that writes to w.
+func New(w io.Writer) *Reporter {
+	return &Reporter{w: w, now: time.Now}
+}
+
+// Start lists the running tasks every interval, until stop is called.
+func (r *Reporter) Start(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				r.status()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// status writes the tasks that are running and how long they have been running for, if there are any.
+func (r *Reporter) status() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.running) == 0 {
+		return
+	}
+	now := r.now()
+	names := make([]string, len(r.running))
+	for i, t := range r.running {
+		names[i] = fmt.Sprintf("%s for %s", t.name, now.Sub(t.start).Round(time.Second))
+	}
+	noun := "tasks"
+	if len(names) == 1 {
+		noun = "task"
+	}
+	fmt.Fprintf(r.w, "xc: %d %s running: %s\n", len(names), noun, strings.Join(names, ", "))
+}
+
+// TaskStarted writes that a task started.
+func (r *Reporter) TaskStarted(ctx context.Context, mt models.Task) context.Context {
+	t := &task{name: mt.Name, start: r.now()}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = append(r.running, t)
+	fmt.Fprintf(r.w, "xc: started %s\n", t.name)
+	return context.WithValue(ctx, taskKey{}, t)
+}
+
+// TaskFinished writes whether a task succeeded and how long it took.
+func (r *Reporter) TaskFinished(ctx context.Context, _ models.Task, err error) {
+	t, ok := ctx.Value(taskKey{}).(*task)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rt := range r.running {
+		if rt == t {
+			r.running = append(r.running[:i], r.running[i+1:]...)
+			break
+		}
+	}
+	took := r.now().Sub(t.start).Round(100 * time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(r.w, "xc: failed %s after %s: %v\n", t.name, took, err)
+		return
+	}
+	fmt.Fprintf(r.w, "xc: finished %s in %s\n", t.name, took)
+}
+
+// TaskSkipped writes that a task was skipped and why.
+func (r *Reporter) TaskSkipped(_ context.Context, mt models.Task, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, "xc: skipped %s: %s\n", mt.Name, reason)
+}
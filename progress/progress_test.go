@@ -0,0 +1,79 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out)
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	ci := models.Task{Name: "ci"}
+	build := models.Task{Name: "build"}
+	ctx := r.TaskStarted(context.Background(), ci)
+	buildCtx := r.TaskStarted(ctx, build)
+	r.TaskSkipped(ctx, models.Task{Name: "setup"}, "ran already")
+	now = now.Add(90 * time.Second)
+	r.status()
+	r.TaskFinished(buildCtx, build, nil)
+	now = now.Add(1500 * time.Millisecond)
+	r.status()
+	r.TaskFinished(ctx, ci, errors.New("exit status 1"))
+	r.status()
+	expected := []string{
+		"xc: started ci",
+		"xc: started build",
+		"xc: skipped setup: ran already",
+		"xc: 2 tasks running: ci for 1m30s, build for 1m30s",
+		"xc: finished build in 1m30s",
+		"xc: 1 task running: ci for 1m32s",
+		"xc: failed ci after 1m31.5s: exit status 1",
+	}
+	if got := strings.TrimSpace(out.String()); got != strings.Join(expected, "\n") {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), got)
+	}
+	if strings.ContainsAny(out.String(), "\x1b\r") {
+		t.Fatalf("expected no control sequences got %q", out.String())
+	}
+}
+
+func TestReporterStart(t *testing.T) {
+	var out syncBuffer
+	r := New(&out)
+	r.TaskStarted(context.Background(), models.Task{Name: "watch"})
+	stop := r.Start(time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "1 task running: watch") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the running tasks to be listed got %q", out.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+}
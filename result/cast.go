@@ -0,0 +1,105 @@
+package result
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// cast writes the output of a task to a file as an asciinema cast, version 2, keeping the time of each write
+// so the output can be played back as it appeared, with `asciinema play`, or read as a timestamped typescript.
+type cast struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	now   func() time.Time
+	start time.Time
+	// partial holds the start of a UTF-8 character split across writes.
+	partial []byte
+	err     error
+}
+
+// castHeader is the first line of an asciinema cast.
+type castHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// newCast creates a cast at path, titled after the task it records.
+func newCast(path, title string) (*cast, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	c := &cast{f: f, w: bufio.NewWriter(f), now: time.Now}
+	c.start = c.now()
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	b, _ := json.Marshal(castHeader{Version: 2, Width: width, Height: height, Timestamp: c.start.Unix(), Title: title})
+	_, _ = c.w.Write(append(b, '\n'))
+	return c, nil
+}
+
+// Write adds an output event to the cast, stdout and stderr are both output as a terminal shows them together.
+// It never fails, so that a recording that cannot be written does not fail the task, the error is returned by Close.
+func (c *cast) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := append(c.partial, p...)
+	c.partial = nil
+	// A character split across writes is kept until the rest of it is written, as events hold whole characters.
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				c.partial = append([]byte{}, data[len(data)-i:]...)
+				data = data[:len(data)-i]
+			}
+			break
+		}
+	}
+	if err := c.event(data); err != nil && c.err == nil {
+		c.err = err
+	}
+	return len(p), nil
+}
+
+// event writes an output event of data to the cast. c.mu must be held.
+func (c *cast) event(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	b, err := json.Marshal([]any{c.now().Sub(c.start).Seconds(), "o", string(data)})
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write(append(b, '\n'))
+	return err
+}
+
+// Close writes what is left of the cast to its file and closes it.
+func (c *cast) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.err
+	if perr := c.event(c.partial); err == nil {
+		err = perr
+	}
+	if ferr := c.w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
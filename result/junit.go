@@ -0,0 +1,114 @@
+package result
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	ID        string          `xml:"id,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes a report as JUnit XML, with a test case for each task that ran or was skipped,
+// so CI systems can show a run in their test UIs.
+func WriteJUnit(w io.Writer, rep Report) error {
+	suite := junitTestSuite{
+		Name:      "xc " + rep.Task,
+		Time:      seconds(rep.Duration),
+		Timestamp: rep.Start.Format("2006-01-02T15:04:05"),
+		ID:        rep.RunID,
+	}
+	for _, t := range rep.Tasks {
+		tc := junitTestCase{
+			Name:      t.Name,
+			Classname: "xc." + rep.Task,
+			Time:      seconds(t.Duration),
+		}
+		if t.Output != "" {
+			tc.SystemOut = &junitOutput{Text: t.Output}
+		}
+		switch t.Status {
+		case StatusFailed:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: t.Error, Text: t.Error}
+		case StatusSkipped:
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: t.Reason}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+	suites := junitTestSuites{
+		Name:     "xc",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteJUnitFile writes the report of a run as JUnit XML to path.
+func (r *Recorder) WriteJUnitFile(path, runID string, runErr error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write junit report: %w", err)
+	}
+	defer f.Close()
+	if err = WriteJUnit(f, r.Report(runID, runErr)); err != nil {
+		return fmt.Errorf("failed to write junit report: %w", err)
+	}
+	return f.Close()
+}
+
+func seconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
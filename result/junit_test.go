@@ -0,0 +1,45 @@
+package result
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	code := 1
+	rep := Report{
+		RunID:    "abc",
+		Task:     "release",
+		Start:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Duration: 2.5,
+		Tasks: []*Task{
+			{Name: "release", Status: StatusFailed, Duration: 2.5, ExitCode: &code, Error: "exit status 1"},
+			{Name: "build", Status: StatusSucceeded, Duration: 1.25, Output: "building\n"},
+			{Name: "setup", Status: StatusSkipped, Reason: "ran already"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, rep); err != nil {
+		t.Fatal(err)
+	}
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Tests != 3 || got.Failures != 1 || got.Skipped != 1 || got.Time != "2.500" {
+		t.Fatalf("unexpected totals %+v", got)
+	}
+	cases := got.Suites[0].Cases
+	if cases[0].Failure == nil || cases[0].Failure.Message != "exit status 1" {
+		t.Fatalf("expected release to fail got %+v", cases[0])
+	}
+	if c := cases[1]; c.Failure != nil || c.Skipped != nil || c.SystemOut == nil ||
+		c.SystemOut.Text != "building\n" || c.Time != "1.250" {
+		t.Fatalf("expected build to pass with output got %+v", cases[1])
+	}
+	if cases[2].Skipped == nil || cases[2].Skipped.Message != "ran already" {
+		t.Fatalf("expected setup to be skipped got %+v", cases[2])
+	}
+}
@@ -0,0 +1,49 @@
+package result
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joerdav/xc/run"
+)
+
+// lastRunReport is the name of the report in the directory of the last run of a task.
+const lastRunReport = "result.json"
+
+// LastRunDir returns the directory the report and logs of the last run of task are kept in,
+// in the state directory of the task file in dir.
+func LastRunDir(dir, task string) string {
+	return filepath.Join(run.StateDir(dir), "runs", unsafeFileChars.ReplaceAllString(task, "_"))
+}
+
+// WriteLastRun replaces the report and logs of the last run of the task in dir, written by LastRunDir,
+// the output of each task that ran is written to a log named after its position in the report and its name.
+// The output is only there if the Recorder captured it, and is readable only by the user as it may hold secrets.
+func (r *Recorder) WriteLastRun(dir, runID string, runErr error) error {
+	rep := r.Report(runID, runErr)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove the last run: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create the last run directory: %w", err)
+	}
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to write the last run: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, lastRunReport), append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write the last run: %w", err)
+	}
+	for i, t := range rep.Tasks {
+		if t.Output == "" {
+			continue
+		}
+		name := fmt.Sprintf("%d-%s.log", i+1, unsafeFileChars.ReplaceAllString(t.Name, "_"))
+		if err = os.WriteFile(filepath.Join(dir, name), []byte(t.Output), 0o600); err != nil {
+			return fmt.Errorf("failed to write the last run: %w", err)
+		}
+	}
+	return nil
+}
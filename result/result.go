@@ -0,0 +1,325 @@
+// Package result records a machine-readable report of a run, for CI steps to use in annotations and badges.
+package result
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+// Status is the outcome of a task.
+type Status string
+
+const (
+	// StatusSucceeded is the status of a task that ran successfully.
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed is the status of a task that failed, or whose required tasks failed.
+	StatusFailed Status = "failed"
+	// StatusSkipped is the status of a task that did not run, Reason says why.
+	StatusSkipped Status = "skipped"
+)
+
+// Report is the result of a run of a task and the tasks it ran.
+type Report struct {
+	RunID    string    `json:"runId"`
+	Task     string    `json:"task"`
+	Inputs   []string  `json:"inputs,omitempty"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"durationSeconds"`
+	Tasks    []*Task   `json:"tasks"`
+}
+
+// Task is the result of a task in a run, in the order tasks started or were skipped.
+// ExitCode is set if the task failed because a script exited with a non-zero code,
+// Artifacts are the outputs asserted by the task and Output is the output of its script.
+// Cache is hit or miss if the outputs of the task were looked up in the cache.
+// The CPU time, largest resident set size and storage IO of the processes a task started are set if it started any.
+// Recording is the path of the asciinema cast of the output of a task that failed, if the Recorder records them.
+type Task struct {
+	Name       string    `json:"name"`
+	Dir        string    `json:"dir,omitempty"`
+	Status     Status    `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+	Cache      string    `json:"cache,omitempty"`
+	Start      time.Time `json:"start"`
+	Duration   float64   `json:"durationSeconds"`
+	ExitCode   *int      `json:"exitCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Artifacts  []string  `json:"artifacts,omitempty"`
+	CPUUser    float64   `json:"cpuUserSeconds,omitempty"`
+	CPUSystem  float64   `json:"cpuSystemSeconds,omitempty"`
+	MaxRSS     int64     `json:"maxRssBytes,omitempty"`
+	ReadBytes  int64     `json:"readBytes,omitempty"`
+	WriteBytes int64     `json:"writeBytes,omitempty"`
+	Recording  string    `json:"recording,omitempty"`
+	Output     string    `json:"-"`
+	output     syncBuffer
+	cast       *cast
+}
+
+// maxOutput is the most output kept for each task, the end of the output is kept as it is where failures are.
+const maxOutput = 1 << 20
+
+// syncBuffer is a buffer of the last maxOutput bytes written to it that is safe for concurrent use.
+type syncBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n, err := b.buf.Write(p)
+	// The buffer is trimmed once it holds twice the limit, so that the output is not copied on every write.
+	if b.buf.Len() > 2*maxOutput {
+		tail := append([]byte(nil), b.buf.Bytes()[b.buf.Len()-maxOutput:]...)
+		b.buf.Reset()
+		b.buf.Write(tail)
+		b.truncated = true
+	}
+	return n, err
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf.Len() <= maxOutput && !b.truncated {
+		return b.buf.String()
+	}
+	start := 0
+	if b.buf.Len() > maxOutput {
+		start = b.buf.Len() - maxOutput
+	}
+	return "[output truncated]\n" + string(b.buf.Bytes()[start:])
+}
+
+type taskKey struct{}
+
+// Recorder is a run.Observer that records the result of each task.
+type Recorder struct {
+	mu     sync.Mutex
+	report Report
+	// recordDir is the directory casts of the output of failed tasks are kept in, if they are recorded.
+	recordDir string
+	// capture is set if the output of tasks is kept in the report.
+	capture bool
+	// secrets are the values of the secrets resolved for the tasks of the run, which are masked in their output.
+	secrets []string
+}
+
+var (
+	_ run.SkipObserver   = &Recorder{}
+	_ run.OutputObserver = &Recorder{}
+	_ run.CacheObserver  = &Recorder{}
+	_ run.UsageObserver  = &Recorder{}
+	_ run.SecretObserver = &Recorder{}
+)
+
+// NewRecorder returns a Recorder for a run of a task with inputs.
+func NewRecorder(task string, inputs []string) *Recorder {
+	return &Recorder{report: Report{Task: task, Inputs: inputs, Start: time.Now()}}
+}
+
+// Record keeps an asciinema cast of the output of each task that fails in dir, which must exist,
+// so that a failure in CI can be played back as it happened. The casts of tasks that succeed are removed.
+func (r *Recorder) Record(dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recordDir = dir
+}
+
+// CaptureOutput keeps the output of the script of each task in the report,
+// with the secrets resolved for the run masked.
+// Scripts write to a pipe rather than the terminal once their output is captured.
+func (r *Recorder) CaptureOutput() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.capture = true
+}
+
+// TaskStarted records that a task started.
+func (r *Recorder) TaskStarted(ctx context.Context, task models.Task) context.Context {
+	t := &Task{Name: task.Name, Dir: task.Dir, Start: time.Now()}
+	r.mu.Lock()
+	r.report.Tasks = append(r.report.Tasks, t)
+	r.mu.Unlock()
+	return context.WithValue(ctx, taskKey{}, t)
+}
+
+// TaskFinished records the outcome of a task.
+func (r *Recorder) TaskFinished(ctx context.Context, task models.Task, err error) {
+	t, ok := ctx.Value(taskKey{}).(*Task)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t.Duration = time.Since(t.Start).Seconds()
+	t.Output = r.mask(t.output.String())
+	if t.cast != nil {
+		path := t.cast.f.Name()
+		if cerr := t.cast.Close(); err != nil && cerr == nil {
+			t.Recording = path
+		} else {
+			_ = os.Remove(path)
+		}
+		t.cast = nil
+	}
+	if err != nil {
+		t.Status = StatusFailed
+		t.Error = err.Error()
+		if code, ok := run.ExitCode(err); ok {
+			t.ExitCode = &code
+		}
+		return
+	}
+	t.Status = StatusSucceeded
+	zero := 0
+	t.ExitCode = &zero
+	for _, o := range task.AssertOutputs {
+		t.Artifacts = append(t.Artifacts, o.Path)
+	}
+}
+
+// TaskOutput returns the writer that records the output of the script of a task.
+func (r *Recorder) TaskOutput(ctx context.Context, task models.Task) io.Writer {
+	t, ok := ctx.Value(taskKey{}).(*Task)
+	if !ok {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recordDir == "" {
+		if !r.capture {
+			return nil
+		}
+		return &t.output
+	}
+	if t.cast == nil {
+		// The position of the task in the report tells apart the recordings of tasks with the same name.
+		i := 0
+		for i < len(r.report.Tasks) && r.report.Tasks[i] != t {
+			i++
+		}
+		name := fmt.Sprintf("%s-%d-%s.cast",
+			r.report.Start.Format("20060102T150405"), i+1, unsafeFileChars.ReplaceAllString(task.Name, "_"))
+		c, err := newCast(filepath.Join(r.recordDir, name), "xc "+task.Name)
+		if err != nil {
+			return &t.output
+		}
+		t.cast = c
+	}
+	return io.MultiWriter(&t.output, t.cast)
+}
+
+// TaskSecrets records the values of the secrets resolved for a task, to be masked in the output of every task.
+func (r *Recorder) TaskSecrets(ctx context.Context, task models.Task, secrets []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Longer secrets are replaced first, so that a secret containing another is masked whole.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// mask replaces the secrets resolved for the run in s, r.mu must be held.
+func (r *Recorder) mask(s string) string {
+	if len(r.secrets) == 0 {
+		return s
+	}
+	pairs := make([]string, 0, 2*len(r.secrets))
+	for _, secret := range r.secrets {
+		pairs = append(pairs, secret, "****")
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// unsafeFileChars matches the characters of a task name that are replaced in the name of its recording.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// TaskSkipped records that a task was skipped.
+func (r *Recorder) TaskSkipped(ctx context.Context, task models.Task, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Tasks = append(r.report.Tasks, &Task{
+		Name:   task.Name,
+		Dir:    task.Dir,
+		Status: StatusSkipped,
+		Reason: reason,
+		Start:  time.Now(),
+	})
+}
+
+// TaskCached records whether the outputs of a task were restored from the cache.
+func (r *Recorder) TaskCached(ctx context.Context, task models.Task, hit bool) {
+	t, ok := ctx.Value(taskKey{}).(*Task)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t.Cache = "miss"
+	if hit {
+		t.Cache = "hit"
+	}
+}
+
+// TaskUsage records the resources used by the processes of a task.
+func (r *Recorder) TaskUsage(ctx context.Context, task models.Task, u run.Usage) {
+	t, ok := ctx.Value(taskKey{}).(*Task)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t.CPUUser = u.UserTime.Seconds()
+	t.CPUSystem = u.SystemTime.Seconds()
+	t.MaxRSS = u.MaxRSS
+	t.ReadBytes = u.ReadBytes
+	t.WriteBytes = u.WriteBytes
+}
+
+// Report returns the report of a run, runErr is the error returned by the run.
+func (r *Recorder) Report(runID string, runErr error) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := r.report
+	rep.RunID = runID
+	rep.Success = runErr == nil
+	if runErr != nil {
+		rep.Error = runErr.Error()
+	}
+	rep.Duration = time.Since(rep.Start).Seconds()
+	rep.Tasks = append([]*Task{}, r.report.Tasks...)
+	return rep
+}
+
+// WriteFile writes the report of a run as JSON to path.
+func (r *Recorder) WriteFile(path, runID string, runErr error) error {
+	b, err := json.MarshalIndent(r.Report(runID, runErr), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to write result file: %w", err)
+	}
+	if err = os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write result file: %w", err)
+	}
+	return nil
+}
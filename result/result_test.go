@@ -0,0 +1,386 @@
+package result
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/xc/cache"
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+	"mvdan.cc/sh/v3/interp"
+)
+
+type scriptRunner map[string]error
+
+func (s scriptRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	return s[text]
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app"), []byte("app"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{
+		{Name: "setup", Script: "setup", Language: "fake", RequiredBehaviour: models.RequiredBehaviourOnce},
+		{
+			Name: "build", Script: "build", Language: "fake", DependsOn: []string{"setup"},
+			AssertOutputs: []models.OutputAssertion{{Path: "app"}},
+		},
+		{Name: "test", Script: "test", Language: "fake", DependsOn: []string{"setup"}},
+		{Name: "release", Script: "release", Language: "fake", DependsOn: []string{"build", "test"}},
+	}
+	recorder := NewRecorder("release", nil)
+	runner, err := run.NewRunner(tasks, dir,
+		run.WithObserver(recorder),
+		run.WithExecutor("fake", scriptRunner{"test": interp.NewExitStatus(3)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := runner.Run(context.Background(), "release", nil)
+	if runErr == nil {
+		t.Fatal("expected the run to fail")
+	}
+	path := filepath.Join(dir, "result.json")
+	if err = recorder.WriteFile(path, runner.RunID(), runErr); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err = json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Success || report.RunID != runner.RunID() || report.Task != "release" || report.Error == "" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	expected := []struct {
+		name     string
+		status   Status
+		exitCode int
+	}{
+		{"release", StatusFailed, 3},
+		{"build", StatusSucceeded, 0},
+		{"setup", StatusSucceeded, 0},
+		{"test", StatusFailed, 3},
+		{"setup", StatusSkipped, -1},
+	}
+	if len(report.Tasks) != len(expected) {
+		t.Fatalf("expected %d tasks got %d", len(expected), len(report.Tasks))
+	}
+	for i, e := range expected {
+		got := report.Tasks[i]
+		if got.Name != e.name || got.Status != e.status {
+			t.Fatalf("expected task %d to be %s %s got %s %s", i, e.name, e.status, got.Name, got.Status)
+		}
+		if e.exitCode >= 0 && (got.ExitCode == nil || *got.ExitCode != e.exitCode) {
+			t.Fatalf("expected %s to exit with %d got %v", e.name, e.exitCode, got.ExitCode)
+		}
+	}
+	if len(report.Tasks[1].Artifacts) != 1 || report.Tasks[1].Artifacts[0] != "app" {
+		t.Fatalf("expected build to have artifact app got %v", report.Tasks[1].Artifacts)
+	}
+}
+
+func TestRecorderOutput(t *testing.T) {
+	recorder := NewRecorder("hello", nil)
+	recorder.CaptureOutput()
+	runner, err := run.NewRunner(models.Tasks{{Name: "hello", Script: "echo hello\necho world >&2\n"}}, t.TempDir(),
+		run.WithObserver(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatal(err)
+	}
+	report := recorder.Report(runner.RunID(), nil)
+	if got := report.Tasks[0].Output; !strings.Contains(got, "hello\n") || !strings.Contains(got, "world\n") {
+		t.Fatalf("expected output to be captured got %q", got)
+	}
+}
+
+func TestRecorderOutputNotCaptured(t *testing.T) {
+	recorder := NewRecorder("hello", nil)
+	runner, err := run.NewRunner(models.Tasks{{Name: "hello", Script: "echo hello\n"}}, t.TempDir(),
+		run.WithObserver(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := recorder.Report(runner.RunID(), nil).Tasks[0].Output; got != "" {
+		t.Fatalf("expected output not to be captured unless asked got %q", got)
+	}
+}
+
+func TestRecorderOutputMasksSecrets(t *testing.T) {
+	recorder := NewRecorder("deploy", nil)
+	recorder.CaptureOutput()
+	tasks := models.Tasks{{Name: "deploy", Env: []string{"TOKEN=$(echo s3cr3t-value)"}, Script: "echo token is $TOKEN\n"}}
+	runner, err := run.NewRunner(tasks, t.TempDir(), run.WithObserver(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "deploy", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := recorder.Report(runner.RunID(), nil).Tasks[0].Output; !strings.Contains(got, "token is ****") {
+		t.Fatalf("expected the resolved secret to be masked got %q", got)
+	}
+}
+
+func TestSyncBufferKeepsTheEnd(t *testing.T) {
+	var b syncBuffer
+	line := strings.Repeat("x", 1023) + "\n"
+	for i := 0; i < 3*maxOutput/len(line); i++ {
+		_, _ = b.Write([]byte(line))
+	}
+	_, _ = b.Write([]byte("the end\n"))
+	got := b.String()
+	if len(got) > maxOutput+len("[output truncated]\n") {
+		t.Fatalf("expected at most %d bytes of output got %d", maxOutput, len(got))
+	}
+	if !strings.HasPrefix(got, "[output truncated]\n") || !strings.HasSuffix(got, "the end\n") {
+		t.Fatalf("expected the end of the output to be kept got %q...%q", got[:20], got[len(got)-20:])
+	}
+}
+
+func TestRecorderWriteLastRun(t *testing.T) {
+	recorder := NewRecorder("ci", nil)
+	recorder.CaptureOutput()
+	tasks := models.Tasks{
+		{Name: "ci", DependsOn: []string{"build:linux"}},
+		{Name: "build:linux", Script: "echo built\n"},
+	}
+	runner, err := run.NewRunner(tasks, t.TempDir(), run.WithObserver(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "ci", nil); err != nil {
+		t.Fatal(err)
+	}
+	dir := LastRunDir(t.TempDir(), "ci")
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "1-old.log"), []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = recorder.WriteLastRun(dir, runner.RunID(), nil); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "2-build_linux.log,result.json" {
+		t.Fatalf("expected the log of build:linux and the report to replace the last run got %s", got)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "2-build_linux.log")); !strings.Contains(string(b), "built\n") {
+		t.Fatalf("expected the output of build:linux got %q", b)
+	}
+	var report Report
+	b, err := os.ReadFile(filepath.Join(dir, "result.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(b, &report); err != nil || report.Task != "ci" || !report.Success {
+		t.Fatalf("expected the report of the run got %s: %v", b, err)
+	}
+}
+
+func TestRecorderRecord(t *testing.T) {
+	dir := t.TempDir()
+	recorder := NewRecorder("ci", nil)
+	recorder.Record(dir)
+	tasks := models.Tasks{
+		{Name: "ci", DependsOn: []string{"build", "test"}},
+		{Name: "build", Script: "echo built\n"},
+		{Name: "test", Script: "echo héllo\necho failed >&2\nexit 1\n", DependsOn: []string{"build"}},
+	}
+	runner, err := run.NewRunner(tasks, t.TempDir(), run.WithObserver(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := runner.Run(context.Background(), "ci", nil)
+	if runErr == nil {
+		t.Fatal("expected the run to fail")
+	}
+	report := recorder.Report(runner.RunID(), runErr)
+	var recorded []*Task
+	for _, task := range report.Tasks {
+		if task.Recording != "" {
+			recorded = append(recorded, task)
+		}
+	}
+	if len(recorded) != 1 || recorded[0].Name != "test" {
+		t.Fatalf("expected only test to be recorded got %+v", recorded)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected the recording of build to be removed got %v", entries)
+	}
+	b, err := os.ReadFile(recorded[0].Recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	var header castHeader
+	if err = json.Unmarshal([]byte(lines[0]), &header); err != nil || header.Version != 2 || header.Title != "xc test" {
+		t.Fatalf("expected an asciinema header got %s: %v", lines[0], err)
+	}
+	var output strings.Builder
+	for _, line := range lines[1:] {
+		var event []any
+		if err = json.Unmarshal([]byte(line), &event); err != nil || len(event) != 3 || event[1] != "o" {
+			t.Fatalf("expected an output event got %s: %v", line, err)
+		}
+		output.WriteString(event[2].(string))
+	}
+	if got := output.String(); !strings.Contains(got, "héllo\n") || !strings.Contains(got, "failed\n") {
+		t.Fatalf("expected the output of test to be recorded got %q", got)
+	}
+	var summary strings.Builder
+	if err = WriteRecordings(&summary, report); err != nil {
+		t.Fatal(err)
+	}
+	expected := "xc: test failed, its output is recorded in " + recorded[0].Recording + "\n"
+	if summary.String() != expected {
+		t.Fatalf("expected %q got %q", expected, summary.String())
+	}
+}
+
+func TestCastSplitCharacter(t *testing.T) {
+	c, err := newCast(filepath.Join(t.TempDir(), "test.cast"), "xc test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := []byte("é")
+	_, _ = c.Write(b[:1])
+	_, _ = c.Write(b[1:])
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(c.f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], `"o","é"]`) {
+		t.Fatalf("expected the character to be written as one event got %q", lines[1:])
+	}
+}
+
+func TestRecorderUsage(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	recorder := NewRecorder("work", nil)
+	runner, err := run.NewRunner(models.Tasks{
+		{Name: "work", Script: "sh -c 'i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done'\n", DependsOn: []string{"noop"}},
+		{Name: "noop", Script: "true\n"},
+	}, t.TempDir(), run.WithObserver(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "work", nil); err != nil {
+		t.Fatal(err)
+	}
+	report := recorder.Report(runner.RunID(), nil)
+	work, noop := report.Tasks[0], report.Tasks[1]
+	if work.CPUUser+work.CPUSystem <= 0 {
+		t.Fatalf("expected the CPU time of work to be recorded got %+v", work)
+	}
+	if runtime.GOOS != "windows" && work.MaxRSS <= 0 {
+		t.Fatalf("expected the max RSS of work to be recorded got %+v", work)
+	}
+	// true is a builtin of the shell, so noop starts no process.
+	if noop.CPUUser != 0 || noop.MaxRSS != 0 {
+		t.Fatalf("expected no usage for noop got %+v", noop)
+	}
+}
+
+func TestRecorderCache(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("input"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{{
+		Name:          "build",
+		Script:        "cp in.txt out.txt\n",
+		Sources:       []string{"in.txt"},
+		AssertOutputs: []models.OutputAssertion{{Path: "out.txt"}},
+	}}
+	b := cache.Dir(t.TempDir())
+	for _, expected := range []string{"miss", "hit"} {
+		recorder := NewRecorder("build", nil)
+		runner, err := run.NewRunner(tasks, dir, run.WithObserver(recorder), run.WithCache(b, cache.ModeUpload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = runner.Run(context.Background(), "build", nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := recorder.Report(runner.RunID(), nil).Tasks[0].Cache; got != expected {
+			t.Fatalf("expected a cache %s got %q", expected, got)
+		}
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		report   Report
+		expected string
+	}{
+		{
+			name: "given a failed run, should count each status",
+			report: Report{Task: "release", Duration: 1.234, Tasks: []*Task{
+				{Name: "release", Status: StatusFailed},
+				{Name: "build", Status: StatusSucceeded},
+				{Name: "test", Status: StatusFailed},
+				{Name: "setup", Status: StatusSkipped},
+			}},
+			expected: "xc: release failed in 1.23s: 3 ran, 1 skipped, 2 failed\n",
+		},
+		{
+			name: "given cached tasks, should print the hit rate",
+			report: Report{Task: "ci", Success: true, Duration: 0.5, Tasks: []*Task{
+				{Name: "ci", Status: StatusSucceeded},
+				{Name: "build", Status: StatusSucceeded, Cache: "hit"},
+				{Name: "docs", Status: StatusSucceeded, Cache: "hit"},
+				{Name: "lint", Status: StatusSucceeded, Cache: "miss"},
+			}},
+			expected: "xc: ci succeeded in 500ms: 4 ran, 0 skipped, 0 failed, 66% cache hits (2 of 3)\n",
+		},
+		{
+			name: "given resource usage, should print the CPU time and the largest RSS",
+			report: Report{Task: "ci", Success: true, Duration: 2, Tasks: []*Task{
+				{Name: "ci", Status: StatusSucceeded},
+				{Name: "build", Status: StatusSucceeded, CPUUser: 1.5, CPUSystem: 0.25, MaxRSS: 300 << 20},
+				{Name: "lint", Status: StatusSucceeded, CPUUser: 0.5, MaxRSS: 80 << 20},
+			}},
+			expected: "xc: ci succeeded in 2s: 3 ran, 0 skipped, 0 failed, 2.25s CPU, 300.0 MiB max RSS (build)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteSummary(&b, tt.report); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.expected {
+				t.Fatalf("expected %q got %q", tt.expected, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package result
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteSummary writes a line summarising a run: its duration, how many tasks ran, were skipped and failed,
+// how often outputs were restored from the cache if any task was cached,
+// and the CPU time of the processes the tasks started with the largest resident set size of any of them,
+// followed by the recordings of the tasks that failed.
+func WriteSummary(w io.Writer, rep Report) error {
+	var ran, skipped, failed, lookups, hits int
+	var cpu float64
+	var maxRSS int64
+	var heaviest string
+	for _, t := range rep.Tasks {
+		switch t.Status {
+		case StatusSkipped:
+			skipped++
+			continue
+		case StatusFailed:
+			failed++
+		}
+		ran++
+		if t.Cache != "" {
+			lookups++
+		}
+		if t.Cache == "hit" {
+			hits++
+		}
+		cpu += t.CPUUser + t.CPUSystem
+		if t.MaxRSS > maxRSS {
+			maxRSS, heaviest = t.MaxRSS, t.Name
+		}
+	}
+	outcome := "succeeded"
+	if !rep.Success {
+		outcome = "failed"
+	}
+	d := time.Duration(rep.Duration * float64(time.Second)).Round(10 * time.Millisecond)
+	_, err := fmt.Fprintf(w, "xc: %s %s in %s: %d ran, %d skipped, %d failed", rep.Task, outcome, d, ran, skipped, failed)
+	if err == nil && lookups > 0 {
+		_, err = fmt.Fprintf(w, ", %d%% cache hits (%d of %d)", hits*100/lookups, hits, lookups)
+	}
+	if err == nil && cpu > 0 {
+		_, err = fmt.Fprintf(w, ", %s CPU", time.Duration(cpu*float64(time.Second)).Round(10*time.Millisecond))
+	}
+	if err == nil && maxRSS > 0 {
+		_, err = fmt.Fprintf(w, ", %s max RSS (%s)", formatBytes(maxRSS), heaviest)
+	}
+	if err == nil {
+		_, err = fmt.Fprintln(w)
+	}
+	if err == nil {
+		err = WriteRecordings(w, rep)
+	}
+	return err
+}
+
+// WriteRecordings writes a line with the path of the recording of each task that failed, if they were recorded.
+func WriteRecordings(w io.Writer, rep Report) error {
+	for _, t := range rep.Tasks {
+		if t.Recording == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "xc: %s failed, its output is recorded in %s\n", t.Name, t.Recording); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatBytes formats n bytes in the largest binary unit it is at least one of.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
@@ -0,0 +1,208 @@
+package run
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/joerdav/xc/models"
+)
+
+// ErrNotApproved is returned by an Approver when a task is rejected.
+var ErrNotApproved = errors.New("not approved")
+
+// Approver approves the tasks with an approval attribute, once the tasks they require have run.
+type Approver interface {
+	// Approve blocks until the task is approved, returning an error if it is rejected or ctx is done.
+	Approve(ctx context.Context, task models.Task) error
+}
+
+// ApproverFunc is an Approver that calls a function.
+type ApproverFunc func(ctx context.Context, task models.Task) error
+
+func (f ApproverFunc) Approve(ctx context.Context, task models.Task) error {
+	return f(ctx, task)
+}
+
+// ApproveAll is an Approver that approves every task without asking, for runs that have been approved up front.
+var ApproveAll Approver = ApproverFunc(func(context.Context, models.Task) error { return nil })
+
+// WithApprover sets the Approver of the tasks with an approval attribute, the default is a TerminalApprover.
+func WithApprover(a Approver) Option {
+	return func(r *Runner) {
+		r.approver = a
+	}
+}
+
+// TerminalApprover asks whether a task may run on stderr, reading the answer from stdin.
+// It fails if stdin is not a terminal, as nobody would be there to answer.
+type TerminalApprover struct {
+	// mu stops tasks running in parallel from asking at the same time.
+	mu sync.Mutex
+}
+
+func (a *TerminalApprover) Approve(ctx context.Context, task models.Task) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("task %s requires approval, but stdin is not a terminal: run it in a terminal or with -approve",
+			task.Name)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "task %q requires approval: %s [y/N] ", task.Name, task.Approval)
+	answer := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer <- line
+	}()
+	select {
+	case <-ctx.Done():
+		fmt.Fprintln(os.Stderr)
+		return ctx.Err()
+	case line := <-answer:
+		return approved(line)
+	}
+}
+
+// approved returns nil if an answer to a question asking for approval is yes.
+func approved(answer string) error {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return ErrNotApproved
+}
+
+// Approvals is an Approver that waits for tasks to be approved over HTTP, so that runs with nobody at a terminal,
+// such as those of xc cron, can be approved by a person or another system.
+//
+// Served at a path such as /approvals, a GET of the path lists the tasks waiting for approval as JSON,
+// and a POST to <path>/<task>/approve or <path>/<task>/reject answers every request to run the task.
+type Approvals struct {
+	mu      sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+type pendingApproval struct {
+	task    models.Task
+	since   time.Time
+	answers []chan error
+}
+
+// PendingApproval is a task waiting for approval, as listed by Approvals.
+type PendingApproval struct {
+	Task    string    `json:"task"`
+	Message string    `json:"message"`
+	Since   time.Time `json:"since"`
+}
+
+// NewApprovals returns an Approvals with no tasks waiting.
+func NewApprovals() *Approvals {
+	return &Approvals{pending: map[string]*pendingApproval{}}
+}
+
+func (a *Approvals) Approve(ctx context.Context, task models.Task) error {
+	answer := make(chan error, 1)
+	key := strings.ToLower(task.Name)
+	a.mu.Lock()
+	p, ok := a.pending[key]
+	if !ok {
+		p = &pendingApproval{task: task, since: time.Now()}
+		a.pending[key] = p
+	}
+	p.answers = append(p.answers, answer)
+	a.mu.Unlock()
+	select {
+	case err := <-answer:
+		return err
+	case <-ctx.Done():
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for i, c := range p.answers {
+			if c == answer {
+				p.answers = append(p.answers[:i], p.answers[i+1:]...)
+				break
+			}
+		}
+		if len(p.answers) == 0 && a.pending[key] == p {
+			delete(a.pending, key)
+		}
+		return ctx.Err()
+	}
+}
+
+// Pending returns the tasks waiting for approval, sorted by name.
+func (a *Approvals) Pending() []PendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pending := make([]PendingApproval, 0, len(a.pending))
+	for _, p := range a.pending {
+		pending = append(pending, PendingApproval{Task: p.task.Name, Message: p.task.Approval, Since: p.since})
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Task < pending[j].Task })
+	return pending
+}
+
+// Answer approves, or rejects, every request to run the named task, ok is false if none is waiting.
+func (a *Approvals) Answer(name string, approve bool) (ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := strings.ToLower(name)
+	p, ok := a.pending[key]
+	if !ok {
+		return false
+	}
+	delete(a.pending, key)
+	var err error
+	if !approve {
+		err = ErrNotApproved
+	}
+	for _, c := range p.answers {
+		c <- err
+	}
+	return true
+}
+
+func (a *Approvals) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	answer := parts[len(parts)-1]
+	switch {
+	case r.Method == http.MethodGet && len(parts) <= 1:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(a.Pending())
+	case r.Method == http.MethodPost && len(parts) >= 2 && (answer == "approve" || answer == "reject"):
+		name := parts[len(parts)-2]
+		if !a.Answer(name, answer == "approve") {
+			http.Error(w, fmt.Sprintf("task %s is not waiting for approval", name), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "GET lists the tasks waiting for approval, POST <task>/approve or <task>/reject answers them",
+			http.StatusMethodNotAllowed)
+	}
+}
+
+// approve asks r's Approver whether a task with an approval attribute may run.
+func (r *Runner) approve(ctx context.Context, task models.Task) error {
+	if task.Approval == "" || r.dryRun {
+		return nil
+	}
+	if err := r.approver.Approve(ctx, task); err != nil {
+		if errors.Is(err, ErrNotApproved) {
+			return fmt.Errorf("task %s was %w", task.Name, err)
+		}
+		return err
+	}
+	return nil
+}
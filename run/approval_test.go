@@ -0,0 +1,128 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestRunApproval(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Script: "build"},
+		{Name: "deploy", Script: "deploy", DependsOn: []string{"build"}, Approval: "Deploy to production?"},
+	}
+	tests := []struct {
+		name        string
+		approve     error
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "given the task is approved, should run it",
+			expected: "build,deploy",
+		},
+		{
+			name:        "given the task is rejected, should not run it",
+			approve:     ErrNotApproved,
+			expected:    "build",
+			expectedErr: ErrNotApproved,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []string
+			approver := ApproverFunc(func(_ context.Context, task models.Task) error {
+				asked = append(asked, task.Name+": "+task.Approval)
+				return tt.approve
+			})
+			runner, err := NewRunner(tasks, t.TempDir(), WithApprover(approver))
+			if err != nil {
+				t.Fatal(err)
+			}
+			scriptRunner := &mockScriptRunner{}
+			runner.scriptRunner = scriptRunner
+			err = runner.Run(context.Background(), "deploy", nil)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v got %v", tt.expectedErr, err)
+			}
+			if got := strings.Join(scriptRunner.scripts, ","); got != tt.expected {
+				t.Fatalf("expected scripts %s got %s", tt.expected, got)
+			}
+			if len(asked) != 1 || asked[0] != "deploy: Deploy to production?" {
+				t.Fatalf("expected only deploy to be approved got %v", asked)
+			}
+		})
+	}
+}
+
+func TestApprovals(t *testing.T) {
+	approvals := NewApprovals()
+	srv := httptest.NewServer(approvals)
+	defer srv.Close()
+	task := models.Task{Name: "Deploy", Approval: "Deploy to production?"}
+	answers := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { answers <- approvals.Approve(context.Background(), task) }()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var pending []PendingApproval
+	for len(pending) == 0 || !waiting(approvals, 2) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected deploy to be waiting for approval")
+		}
+		res, err := http.Get(srv.URL + "/approvals")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.NewDecoder(res.Body).Decode(&pending)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(pending) != 1 || pending[0].Task != "Deploy" || pending[0].Message != "Deploy to production?" {
+		t.Fatalf("unexpected pending approvals %+v", pending)
+	}
+	res, err := http.Post(srv.URL+"/approvals/lint/approve", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a task that is not waiting to be not found got %s", res.Status)
+	}
+	res, err = http.Post(srv.URL+"/approvals/deploy/reject", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected the task to be rejected got %s", res.Status)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-answers; !errors.Is(err, ErrNotApproved) {
+			t.Fatalf("expected each run to be rejected got %v", err)
+		}
+	}
+	if len(approvals.Pending()) != 0 {
+		t.Fatalf("expected no pending approvals got %+v", approvals.Pending())
+	}
+}
+
+// waiting reports whether n requests to run a task are waiting for approval.
+func waiting(a *Approvals, n int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, p := range a.pending {
+		return len(p.answers) == n
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/cache"
+	"github.com/joerdav/xc/glob"
+	"github.com/joerdav/xc/models"
+)
+
+// WithCache skips tasks whose inputs match an entry in the cache, restoring their outputs from it.
+// In cache.ModeUpload the outputs of tasks that run are stored in the cache.
+//
+// Only tasks with both sources and assert-outputs attributes are cached,
+// the sources, script, env and inputs of a task make up its key and its asserted outputs are stored.
+func WithCache(b cache.Backend, mode cache.Mode) Option {
+	return func(r *Runner) {
+		r.cache = b
+		r.cacheMode = mode
+	}
+}
+
+// WithoutGitignore makes the files ignored by .gitignore files part of the sources of tasks.
+func WithoutGitignore() Option {
+	return func(r *Runner) {
+		r.noGitignore = true
+	}
+}
+
+// cacheable reports whether the outputs of a task can be restored from the cache.
+func (r *Runner) cacheable(task models.Task) bool {
+	return r.cache != nil && !r.dryRun && len(task.Sources) > 0 && len(task.AssertOutputs) > 0 &&
+		len(task.Foreach) == 0 && !task.Service
+}
+
+// cacheKey returns a hash of the inputs of a task: its script, env, inputs and the hashes of its sources.
+func cacheKey(task models.Task, env []string, sources map[string]string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "xc-cache-v2\x00%s\x00%s\x00%s\x00", task.Name, task.Language, task.Script)
+	env = append(env[:0:0], env...)
+	sort.Strings(env)
+	for _, e := range env {
+		fmt.Fprintf(h, "env\x00%s\x00", e)
+	}
+	paths := make([]string, 0, len(sources))
+	for path := range sources {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(h, "file\x00%s\x00%s\x00", path, sources[path])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sourceHashes returns the sha256 hash of the content of each file in dir that matches the sources of a task,
+// keyed by its slash separated path relative to dir. Files ignored by git are not sources unless gitignore is false.
+func sourceHashes(task models.Task, dir string, gitignore bool) (map[string]string, error) {
+	var ignore *glob.Ignore
+	var prefix string
+	if gitignore {
+		ignore, prefix = gitIgnores(dir)
+	}
+	hashes := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if path == dir && errors.Is(err, fs.ErrNotExist) {
+			// A directory that does not exist yet has no sources.
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if path != dir && (d.Name() == ".git" || d.Name() == ".xc") {
+				return filepath.SkipDir
+			}
+			if ignore != nil {
+				if path != dir && ignore.Ignored(prefix+rel, true) {
+					return filepath.SkipDir
+				}
+				addGitignore(ignore, path, strings.TrimSuffix(prefix+rel, "/."))
+			}
+			return nil
+		}
+		if !matchesAny(task.Sources, rel) || (ignore != nil && ignore.Ignored(prefix+rel, false)) {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err = io.Copy(h, f); err != nil {
+			return err
+		}
+		hashes[rel] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the sources of %s: %w", task.Name, err)
+	}
+	return hashes, nil
+}
+
+// gitIgnores returns the patterns of the .gitignore files of the repository containing dir that apply to it,
+// and the path of dir relative to the root of the repository, which is dir itself if it is not in one.
+// The patterns of the .gitignore files in dir and below are added as they are walked.
+func gitIgnores(dir string) (*glob.Ignore, string) {
+	var parents []string
+	root := dir
+	for {
+		if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			// dir is not in a repository, only its own .gitignore files apply.
+			parents, root = nil, dir
+			break
+		}
+		parents = append(parents, filepath.Base(root))
+		root = parent
+	}
+	ignore := &glob.Ignore{}
+	if b, err := os.ReadFile(filepath.Join(root, ".git", "info", "exclude")); err == nil {
+		ignore.Add("", string(b))
+	}
+	var prefix string
+	for i := len(parents) - 1; i >= 0; i-- {
+		addGitignore(ignore, filepath.Join(root, filepath.FromSlash(prefix)), strings.TrimSuffix(prefix, "/"))
+		prefix += parents[i] + "/"
+	}
+	return ignore, prefix
+}
+
+// addGitignore adds the patterns of the .gitignore file in dir, if there is one, to ignore.
+func addGitignore(ignore *glob.Ignore, dir, base string) {
+	if b, err := os.ReadFile(filepath.Join(dir, ".gitignore")); err == nil {
+		ignore.Add(base, string(b))
+	}
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if glob.Match(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreCache extracts the outputs of a task from the cache entry under key into dir,
+// it reports whether there was one.
+func (r *Runner) restoreCache(ctx context.Context, key, dir string) (bool, error) {
+	rc, ok, err := r.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer rc.Close()
+	if err = cache.Unpack(rc, dir); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// storeCache stores the outputs of a task in dir in the cache under key.
+func (r *Runner) storeCache(ctx context.Context, task models.Task, key, dir string) error {
+	paths := make([]string, len(task.AssertOutputs))
+	for i, o := range task.AssertOutputs {
+		paths[i] = o.Path
+	}
+	var buf bytes.Buffer
+	if err := cache.Pack(&buf, dir, paths); err != nil {
+		return err
+	}
+	// The archive is buffered, as some backends need the length of an entry before it is sent.
+	return r.cache.Put(ctx, key, &buf)
+}
+
+// cacheEnv returns the environment variables of a task that make up its cache key,
+// those set for the task rather than inherited from xc.
+func cacheEnv(taskEnv, with, inputs []string) []string {
+	env := append(append(taskEnv[:len(taskEnv):len(taskEnv)], with...), inputs...)
+	result := env[:0:0]
+	for _, e := range env {
+		if !strings.HasPrefix(e, "XC_") {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/xc/cache"
+	"github.com/joerdav/xc/models"
+)
+
+func TestRunCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        cache.Mode
+		changeInput bool
+		expectRuns  int
+	}{
+		{
+			name:       "given upload mode, should restore the outputs of the second run",
+			mode:       cache.ModeUpload,
+			expectRuns: 1,
+		},
+		{
+			name:        "given a changed source, should run again",
+			mode:        cache.ModeUpload,
+			changeInput: true,
+			expectRuns:  2,
+		},
+		{
+			name:       "given read-only mode, should not store outputs",
+			mode:       cache.ModeReadOnly,
+			expectRuns: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("input"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tasks := models.Tasks{{
+				Name:          "build",
+				Script:        "echo run >> runs\ncp in.txt out.txt\n",
+				Sources:       []string{"*.txt"},
+				AssertOutputs: []models.OutputAssertion{{Path: "out.txt", NonEmpty: true}},
+			}}
+			b := cache.Dir(t.TempDir())
+			for i := 0; i < 2; i++ {
+				runner, err := NewRunner(tasks, dir, WithCache(b, tt.mode))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = runner.Run(context.Background(), "build", nil); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.Remove(filepath.Join(dir, "out.txt")); err != nil {
+					t.Fatal(err)
+				}
+				if tt.changeInput {
+					if err = os.WriteFile(filepath.Join(dir, "in.txt"), []byte("changed"), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			runs, err := os.ReadFile(filepath.Join(dir, "runs"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Count(string(runs), "run"); got != tt.expectRuns {
+				t.Errorf("expected the script to run %d times got %d", tt.expectRuns, got)
+			}
+		})
+	}
+}
+
+func TestCacheKeyGitignore(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "web")
+	for name, content := range map[string]string{
+		".git/HEAD":                   "ref: refs/heads/main\n",
+		".gitignore":                  "*.log\n",
+		"web/.gitignore":              "node_modules/\n",
+		"web/src/main.js":             "main",
+		"web/node_modules/dep/dep.js": "dep",
+		"web/debug.log":               "log",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	task := models.Task{Name: "build", Sources: []string{"**"}}
+	key := func(gitignore bool) string {
+		t.Helper()
+		sources, err := sourceHashes(task, dir, gitignore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cacheKey(task, nil, sources)
+	}
+	ignored, all := key(true), key(false)
+	for _, name := range []string{"node_modules/dep/dep.js", "debug.log"} {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte("changed"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := key(true); got != ignored {
+		t.Error("expected changes to ignored files not to change the key")
+	}
+	if got := key(false); got == all {
+		t.Error("expected changes to ignored files to change the key without gitignore")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "main.js"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := key(true); got == ignored {
+		t.Error("expected changes to sources to change the key")
+	}
+}
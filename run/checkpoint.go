@@ -0,0 +1,105 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/joerdav/xc/models"
+)
+
+// checkpoint records the tasks that have succeeded in a run, so a failed run can be resumed.
+type checkpoint struct {
+	path      string
+	mu        sync.Mutex
+	RunID     string   `json:"runId"`
+	Completed []string `json:"completed"`
+	// previous counts the tasks that succeeded before the run was resumed,
+	// a task that is run more than once is skipped as many times as it succeeded.
+	previous map[string]int
+}
+
+// checkpointPath returns the checkpoint file of a run of a task with inputs.
+func checkpointPath(dir, name string, inputs []string) string {
+	return filepath.Join(StateDir(dir), "checkpoints", hash(append([]string{strings.ToLower(name)}, inputs...))+".json")
+}
+
+// taskKey identifies a task run with inputs and environment variables, along with its script
+// so a task is not skipped if it has been changed since it succeeded.
+func taskKey(task models.Task, inputs, with []string) string {
+	return hash([]string{task.Name, strings.Join(inputs, "\x00"), strings.Join(with, "\x00"), task.Script})
+}
+
+func hash(values []string) string {
+	h := sha256.New()
+	for _, v := range values {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// loadCheckpoint reads the checkpoint at path, ok is false if there is none.
+func loadCheckpoint(path string) (c *checkpoint, ok bool, err error) {
+	c = &checkpoint{path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, false, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+	}
+	c.previous = map[string]int{}
+	for _, k := range c.Completed {
+		c.previous[k]++
+	}
+	return c, true, nil
+}
+
+// skip reports whether the task identified by key succeeded before the run was resumed.
+func (c *checkpoint) skip(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.previous[key] == 0 {
+		return false
+	}
+	c.previous[key]--
+	return true
+}
+
+// complete records that the task identified by key succeeded.
+// A task skipped on resume is already recorded.
+func (c *checkpoint) complete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Completed = append(c.Completed, key)
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err = os.WriteFile(c.path, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the checkpoint once the run has succeeded.
+func (c *checkpoint) remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+
+	"github.com/joerdav/xc/models"
+)
+
+// composeLogLines is how many lines of the logs of each compose service are printed when a task using them fails.
+const composeLogLines = 50
+
+// composeProject is the docker compose services started for the tasks of a run, they are removed once it has finished.
+type composeProject struct {
+	ctx      context.Context
+	run      func(ctx context.Context, command string) error
+	files    []string
+	services []string
+}
+
+func (p *composeProject) String() string {
+	if len(p.services) > 0 {
+		return "compose services " + strings.Join(p.services, ", ")
+	}
+	if len(p.files) > 0 {
+		return "compose project " + strings.Join(p.files, ", ")
+	}
+	return "compose project"
+}
+
+// stop removes the services, or the whole project if every service was started.
+func (p *composeProject) stop() error {
+	if len(p.services) == 0 {
+		return p.run(p.ctx, composeCommand(p.files, "down"))
+	}
+	return p.run(p.ctx, composeCommand(p.files, "rm", append([]string{"--stop", "--force"}, p.services...)...))
+}
+
+// composeCommand returns the shell command that runs docker compose with files and args.
+func composeCommand(files []string, command string, args ...string) string {
+	words := []string{"docker", "compose"}
+	for _, f := range files {
+		words = append(words, "-f", f)
+	}
+	words = append(append(words, command), args...)
+	for i, w := range words {
+		if q, err := syntax.Quote(w, syntax.LangBash); err == nil {
+			words[i] = q
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// composeUp starts the docker compose services of a task, that have not been started by the run already,
+// and waits for them to be running and healthy. They are removed when the run finishes.
+func (r *Runner) composeUp(ctx context.Context, task models.Task, env []string, dir string) error {
+	if len(task.Compose) == 0 || r.dryRun {
+		return nil
+	}
+	files, services := models.ParseCompose(task.Compose)
+	// Services are started once per run, whichever tasks require them, "*" is every service.
+	prefix := dir + "\x00" + strings.Join(files, "\x00") + "\x00"
+	keys := []string{prefix + "*"}
+	if len(services) > 0 {
+		keys = keys[:0]
+		for _, s := range services {
+			keys = append(keys, prefix+s)
+		}
+	}
+	claimed := r.services.claim(keys)
+	if len(claimed) == 0 {
+		return nil
+	}
+	var start []string
+	if len(services) > 0 {
+		for _, k := range claimed {
+			start = append(start, strings.TrimPrefix(k, prefix))
+		}
+	}
+	run := func(ctx context.Context, command string) error {
+		return r.scriptRunner.Execute(ctx, command, env, nil, dir)
+	}
+	p := &composeProject{ctx: detachedContext{ctx}, run: run, files: files, services: start}
+	fmt.Printf("starting %s\n", p)
+	// The services are removed even if they fail to start, as some of them may have.
+	r.services.add(p)
+	if err := run(ctx, composeCommand(files, "up", append([]string{"--detach", "--wait"}, start...)...)); err != nil {
+		return fmt.Errorf("task %s failed to start its compose services: %w", task.Name, err)
+	}
+	return nil
+}
+
+// composeLogs prints the last lines of the logs of the docker compose services of a task, once it has failed.
+func (r *Runner) composeLogs(ctx context.Context, task models.Task, env []string, dir string) {
+	if len(task.Compose) == 0 || r.dryRun {
+		return
+	}
+	files, services := models.ParseCompose(task.Compose)
+	fmt.Printf("task %q failed, the logs of its compose services:\n", task.Name)
+	args := append([]string{"--no-color", "--tail", fmt.Sprint(composeLogLines)}, services...)
+	_ = r.scriptRunner.Execute(detachedContext{ctx}, composeCommand(files, "logs", args...), env, nil, dir)
+}
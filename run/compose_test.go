@@ -0,0 +1,93 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+// failingScriptRunner records the scripts it runs, failing the script fail.
+type failingScriptRunner struct {
+	mu      sync.Mutex
+	fail    string
+	scripts []string
+}
+
+func (f *failingScriptRunner) Execute(_ context.Context, text string, _ []string, _ []string, _ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scripts = append(f.scripts, text)
+	if text == f.fail {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+func TestRunCompose(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "migrate", Script: "migrate", Compose: []string{"db", "redis"}},
+		{Name: "test", Script: "test", Compose: []string{"db"}, DependsOn: []string{"migrate"}},
+		{Name: "e2e", Script: "e2e", Compose: []string{"compose.e2e.yaml"}},
+	}
+	tests := []struct {
+		name        string
+		task        string
+		fail        string
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name: "given tasks that share a compose service, should start it once and remove it at the end of the run",
+			task: "test",
+			expected: []string{
+				"docker compose up --detach --wait db redis",
+				"migrate",
+				"test",
+				"docker compose rm --stop --force db redis",
+			},
+		},
+		{
+			name: "given a compose file without services, should start and tear down the whole project",
+			task: "e2e",
+			expected: []string{
+				"docker compose -f compose.e2e.yaml up --detach --wait",
+				"e2e",
+				"docker compose -f compose.e2e.yaml down",
+			},
+		},
+		{
+			name: "given a task that fails, should print the logs of its compose services",
+			task: "test",
+			fail: "test",
+			expected: []string{
+				"docker compose up --detach --wait db redis",
+				"migrate",
+				"test",
+				"docker compose logs --no-color --tail 50 db",
+				"docker compose rm --stop --force db redis",
+			},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := NewRunner(tasks, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			scriptRunner := &failingScriptRunner{fail: tt.fail}
+			runner.scriptRunner = scriptRunner
+			err = runner.Run(context.Background(), tt.task, nil)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v got %v", tt.expectedErr, err)
+			}
+			if got := strings.Join(scriptRunner.scripts, "\n"); got != strings.Join(tt.expected, "\n") {
+				t.Fatalf("expected scripts:\n%s\ngot:\n%s", strings.Join(tt.expected, "\n"), got)
+			}
+		})
+	}
+}
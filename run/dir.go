@@ -0,0 +1,77 @@
+package run
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/joerdav/xc/interpolate"
+)
+
+// expandWindowsVars expands the %NAME% variables of cmd in s, looking names up case insensitively as Windows does.
+// Unset variables are left as they are, as cmd does.
+func expandWindowsVars(s string, env []string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, '%')
+		if start < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := strings.IndexByte(s[start+1:], '%')
+		if end < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end += start + 1
+		name := s[start+1 : end]
+		if v, ok := lookupFold(env, name); ok && name != "" {
+			b.WriteString(s[:start] + v)
+			s = s[end+1:]
+			continue
+		}
+		// The closing % may start the next variable, as in %UNSET%%VERSION%.
+		b.WriteString(s[:end])
+		s = s[end:]
+	}
+}
+
+// lookupFold returns the value of the environment variable name, ignoring case, later values take precedence.
+func lookupFold(env []string, name string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		k, v, found := strings.Cut(env[i], "=")
+		if found && strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// resolveDir returns the directory dir, relative to base unless it is absolute, in which a task runs.
+// Both / and \ separate the directories of dir so that task files work on every OS, such as `dir: .\build`,
+// and on Windows a drive letter or leading \ makes it absolute and %NAME% variables are expanded.
+func resolveDir(base, dir string, env []string, expand bool) (string, error) {
+	if expand {
+		var err error
+		if dir, err = interpolate.Expand(dir, interpolate.EnvLookup(env)); err != nil {
+			return "", err
+		}
+		if runtime.GOOS == "windows" {
+			dir = expandWindowsVars(dir, env)
+		}
+	}
+	if dir == "" {
+		return base, nil
+	}
+	dir = filepath.FromSlash(strings.ReplaceAll(dir, `\`, "/"))
+	switch {
+	case filepath.IsAbs(dir):
+		return filepath.Clean(dir), nil
+	case filepath.VolumeName(dir) != "":
+		// A drive relative path, such as C:build, is relative to the current directory of the drive.
+		return filepath.Abs(dir)
+	case runtime.GOOS == "windows" && strings.HasPrefix(dir, string(filepath.Separator)):
+		return filepath.Join(filepath.VolumeName(base), dir), nil
+	}
+	return filepath.Join(base, dir), nil
+}
@@ -0,0 +1,78 @@
+package run
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestExpandWindowsVars(t *testing.T) {
+	env := []string{"USERPROFILE=C:\\Users\\me", "Build=out"}
+	tests := map[string]string{
+		`%USERPROFILE%\src`: `C:\Users\me\src`,
+		`%userprofile%\src`: `C:\Users\me\src`,
+		`.\%BUILD%\%UNSET%`: `.\out\%UNSET%`,
+		`%UNSET%%Build%`:    `%UNSET%out`,
+		`no variables`:      `no variables`,
+		`trailing %`:        `trailing %`,
+	}
+	for in, expected := range tests {
+		if got := expandWindowsVars(in, env); got != expected {
+			t.Errorf("expandWindowsVars(%q) = %q, want %q", in, got, expected)
+		}
+	}
+}
+
+func TestResolveDir(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "project")
+	env := []string{"OUT=build"}
+	tests := []struct {
+		name     string
+		dir      string
+		expand   bool
+		windows  bool
+		expected string
+	}{
+		{name: "given no dir, should use the base", expected: base},
+		{
+			name:     "given a relative dir, should join it to the base",
+			dir:      "./src/app",
+			expected: filepath.Join(base, "src", "app"),
+		},
+		{
+			name:     "given backslashes, should treat them as separators",
+			dir:      `.\src\app`,
+			expected: filepath.Join(base, "src", "app"),
+		},
+		{
+			name:     "given a variable, should expand it",
+			dir:      `.\${OUT}\bin`,
+			expand:   true,
+			expected: filepath.Join(base, "build", "bin"),
+		},
+		{name: "given no expansion, should not expand", dir: "$OUT", expected: filepath.Join(base, "$OUT")},
+		{name: "given an absolute dir, should clean it", dir: base + "/src/../bin", expected: filepath.Join(base, "bin")},
+		{name: "given a drive letter, should be absolute", dir: `D:\build`, windows: true, expected: `D:\build`},
+		{
+			name:     "given a %VAR%, should expand it",
+			dir:      `%OUT%\bin`,
+			expand:   true,
+			windows:  true,
+			expected: filepath.Join(base, "build", "bin"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.windows && runtime.GOOS != "windows" {
+				t.Skip("only on windows")
+			}
+			got, err := resolveDir(base, tt.dir, env, tt.expand)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Fatalf("expected %s got %s", tt.expected, got)
+			}
+		})
+	}
+}
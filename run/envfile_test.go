@@ -0,0 +1,41 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	src := `# database
+DATABASE_URL=postgres://localhost/app
+export REGION = eu-west-1
+
+GREETING="hello\nworld"
+QUOTED='$NOT_EXPANDED'
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env, err := readEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"DATABASE_URL=postgres://localhost/app",
+		"REGION=eu-west-1",
+		"GREETING=hello\nworld",
+		"QUOTED=$NOT_EXPANDED",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected %q got %q", expected, env)
+	}
+	if err = os.WriteFile(path, []byte("NOT A VARIABLE\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readEnvFile(path); err == nil {
+		t.Fatal("expected an error for a line without =")
+	}
+}
@@ -0,0 +1,80 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Environment returns the environment variables the script of the named task would receive if it were run with inputs,
+// sorted by name: the env files, the environment of xc, the profile, the expanded env attribute of the task,
+// its inputs and the XC_ variables, later values taking precedence.
+// Secret references are not resolved and XC_TMPDIR is omitted as it is created each time the task runs.
+func (r *Runner) Environment(name string, inputs []string) ([]string, error) {
+	env, _, err := r.declaredEnvironment(name, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return sortEnv(env, nil), nil
+}
+
+// DeclaredEnvironment returns the variables of Environment that are set by the task file rather than only
+// inherited from the environment of xc: those in the env files, the profile, the env attribute of the task,
+// its inputs and the XC_ variables.
+func (r *Runner) DeclaredEnvironment(name string, inputs []string) ([]string, error) {
+	env, declared, err := r.declaredEnvironment(name, inputs)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for _, e := range declared {
+		k, _, _ := strings.Cut(e, "=")
+		keys[k] = true
+	}
+	return sortEnv(env, keys), nil
+}
+
+// declaredEnvironment returns the environment of the named task, and the variables in it set by the task file.
+func (r *Runner) declaredEnvironment(name string, inputs []string) (env, declared []string, err error) {
+	task, ok := r.tasks.Get(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("task %s not found", name)
+	}
+	env = append(r.fileEnv[:len(r.fileEnv):len(r.fileEnv)], os.Environ()...)
+	taskEnv, err := r.expandEnv(r.taskEnv(task), nil, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	env = append(env, taskEnv...)
+	inp, err := getInputs(task, inputs, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	xcEnv := r.xcEnv(task)
+	env = append(append(env, inp...), xcEnv...)
+	declared = append(append(append(append(declared, r.fileEnv...), taskEnv...), inp...), xcEnv...)
+	return env, declared, nil
+}
+
+// sortEnv returns the last value of each variable in env sorted by name, only those in keys unless it is nil.
+func sortEnv(env []string, keys map[string]bool) []string {
+	values := map[string]string{}
+	names := []string{}
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		if keys != nil && !keys[k] {
+			continue
+		}
+		if _, ok := values[k]; !ok {
+			names = append(names, k)
+		}
+		values[k] = v
+	}
+	sort.Strings(names)
+	result := make([]string, len(names))
+	for i, k := range names {
+		result[i] = k + "=" + values[k]
+	}
+	return result
+}
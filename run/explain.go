@@ -0,0 +1,162 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/terminal"
+)
+
+// WithExplain prints which sources of a task were added, removed or modified since its last successful run,
+// before it is run or restored from the cache.
+//
+// The hashes of the sources of each task with a sources attribute are recorded in the state directory
+// whenever it succeeds, whether or not WithExplain is given.
+func WithExplain() Option {
+	return func(r *Runner) {
+		r.explain = true
+	}
+}
+
+// sourcesPath returns the file that the hashes of the sources of the last successful run of a task are kept in.
+// A task run with different inputs or environment variables has a file of its own.
+func sourcesPath(dir, name string, inputs, with []string) string {
+	key := hash(append([]string{strings.ToLower(name), strings.Join(with, "\x00")}, inputs...))
+	return filepath.Join(StateDir(dir), "sources", key+".json")
+}
+
+// recordedSources is the content of the file at sourcesPath.
+type recordedSources struct {
+	RunID string `json:"runId"`
+	// Files holds the sha256 hash of each source, keyed by its path relative to the directory of the task.
+	Files map[string]string `json:"files"`
+}
+
+// loadSources reads the hashes of the sources recorded at path, ok is false if there are none.
+func loadSources(path string) (files map[string]string, ok bool, err error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read the sources of the last run: %w", err)
+	}
+	var rs recordedSources
+	if err = json.Unmarshal(b, &rs); err != nil {
+		return nil, false, fmt.Errorf("failed to read the sources of the last run %s: %w", path, err)
+	}
+	return rs.Files, true, nil
+}
+
+// recordSources keeps the hashes of the sources of a task that succeeded, to be compared with by later runs.
+// A task that succeeded does not fail because they could not be kept.
+func (r *Runner) recordSources(task models.Task, inputs, with []string, sources map[string]string) {
+	if len(task.Sources) == 0 || r.dryRun {
+		return
+	}
+	err := createStateDir(r.dir)
+	path := sourcesPath(r.dir, task.Name, inputs, with)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	var b []byte
+	if err == nil {
+		b, err = json.MarshalIndent(recordedSources{RunID: r.runID, Files: sources}, "", "  ")
+	}
+	if err == nil {
+		err = os.WriteFile(path, b, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "task %q could not record the hashes of its sources: %v\n", task.Name, err)
+	}
+}
+
+// sourceChange is a source of a task that was added, removed or modified since its last successful run.
+type sourceChange struct {
+	path string
+	// before is the hash of the file in the last successful run, it is empty if the file was added.
+	before string
+	// after is the hash of the file now, it is empty if the file was removed.
+	after string
+}
+
+// diffSources returns the changes from the hashes of the sources before to those after, sorted by path.
+func diffSources(before, after map[string]string) []sourceChange {
+	var changes []sourceChange
+	for path, h := range after {
+		if before[path] != h {
+			changes = append(changes, sourceChange{path: path, before: before[path], after: h})
+		}
+	}
+	for path, h := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, sourceChange{path: path, before: h})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	return changes
+}
+
+// format describes the change as "added", "removed" or "modified" followed by the path and the short hashes
+// of the file, the first word is painted if color is true.
+func (c sourceChange) format(color bool) string {
+	verb, style, hashes := "modified", terminal.Yellow, shortHash(c.before)+" -> "+shortHash(c.after)
+	switch {
+	case c.before == "":
+		verb, style, hashes = "added", terminal.Green, shortHash(c.after)
+	case c.after == "":
+		verb, style, hashes = "removed", terminal.Red, shortHash(c.before)
+	}
+	if color {
+		verb = style.Paint(verb)
+	}
+	return fmt.Sprintf("%s %s %s", verb, c.path, hashes)
+}
+
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+// sourceChanges returns the changes to the sources of a task since its last successful run,
+// recorded is false if it has not succeeded with its sources recorded.
+func (r *Runner) sourceChanges(
+	task models.Task, inputs, with []string, sources map[string]string,
+) (changes []sourceChange, recorded bool, err error) {
+	before, recorded, err := loadSources(sourcesPath(r.dir, task.Name, inputs, with))
+	if err != nil || !recorded {
+		return nil, recorded, err
+	}
+	return diffSources(before, sources), true, nil
+}
+
+// explainSources prints the changes to the sources of a task since its last successful run.
+func (r *Runner) explainSources(task models.Task, inputs, with []string, sources map[string]string) {
+	changes, recorded, err := r.sourceChanges(task, inputs, with, sources)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "task %q: %v\n", task.Name, err)
+	case !recorded:
+		fmt.Printf("task %q has no sources recorded from a successful run\n", task.Name)
+	case len(changes) == 0:
+		fmt.Printf("task %q sources are unchanged since its last successful run\n", task.Name)
+	default:
+		// The changes are printed at once so that they are not interleaved with the output of other tasks.
+		color := terminal.Color(os.Stdout)
+		var b strings.Builder
+		fmt.Fprintf(&b, "task %q sources changed since its last successful run:\n", task.Name)
+		for _, c := range changes {
+			fmt.Fprintf(&b, "  %s\n", c.format(color))
+		}
+		fmt.Print(b.String())
+	}
+}
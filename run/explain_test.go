@@ -0,0 +1,102 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestDiffSources(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after map[string]string
+		expected      []string
+	}{
+		{
+			name:   "given the same hashes, should have no changes",
+			before: map[string]string{"main.go": "aaa"},
+			after:  map[string]string{"main.go": "aaa"},
+		},
+		{
+			name:     "given added, removed and modified files, should list them by path",
+			before:   map[string]string{"old.go": "bbb", "main.go": "aaa"},
+			after:    map[string]string{"main.go": "ccc", "api/new.go": "ddd"},
+			expected: []string{"added api/new.go ddd", "modified main.go aaa -> ccc", "removed old.go bbb"},
+		},
+		{
+			name:     "given long hashes, should shorten them",
+			before:   map[string]string{},
+			after:    map[string]string{"main.go": "0123456789abcdef"},
+			expected: []string{"added main.go 0123456789ab"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range diffSources(tt.before, tt.after) {
+				got = append(got, c.format(false))
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %q got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecordSources(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.go", "package main")
+	write("old.go", "package main")
+	tasks := models.Tasks{
+		{Name: "build", Script: "true\n", Sources: []string{"*.go"}},
+		{Name: "fail", Script: "false\n", Sources: []string{"*.go"}},
+	}
+	runner, err := NewRunner(tasks, dir, WithExplain())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "build", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "fail", nil); err == nil {
+		t.Fatal("expected fail to fail")
+	}
+	write("main.go", "package main // changed")
+	write("new.go", "package main")
+	if err = os.Remove(filepath.Join(dir, "old.go")); err != nil {
+		t.Fatal(err)
+	}
+	task, _ := tasks.Get("build")
+	sources, err := sourceHashes(task, dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, recorded, err := runner.sourceChanges(task, nil, nil, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recorded {
+		t.Fatal("expected the sources of build to be recorded")
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.path)
+	}
+	if expected := []string{"main.go", "new.go", "old.go"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected changes to %q got %q", expected, got)
+	}
+	task, _ = tasks.Get("fail")
+	if _, recorded, err = runner.sourceChanges(task, nil, nil, sources); err != nil || recorded {
+		t.Fatalf("expected the sources of a failed task not to be recorded got %v %v", recorded, err)
+	}
+}
@@ -0,0 +1,171 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// failedCommand is a command run by the built-in shell that exited with a non-zero status.
+type failedCommand struct {
+	args []string
+	// pipeline is whether the command is part of a pipeline.
+	pipeline bool
+}
+
+func (c failedCommand) String() string {
+	quoted := make([]string, len(c.args))
+	for i, a := range c.args {
+		q, err := syntax.Quote(a, syntax.LangBash)
+		if err != nil {
+			q = a
+		}
+		quoted[i] = q
+	}
+	return strings.Join(quoted, " ")
+}
+
+// failures records the commands of a script run by the built-in shell that fail,
+// so that the error of the script can say which command caused it rather than only its exit status.
+type failures struct {
+	// stdin and stdout are those of the script, commands with other pipes are part of a pipeline.
+	stdin  io.Reader
+	stdout io.Writer
+	// builtins is whether the status of the script can be that of the builtin that started last.
+	builtins bool
+	mu       sync.Mutex
+	// last is the most recent command to fail with each status since a command outside a pipeline started.
+	last map[uint8]failedCommand
+	// builtin is the builtin outside a pipeline that started last, if no other command started after it.
+	builtin *failedCommand
+}
+
+func newFailures(stdin io.Reader, stdout io.Writer, builtins bool) *failures {
+	return &failures{stdin: stdin, stdout: stdout, builtins: builtins, last: map[uint8]failedCommand{}}
+}
+
+// callHandler forgets earlier failures when a command outside a pipeline starts, as it is then the command
+// that fails the script if it fails. The status of a builtin such as false is not recorded,
+// so it is kept as the command that failed the script if the script fails before another command starts.
+// exit is ignored as it passes on the status of the command before it, such as in `cmd || exit $?`,
+// but it is not a builtin that fails the script.
+func (f *failures) callHandler(ctx context.Context, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.builtin = nil
+	if args[0] == "exit" || f.inPipeline(interp.HandlerCtx(ctx)) {
+		return args, nil
+	}
+	f.last = map[uint8]failedCommand{}
+	if f.builtins && shellBuiltins[args[0]] {
+		f.builtin = &failedCommand{args: args}
+	}
+	return args, nil
+}
+
+// execHandler records the commands run by next that fail.
+func (f *failures) execHandler(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		err := next(ctx, args)
+		if status, ok := interp.IsExitStatus(err); ok && status != 0 {
+			c := failedCommand{args: args, pipeline: f.inPipeline(interp.HandlerCtx(ctx))}
+			f.mu.Lock()
+			f.last[status] = c
+			f.mu.Unlock()
+		}
+		return err
+	}
+}
+
+func (f *failures) inPipeline(hc interp.HandlerContext) bool {
+	return (hc.Stdin != f.stdin && isPipe(hc.Stdin)) || (hc.Stdout != f.stdout && isPipe(hc.Stdout))
+}
+
+func isPipe(v any) bool {
+	file, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := file.Stat()
+	return err == nil && fi.Mode()&os.ModeNamedPipe != 0
+}
+
+// wrap returns err, the error of a script, with the command that caused it if it was recorded.
+// With pipefail the failing command of a pipeline is the last one to fail.
+func (f *failures) wrap(err error) error {
+	status, ok := interp.IsExitStatus(err)
+	if !ok {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.last[status]
+	if !ok && f.builtin != nil {
+		c, ok = *f.builtin, true
+	}
+	switch {
+	case !ok:
+		return err
+	case c.pipeline:
+		return fmt.Errorf("command `%s` in a pipeline failed: %w", c, err)
+	}
+	return fmt.Errorf("command `%s` failed: %w", c, err)
+}
+
+// shellBuiltins are the builtins of the built-in shell.
+var shellBuiltins = map[string]bool{
+	"true": true, ":": true, "false": true, "exit": true, "set": true, "shift": true, "unset": true,
+	"echo": true, "printf": true, "break": true, "continue": true, "pwd": true, "cd": true,
+	"wait": true, "builtin": true, "trap": true, "type": true, "source": true, ".": true, "command": true,
+	"dirs": true, "pushd": true, "popd": true, "umask": true, "alias": true, "unalias": true,
+	"fg": true, "bg": true, "getopts": true, "eval": true, "test": true, "[": true, "exec": true,
+	"return": true, "read": true, "mapfile": true, "readarray": true, "shopt": true,
+}
+
+// statusOfCommands reports whether the status of a script is always that of a simple command, so that a failure of
+// the script can be attributed to the builtin that started last. It is not if a test clause such as [[ ]],
+// an arithmetic command, a declaration or a negated command can end the script, rather than only be the condition
+// of an if or while clause or the left of ||, as no handler is called for them.
+func statusOfCommands(file *syntax.File) bool {
+	skip := map[*syntax.Stmt]bool{}
+	mark := func(stmts ...*syntax.Stmt) {
+		for _, s := range stmts {
+			skip[s] = true
+		}
+	}
+	ok := true
+	syntax.Walk(file, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.IfClause:
+			mark(n.Cond...)
+		case *syntax.WhileClause:
+			mark(n.Cond...)
+		case *syntax.BinaryCmd:
+			if n.Op == syntax.OrStmt {
+				mark(n.X)
+			}
+		case *syntax.Stmt:
+			if skip[n] {
+				return false
+			}
+			switch n.Cmd.(type) {
+			case *syntax.TestClause, *syntax.ArithmCmd, *syntax.LetClause, *syntax.DeclClause:
+				ok = false
+			}
+			if n.Negated {
+				ok = false
+			}
+		}
+		return ok
+	})
+	return ok
+}
@@ -0,0 +1,60 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+)
+
+var packageClauseRe = regexp.MustCompile(`(?m)^package\s+\w+`)
+
+// goRunner runs scripts in code blocks tagged go using `go run`.
+//
+// The script is written to a temporary file inside the task directory,
+// so it is part of the enclosing module and can import its packages and dependencies.
+// If the script has no package clause `package main` is added.
+type goRunner struct {
+	cmdRunner func(*exec.Cmd) error
+}
+
+func newGoRunner() goRunner {
+	return goRunner{cmdRunner: cmdShebangRunner}
+}
+
+// Source returns text with `package main` added if it has no package clause.
+func (g goRunner) Source(_ context.Context, text string, _ []string) (string, error) {
+	if !packageClauseRe.MatchString(text) {
+		text = "package main\n\n" + text
+	}
+	return text, nil
+}
+
+//nolint:gosec // accept that command is being executed here from outside of xc
+func (g goRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	text, _ = g.Source(ctx, text, env)
+	// The directory starts with . so the go tool ignores it when matching packages.
+	tmp, err := os.MkdirTemp(dir, ".xc_go_")
+	if err != nil {
+		return fmt.Errorf("failed to create execution directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	if err = sandboxChown(ctx, tmp); err != nil {
+		return fmt.Errorf("failed to change the owner of the execution directory: %w", err)
+	}
+	file := filepath.Join(tmp, "main.go")
+	if err = os.WriteFile(file, []byte(text), 0o644); err != nil {
+		return fmt.Errorf("failed to write execution file: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, "go", append([]string{"run", file}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = stdin(ctx)
+	cmd.Stdout, cmd.Stderr = stdio(ctx)
+	if err = sandboxCmd(ctx, cmd); err != nil {
+		return err
+	}
+	return g.cmdRunner(withStopPolicyFrom(ctx, withProcessGroup(cmd)))
+}
@@ -0,0 +1,59 @@
+package run
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGoRunner(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		expect string
+	}{
+		{
+			name:   "given a script without a package clause, should add package main",
+			script: "func main() {}\n",
+			expect: "package main\n\nfunc main() {}\n",
+		},
+		{
+			name:   "given a script with a package clause, should not change it",
+			script: "package main\n\nfunc main() {}\n",
+			expect: "package main\n\nfunc main() {}\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var args []string
+			var source string
+			g := goRunner{cmdRunner: func(c *exec.Cmd) error {
+				args = c.Args
+				b, err := os.ReadFile(c.Args[2])
+				source = string(b)
+				return err
+			}}
+			err := g.Execute(context.Background(), tt.script, nil, []string{"a", "b"}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source != tt.expect {
+				t.Fatalf("expected source %q got %q", tt.expect, source)
+			}
+			if len(args) != 5 || args[1] != "run" || !strings.HasPrefix(args[2], dir) || strings.Join(args[3:], " ") != "a b" {
+				t.Fatalf("unexpected args %v", args)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Fatalf("expected the execution directory to be removed got %v", entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package run
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joerdav/xc/interpolate"
+)
+
+// httpRunner runs scripts in code blocks tagged http.
+//
+// The script is a request line, followed by headers, a blank line and an optional body.
+// Lines beginning with > are assertions about the response:
+//
+//	POST https://localhost:8080/api/items
+//	Content-Type: application/json
+//
+//	{"name": "test"}
+//
+//	> status 201
+//	> header Content-Type: application/json
+//	> body contains "name"
+//
+// Without a status assertion any status below 400 is accepted.
+type httpRunner struct {
+	client *http.Client
+	// stdout is where the response body is written, if nil it is the output of the script.
+	stdout io.Writer
+}
+
+func newHTTPRunner() httpRunner {
+	return httpRunner{
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type httpRequest struct {
+	method, url string
+	headers     http.Header
+	body        string
+	assertions  []httpAssertion
+}
+
+type httpAssertion struct {
+	kind, name, value string
+}
+
+// Source returns the request in text with the variables in env expanded.
+func (h httpRunner) Source(_ context.Context, text string, env []string) (string, error) {
+	text, err := interpolate.Expand(text, interpolate.EnvLookup(env))
+	if err != nil {
+		return "", fmt.Errorf("failed to expand http request: %w", err)
+	}
+	return text, nil
+}
+
+func (h httpRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	if networkDisabled(ctx) {
+		return fmt.Errorf("http blocks cannot run without network access")
+	}
+	text, err := h.Source(ctx, text, env)
+	if err != nil {
+		return err
+	}
+	hr, err := parseHTTPRequest(text)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, hr.method, hr.url, strings.NewReader(hr.body))
+	if err != nil {
+		return fmt.Errorf("invalid http request: %w", err)
+	}
+	req.Header = hr.headers
+	if host := hr.headers.Get("Host"); host != "" {
+		req.Host = host
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request failed: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read http response: %w", err)
+	}
+	stdout := h.stdout
+	if stdout == nil {
+		stdout, _ = stdio(ctx)
+	}
+	if _, err = stdout.Write(body); err != nil {
+		return err
+	}
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		fmt.Fprintln(stdout)
+	}
+	return checkHTTPResponse(hr, res, string(body))
+}
+
+func parseHTTPRequest(text string) (hr httpRequest, err error) {
+	hr.headers = http.Header{}
+	sc := bufio.NewScanner(strings.NewReader(text))
+	var body []string
+	inBody := false
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, ">"):
+			a, err := parseHTTPAssertion(strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+			if err != nil {
+				return hr, err
+			}
+			hr.assertions = append(hr.assertions, a)
+		case hr.url == "":
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			fs := strings.Fields(trimmed)
+			switch {
+			case len(fs) == 1:
+				hr.method, hr.url = http.MethodGet, fs[0]
+			case len(fs) == 2 || (len(fs) == 3 && strings.HasPrefix(fs[2], "HTTP/")):
+				hr.method, hr.url = strings.ToUpper(fs[0]), fs[1]
+			default:
+				return hr, fmt.Errorf("invalid http request line %q, should be METHOD URL", trimmed)
+			}
+		case !inBody && trimmed == "":
+			inBody = true
+		case !inBody:
+			k, v, found := strings.Cut(line, ":")
+			if !found {
+				return hr, fmt.Errorf("invalid http header %q, should be Name: value", trimmed)
+			}
+			hr.headers.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+		default:
+			body = append(body, line)
+		}
+	}
+	if hr.url == "" {
+		return hr, fmt.Errorf("http request has no url")
+	}
+	hr.body = strings.TrimSpace(strings.Join(body, "\n"))
+	return hr, nil
+}
+
+func parseHTTPAssertion(s string) (a httpAssertion, err error) {
+	kind, rest, _ := strings.Cut(s, " ")
+	rest = strings.TrimSpace(rest)
+	a.kind = strings.ToLower(kind)
+	switch a.kind {
+	case "status":
+		if rest == "" {
+			return a, fmt.Errorf("status assertion has no status")
+		}
+		a.value = rest
+	case "header":
+		name, value, found := strings.Cut(rest, ":")
+		if !found {
+			return a, fmt.Errorf("invalid header assertion %q, should be header Name: value", s)
+		}
+		a.name, a.value = strings.TrimSpace(name), strings.TrimSpace(value)
+	case "body":
+		op, value, _ := strings.Cut(rest, " ")
+		if op != "contains" {
+			return a, fmt.Errorf("invalid body assertion %q, should be body contains text", s)
+		}
+		a.value = unquote(strings.TrimSpace(value))
+	default:
+		return a, fmt.Errorf("unknown http assertion %q, should be status, header or body", s)
+	}
+	return a, nil
+}
+
+func unquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
+
+func checkHTTPResponse(hr httpRequest, res *http.Response, body string) error {
+	checkedStatus := false
+	for _, a := range hr.assertions {
+		switch a.kind {
+		case "status":
+			checkedStatus = true
+			if !statusMatches(res.StatusCode, strings.Fields(a.value)) {
+				return fmt.Errorf("%s %s: expected status %s got %s", hr.method, hr.url, a.value, res.Status)
+			}
+		case "header":
+			if got := res.Header.Get(a.name); !strings.Contains(got, a.value) {
+				return fmt.Errorf("%s %s: expected header %s to contain %q got %q", hr.method, hr.url, a.name, a.value, got)
+			}
+		case "body":
+			if !strings.Contains(body, a.value) {
+				return fmt.Errorf("%s %s: expected body to contain %q", hr.method, hr.url, a.value)
+			}
+		}
+	}
+	if !checkedStatus && res.StatusCode >= 400 {
+		return fmt.Errorf("%s %s: %s", hr.method, hr.url, res.Status)
+	}
+	return nil
+}
+
+// statusMatches reports whether code matches any of the patterns, such as 200 or 2xx.
+func statusMatches(code int, patterns []string) bool {
+	s := strconv.Itoa(code)
+	for _, p := range patterns {
+		if len(p) != len(s) {
+			continue
+		}
+		match := true
+		for i := range p {
+			if p[i] != 'x' && p[i] != 'X' && p[i] != s[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRunner(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		case "/echo":
+			b, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(r.Method + " " + string(b)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	tests := []struct {
+		name         string
+		script       string
+		expectErr    string
+		expectOutput string
+	}{
+		{
+			name:         "given a url, should get it",
+			script:       "${URL}/health\n",
+			expectOutput: "{\"status\":\"ok\"}\n",
+		},
+		{
+			name: "given a request with headers and a body, should send them",
+			script: `POST ${URL}/echo
+Content-Type: text/plain
+
+hello
+
+> status 201
+> header Content-Type: text/plain
+> body contains "POST hello"
+`,
+			expectOutput: "POST hello\n",
+		},
+		{
+			name:         "given a status pattern, should match",
+			script:       "GET ${URL}/health\n> status 2xx\n",
+			expectOutput: "{\"status\":\"ok\"}\n",
+		},
+		{
+			name:      "given an error status, should fail",
+			script:    "GET ${URL}/missing\n",
+			expectErr: "404 Not Found",
+		},
+		{
+			name:         "given an expected error status, should succeed",
+			script:       "GET ${URL}/missing\n> status 404\n",
+			expectOutput: "404 page not found\n",
+		},
+		{
+			name:      "given a failing status assertion, should fail",
+			script:    "GET ${URL}/health\n> status 201\n",
+			expectErr: "expected status 201 got 200 OK",
+		},
+		{
+			name:      "given a failing body assertion, should fail",
+			script:    "GET ${URL}/health\n> body contains error\n",
+			expectErr: `expected body to contain "error"`,
+		},
+		{
+			name:      "given a failing header assertion, should fail",
+			script:    "GET ${URL}/health\n> header Content-Type: text/html\n",
+			expectErr: "expected header Content-Type",
+		},
+		{
+			name:      "given an unknown assertion, should fail",
+			script:    "GET ${URL}/health\n> latency 10ms\n",
+			expectErr: "unknown http assertion",
+		},
+		{
+			name:      "given no url, should fail",
+			script:    "# nothing here\n",
+			expectErr: "http request has no url",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			h := newHTTPRunner()
+			h.stdout = &out
+			err := h.Execute(context.Background(), tt.script, []string{"URL=" + srv.URL}, nil, "")
+			if tt.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+					t.Fatalf("expected error containing %q got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.expectOutput {
+				t.Fatalf("expected output %q got %q", tt.expectOutput, out.String())
+			}
+		})
+	}
+}
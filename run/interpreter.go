@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -23,6 +24,8 @@ type interpreter struct {
 	shellRunner    func(context.Context, *interp.Runner, *syntax.File) error
 	shebangRunner  func(*exec.Cmd) error
 	tempFilePrefix string
+	// shell is the command that runs scripts without a shebang, they are run by the built-in shell if empty.
+	shell []string
 }
 
 func interpShellRunner(ctx context.Context, runner *interp.Runner, file *syntax.File) error {
@@ -43,12 +46,43 @@ func newInterpreter() interpreter {
 
 func (i interpreter) Execute(ctx context.Context, script string, env []string, args []string, dir string) error {
 	interpreterCmd, interpreterArgs, text, ok := parseShebang(script)
+	if !ok && len(i.shell) > 0 && !shellShebangRe.MatchString(script) {
+		// The shell of the front matter sets its own options, unless shell-opts is set.
+		if opts, set := shellOpts(ctx); set && posixShells[filepath.Base(i.shell[0])] {
+			script = shellHeader(opts) + script
+		}
+		return i.executeShebang(ctx, i.shell[0], i.shell[1:], script, env, args, dir)
+	}
 	if !ok {
 		return i.executeShell(ctx, script, env, args, dir)
 	}
 	return i.executeShebang(ctx, interpreterCmd, interpreterArgs, text, env, args, dir)
 }
 
+// Source returns script with the options it is run with, and a shebang for the shell that runs it if it has none.
+// Scripts run by the built-in shell, which is compatible with bash rather than only POSIX sh,
+// are given a bash shebang.
+func (i interpreter) Source(ctx context.Context, script string, _ []string) (string, error) {
+	if _, _, _, ok := parseShebang(script); ok {
+		return script, nil
+	}
+	if len(i.shell) > 0 && !shellShebangRe.MatchString(script) {
+		if opts, set := shellOpts(ctx); set && posixShells[filepath.Base(i.shell[0])] {
+			script = shellHeader(opts) + script
+		}
+		return shebang(i.shell) + script, nil
+	}
+	line := "#!/usr/bin/env bash"
+	if shellShebangRe.MatchString(script) {
+		line, script, _ = strings.Cut(script, "\n")
+	}
+	opts, ok := shellOpts(ctx)
+	if !ok {
+		opts = defaultShellOpts
+	}
+	return line + "\n" + shellHeader(opts) + script, nil
+}
+
 //nolint:gosec // accept that command is being executed here from outside of xc
 func (i interpreter) executeShebang(
 	ctx context.Context,
@@ -67,22 +101,33 @@ func (i interpreter) executeShebang(
 	if _, err = f.WriteString(text); err != nil {
 		return fmt.Errorf("failed to write execution file")
 	}
+	if err = sandboxChown(ctx, f.Name()); err != nil {
+		return fmt.Errorf("failed to change the owner of the execution file: %w", err)
+	}
 	interpreterArgs = append(interpreterArgs, f.Name())
 	cmd := exec.CommandContext(ctx, interpreterCmd, append(interpreterArgs, args...)...)
 	cmd.Dir = dir
 	cmd.Env = env
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return i.shebangRunner(cmd)
+	cmd.Stdin = stdin(ctx)
+	cmd.Stdout, cmd.Stderr = stdio(ctx)
+	if err = sandboxCmd(ctx, cmd); err != nil {
+		return err
+	}
+	err = i.shebangRunner(withStopPolicyFrom(ctx, withProcessGroup(cmd)))
+	addUsage(ctx, cmd.ProcessState)
+	return err
 }
 
 func (i interpreter) executeShell(ctx context.Context, text string, env []string, args []string, dir string) error {
 	if shellShebangRe.MatchString(text) {
 		text = strings.Join(strings.Split(text, "\n")[1:], "\n")
 	}
+	opts, ok := shellOpts(ctx)
+	if !ok {
+		opts = defaultShellOpts
+	}
 	var script bytes.Buffer
-	if _, err := script.Write([]byte(scriptHeader)); err != nil {
+	if _, err := script.Write([]byte(shellHeader(opts))); err != nil {
 		return fmt.Errorf("failed to write script header: %w", err)
 	}
 	if _, err := script.Write([]byte(text)); err != nil {
@@ -92,16 +137,36 @@ func (i interpreter) executeShell(ctx context.Context, text string, env []string
 	if err != nil {
 		return fmt.Errorf("failed to parse task: %w", err)
 	}
+	if hasShellOpt(opts, "errexit") && hasShellOpt(opts, "pipefail") {
+		exitOnPipelineFailure(file)
+	}
+	in := stdin(ctx)
+	stdout, stderr := stdio(ctx)
+	failed := newFailures(in, stdout, statusOfCommands(file))
 	runner, err := interp.New(
 		interp.Env(expand.ListEnviron(env...)),
-		interp.StdIO(os.Stdin, os.Stdout, os.Stderr),
+		interp.StdIO(in, stdout, stderr),
 		interp.Dir(dir),
 		interp.Params(args...),
+		interp.CallHandler(failed.callHandler),
+		interp.ExecHandler(failed.execHandler(sandboxExecHandler(execHandler()))),
+		interp.OpenHandler(sandboxOpenHandler(interp.DefaultOpenHandler())),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to compose script: %w", err)
 	}
-	return i.shellRunner(ctx, runner, file)
+	return failed.wrap(i.shellRunner(ctx, runner, file))
+}
+
+// sandboxExecHandler runs the commands of a script in the sandbox of the context of the script, if there is one.
+func sandboxExecHandler(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		args, err := sandboxArgs(ctx, args)
+		if err != nil {
+			return err
+		}
+		return next(ctx, args)
+	}
 }
 
 func parseShebang(script string) (interpreterCmd string, interpreterArgs []string, text string, ok bool) {
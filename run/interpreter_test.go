@@ -2,9 +2,13 @@ package run
 
 import (
 	"context"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 
+	"github.com/joerdav/xc/models"
+
 	"mvdan.cc/sh/v3/interp"
 	"mvdan.cc/sh/v3/syntax"
 )
@@ -96,6 +100,25 @@ func TestIsShell(t *testing.T) {
 			}
 		}
 	})
+	t.Run("configured shell should run scripts without a shebang", func(t *testing.T) {
+		ti := newTestInterpreter()
+		ti.shell = []string{"bash", "-eu"}
+		var args []string
+		ti.shebangRunner = func(cmd *exec.Cmd) error {
+			ti.shebangRunnerCalled = true
+			args = cmd.Args
+			return nil
+		}
+		if err := ti.Execute(context.Background(), "echo", nil, []string{"a"}, ""); err != nil {
+			t.Fatal(err)
+		}
+		if ti.shellRunnerCalled {
+			t.Fatal("expected no shell call")
+		}
+		if len(args) != 4 || args[0] != "bash" || args[1] != "-eu" || args[3] != "a" {
+			t.Fatalf("expected bash -eu <file> a got %v", args)
+		}
+	})
 	t.Run("shell shebang with invalid bash script should fail", func(t *testing.T) {
 		she := `#!/usr/bin/env bash
 
@@ -128,3 +151,139 @@ func TestIsShell(t *testing.T) {
 		}
 	})
 }
+
+func TestShellOpts(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		task      []string
+		file      []string
+		expectErr bool
+	}{
+		{
+			name:      "given no shell-opts, should stop at the first failing command",
+			script:    "false\necho > after\n",
+			expectErr: true,
+		},
+		{
+			name:   "given shell-opts none, should continue after a failing command",
+			script: "false\necho > after\n",
+			task:   []string{},
+		},
+		{
+			name:      "given pipefail, should fail if any command in a pipeline fails",
+			script:    "false | true\necho > after\n",
+			task:      []string{"errexit", "pipefail"},
+			expectErr: true,
+		},
+		{
+			name:   "given pipefail, should not stop for a failing pipeline in a condition or list",
+			script: "if false | true; then exit 1; fi\nfalse | true || true\necho > after\n",
+			task:   []string{"errexit", "pipefail"},
+		},
+		{
+			name:   "given file shell-opts, should use them",
+			script: "false\necho > after\n",
+			file:   []string{"xtrace"},
+		},
+		{
+			name:      "given task shell-opts, should take precedence over the file",
+			script:    "false\necho > after\n",
+			task:      []string{"errexit"},
+			file:      []string{},
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tasks := models.Tasks{{Name: "build", Script: tt.script, ShellOpts: tt.task}}
+			runner, err := NewRunner(tasks, dir, WithShellOpts(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = runner.Run(context.Background(), "build", nil)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v got %v", tt.expectErr, err)
+			}
+			if _, statErr := os.Stat(filepath.Join(dir, "after")); (statErr == nil) == tt.expectErr {
+				t.Fatalf("expected the script to stop %v", tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestShellOptsConfiguredShell(t *testing.T) {
+	ti := newTestInterpreter()
+	ti.shell = []string{"/bin/bash"}
+	var script []byte
+	ti.shebangRunner = func(cmd *exec.Cmd) error {
+		var err error
+		script, err = os.ReadFile(cmd.Args[1])
+		return err
+	}
+	ctx := withShellOpts(context.Background(), []string{"errexit", "pipefail"})
+	if err := ti.Execute(ctx, "echo", nil, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if string(script) != "set -o errexit\nset -o pipefail\necho" {
+		t.Fatalf("expected the options to be set got %q", script)
+	}
+}
+
+func TestFailingCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		expectErr  string
+		expectCode int
+	}{
+		{
+			name:       "given a failing command, should report it",
+			script:     "echo ok\nsh -c 'exit 3'\necho > after\n",
+			expectErr:  "command `sh -c 'exit 3'` failed: exit status 3",
+			expectCode: 3,
+		},
+		{
+			name:       "given pipefail, should report the failing command of a pipeline",
+			script:     "set -o pipefail\nsh -c 'exit 4' | cat\n",
+			expectErr:  "command `sh -c 'exit 4'` in a pipeline failed: exit status 4",
+			expectCode: 4,
+		},
+		{
+			name:       "given a failing builtin, should report it",
+			script:     "sh -c 'exit 1' || true\nfalse\n",
+			expectErr:  "command `false` failed: exit status 1",
+			expectCode: 1,
+		},
+		{
+			name:       "given a failing builtin in a condition, should report the command that failed after it",
+			script:     "if ! test -f missing; then sh -c 'exit 2'; fi\n",
+			expectErr:  "command `sh -c 'exit 2'` failed: exit status 2",
+			expectCode: 2,
+		},
+		{
+			name:       "given exit after a builtin, should report the exit status",
+			script:     "echo ok\nexit 3\n",
+			expectErr:  "exit status 3",
+			expectCode: 3,
+		},
+		{
+			name:       "given a failing test clause after a builtin, should report the exit status",
+			script:     "echo ok\n[[ -f missing ]]\n",
+			expectErr:  "exit status 1",
+			expectCode: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newInterpreter().Execute(context.Background(), tt.script, os.Environ(), nil, t.TempDir())
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q got %v", tt.expectErr, err)
+			}
+			if code, _ := ExitCode(err); code != tt.expectCode {
+				t.Fatalf("expected exit code %d got %d", tt.expectCode, code)
+			}
+		})
+	}
+}
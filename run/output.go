@@ -0,0 +1,72 @@
+package run
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+// OutputObserver is an Observer that is also sent a copy of the output of the script of each task.
+type OutputObserver interface {
+	Observer
+	// TaskOutput returns a writer for the output of a task, or nil if the output is not needed.
+	// ctx is derived from the context returned by TaskStarted for the task.
+	// The writer must be safe for concurrent use, as the items of a foreach task may run in parallel.
+	TaskOutput(ctx context.Context, task models.Task) io.Writer
+}
+
+type outputKey struct{}
+
+type output struct {
+	stdout, stderr io.Writer
+}
+
+// withOutput returns a context in which scripts also write their output to the writers of r's OutputObservers.
+func (r *Runner) withOutput(ctx context.Context, task models.Task) context.Context {
+	var ws []io.Writer
+	// The output of a service is also matched against its ready-when pattern.
+	if w, ok := ctx.Value(readyOutputKey{}).(io.Writer); ok {
+		ws = append(ws, w)
+	}
+	for _, o := range r.observers {
+		if oo, ok := o.(OutputObserver); ok {
+			if w := oo.TaskOutput(ctx, task); w != nil {
+				ws = append(ws, w)
+			}
+		}
+	}
+	if len(ws) == 0 {
+		return ctx
+	}
+	stdout, stderr := stdio(ctx)
+	return context.WithValue(ctx, outputKey{}, output{
+		stdout: io.MultiWriter(append([]io.Writer{stdout}, ws...)...),
+		stderr: io.MultiWriter(append([]io.Writer{stderr}, ws...)...),
+	})
+}
+
+// stdio returns where a script run with ctx writes its output, os.Stdout and os.Stderr unless it is being captured.
+func stdio(ctx context.Context) (stdout, stderr io.Writer) {
+	if o, ok := ctx.Value(outputKey{}).(output); ok {
+		return o.stdout, o.stderr
+	}
+	return os.Stdout, os.Stderr
+}
+
+type stdinKey struct{}
+
+// withStdin returns a context in which scripts read text on their standard input, rather than os.Stdin.
+func withStdin(ctx context.Context, text string) context.Context {
+	return context.WithValue(ctx, stdinKey{}, text)
+}
+
+// stdin returns what a script run with ctx reads on its standard input, os.Stdin unless the task has a stdin block.
+func stdin(ctx context.Context) io.Reader {
+	if text, ok := ctx.Value(stdinKey{}).(string); ok {
+		return strings.NewReader(text)
+	}
+	return os.Stdin
+}
@@ -0,0 +1,147 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+// PluginDir returns the directory that plugins are loaded from for the tasks in dir.
+//
+// Each executable file in the directory runs the code blocks tagged with its name,
+// without any extension, e.g. `.xc/plugins/terraform` runs blocks tagged terraform.
+// A plugin receives the script on stdin and the task inputs as arguments, and runs
+// with the environment and directory of the task.
+func PluginDir(dir string) string {
+	return filepath.Join(dir, ".xc", "plugins")
+}
+
+// pluginRunner runs scripts with an executable plugin.
+type pluginRunner struct {
+	path      string
+	cmdRunner func(*exec.Cmd) error
+}
+
+//nolint:gosec // accept that command is being executed here from outside of xc
+func (p pluginRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	cmd := exec.CommandContext(ctx, p.path, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout, cmd.Stderr = stdio(ctx)
+	if err := sandboxCmd(ctx, cmd); err != nil {
+		return err
+	}
+	if err := p.cmdRunner(withProcessGroup(cmd)); err != nil {
+		return fmt.Errorf("plugin %s: %w", filepath.Base(p.path), err)
+	}
+	return nil
+}
+
+// AttributeDir returns the directory that attribute handlers are loaded from for the tasks in dir.
+//
+// Each executable file in the directory handles the attribute of tasks with its name, without any extension,
+// e.g. `.xc/plugins/attributes/vault-role` handles `vault-role: deploy`. Before the script of a task with
+// the attribute runs, the handler runs with its value as the only argument, in the environment and directory
+// of the task. The task fails if the handler fails, and the lines of the form KEY=VALUE that it prints
+// are added to the environment of the script.
+func AttributeDir(dir string) string {
+	return filepath.Join(PluginDir(dir), "attributes")
+}
+
+// shellLanguages are the languages of code blocks run by the built-in shell interpreter.
+var shellLanguages = map[string]bool{"": true, "sh": true, "bash": true, "shell": true}
+
+// loadPlugins returns a ScriptRunner for each plugin in pluginDir, keyed by language.
+// It returns an error if a plugin is named after a language of builtin or a shell language,
+// as plugins add languages rather than replace the ones built into xc.
+func loadPlugins(pluginDir string, builtin map[string]ScriptRunner) (map[string]ScriptRunner, error) {
+	paths, err := executables(pluginDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugins: %w", err)
+	}
+	plugins := map[string]ScriptRunner{}
+	for language, path := range paths {
+		if _, ok := builtin[language]; ok || shellLanguages[language] {
+			return nil, fmt.Errorf("failed to load plugins: %s cannot replace the built-in %s blocks", path, language)
+		}
+		plugins[language] = pluginRunner{path: path, cmdRunner: cmdShebangRunner}
+	}
+	return plugins, nil
+}
+
+// executables returns the path of each executable file in dir, keyed by its lower case name without any extension.
+func executables(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string]string{}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		if !isExecutable(info) {
+			continue
+		}
+		paths[strings.ToLower(strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())))] = filepath.Join(dir, e.Name())
+	}
+	return paths, nil
+}
+
+// handleAttributes runs the handler of each attribute of a task that has one, in the order of their names,
+// and returns the environment variables they print. Their values are treated as secrets, as handlers may print tokens.
+//
+//nolint:gosec // accept that command is being executed here from outside of xc
+func (r *Runner) handleAttributes(ctx context.Context, task models.Task, env []string, dir string) ([]string, error) {
+	names := make([]string, 0, len(task.Metadata))
+	for name := range task.Metadata {
+		if _, ok := r.attributeHandlers[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var handled, secrets []string
+	for _, name := range names {
+		var stdout bytes.Buffer
+		cmd := exec.CommandContext(ctx, r.attributeHandlers[name], task.Metadata[name])
+		cmd.Dir = dir
+		cmd.Env = env
+		out, errOut := stdio(ctx)
+		cmd.Stdout, cmd.Stderr = &stdout, errOut
+		if err := cmdShebangRunner(withProcessGroup(cmd)); err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		for _, line := range strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if k, v, ok := strings.Cut(line, "="); ok && envNameRe.MatchString(k) {
+				handled = append(handled, line)
+				secrets = append(secrets, v)
+			} else if line != "" {
+				fmt.Fprintln(out, line)
+			}
+		}
+	}
+	r.notifySecrets(ctx, task, secrets)
+	return handled, nil
+}
+
+// envNameRe matches the names of environment variables.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
@@ -0,0 +1,141 @@
+//go:build !windows
+
+package run
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestLoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	pluginDir := PluginDir(dir)
+	if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range map[string]os.FileMode{
+		"terraform.sh": 0o755,
+		"README.md":    0o644,
+	} {
+		if err := os.WriteFile(filepath.Join(pluginDir, name), nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plugins, err := loadPlugins(pluginDir, DefaultExecutors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 1 {
+		t.Fatalf("expected 1 plugin got %d", len(plugins))
+	}
+	p, ok := plugins["terraform"].(pluginRunner)
+	if !ok {
+		t.Fatalf("expected a terraform plugin got %v", plugins)
+	}
+	var stdin string
+	p.cmdRunner = func(c *exec.Cmd) error {
+		b, err := io.ReadAll(c.Stdin)
+		stdin = string(b)
+		return err
+	}
+	if err := p.Execute(context.Background(), "plan\n", nil, nil, dir); err != nil {
+		t.Fatal(err)
+	}
+	if stdin != "plan\n" {
+		t.Fatalf("expected script on stdin got %q", stdin)
+	}
+}
+
+func TestLoadPluginsMissingDir(t *testing.T) {
+	plugins, err := loadPlugins(PluginDir(t.TempDir()), DefaultExecutors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 0 {
+		t.Fatalf("expected no plugins got %v", plugins)
+	}
+}
+
+func TestLoadPluginsBuiltIn(t *testing.T) {
+	for _, name := range []string{"sql", "HTTP.sh", "bash"} {
+		t.Run(name, func(t *testing.T) {
+			pluginDir := PluginDir(t.TempDir())
+			if err := os.MkdirAll(pluginDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(pluginDir, name), nil, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadPlugins(pluginDir, DefaultExecutors()); err == nil {
+				t.Fatalf("expected an error for a plugin replacing %s", name)
+			}
+		})
+	}
+}
+
+func TestAttributeHandlers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(AttributeDir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	handlers := map[string]string{
+		"vault-role.sh": "#!/bin/sh\necho \"logging in as $1\"\necho VAULT_TOKEN=token-for-$1\n",
+		"sla":           "#!/bin/sh\n[ \"$1\" = \"99.9%\" ] || { echo \"unknown sla $1\" >&2; exit 1; }\n",
+	}
+	for name, script := range handlers {
+		if err := os.WriteFile(filepath.Join(AttributeDir(dir), name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name        string
+		metadata    map[string]string
+		expectedEnv string
+		expectedErr bool
+	}{
+		{
+			name:        "given an attribute with a handler, should add the variables it prints",
+			metadata:    map[string]string{"vault-role": "deploy", "team": "@platform"},
+			expectedEnv: "VAULT_TOKEN=token-for-deploy",
+		},
+		{
+			name:        "given a handler that fails, should fail the task",
+			metadata:    map[string]string{"sla": "50%"},
+			expectedErr: true,
+		},
+		{
+			name:     "given attributes without handlers, should run the task",
+			metadata: map[string]string{"team": "@platform"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := NewRunner(models.Tasks{{Name: "deploy", Script: "deploy", Metadata: tt.metadata}}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scriptRunner := &mockScriptRunner{}
+			runner.scriptRunner = scriptRunner
+			err = runner.Run(context.Background(), "deploy", nil)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v got %v", tt.expectedErr, err)
+			}
+			if tt.expectedErr {
+				if scriptRunner.calls != 0 {
+					t.Fatal("expected the script not to run")
+				}
+				return
+			}
+			if tt.expectedEnv != "" && !containsString(scriptRunner.env, tt.expectedEnv) {
+				t.Fatalf("expected %s in env got %v", tt.expectedEnv, scriptRunner.env)
+			}
+		})
+	}
+}
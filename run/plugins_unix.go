@@ -0,0 +1,9 @@
+//go:build !windows
+
+package run
+
+import "io/fs"
+
+func isExecutable(info fs.FileInfo) bool {
+	return info.Mode()&0o111 != 0
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package run
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+func isExecutable(info fs.FileInfo) bool {
+	switch strings.ToLower(filepath.Ext(info.Name())) {
+	case ".exe", ".bat", ".cmd":
+		return true
+	}
+	return false
+}
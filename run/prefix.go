@@ -0,0 +1,72 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/joerdav/xc/terminal"
+)
+
+// prefixColors are the colors of the prefixes of each service in `xc up`.
+var prefixColors = []terminal.Style{
+	terminal.Cyan, terminal.Yellow, terminal.Green, terminal.Magenta, terminal.Blue, terminal.Red,
+}
+
+// prefixWriter writes each line written to it to w, prefixed with prefix.
+// Partial lines are buffered until they are completed or the writer is flushed.
+type prefixWriter struct {
+	// mu is shared by the writers to w, so lines are never interleaved.
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(mu *sync.Mutex, w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{mu: mu, w: w, prefix: []byte(prefix)}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// flush writes a buffered partial line, followed by a newline.
+func (p *prefixWriter) flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	_, err := p.w.Write(append(p.prefix[:len(p.prefix):len(p.prefix)], line...))
+	return err
+}
+
+// servicePrefix returns the prefix of the output of a service in `xc up`, name padded to width.
+// The prefix is painted in style unless color is false.
+func servicePrefix(name string, width int, style terminal.Style, color bool) string {
+	prefix := fmt.Sprintf("%-*s |", width, name)
+	if color {
+		prefix = style.Paint(prefix)
+	}
+	return prefix + " "
+}
@@ -0,0 +1,168 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// startsProcessGroup reports whether a command reading stdin is started in a process group of its own.
+// Commands reading a terminal stay in the process group of xc, so they can read from it and Ctrl-C reaches them.
+func startsProcessGroup(stdin io.Reader) bool {
+	f, ok := stdin.(*os.File)
+	return !ok || !term.IsTerminal(int(f.Fd()))
+}
+
+// defaultStopTimeout is how long the processes of a script have to exit once interrupted, before they are killed.
+const defaultStopTimeout = 2 * time.Second
+
+type stopKey struct{}
+
+// stopPolicy is how the processes of a script are stopped when its context is done,
+// they are sent signal and killed if they have not exited after timeout.
+type stopPolicy struct {
+	signal  os.Signal
+	timeout time.Duration
+}
+
+// stopSignals are the signals of the stop-signal attribute.
+var stopSignals = map[string]os.Signal{
+	"SIGINT":  os.Interrupt,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGKILL": os.Kill,
+}
+
+// withStopPolicy returns a context in which the processes of scripts are stopped with p.
+func withStopPolicy(ctx context.Context, p stopPolicy) context.Context {
+	return context.WithValue(ctx, stopKey{}, p)
+}
+
+// stopPolicyFrom returns how the processes of a script run with ctx are stopped,
+// by default they are interrupted and killed after defaultStopTimeout.
+func stopPolicyFrom(ctx context.Context) stopPolicy {
+	if p, ok := ctx.Value(stopKey{}).(stopPolicy); ok {
+		return p
+	}
+	return stopPolicy{signal: os.Interrupt, timeout: defaultStopTimeout}
+}
+
+// withStopPolicyFrom makes cmd, created with exec.CommandContext and withProcessGroup, stop with the stop policy of ctx
+// if it has one, rather than being killed as soon as ctx is done.
+func withStopPolicyFrom(ctx context.Context, cmd *exec.Cmd) *exec.Cmd {
+	p, ok := ctx.Value(stopKey{}).(stopPolicy)
+	if !ok {
+		return cmd
+	}
+	cmd.Cancel = func() error {
+		return signalProcessGroup(cmd, p.signal)
+	}
+	cmd.WaitDelay = p.timeout
+	return cmd
+}
+
+// withProcessGroup makes cmd, created with exec.CommandContext, start a process group where supported
+// and kill the whole group when its context is done, so the processes a script starts do not outlive it.
+// It must be called after the Stdin of cmd has been set.
+func withProcessGroup(cmd *exec.Cmd) *exec.Cmd {
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return signalProcessGroup(cmd, os.Kill)
+	}
+	return cmd
+}
+
+// execHandler is interp.DefaultExecHandler, starting each command with withProcessGroup.
+// When ctx is done the group is sent the signal of the stop policy of ctx, then killed after its timeout.
+func execHandler() interp.ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		hc := interp.HandlerCtx(ctx)
+		path, err := interp.LookPathDir(hc.Dir, hc.Env, args[0])
+		if err != nil {
+			fmt.Fprintln(hc.Stderr, err)
+			return interp.NewExitStatus(127)
+		}
+		cmd := &exec.Cmd{
+			Path:   path,
+			Args:   args,
+			Env:    execEnv(hc.Env),
+			Dir:    hc.Dir,
+			Stdin:  hc.Stdin,
+			Stdout: hc.Stdout,
+			Stderr: hc.Stderr,
+		}
+		setProcessGroup(cmd)
+		if err = cmd.Start(); err == nil {
+			stop := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-stop:
+					return
+				}
+				policy := stopPolicyFrom(ctx)
+				_ = signalProcessGroup(cmd, policy.signal)
+				select {
+				case <-time.After(policy.timeout):
+					_ = signalProcessGroup(cmd, os.Kill)
+				case <-stop:
+				}
+			}()
+			err = cmd.Wait()
+			close(stop)
+			addUsage(ctx, cmd.ProcessState)
+			if ctx.Err() != nil {
+				// The processes started by the command may ignore the interrupt and outlive it.
+				_ = signalProcessGroup(cmd, os.Kill)
+			}
+		}
+		switch x := err.(type) {
+		case *exec.ExitError:
+			if status, ok := x.Sys().(syscall.WaitStatus); ok {
+				if status.Signaled() {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					return interp.NewExitStatus(uint8(128 + status.Signal()))
+				}
+				return interp.NewExitStatus(uint8(status.ExitStatus()))
+			}
+			return interp.NewExitStatus(1)
+		case *exec.Error:
+			fmt.Fprintf(hc.Stderr, "%v\n", err)
+			return interp.NewExitStatus(127)
+		default:
+			return err
+		}
+	}
+}
+
+// execEnv returns the exported variables of env, as passed to a command.
+// Variables unset by the script are removed, even if they are set in the environment of the script.
+func execEnv(env expand.Environ) []string {
+	var list []string
+	env.Each(func(name string, vr expand.Variable) bool {
+		if !vr.IsSet() {
+			for i, kv := range list {
+				if strings.HasPrefix(kv, name+"=") {
+					list[i] = ""
+				}
+			}
+		}
+		if vr.Exported && vr.Kind == expand.String {
+			list = append(list, name+"="+vr.String())
+		}
+		return true
+	})
+	return list
+}
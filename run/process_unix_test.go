@@ -0,0 +1,78 @@
+//go:build !windows
+
+package run
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+// running reports whether the process pid is running, and has not exited waiting to be reaped.
+func running(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return false
+	}
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return true
+	}
+	_, rest, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(rest, "Z")
+}
+
+func TestCancelKillsProcessGroup(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		opts   []Option
+	}{
+		{
+			name:   "given the built-in shell, should kill the processes started by a command",
+			script: "sh -c 'sleep 30 & echo $! > pid; wait'",
+		},
+		{
+			name:   "given a configured shell, should kill the processes started by the script",
+			script: "sleep 30 &\necho $! > pid\nwait\n",
+			opts:   []Option{WithShell("sh")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			runner, err := NewRunner(models.Tasks{{Name: "task", Script: tt.script}}, dir, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- runner.Run(ctx, "task", nil) }()
+			var pid int
+			for start := time.Now(); pid == 0; time.Sleep(10 * time.Millisecond) {
+				if time.Since(start) > 5*time.Second {
+					t.Fatal("the script did not start")
+				}
+				b, _ := os.ReadFile(filepath.Join(dir, "pid"))
+				pid, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+			}
+			cancel()
+			if err = <-done; err == nil {
+				t.Fatal("expected the cancelled run to fail")
+			}
+			for start := time.Now(); running(pid); time.Sleep(10 * time.Millisecond) {
+				if time.Since(start) > 5*time.Second {
+					_ = syscall.Kill(pid, syscall.SIGKILL)
+					t.Fatalf("expected process %d to be killed with the script", pid)
+				}
+			}
+		})
+	}
+}
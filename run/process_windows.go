@@ -0,0 +1,16 @@
+//go:build windows
+
+package run
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing on windows, where the processes started by a script are not killed with it.
+func setProcessGroup(*exec.Cmd) {}
+
+// signalProcessGroup kills the process of cmd, as windows processes cannot be interrupted.
+func signalProcessGroup(cmd *exec.Cmd, _ os.Signal) error {
+	return cmd.Process.Kill()
+}
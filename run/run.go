@@ -2,13 +2,28 @@ package run
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/google/shlex"
+	"github.com/joerdav/xc/cache"
+	"github.com/joerdav/xc/ci"
+	"github.com/joerdav/xc/glob"
+	"github.com/joerdav/xc/interpolate"
 	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/terminal"
+	"github.com/joerdav/xc/tools"
+	"mvdan.cc/sh/v3/interp"
 )
 
 const maxDeps = 50
@@ -19,10 +34,272 @@ type ScriptRunner interface {
 
 // Runner is responsible for running Tasks.
 type Runner struct {
-	scriptRunner ScriptRunner
-	tasks        models.Tasks
-	dir          string
-	alreadyRan   map[string]bool
+	scriptRunner   ScriptRunner
+	executors      map[string]ScriptRunner
+	secretResolver SecretResolver
+	approver       Approver
+	toolChecker    *tools.Checker
+	tasks          models.Tasks
+	dir            string
+	runID          string
+	keepTmp        bool
+	groups         ci.Provider
+	inCI           bool
+	dryRun         bool
+	resume         bool
+	checkpoint     *checkpoint
+	noExpand       bool
+	observers      []Observer
+	jobs           int
+	envFiles       []string
+	noNetwork      bool
+	noSandbox      bool
+	shellOpts      []string
+	fileEnv        []string
+	profileEnv     []string
+	scheduler      *scheduler
+	services       *services
+	cache          cache.Backend
+	cacheMode      cache.Mode
+	detach         bool
+	noGitignore    bool
+	explain        bool
+	noDeps         bool
+	// attributeHandlers are the paths of the handlers of attributes that are not built in, keyed by attribute.
+	attributeHandlers map[string]string
+	// only is nil unless only the scripts of some tasks should run.
+	only map[string]bool
+	// shard is the index and count of the shard of a run the Runner runs, the count is 0 if it runs all of it.
+	shard [2]int
+	// shardSelected holds the lower case names of the tasks in the shard of the current run.
+	shardSelected map[string]bool
+	// from is the task a run starts from, fromReached is set once it has, both are guarded by mu.
+	from        string
+	fromReached bool
+	// mu guards alreadyRan and affectedMemo, as required tasks may run in parallel.
+	mu *sync.Mutex
+	// alreadyRan is closed once each task has finished.
+	alreadyRan map[string]chan struct{}
+	// changedFiles is nil unless tasks not affected by a change should be skipped.
+	changedFiles []string
+	affectedMemo map[string]bool
+	// wsl wraps the ScriptRunner of the shell scripts of tasks with the wsl attribute, nil if they run natively.
+	wsl func(ScriptRunner) ScriptRunner
+	// wrapper is the command the shell scripts of tasks without their own wrapper run inside, nil if none.
+	wrapper []string
+}
+
+// Observer is notified as a Runner runs tasks.
+type Observer interface {
+	// TaskStarted is called before a task and its dependencies run.
+	// The returned context is used to run the task, so dependencies
+	// receive a context derived from the one returned for their parent.
+	TaskStarted(ctx context.Context, task models.Task) context.Context
+	// TaskFinished is called once a task has finished, err is nil if it succeeded.
+	TaskFinished(ctx context.Context, task models.Task, err error)
+}
+
+// SkipObserver is an Observer that is also notified of tasks that are skipped,
+// such as tasks that run once and have already run.
+type SkipObserver interface {
+	Observer
+	TaskSkipped(ctx context.Context, task models.Task, reason string)
+}
+
+// CacheObserver is an Observer that is also notified when the outputs of a task are looked up in the cache,
+// hit is true if they were restored and its scripts did not run.
+type CacheObserver interface {
+	Observer
+	TaskCached(ctx context.Context, task models.Task, hit bool)
+}
+
+// ExitCode returns the exit code of the script that caused err, ok is false if err was not caused by a script exiting.
+func ExitCode(err error) (code int, ok bool) {
+	if status, ok := interp.IsExitStatus(err); ok {
+		return int(status), true
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithSecretResolver sets the SecretResolver used to resolve Env values,
+// the default is DefaultSecretResolvers.
+func WithSecretResolver(sr SecretResolver) Option {
+	return func(r *Runner) {
+		r.secretResolver = sr
+	}
+}
+
+// WithObserver adds an Observer to be notified as tasks run.
+func WithObserver(o Observer) Option {
+	return func(r *Runner) {
+		r.observers = append(r.observers, o)
+	}
+}
+
+// WithExecutor sets the ScriptRunner for scripts in code blocks tagged with language,
+// scripts in untagged blocks or blocks with an unknown language are run as shell scripts.
+func WithExecutor(language string, sr ScriptRunner) Option {
+	return func(r *Runner) {
+		r.executors[strings.ToLower(language)] = sr
+	}
+}
+
+// WithoutExpansion stops the Runner from interpolating variables in Env and Dir values.
+func WithoutExpansion() Option {
+	return func(r *Runner) {
+		r.noExpand = true
+	}
+}
+
+// WithDryRun makes the Runner print the script of each task instead of running it.
+func WithDryRun() Option {
+	return func(r *Runner) {
+		r.dryRun = true
+	}
+}
+
+// WithChangedFiles makes the Runner skip tasks that are not affected by a change to files,
+// a list of absolute paths.
+//
+// A task is affected if a file matches its sources, or it runs a task that is affected.
+// A task with a script and no sources is always affected.
+func WithChangedFiles(files []string) Option {
+	return func(r *Runner) {
+		r.changedFiles = append([]string{}, files...)
+	}
+}
+
+// WithOutputGroups makes the Runner fold the output of each script into a collapsible section
+// of the log of a CI system.
+func WithOutputGroups(p ci.Provider) Option {
+	return func(r *Runner) {
+		r.groups = p
+	}
+}
+
+// WithCI sets whether the Runner is running in CI, which decides whether tasks with the ci-only
+// or local-only attributes can run. The default is ci.Running.
+func WithCI(inCI bool) Option {
+	return func(r *Runner) {
+		r.inCI = inCI
+	}
+}
+
+// WithResume makes the Runner skip the tasks that succeeded in the last run of the same task
+// with the same inputs, if that run failed. The run continues with the same run ID.
+func WithResume() Option {
+	return func(r *Runner) {
+		r.resume = true
+	}
+}
+
+// WithJobs sets how many scripts the Runner may run at the same time, the default is 1.
+// When more than 1 the required tasks of a task run in parallel, steps always run in order.
+func WithJobs(n int) Option {
+	return func(r *Runner) {
+		r.jobs = n
+	}
+}
+
+// WithShell sets the command used to run scripts without a shebang, such as `bash -euo pipefail`,
+// instead of the built-in shell. The script is passed as a file after the arguments.
+func WithShell(shell string) Option {
+	return func(r *Runner) {
+		i := newInterpreter()
+		i.shell = strings.Fields(shell)
+		r.scriptRunner = i
+	}
+}
+
+// WithWrapper sets the command the shell scripts of tasks run inside, such as nix develop -c,
+// unless a task sets its own with the wrapper attribute.
+func WithWrapper(wrapper []string) Option {
+	return func(r *Runner) {
+		r.wrapper = wrapper
+	}
+}
+
+// WithShellOpts sets the shell options scripts run with, unless a task sets its own with shell-opts,
+// instead of errexit and xtrace. It also applies to a POSIX shell set with WithShell.
+func WithShellOpts(opts []string) Option {
+	return func(r *Runner) {
+		r.shellOpts = opts
+	}
+}
+
+// WithEnvFiles sets dotenv files that are loaded into the environment of every task.
+// Variables already set in the environment take precedence.
+func WithEnvFiles(paths ...string) Option {
+	return func(r *Runner) {
+		r.envFiles = append(r.envFiles, paths...)
+	}
+}
+
+// WithProfile adds env, the variables of a named profile, to the environment of every task.
+// They take precedence over the environment of xc, and the env attribute of a task takes precedence over them.
+func WithProfile(env ...string) Option {
+	return func(r *Runner) {
+		r.profileEnv = append(r.profileEnv, env...)
+	}
+}
+
+// WithoutNetwork makes the Runner run every script without network access,
+// as if each task had the attribute network: false.
+func WithoutNetwork() Option {
+	return func(r *Runner) {
+		r.noNetwork = true
+	}
+}
+
+// WithoutSandbox makes the Runner ignore the allow-paths, deny-paths and network attributes of tasks,
+// running their scripts without restrictions. WithoutNetwork still applies.
+func WithoutSandbox() Option {
+	return func(r *Runner) {
+		r.noSandbox = true
+	}
+}
+
+// WithKeepTmp stops the Runner from removing the temporary directory
+// of each task after it has run.
+func WithKeepTmp() Option {
+	return func(r *Runner) {
+		r.keepTmp = true
+	}
+}
+
+// WithoutDependencies makes the Runner skip the required tasks of every task,
+// running only the task itself and its steps.
+func WithoutDependencies() Option {
+	return func(r *Runner) {
+		r.noDeps = true
+	}
+}
+
+// WithOnly makes the Runner only run the scripts of the named tasks, names are case insensitive.
+// Other tasks in the run are skipped, but the tasks they require and their steps are still run.
+func WithOnly(names ...string) Option {
+	return func(r *Runner) {
+		r.only = map[string]bool{}
+		for _, n := range names {
+			r.only[strings.ToLower(n)] = true
+		}
+	}
+}
+
+// WithFrom makes the Runner start a run from the named task, as if the tasks before it had already run.
+// Tasks that would run before it, including the tasks it requires, are skipped,
+// other than those that lead to it, and every task after it runs.
+func WithFrom(name string) Option {
+	return func(r *Runner) {
+		r.from = name
+	}
 }
 
 // NewRunner takes Tasks and returns a Runner.
@@ -33,15 +310,59 @@ type Runner struct {
 //
 // NewRunner will return an error in the case that Dependent tasks are cyclical,
 // invalid or at a larger depth than 50.
-func NewRunner(ts models.Tasks, dir string) (runner Runner, err error) {
+func NewRunner(ts models.Tasks, dir string, opts ...Option) (runner Runner, err error) {
 	runner = Runner{
-		scriptRunner: newInterpreter(),
-		tasks:        ts,
-		dir:          dir,
-		alreadyRan:   map[string]bool{},
+		scriptRunner:   newInterpreter(),
+		executors:      DefaultExecutors(),
+		secretResolver: DefaultSecretResolvers(),
+		approver:       &TerminalApprover{},
+		toolChecker:    tools.NewChecker(),
+		tasks:          ts,
+		dir:            dir,
+		runID:          newRunID(),
+		jobs:           1,
+		mu:             &sync.Mutex{},
+		alreadyRan:     map[string]chan struct{}{},
+		services:       &services{},
+		inCI:           ci.Running(),
+	}
+	if runtime.GOOS == "windows" {
+		runner.wsl = func(next ScriptRunner) ScriptRunner { return newWSLRunner(next) }
+	}
+	plugins, err := loadPlugins(PluginDir(dir), runner.executors)
+	if err != nil {
+		return
+	}
+	for language, sr := range plugins {
+		runner.executors[language] = sr
+	}
+	if runner.attributeHandlers, err = executables(AttributeDir(dir)); err != nil {
+		err = fmt.Errorf("failed to load attribute handlers: %w", err)
+		return
+	}
+	for _, opt := range opts {
+		opt(&runner)
+	}
+	for _, path := range runner.envFiles {
+		var env []string
+		if env, err = readEnvFile(path); err != nil {
+			return
+		}
+		runner.fileEnv = append(runner.fileEnv, env...)
+	}
+	if runner.dryRun {
+		runner.scheduler = newScheduler(1, "")
+	} else {
+		runner.scheduler = newScheduler(runner.jobs, dir)
 	}
 	for _, t := range ts {
 		err = runner.ValidateDependencies(t.Name, []string{})
+		// A task with a parsing error, or that requires one, fails when it is run so the other tasks can be.
+		var pe *parsingError
+		if errors.As(err, &pe) {
+			err = nil
+			continue
+		}
 		if err != nil {
 			return
 		}
@@ -49,11 +370,6 @@ func NewRunner(ts models.Tasks, dir string) (runner Runner, err error) {
 	return
 }
 
-const scriptHeader = ` #!/bin/bash
-      set -e
-      set -o xtrace
-`
-
 func taskUsage(task models.Task) string {
 	argUsage := fmt.Sprintf("xc %s", task.Name)
 	for _, n := range task.Inputs {
@@ -76,67 +392,990 @@ func environmentContainsInput(env []string, input string) bool {
 	return false
 }
 
+// checkInputValue returns an error if the task lists the values input accepts and value is not one of them.
+func checkInputValue(task models.Task, input, value string) error {
+	values := task.InputValues[input]
+	if len(values) == 0 {
+		return nil
+	}
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("input %s of task %s must be one of %s, got %q", input, task.Name, strings.Join(values, ", "), value)
+}
+
 func getInputs(task models.Task, inputs []string, env []string) ([]string, error) {
 	result := []string{}
 	for i, n := range task.Inputs {
-		// Do the command args contain the input?
-		if len(inputs) > i {
-			result = append(result, fmt.Sprintf("%v=%v", n, inputs[i]))
-			continue
+		v, set, ok := inputValue(task, i, inputs, env)
+		if !ok {
+			return nil, missingInput(task, n)
 		}
-		// Does the task environment contain the input?
-		if environmentContainsInput(env, n) {
-			continue
+		if err := checkInputValue(task, n, v); err != nil {
+			return nil, err
+		}
+		if set {
+			result = append(result, fmt.Sprintf("%v=%v", n, v))
 		}
-		return nil, fmt.Errorf(taskUsage(task))
 	}
 	return result, nil
 }
 
+// inputValue returns the value of the i-th input of a task from the command args, inputs, or the task environment, env,
+// in which it is looked up in the environment variables it is read from in order.
+// set is true if the value is not already in env under the name of the input, ok is false if the input is not given.
+func inputValue(task models.Task, i int, inputs, env []string) (value string, set, ok bool) {
+	n := task.Inputs[i]
+	if len(inputs) > i {
+		return inputs[i], true, true
+	}
+	if environmentContainsInput(env, n) {
+		v, _ := interpolate.EnvLookup(env)(n)
+		return v, false, true
+	}
+	for _, source := range task.InputSources[n] {
+		if source != n && environmentContainsInput(env, source) {
+			v, _ := interpolate.EnvLookup(env)(source)
+			return v, true, true
+		}
+	}
+	return "", false, false
+}
+
+// missingInput returns the error for an input of a task that is not given.
+func missingInput(task models.Task, input string) error {
+	sources := task.InputSources[input]
+	if len(sources) == 0 {
+		return fmt.Errorf(taskUsage(task))
+	}
+	return fmt.Errorf("task %s requires input %s: pass it as an argument or set %s",
+		task.Name, input, strings.Join(sources, " or "))
+}
+
+// checkContracts checks the inputs with a contract, such as AWS_REGION (required), of each task in a run of the
+// named task, so that a missing input fails the run before any script starts rather than once its task is reached.
+// Inputs without a contract are only checked when their task runs, as tasks may be skipped.
+func (r *Runner) checkContracts(name string, inputs []string) error {
+	seen := map[string]bool{}
+	var check func(name string, inputs, with []string) error
+	check = func(name string, inputs, with []string) error {
+		task, ok := r.tasks.Get(name)
+		if !ok {
+			return nil
+		}
+		key := models.Dependency{Name: task.Name, Env: with}.Node() + "\x00" + strings.Join(inputs, "\x00")
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		env := append(append(r.fileEnv[:len(r.fileEnv):len(r.fileEnv)], os.Environ()...), with...)
+		taskEnv, err := r.expandEnv(r.taskEnv(task), with, env)
+		if err != nil {
+			// The error is returned when the task runs.
+			return nil
+		}
+		// Secrets are not resolved, as only whether an input is set matters.
+		env = append(env, taskEnv...)
+		for i, n := range task.Inputs {
+			if len(task.InputSources[n]) == 0 {
+				continue
+			}
+			v, _, ok := inputValue(task, i, inputs, env)
+			if !ok {
+				return missingInput(task, n)
+			}
+			if err := checkInputValue(task, n, v); err != nil {
+				return err
+			}
+		}
+		entries := task.Steps
+		if !r.noDeps {
+			entries = append(task.DependsOn[:len(task.DependsOn):len(task.DependsOn)], entries...)
+		}
+		for _, entry := range entries {
+			d, err := models.ParseDependency(entry)
+			if err != nil {
+				continue
+			}
+			dwith, err := r.expandEnv(d.Env, nil, env)
+			if err != nil {
+				continue
+			}
+			if err := check(d.Name, d.Inputs, dwith); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(name, inputs, nil)
+}
+
 // Run runs a task given a string name.
+// Cancelling ctx interrupts the scripts that are running, and kills the processes they started.
 // Task dependencies will be run first, an error will return if any fail.
+// Task steps are run next, strictly in the order they are listed.
 // Task commands are run next, in case of a non zero result an error will return.
+//
+// Each task that succeeds is recorded in a checkpoint in the state directory,
+// which is removed once the whole run has succeeded.
 func (r *Runner) Run(ctx context.Context, name string, inputs []string) error {
+	if err := r.ValidateDependencies(name, []string{}); err != nil {
+		return err
+	}
+	if err := r.validateFrom(name); err != nil {
+		return err
+	}
+	if err := r.checkContracts(name, inputs); err != nil {
+		return err
+	}
+	if r.shard[1] > 0 {
+		selected, err := r.shardTasks(name)
+		if err != nil {
+			return err
+		}
+		r.shardSelected = selected
+		defer func() { r.shardSelected = nil }()
+	}
+	if r.dryRun {
+		return r.run(ctx, name, inputs, nil)
+	}
+	if err := createStateDir(r.dir); err != nil {
+		return err
+	}
+	cp, found, err := loadCheckpoint(checkpointPath(r.dir, name, inputs))
+	if err != nil {
+		return err
+	}
+	switch {
+	case r.resume && found:
+		fmt.Printf("resuming run %s\n", cp.RunID)
+		r.runID = cp.RunID
+	default:
+		cp.RunID = r.runID
+		cp.Completed = nil
+		cp.previous = nil
+	}
+	r.checkpoint = cp
+	defer func() { r.checkpoint = nil }()
+	if r.detach {
+		defer r.services.detach()
+	} else {
+		defer r.services.stop()
+	}
+	if err = r.run(ctx, name, inputs, nil); err != nil {
+		return err
+	}
+	return cp.remove()
+}
+
+func (r *Runner) notifyCached(ctx context.Context, task models.Task, hit bool) {
+	for _, o := range r.observers {
+		if co, ok := o.(CacheObserver); ok {
+			co.TaskCached(ctx, task, hit)
+		}
+	}
+}
+
+func (r *Runner) notifySkipped(ctx context.Context, task models.Task, reason string) {
+	for _, o := range r.observers {
+		if so, ok := o.(SkipObserver); ok {
+			so.TaskSkipped(ctx, task, reason)
+		}
+	}
+}
+
+// run runs a task with extra environment variables, set by the task that requires it.
+func (r *Runner) run(ctx context.Context, name string, inputs []string, with []string) error {
 	task, ok := r.tasks.Get(name)
 	if !ok {
 		return fmt.Errorf("task %s not found", name)
 	}
-	if task.RequiredBehaviour == models.RequiredBehaviourOnce && r.alreadyRan[task.Name] {
-		fmt.Printf("task %q ran already: skipping\n", task.Name)
+	if r.beforeFrom(task) {
+		fmt.Printf("task %q runs before %q: skipping\n", task.Name, r.from)
+		r.notifySkipped(ctx, task, "runs before "+r.from)
+		return nil
+	}
+	if r.changedFiles != nil && !r.affected(task) {
+		fmt.Printf("task %q is not affected by changes: skipping\n", task.Name)
+		r.notifySkipped(ctx, task, "not affected by changes")
 		return nil
 	}
-	r.alreadyRan[task.Name] = true
-	env := os.Environ()
-	env = append(env, task.Env...)
+	if !r.selected(task) {
+		fmt.Printf("task %q is not selected: skipping\n", task.Name)
+		r.notifySkipped(ctx, task, "not selected")
+		env, _, err := r.environment(ctx, task, with)
+		if err != nil {
+			return err
+		}
+		return r.runDependencies(ctx, task, env)
+	}
+	// The same task run with different environment variables is treated as a different task.
+	key := models.Dependency{Name: task.Name, Env: with}.Node()
+	r.mu.Lock()
+	ran, ok := r.alreadyRan[key]
+	// Services are only started once, as they keep running.
+	if (task.RequiredBehaviour == models.RequiredBehaviourOnce || task.Service) && ok {
+		r.mu.Unlock()
+		fmt.Printf("task %q ran already: skipping\n", key)
+		r.notifySkipped(ctx, task, "ran already")
+		// When running in parallel the task may not have finished yet.
+		select {
+		case <-ran:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	done := make(chan struct{})
+	defer close(done)
+	r.alreadyRan[key] = done
+	r.mu.Unlock()
+	var checkpointKey string
+	if r.checkpoint != nil {
+		checkpointKey = taskKey(task, inputs, with)
+		if r.checkpoint.skip(checkpointKey) {
+			fmt.Printf("task %q succeeded in run %s: skipping\n", key, r.runID)
+			r.notifySkipped(ctx, task, "succeeded in run "+r.runID)
+			return nil
+		}
+	}
+	for _, o := range r.observers {
+		ctx = o.TaskStarted(ctx, task)
+	}
+	ctx, meter := r.withUsage(ctx)
+	err := r.runTask(ctx, task, inputs, with)
+	// Services are always started again on resume, as they were stopped.
+	if err == nil && r.checkpoint != nil && !task.Service {
+		err = r.checkpoint.complete(checkpointKey)
+	}
+	r.notifyUsage(ctx, task, meter)
+	for i := len(r.observers) - 1; i >= 0; i-- {
+		r.observers[i].TaskFinished(ctx, task, err)
+	}
+	return err
+}
+
+func (r *Runner) runTask(ctx context.Context, task models.Task, inputs []string, with []string) error {
+	if err := r.checkWhere(task); err != nil {
+		return err
+	}
+	if err := r.checkTools(ctx, task); err != nil {
+		return err
+	}
+	start := time.Now()
+	env, taskEnv, err := r.environment(ctx, task, with)
+	if err != nil {
+		return err
+	}
 	inp, err := getInputs(task, inputs, env)
 	if err != nil {
 		return err
 	}
-	for _, t := range task.DependsOn {
-		ta, _ := shlex.Split(t)
-		err := r.Run(ctx, ta[0], ta[1:])
+	if err = r.runDependencies(ctx, task, env); err != nil {
+		return err
+	}
+	env = append(env, inp...)
+	dir, err := r.getExecutionPath(task, env)
+	if err != nil {
+		return err
+	}
+	var key string
+	var sources map[string]string
+	if len(task.Sources) > 0 {
+		if sources, err = sourceHashes(task, dir, !r.noGitignore); err != nil {
+			return err
+		}
+		if r.explain {
+			r.explainSources(task, inputs, with, sources)
+		}
+	}
+	if r.cacheable(task) {
+		key = cacheKey(task, cacheEnv(taskEnv, with, inp), sources)
+		restored, err := r.restoreCache(ctx, key, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "task %q could not be restored from the cache: %v\n", task.Name, err)
+		}
+		r.notifyCached(ctx, task, restored)
+		if restored {
+			fmt.Printf("task %q restored from cache: skipping\n", task.Name)
+			r.recordSources(task, inputs, with, sources)
+			return nil
+		}
+	}
+	// Waiting for approval does not hold one of the jobs.
+	if err = r.approve(ctx, task); err != nil {
+		return err
+	}
+	release, err := r.scheduler.acquire(ctx, task)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err = r.composeUp(ctx, task, env, dir); err != nil {
+		return err
+	}
+	if len(task.Script) > 0 && !r.dryRun {
+		var handled []string
+		if handled, err = r.handleAttributes(ctx, task, env, dir); err != nil {
+			return err
+		}
+		env = append(env, handled...)
+	}
+	if task.Service && !r.dryRun && len(task.Script) > 0 {
+		return r.startService(ctx, task, env, inputs, dir)
+	}
+	if err = r.runScript(ctx, task, env, inputs, dir); err != nil {
+		r.composeLogs(ctx, task, env, dir)
+		return err
+	}
+	if r.dryRun {
+		return nil
+	}
+	if err = checkOutputs(task, dir, start); err != nil {
+		return err
+	}
+	if key != "" && r.cacheMode == cache.ModeUpload {
+		// A task that succeeded does not fail because its outputs could not be stored.
+		if err = r.storeCache(ctx, task, key, dir); err != nil {
+			fmt.Fprintf(os.Stderr, "task %q could not be stored in the cache: %v\n", task.Name, err)
+		}
+	}
+	r.recordSources(task, inputs, with, sources)
+	return nil
+}
+
+// affected reports whether a task is affected by the changed files.
+func (r *Runner) affected(task models.Task) bool {
+	r.mu.Lock()
+	a, ok := r.affectedMemo[task.Name]
+	r.mu.Unlock()
+	if ok {
+		return a
+	}
+	a = r.sourcesChanged(task)
+	for _, entry := range append(task.DependsOn[:len(task.DependsOn):len(task.DependsOn)], task.Steps...) {
+		if a {
+			break
+		}
+		d, err := models.ParseDependency(entry)
 		if err != nil {
+			continue
+		}
+		if dt, ok := r.tasks.Get(d.Name); ok {
+			a = r.affected(dt)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.affectedMemo == nil {
+		r.affectedMemo = map[string]bool{}
+	}
+	r.affectedMemo[task.Name] = a
+	return a
+}
+
+// sourcesChanged reports whether a changed file matches the sources of a task,
+// a task with a script and no sources is always affected.
+func (r *Runner) sourcesChanged(task models.Task) bool {
+	if len(task.Sources) == 0 {
+		return task.Script != ""
+	}
+	return len(r.changedSources(task)) > 0
+}
+
+// changedSources returns the changed files that match the sources of a task,
+// patterns are relative to the directory of the task.
+// If the directory cannot be found every changed file is returned.
+func (r *Runner) changedSources(task models.Task) []string {
+	dir, err := r.getExecutionPath(task, os.Environ())
+	if err != nil {
+		return r.changedFiles
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	var changed []string
+	for _, f := range r.changedFiles {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		// Patterns such as ** also match paths starting with .., so files outside the directory
+		// only match patterns that lead out of it.
+		outside := rel == ".." || strings.HasPrefix(rel, "../")
+		for _, pattern := range task.Sources {
+			if outside && !strings.HasPrefix(path.Clean(pattern), "../") {
+				continue
+			}
+			if glob.Match(pattern, rel) {
+				changed = append(changed, rel)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// environment returns the environment of a task run with extra environment variables set by the task that requires it,
+// and its expanded env attribute with secrets resolved.
+func (r *Runner) environment(ctx context.Context, task models.Task, with []string) (env, taskEnv []string, err error) {
+	env = append(append(r.fileEnv[:len(r.fileEnv):len(r.fileEnv)], os.Environ()...), with...)
+	if taskEnv, err = r.expandEnv(r.taskEnv(task), with, env); err != nil {
+		return nil, nil, err
+	}
+	if taskEnv, err = r.resolveSecrets(ctx, task, taskEnv, env); err != nil {
+		return nil, nil, err
+	}
+	return append(env, taskEnv...), taskEnv, nil
+}
+
+// runDependencies runs the required tasks and then the steps of a task,
+// unless WithoutDependencies skips its required tasks.
+func (r *Runner) runDependencies(ctx context.Context, task models.Task, env []string) error {
+	if !r.noDeps && !r.startFrom(task) {
+		if err := r.runRequired(ctx, task.DependsOn, env); err != nil {
+			return err
+		}
+	}
+	for _, t := range task.Steps {
+		if err := r.runDependency(ctx, t, env); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// selected reports whether the script of a task runs, as it is selected by WithOnly and in the shard set by WithShard.
+func (r *Runner) selected(task models.Task) bool {
+	name := strings.ToLower(task.Name)
+	return (r.only == nil || r.only[name]) && (r.shardSelected == nil || r.shardSelected[name])
+}
+
+// validateFrom returns an error if the task set by WithFrom is not run by the named task.
+func (r *Runner) validateFrom(name string) error {
+	if r.from == "" {
+		return nil
+	}
+	task, ok := r.tasks.Get(name)
+	if !ok {
+		return fmt.Errorf("task %s not found", name)
+	}
+	if !strings.EqualFold(task.Name, r.from) && !r.leadsTo(task, r.from) {
+		return fmt.Errorf("task %s is not run by %s", r.from, task.Name)
+	}
+	return nil
+}
+
+// beforeFrom reports whether a task runs before the task set by WithFrom has been reached,
+// and does not lead to it.
+func (r *Runner) beforeFrom(task models.Task) bool {
+	if r.from == "" || strings.EqualFold(task.Name, r.from) {
+		return false
+	}
+	r.mu.Lock()
+	reached := r.fromReached
+	r.mu.Unlock()
+	return !reached && !r.leadsTo(task, r.from)
+}
+
+// startFrom reports whether a task is the task set by WithFrom, run for the first time,
+// in which case the tasks it requires are skipped.
+func (r *Runner) startFrom(task models.Task) bool {
+	if r.from == "" || !strings.EqualFold(task.Name, r.from) {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	first := !r.fromReached
+	r.fromReached = true
+	return first
+}
+
+// leadsTo reports whether a task requires or runs the named task, directly or through other tasks.
+// The tasks it requires are not followed if WithoutDependencies skips them.
+func (r *Runner) leadsTo(task models.Task, name string) bool {
+	entries := task.Steps
+	if !r.noDeps {
+		entries = append(task.DependsOn[:len(task.DependsOn):len(task.DependsOn)], entries...)
+	}
+	for _, entry := range entries {
+		d, err := models.ParseDependency(entry)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(d.Name, name) {
+			return true
+		}
+		if dt, ok := r.tasks.Get(d.Name); ok && r.leadsTo(dt, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// runRequired runs the required tasks of a task, in parallel if the Runner has more than one job.
+// Once one fails the others are cancelled.
+func (r *Runner) runRequired(ctx context.Context, entries []string, env []string) error {
+	if r.jobs <= 1 || r.dryRun || len(entries) < 2 {
+		for _, entry := range entries {
+			if err := r.runDependency(ctx, entry, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []error
+	)
+	for _, entry := range entries {
+		wg.Add(1)
+		go func(entry string) {
+			defer wg.Done()
+			err := r.runDependency(ctx, entry, env)
+			if err == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			// Tasks that fail because they were cancelled are not reported.
+			if ctx.Err() == nil {
+				failed = append(failed, err)
+			}
+			cancel()
+		}(entry)
+	}
+	wg.Wait()
+	if len(failed) == 0 {
+		return ctx.Err()
+	}
+	return errors.Join(failed...)
+}
+
+// runDependency runs an entry in the requires or steps of a task,
+// values of its environment variables are expanded using env.
+func (r *Runner) runDependency(ctx context.Context, entry string, env []string) error {
+	d, err := models.ParseDependency(entry)
+	if err != nil {
+		return err
+	}
+	with, err := r.expandEnv(d.Env, nil, env)
+	if err != nil {
+		return err
+	}
+	return r.run(ctx, d.Name, d.Inputs, with)
+}
+
+// runScript runs the script of a task, once for each item if it has a foreach attribute.
+func (r *Runner) runScript(ctx context.Context, task models.Task, env, inputs []string, dir string) error {
 	if len(task.Script) == 0 {
 		return nil
 	}
-	env = append(env, inp...)
-	return r.scriptRunner.Execute(ctx, task.Script, env, inputs, r.getExecutionPath(task))
+	if len(task.Foreach) == 0 {
+		return r.execute(ctx, task, env, inputs, dir)
+	}
+	items, err := foreachItems(task.Foreach, dir)
+	if err != nil {
+		return err
+	}
+	runItem := func(item string) error {
+		itemEnv := append(env[:len(env):len(env)], "XC_ITEM="+item)
+		itemDir, err := r.getExecutionPath(task, itemEnv)
+		if err != nil {
+			return err
+		}
+		if err := r.execute(ctx, task, itemEnv, inputs, itemDir); err != nil {
+			return fmt.Errorf("%s failed for %s: %w", task.Name, item, err)
+		}
+		return nil
+	}
+	if !task.ForeachParallel {
+		for _, item := range items {
+			if err := runItem(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// The task already holds one of the jobs, which runs items until there are none left.
+	// Each other worker waits for a job of its own, so that no more than -j scripts run at once.
+	queue := make(chan int, len(items))
+	for i := range items {
+		queue <- i
+	}
+	close(queue)
+	errs := make([]error, len(items))
+	work := func() {
+		for i := range queue {
+			errs[i] = runItem(items[i])
+		}
+	}
+	waitCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for n := 1; n < len(items); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := r.scheduler.acquireExtra(waitCtx, task)
+			if err != nil {
+				return
+			}
+			defer release()
+			work()
+		}()
+	}
+	work()
+	// Workers still waiting for a job once every item has started are no longer needed.
+	cancel()
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
-func (r *Runner) getExecutionPath(task models.Task) string {
-	if task.Dir == "" {
-		return r.dir
+// checkOutputs returns an error if the outputs in the assert-outputs attribute
+// of a task are missing or do not meet their conditions.
+func checkOutputs(task models.Task, dir string, start time.Time) error {
+	var errs []error
+	for _, o := range task.AssertOutputs {
+		path := o.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		fi, err := os.Stat(path)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s does not exist", o.Path))
+		case o.NonEmpty && fi.Size() == 0:
+			errs = append(errs, fmt.Errorf("%s is empty", o.Path))
+		// Some filesystems only store modification times to the second.
+		case o.Newer && fi.ModTime().Before(start.Truncate(time.Second)):
+			errs = append(errs, fmt.Errorf("%s was not updated, last modified %s", o.Path, fi.ModTime().Format(time.RFC3339)))
+		}
 	}
-	if filepath.IsAbs(task.Dir) {
-		return task.Dir
+	if len(errs) > 0 {
+		return fmt.Errorf("task %s did not produce its outputs:\n%w", task.Name, errors.Join(errs...))
 	}
-	return filepath.Join(r.dir, task.Dir)
+	return nil
 }
 
-// ValidateDependencies checks that task dependencies follow these rules:
+// checkWhere returns an error if a task with the ci-only attribute is run outside of CI,
+// or a task with the local-only attribute is run in CI. Dry runs are allowed anywhere.
+func (r *Runner) checkWhere(task models.Task) error {
+	switch {
+	case r.dryRun:
+		return nil
+	case task.CIOnly && !r.inCI:
+		return fmt.Errorf("task %s only runs in CI: trigger the pipeline that runs it rather than running it here, "+
+			"if this is CI set CI=true", task.Name)
+	case task.LocalOnly && r.inCI:
+		return fmt.Errorf("task %s only runs locally, not in CI: remove it from the pipeline, "+
+			"or set CI=false if this is not CI", task.Name)
+	}
+	return nil
+}
+
+// checkTools returns an error if any binary in the requires-tools attribute of a task is not installed.
+// Dry runs do not check, as the binaries are run to find their versions.
+func (r *Runner) checkTools(ctx context.Context, task models.Task) error {
+	if r.dryRun {
+		return nil
+	}
+	reqs := make([]tools.Requirement, 0, len(task.RequiresTools))
+	for _, t := range task.RequiresTools {
+		req, err := tools.Parse(t)
+		if err != nil {
+			return err
+		}
+		reqs = append(reqs, req)
+	}
+	if err := r.toolChecker.Check(ctx, reqs); err != nil {
+		return fmt.Errorf("task %s is missing required tools:\n%w", task.Name, err)
+	}
+	return nil
+}
+
+// execute runs the script of a task in its own temporary directory.
+func (r *Runner) execute(ctx context.Context, task models.Task, env, inputs []string, dir string) error {
+	if r.dryRun {
+		script, deferred := task.Script, task.Deferred
+		if terminal.Color(os.Stdout) {
+			script = terminal.Highlight(script, task.Language)
+			deferred = terminal.Highlight(deferred, task.DeferredLanguage)
+		}
+		fmt.Printf("# %s\n```%s\n%s```\n", task.Name, task.Language, script)
+		if task.Stdin != "" {
+			fmt.Printf("```stdin\n%s```\n", task.Stdin)
+		}
+		if deferred != "" {
+			fmt.Printf("```%s\n%s```\n", strings.TrimSpace(task.DeferredLanguage+" deferred"), deferred)
+		}
+		return nil
+	}
+	if r.groups != nil {
+		title := task.Name
+		if item, ok := interpolate.EnvLookup(env)("XC_ITEM"); ok && len(task.Foreach) > 0 {
+			title += " (" + item + ")"
+		}
+		defer r.groups.Group(os.Stdout, title)()
+	}
+	tmp, err := os.MkdirTemp("", "xc_")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	if r.keepTmp {
+		fmt.Printf("task %q temporary directory: %s\n", task.Name, tmp)
+	} else {
+		defer os.RemoveAll(tmp)
+	}
+	if err = createStateDir(r.dir); err != nil {
+		return err
+	}
+	env = append(append(env[:len(env):len(env)], r.xcEnv(task)...), "XC_TMPDIR="+tmp)
+	ctx = r.withOutput(ctx, task)
+	s, err := r.sandbox(task, dir, tmp)
+	if err != nil {
+		return err
+	}
+	ctx = withSandbox(ctx, s)
+	if opts := task.ShellOpts; opts != nil {
+		ctx = withShellOpts(ctx, opts)
+	} else if r.shellOpts != nil {
+		ctx = withShellOpts(ctx, r.shellOpts)
+	}
+	if err = sandboxChown(ctx, tmp); err != nil {
+		return fmt.Errorf("failed to change the owner of the temporary directory: %w", err)
+	}
+	scriptCtx := ctx
+	if task.Stdin != "" {
+		scriptCtx = withStdin(ctx, task.Stdin)
+	}
+	err = r.taskScriptRunner(task, task.Language).Execute(scriptCtx, task.Script, env, inputs, dir)
+	if task.Deferred == "" {
+		return err
+	}
+	return errors.Join(err, r.executeDeferred(ctx, task, env, inputs, dir, err))
+}
+
+// executeDeferred runs the deferred script of a task once its script has finished, with XC_EXIT_CODE set to
+// the exit code of the script. It runs even if the script failed or ctx was cancelled, such as by Ctrl-C.
+func (r *Runner) executeDeferred(
+	ctx context.Context,
+	task models.Task,
+	env, inputs []string,
+	dir string,
+	scriptErr error,
+) error {
+	code, ok := ExitCode(scriptErr)
+	if !ok && scriptErr != nil {
+		code = 1
+	}
+	env = append(env[:len(env):len(env)], "XC_EXIT_CODE="+strconv.Itoa(code))
+	err := r.taskScriptRunner(task, task.DeferredLanguage).Execute(detachedContext{ctx}, task.Deferred, env, inputs, dir)
+	if err != nil {
+		return fmt.Errorf("deferred script of %s failed: %w", task.Name, err)
+	}
+	return nil
+}
+
+// scriptRunnerFor returns the ScriptRunner for scripts in a code block of language.
+func (r *Runner) scriptRunnerFor(language string) ScriptRunner {
+	if sr, ok := r.executors[language]; ok {
+		return sr
+	}
+	return r.scriptRunner
+}
+
+// taskScriptRunner returns the ScriptRunner for the scripts of task in a code block of language,
+// which runs shell scripts in WSL if the task has the wsl attribute and xc runs on Windows,
+// or otherwise inside the wrapper of the task.
+func (r *Runner) taskScriptRunner(task models.Task, language string) ScriptRunner {
+	sr := r.scriptRunnerFor(language)
+	if _, native := r.executors[language]; native {
+		return sr
+	}
+	if task.WSL && r.wsl != nil {
+		return r.wsl(sr)
+	}
+	wrapper := r.wrapper
+	if task.Wrapper != nil {
+		wrapper = task.Wrapper
+	}
+	if len(wrapper) > 0 {
+		return newWrapperRunner(wrapper, sr)
+	}
+	return sr
+}
+
+// sandbox returns the sandbox the scripts of a task run in, tmp is its temporary directory which is always writable.
+// The umask, user and scheduling of a task are applied even if the Runner has no sandbox, as they are not restrictions.
+func (r *Runner) sandbox(task models.Task, dir, tmp string) (sandbox, error) {
+	s := sandbox{
+		noNetwork: r.noNetwork || (task.NoNetwork && !r.noSandbox),
+		umask:     task.Umask,
+		nice:      task.Nice,
+		ionice:    task.IONice,
+	}
+	if task.User != "" {
+		c, err := lookupCredential(task.User)
+		if err != nil {
+			return s, err
+		}
+		s.user = c
+	}
+	if r.noSandbox || (len(task.AllowPaths) == 0 && len(task.DenyPaths) == 0) {
+		return s, nil
+	}
+	var err error
+	if len(task.AllowPaths) > 0 {
+		allow := append(task.AllowPaths[:len(task.AllowPaths):len(task.AllowPaths)], tmp)
+		if s.allow, err = sandboxPaths(allow, dir); err != nil {
+			return s, err
+		}
+	}
+	if s.deny, err = sandboxPaths(task.DenyPaths, dir); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// xcEnv returns the XC_ environment variables of a task, other than XC_TMPDIR which is created each time it runs.
+func (r *Runner) xcEnv(task models.Task) []string {
+	return []string{
+		"XC_STATE_DIR=" + StateDir(r.dir),
+		"XC_TASK_NAME=" + task.Name,
+		"XC_RUN_ID=" + r.runID,
+	}
+}
+
+// DefaultExecutors returns the ScriptRunners for code block languages that xc runs natively.
+func DefaultExecutors() map[string]ScriptRunner {
+	return map[string]ScriptRunner{
+		"http": newHTTPRunner(),
+		"sql":  newSQLRunner(),
+		"go":   newGoRunner(),
+	}
+}
+
+// foreachItems returns the items of a foreach attribute, patterns containing
+// glob characters are replaced by their matches relative to dir.
+// A pattern ending in / only matches directories.
+func foreachItems(values []string, dir string) ([]string, error) {
+	var items []string
+	for _, v := range values {
+		if !strings.ContainsAny(v, "*?[") {
+			items = append(items, v)
+			continue
+		}
+		onlyDirs := strings.HasSuffix(v, "/")
+		pattern := strings.TrimSuffix(v, "/")
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid foreach pattern %q: %w", v, err)
+		}
+		for _, m := range matches {
+			if onlyDirs {
+				if fi, err := os.Stat(m); err != nil || !fi.IsDir() {
+					continue
+				}
+			}
+			rel, err := filepath.Rel(dir, m)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, rel)
+		}
+	}
+	return items, nil
+}
+
+// StateDir returns the directory that persists state between runs for
+// the tasks in dir, it is available to scripts as XC_STATE_DIR.
+func StateDir(dir string) string {
+	return filepath.Join(dir, ".xc", "state")
+}
+
+// createStateDir creates the state directory of the tasks in dir with a .gitignore,
+// as the checkpoints, durations and other state of runs are specific to the machine and are not committed.
+func createStateDir(dir string) error {
+	stateDir := StateDir(dir)
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	gitignore := filepath.Join(stateDir, ".gitignore")
+	if _, err := os.Stat(gitignore); err == nil {
+		return nil
+	}
+	content := []byte("# Created by xc, the state of runs is not committed.\n*\n")
+	if err := os.WriteFile(gitignore, content, 0o644); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return nil
+}
+
+// RunID returns the identifier shared by every task run by r,
+// it is available to scripts as XC_RUN_ID.
+func (r *Runner) RunID() string {
+	return r.runID
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// taskEnv returns the variables of the profile followed by the env attribute of task, before expansion.
+func (r *Runner) taskEnv(task models.Task) []string {
+	return append(r.profileEnv[:len(r.profileEnv):len(r.profileEnv)], task.Env...)
+}
+
+// expandEnv interpolates the values of taskEnv, each value can refer to those before it.
+// Values set by the task that requires the task, in with, replace the defaults of taskEnv with the same name as they
+// are, so that the values after them are interpolated with the values set.
+func (r *Runner) expandEnv(taskEnv, with, env []string) ([]string, error) {
+	set := interpolate.EnvLookup(with)
+	result := make([]string, 0, len(taskEnv))
+	for _, e := range taskEnv {
+		k, v, found := strings.Cut(e, "=")
+		if wv, ok := set(k); ok {
+			e = k + "=" + wv
+		} else if found && !r.noExpand {
+			ev, err := interpolate.Expand(v, interpolate.EnvLookup(append(env[:len(env):len(env)], result...)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand env %s: %w", k, err)
+			}
+			e = k + "=" + ev
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *Runner) getExecutionPath(task models.Task, env []string) (string, error) {
+	dir, err := resolveDir(r.dir, task.Dir, env, !r.noExpand)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand directory: %w", err)
+	}
+	return dir, nil
+}
+
+// parsingError is returned for a task that was parsed with an error, such as by a lenient parser.
+type parsingError struct {
+	task, err string
+}
+
+func (e *parsingError) Error() string {
+	return fmt.Sprintf("task %s has a parsing error: %s", e.task, e.err)
+}
+
+// ValidateDependencies checks that task dependencies and steps follow these rules:
 // - No deeper dependency trees than maxDeps.
 // - Dependencies must exist as tasks.
 // - No cyclical dependencies.
@@ -150,10 +1389,14 @@ func (r *Runner) ValidateDependencies(task string, prevTasks []string) error {
 		return fmt.Errorf("task %s not found", task)
 	}
 	if t.ParsingError != "" {
-		return fmt.Errorf("task %s has a parsing error: %s", task, t.ParsingError)
+		return &parsingError{task: task, err: t.ParsingError}
 	}
-	for _, t := range t.DependsOn {
-		t, _, _ := strings.Cut(t, " ")
+	for _, t := range append(t.DependsOn[:len(t.DependsOn):len(t.DependsOn)], t.Steps...) {
+		d, err := models.ParseDependency(t)
+		if err != nil {
+			return fmt.Errorf("task %s has an invalid dependency: %w", task, err)
+		}
+		t := d.Name
 		st, ok := r.tasks.Get(t)
 		if !ok {
 			return fmt.Errorf("task %s not found", t)
@@ -163,7 +1406,7 @@ func (r *Runner) ValidateDependencies(task string, prevTasks []string) error {
 				return fmt.Errorf("task %s contains a circular dependency", t)
 			}
 		}
-		err := r.ValidateDependencies(st.Name, append([]string{st.Name}, prevTasks...))
+		err = r.ValidateDependencies(st.Name, append([]string{st.Name}, prevTasks...))
 		if err != nil {
 			return err
 		}
@@ -3,22 +3,56 @@ package run
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/joerdav/xc/interpolate"
 	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/tools"
 )
 
 type mockScriptRunner struct {
+	mu      sync.Mutex
 	calls   int
 	returns error
+	env     []string
+	dir     string
+	scripts []string
 }
 
 func (r *mockScriptRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.calls++
+	r.env = env
+	r.dir = dir
+	r.scripts = append(r.scripts, text)
 	return r.returns
 }
 
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type mockSecretResolver map[string]string
+
+func (r mockSecretResolver) Resolve(ctx context.Context, ref, dir string, env []string) (string, bool, error) {
+	v, ok := r[ref]
+	return v, ok, nil
+}
+
 func TestRun(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -116,6 +150,32 @@ func TestRun(t *testing.T) {
 			taskName:         "mytask2",
 			expectedTasksRun: 2,
 		},
+		{
+			name: "given a step that does not exist should not run",
+			tasks: []models.Task{
+				{
+					Name:  "mytask",
+					Steps: []string{"fake"},
+				},
+			},
+			taskName:           "mytask",
+			expectedParseError: true,
+		},
+		{
+			name: "given a circular step should not run",
+			tasks: []models.Task{
+				{
+					Name:  "mytask",
+					Steps: []string{"mytask2"},
+				},
+				{
+					Name:  "mytask2",
+					Steps: []string{"mytask"},
+				},
+			},
+			taskName:           "mytask",
+			expectedParseError: true,
+		},
 		{
 			name: "given a valid command with run always set, should only run always",
 			tasks: []models.Task{
@@ -166,7 +226,7 @@ func TestRun(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			runner, err := NewRunner(tt.tasks, "")
+			runner, err := NewRunner(tt.tasks, t.TempDir())
 			if (err != nil) != tt.expectedParseError {
 				t.Fatalf("expected error %v, got %v", tt.expectedParseError, err)
 			}
@@ -194,7 +254,7 @@ func TestRunWithInputs(t *testing.T) {
 				Script: "somecmd",
 				Inputs: []string{"FOO"},
 			},
-		}, "")
+		}, t.TempDir())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -210,7 +270,7 @@ func TestRunWithInputs(t *testing.T) {
 				Script: "somecmd",
 				Inputs: []string{"FOO"},
 			},
-		}, "")
+		}, t.TempDir())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -231,7 +291,7 @@ func TestRunWithInputs(t *testing.T) {
 				Script: "somecmd",
 				Inputs: []string{"FOO"},
 			},
-		}, "")
+		}, t.TempDir())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -247,4 +307,1183 @@ func TestRunWithInputs(t *testing.T) {
 			t.Fatal("task was not run")
 		}
 	})
+	t.Run("given an input with values is not one of them, return an error", func(t *testing.T) {
+		runner, err := NewRunner(models.Tasks{
+			{
+				Name:        "task",
+				Script:      "somecmd",
+				Inputs:      []string{"FOO"},
+				InputValues: map[string][]string{"FOO": {"a", "b"}},
+			},
+		}, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		if err = runner.Run(context.Background(), "task", []string{"c"}); err == nil {
+			t.Fatal("expected an error got none")
+		}
+		t.Setenv("FOO", "c")
+		if err = runner.Run(context.Background(), "task", nil); err == nil {
+			t.Fatal("expected an error for the environment variable got none")
+		}
+		if err = runner.Run(context.Background(), "task", []string{"b"}); err != nil {
+			t.Fatal(err)
+		}
+		if scriptRunner.calls != 1 {
+			t.Fatalf("expected the task to run once got %d", scriptRunner.calls)
+		}
+	})
+}
+
+func TestRunInputContracts(t *testing.T) {
+	tasks := models.Tasks{
+		{
+			Name:         "deploy",
+			Script:       "deploy",
+			Inputs:       []string{"AWS_REGION"},
+			InputSources: map[string][]string{"AWS_REGION": {"AWS_REGION", "AWS_DEFAULT_REGION"}},
+		},
+		{Name: "build", Script: "build"},
+		{Name: "release", DependsOn: []string{"build", "deploy"}},
+	}
+	t.Run("given a required input is not set, should fail before any script runs", func(t *testing.T) {
+		t.Setenv("AWS_REGION", "")
+		os.Unsetenv("AWS_REGION")
+		runner, err := NewRunner(tasks, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		err = runner.Run(context.Background(), "release", nil)
+		expected := "task deploy requires input AWS_REGION: pass it as an argument or set AWS_REGION or AWS_DEFAULT_REGION"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %q got %v", expected, err)
+		}
+		if scriptRunner.calls != 0 {
+			t.Fatalf("expected no scripts to run got %v", scriptRunner.scripts)
+		}
+	})
+	t.Run("given an input is set by another source, should set the input", func(t *testing.T) {
+		t.Setenv("AWS_REGION", "")
+		os.Unsetenv("AWS_REGION")
+		t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+		runner, err := NewRunner(tasks, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		if err = runner.Run(context.Background(), "deploy", nil); err != nil {
+			t.Fatal(err)
+		}
+		if !containsString(scriptRunner.env, "AWS_REGION=eu-west-1") {
+			t.Fatalf("expected AWS_REGION to be set from AWS_DEFAULT_REGION got %v", scriptRunner.env)
+		}
+	})
+	t.Run("given an input is set by itself, should prefer it", func(t *testing.T) {
+		t.Setenv("AWS_REGION", "us-east-1")
+		t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+		runner, err := NewRunner(tasks, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		if err = runner.Run(context.Background(), "deploy", nil); err != nil {
+			t.Fatal(err)
+		}
+		if containsString(scriptRunner.env, "AWS_REGION=eu-west-1") {
+			t.Fatalf("expected AWS_REGION not to be replaced got %v", scriptRunner.env)
+		}
+	})
+}
+
+func TestRunWithSecrets(t *testing.T) {
+	t.Run("given an env value is a secret reference, resolve it", func(t *testing.T) {
+		runner, err := NewRunner(models.Tasks{
+			{
+				Name:   "task",
+				Script: "somecmd",
+				Env:    []string{"TOKEN=op://vault/item/field", "PLAIN=value"},
+			},
+		}, t.TempDir(), WithSecretResolver(mockSecretResolver{"op://vault/item/field": "secret"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		err = runner.Run(context.Background(), "task", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsString(scriptRunner.env, "TOKEN=secret") {
+			t.Fatalf("expected resolved secret in env, got %v", scriptRunner.env)
+		}
+		if !containsString(scriptRunner.env, "PLAIN=value") {
+			t.Fatalf("expected plain value in env, got %v", scriptRunner.env)
+		}
+	})
+	t.Run("given a command substitution, resolve it with the command output", func(t *testing.T) {
+		v, ok, err := DefaultSecretResolvers().Resolve(context.Background(), "$(echo secret)", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || v != "secret" {
+			t.Fatalf("expected secret got %q (ok=%v)", v, ok)
+		}
+	})
+	t.Run("given a command substitution in a task with a dir, run it in the dir", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "sub", "token"), []byte("secret"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		runner, err := NewRunner(models.Tasks{
+			{
+				Name:   "task",
+				Script: "somecmd",
+				Dir:    "sub",
+				Env:    []string{"TOKEN=$(cat token)"},
+			},
+		}, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		if err = runner.Run(context.Background(), "task", nil); err != nil {
+			t.Fatal(err)
+		}
+		if !containsString(scriptRunner.env, "TOKEN=secret") {
+			t.Fatalf("expected secret read from the task dir in env, got %v", scriptRunner.env)
+		}
+	})
+	t.Run("given a plain value, do not resolve it", func(t *testing.T) {
+		_, ok, err := DefaultSecretResolvers().Resolve(context.Background(), "value", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Fatal("expected plain value not to be resolved")
+		}
+	})
+}
+
+func TestRunEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	runner, err := NewRunner(models.Tasks{
+		{
+			Name:   "task",
+			Script: "somecmd",
+		},
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tmp string
+	scriptRunner := &mockScriptRunner{}
+	runner.scriptRunner = scriptRunner
+	err = runner.Run(context.Background(), "task", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range scriptRunner.env {
+		if v, ok := strings.CutPrefix(e, "XC_TMPDIR="); ok {
+			tmp = v
+		}
+	}
+	if tmp == "" {
+		t.Fatalf("expected XC_TMPDIR in env, got %v", scriptRunner.env)
+	}
+	if _, err := os.Stat(tmp); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %s to be removed, got %v", tmp, err)
+	}
+	if !containsString(scriptRunner.env, "XC_TASK_NAME=task") {
+		t.Fatalf("expected XC_TASK_NAME in env, got %v", scriptRunner.env)
+	}
+	if !containsString(scriptRunner.env, "XC_RUN_ID="+runner.RunID()) {
+		t.Fatalf("expected XC_RUN_ID in env, got %v", scriptRunner.env)
+	}
+	if !containsString(scriptRunner.env, "XC_STATE_DIR="+StateDir(dir)) {
+		t.Fatalf("expected XC_STATE_DIR in env, got %v", scriptRunner.env)
+	}
+	if _, err := os.Stat(StateDir(dir)); err != nil {
+		t.Fatalf("expected state directory to exist: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(StateDir(dir), ".gitignore"))
+	if err != nil || !strings.Contains(string(b), "*\n") {
+		t.Fatalf("expected the state directory to be ignored by git, got %q: %v", b, err)
+	}
+}
+
+func TestRunDeferred(t *testing.T) {
+	dir := t.TempDir()
+	runner, err := NewRunner(models.Tasks{
+		{Name: "test", Script: "echo started > log\nexit 3\n", Deferred: "echo \"stopped $XC_EXIT_CODE\" >> log\n"},
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runner.Run(context.Background(), "test", nil)
+	if code, _ := ExitCode(err); code != 3 {
+		t.Fatalf("expected exit code 3 got %v", err)
+	}
+	log, err := os.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(log) != "started\nstopped 3\n" {
+		t.Fatalf("expected the deferred script to run after the script got %q", log)
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	dir := t.TempDir()
+	runner, err := NewRunner(models.Tasks{
+		{Name: "apply", Script: "cat > config.yaml\n", Stdin: "replicas: 3\n\nimage: app\n"},
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "apply", nil); err != nil {
+		t.Fatal(err)
+	}
+	config, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(config) != "replicas: 3\n\nimage: app\n" {
+		t.Fatalf("expected the stdin block on stdin got %q", config)
+	}
+}
+
+// cancellingScriptRunner cancels the run during the first script, recording whether later scripts were cancelled.
+type cancellingScriptRunner struct {
+	cancel    context.CancelFunc
+	cancelled []bool
+}
+
+func (r *cancellingScriptRunner) Execute(ctx context.Context, text string, env, args []string, dir string) error {
+	r.cancelled = append(r.cancelled, ctx.Err() != nil)
+	if len(r.cancelled) == 1 {
+		r.cancel()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestRunDeferredCancelled(t *testing.T) {
+	runner, err := NewRunner(models.Tasks{{Name: "test", Script: "serve", Deferred: "cleanup"}}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scriptRunner := &cancellingScriptRunner{cancel: cancel}
+	runner.scriptRunner = scriptRunner
+	if err = runner.Run(ctx, "test", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to be cancelled got %v", err)
+	}
+	if len(scriptRunner.cancelled) != 2 || scriptRunner.cancelled[1] {
+		t.Fatalf("expected the deferred script to run without being cancelled got %v", scriptRunner.cancelled)
+	}
+}
+
+func TestRunSteps(t *testing.T) {
+	runner, err := NewRunner(models.Tasks{
+		{Name: "first", Script: "first"},
+		{Name: "second", Script: "second"},
+		{Name: "setup", Script: "setup"},
+		{Name: "all", DependsOn: []string{"setup"}, Steps: []string{"second", "first", "second"}},
+	}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptRunner := &mockScriptRunner{}
+	runner.scriptRunner = scriptRunner
+	err = runner.Run(context.Background(), "all", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "setup,second,first,second"
+	if got := strings.Join(scriptRunner.scripts, ","); got != expected {
+		t.Fatalf("expected scripts %s got %s", expected, got)
+	}
+}
+
+func TestRunSubset(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "setup", Script: "setup"},
+		{Name: "build", Script: "build", DependsOn: []string{"setup"}},
+		{Name: "test", Script: "test", DependsOn: []string{"build"}},
+		{Name: "release", Script: "release", DependsOn: []string{"test"}, Steps: []string{"build"}},
+	}
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{
+			name:     "given no options, should run every task",
+			expected: "setup,build,test,setup,build,release",
+		},
+		{
+			name:     "given no deps, should only run the task and its steps",
+			opts:     []Option{WithoutDependencies()},
+			expected: "build,release",
+		},
+		{
+			name:     "given only, should run the selected tasks in the graph",
+			opts:     []Option{WithOnly("build", "release")},
+			expected: "build,build,release",
+		},
+		{
+			name:     "given from, should run the tasks from it onwards",
+			opts:     []Option{WithFrom("test")},
+			expected: "test,setup,build,release",
+		},
+		{
+			name:     "given from the task being run, should skip the tasks it requires",
+			opts:     []Option{WithFrom("release")},
+			expected: "setup,build,release",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := NewRunner(tasks, t.TempDir(), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scriptRunner := &mockScriptRunner{}
+			runner.scriptRunner = scriptRunner
+			if err = runner.Run(context.Background(), "release", nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(scriptRunner.scripts, ","); got != tt.expected {
+				t.Fatalf("expected scripts %s got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunFromNotInRun(t *testing.T) {
+	runner, err := NewRunner(models.Tasks{
+		{Name: "build", Script: "build"},
+		{Name: "lint", Script: "lint"},
+	}, t.TempDir(), WithFrom("lint"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "build", nil); err == nil {
+		t.Fatal("expected an error for a task that is not run")
+	}
+}
+
+func TestRunParsingError(t *testing.T) {
+	runner, err := NewRunner(models.Tasks{
+		{Name: "broken", Script: "broken", ParsingError: "command block in task broken was not ended"},
+		{Name: "requires-broken", DependsOn: []string{"broken"}},
+		{Name: "ok", Script: "ok"},
+	}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptRunner := &mockScriptRunner{}
+	runner.scriptRunner = scriptRunner
+	if err = runner.Run(context.Background(), "ok", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"broken", "requires-broken"} {
+		err = runner.Run(context.Background(), name, nil)
+		if err == nil || !strings.Contains(err.Error(), "task broken has a parsing error") {
+			t.Fatalf("%s: expected a parsing error got %v", name, err)
+		}
+	}
+	if got := strings.Join(scriptRunner.scripts, ","); got != "ok" {
+		t.Fatalf("expected scripts ok got %s", got)
+	}
+}
+
+func TestRunForeach(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"services/api", "services/web"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "services", "README.md"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		task          models.Task
+		expectedItems []string
+		expectedDirs  []string
+	}{
+		{
+			name:          "given a list, should run once per item",
+			task:          models.Task{Name: "t", Script: "s", Foreach: []string{"a", "b"}},
+			expectedItems: []string{"a", "b"},
+			expectedDirs:  []string{dir, dir},
+		},
+		{
+			name:          "given a directory glob, should run once per directory",
+			task:          models.Task{Name: "t", Script: "s", Foreach: []string{"services/*/"}},
+			expectedItems: []string{filepath.Join("services", "api"), filepath.Join("services", "web")},
+			expectedDirs:  []string{dir, dir},
+		},
+		{
+			name:          "given a directory using the item, should run in each item",
+			task:          models.Task{Name: "t", Script: "s", Dir: "services/${XC_ITEM}", Foreach: []string{"api", "web"}},
+			expectedItems: []string{"api", "web"},
+			expectedDirs:  []string{filepath.Join(dir, "services", "api"), filepath.Join(dir, "services", "web")},
+		},
+		{
+			name:          "given parallel, should run once per item",
+			task:          models.Task{Name: "t", Script: "s", Foreach: []string{"a", "b", "c"}, ForeachParallel: true},
+			expectedItems: []string{"a", "b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := NewRunner(models.Tasks{tt.task}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scriptRunner := &itemScriptRunner{}
+			runner.scriptRunner = scriptRunner
+			if err := runner.Run(context.Background(), "t", nil); err != nil {
+				t.Fatal(err)
+			}
+			if tt.task.ForeachParallel {
+				sort.Strings(scriptRunner.items)
+			}
+			if got, want := strings.Join(scriptRunner.items, ","), strings.Join(tt.expectedItems, ","); got != want {
+				t.Fatalf("expected items %s got %s", want, got)
+			}
+			if tt.expectedDirs == nil {
+				return
+			}
+			if got, want := strings.Join(scriptRunner.dirs, ","), strings.Join(tt.expectedDirs, ","); got != want {
+				t.Fatalf("expected dirs %s got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestRunForeachParallelJobs(t *testing.T) {
+	for _, jobs := range []int{1, 2} {
+		runner, err := NewRunner(models.Tasks{
+			{Name: "t", Script: "s", Foreach: []string{"a", "b", "c", "d", "e"}, ForeachParallel: true},
+		}, t.TempDir(), WithJobs(jobs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &concurrencyScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		if err := runner.Run(context.Background(), "t", nil); err != nil {
+			t.Fatal(err)
+		}
+		if scriptRunner.calls != 5 {
+			t.Fatalf("expected every item to run got %d", scriptRunner.calls)
+		}
+		if scriptRunner.max != jobs {
+			t.Fatalf("expected %d items to run at once got %d", jobs, scriptRunner.max)
+		}
+	}
+}
+
+// concurrencyScriptRunner records the most scripts that ran at the same time.
+type concurrencyScriptRunner struct {
+	mu                  sync.Mutex
+	calls, running, max int
+}
+
+func (r *concurrencyScriptRunner) Execute(ctx context.Context, text string, env, args []string, dir string) error {
+	r.mu.Lock()
+	r.calls++
+	r.running++
+	if r.running > r.max {
+		r.max = r.running
+	}
+	r.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	r.mu.Lock()
+	r.running--
+	r.mu.Unlock()
+	return nil
+}
+
+type itemScriptRunner struct {
+	mu    sync.Mutex
+	items []string
+	dirs  []string
+}
+
+func (r *itemScriptRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range env {
+		if item, ok := strings.CutPrefix(e, "XC_ITEM="); ok {
+			r.items = append(r.items, item)
+		}
+	}
+	r.dirs = append(r.dirs, dir)
+	return nil
+}
+
+func TestRunExecutor(t *testing.T) {
+	httpRunner := &mockScriptRunner{}
+	runner, err := NewRunner(models.Tasks{
+		{Name: "health", Script: "GET http://localhost/health\n", Language: "http"},
+		{Name: "shell", Script: "echo hello\n", Language: "sh"},
+	}, t.TempDir(), WithExecutor("HTTP", httpRunner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptRunner := &mockScriptRunner{}
+	runner.scriptRunner = scriptRunner
+	for _, name := range []string{"health", "shell"} {
+		if err := runner.Run(context.Background(), name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if httpRunner.calls != 1 || httpRunner.scripts[0] != "GET http://localhost/health\n" {
+		t.Fatalf("expected the http executor to run the http script got %v", httpRunner.scripts)
+	}
+	if scriptRunner.calls != 1 || scriptRunner.scripts[0] != "echo hello\n" {
+		t.Fatalf("expected the script runner to run the sh script got %v", scriptRunner.scripts)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	runner, err := NewRunner(models.Tasks{
+		{Name: "migrate", Script: "create table t (id int);\n", Language: "sql"},
+		{Name: "all", DependsOn: []string{"migrate"}, Script: "echo done\n"},
+	}, t.TempDir(), WithDryRun(), WithExecutor("sql", &mockScriptRunner{returns: errors.New("should not run")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptRunner := &mockScriptRunner{}
+	runner.scriptRunner = scriptRunner
+	if err := runner.Run(context.Background(), "all", nil); err != nil {
+		t.Fatal(err)
+	}
+	if scriptRunner.calls != 0 {
+		t.Fatalf("expected no scripts to run got %d", scriptRunner.calls)
+	}
+}
+
+func TestRunDryRunRunsNoCommands(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "resolved")
+	runner, err := NewRunner(models.Tasks{
+		{
+			Name:          "deploy",
+			Script:        "echo $TOKEN\n",
+			Env:           []string{"TOKEN=$(touch " + marker + "; echo secret)", "PASSWORD=op://vault/item/field"},
+			RequiresTools: []string{"docker>=24"},
+		},
+	}, t.TempDir(), WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.toolChecker = &tools.Checker{
+		LookPath: func(name string) (string, error) {
+			t.Errorf("expected no tools to be looked up got %s", name)
+			return name, nil
+		},
+		Probe: func(ctx context.Context, path string) (string, error) {
+			t.Errorf("expected no tools to be run got %s", path)
+			return "", nil
+		},
+	}
+	scriptRunner := &mockScriptRunner{}
+	runner.scriptRunner = scriptRunner
+	if err = runner.Run(context.Background(), "deploy", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(marker); err == nil {
+		t.Fatal("expected the secret command not to run")
+	}
+	if scriptRunner.calls != 0 {
+		t.Fatalf("expected no scripts to run got %d", scriptRunner.calls)
+	}
+}
+
+func TestRunRequiresTools(t *testing.T) {
+	runner, err := NewRunner(models.Tasks{
+		{Name: "setup", Script: "setup"},
+		{Name: "build", DependsOn: []string{"setup"}, Script: "build", RequiresTools: []string{"docker>=24", "missing"}},
+	}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.toolChecker = &tools.Checker{
+		LookPath: func(name string) (string, error) {
+			if name == "missing" {
+				return "", errors.New("not found")
+			}
+			return name, nil
+		},
+		Probe: func(ctx context.Context, path string) (string, error) {
+			return "Docker version 20.10.7", nil
+		},
+	}
+	scriptRunner := &mockScriptRunner{}
+	runner.scriptRunner = scriptRunner
+	err = runner.Run(context.Background(), "build", nil)
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+	for _, msg := range []string{"docker 20.10.7 is installed, install docker 24 or later", "missing was not found"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected error to contain %q got %v", msg, err)
+		}
+	}
+	if scriptRunner.calls != 0 {
+		t.Fatalf("expected nothing to run got %v", scriptRunner.scripts)
+	}
+}
+
+func TestRunCIOnly(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "build", Script: "build"},
+		{Name: "deploy", DependsOn: []string{"build"}, Script: "deploy", CIOnly: true},
+		{Name: "reset-db", Script: "reset-db", LocalOnly: true},
+	}
+	tests := []struct {
+		name    string
+		task    string
+		inCI    bool
+		opts    []Option
+		scripts []string
+		err     string
+	}{
+		{name: "given a ci-only task in CI, should run", task: "deploy", inCI: true, scripts: []string{"build", "deploy"}},
+		{
+			name: "given a ci-only task locally, should fail before its dependencies run",
+			task: "deploy",
+			err:  "task deploy only runs in CI",
+		},
+		{name: "given a ci-only task in a local dry run, should not fail", task: "deploy", opts: []Option{WithDryRun()}},
+		{name: "given a local-only task locally, should run", task: "reset-db", scripts: []string{"reset-db"}},
+		{
+			name: "given a local-only task in CI, should fail",
+			task: "reset-db",
+			inCI: true,
+			err:  "task reset-db only runs locally, not in CI",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := NewRunner(tasks, t.TempDir(), append(tt.opts, WithCI(tt.inCI))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scriptRunner := &mockScriptRunner{}
+			runner.scriptRunner = scriptRunner
+			err = runner.Run(context.Background(), tt.task, nil)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q got %v", tt.err, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(scriptRunner.scripts, ","); got != strings.Join(tt.scripts, ",") {
+				t.Fatalf("expected scripts %v got %v", tt.scripts, scriptRunner.scripts)
+			}
+		})
+	}
+}
+
+type scriptRunnerFunc func(script string, env []string, dir string) error
+
+func (f scriptRunnerFunc) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	return f(text, env, dir)
+}
+
+func TestRunAssertOutputs(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "stale")
+	if err := os.WriteFile(stale, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		outputs     []models.OutputAssertion
+		expectError string
+	}{
+		{
+			name:    "given produced outputs, should succeed",
+			outputs: []models.OutputAssertion{{Path: "out/app", NonEmpty: true, Newer: true}, {Path: "out/empty"}},
+		},
+		{
+			name:        "given a missing output, should fail",
+			outputs:     []models.OutputAssertion{{Path: "out/missing"}},
+			expectError: "out/missing does not exist",
+		},
+		{
+			name:        "given an empty output, should fail",
+			outputs:     []models.OutputAssertion{{Path: "out/empty", NonEmpty: true}},
+			expectError: "out/empty is empty",
+		},
+		{
+			name:        "given an output that was not updated, should fail",
+			outputs:     []models.OutputAssertion{{Path: "stale", Newer: true}},
+			expectError: "stale was not updated",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			runner, err := NewRunner(models.Tasks{
+				{Name: "build", Script: "build", AssertOutputs: tt.outputs},
+			}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			runner.scriptRunner = scriptRunnerFunc(func(_ string, _ []string, dir string) error {
+				if err := os.MkdirAll(filepath.Join(dir, "out"), 0o755); err != nil {
+					return err
+				}
+				if err := os.WriteFile(filepath.Join(dir, "out", "app"), []byte("app"), 0o644); err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join(dir, "out", "empty"), nil, 0o644)
+			})
+			err = runner.Run(context.Background(), "build", nil)
+			if tt.expectError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Fatalf("expected error containing %q got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestRunDependencyEnv(t *testing.T) {
+	runner, err := NewRunner(models.Tasks{
+		{
+			Name:              "deploy",
+			Script:            "deploy",
+			Env:               []string{"ENVIRONMENT=dev", "TARGET=${ENVIRONMENT}-cluster"},
+			RequiredBehaviour: models.RequiredBehaviourOnce,
+		},
+		{
+			Name:   "deploy-all",
+			Env:    []string{"STAGE=production"},
+			Script: "done",
+			DependsOn: []string{
+				"deploy with ENVIRONMENT=staging",
+				"deploy with ENVIRONMENT=${STAGE}",
+				"deploy with ENVIRONMENT=staging",
+				"deploy",
+			},
+		},
+	}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var environments, targets []string
+	runner.scriptRunner = scriptRunnerFunc(func(script string, env []string, dir string) error {
+		if script == "deploy" {
+			v, _ := interpolate.EnvLookup(env)("ENVIRONMENT")
+			environments = append(environments, v)
+			v, _ = interpolate.EnvLookup(env)("TARGET")
+			targets = append(targets, v)
+		}
+		return nil
+	})
+	if err := runner.Run(context.Background(), "deploy-all", nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "staging,production,dev"
+	if got := strings.Join(environments, ","); got != expected {
+		t.Fatalf("expected environments %s got %s", expected, got)
+	}
+	// Values derived from a value set with 'with' use the value set rather than the default.
+	expected = "staging-cluster,production-cluster,dev-cluster"
+	if got := strings.Join(targets, ","); got != expected {
+		t.Fatalf("expected targets %s got %s", expected, got)
+	}
+}
+
+func TestRunChangedFiles(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{
+		{Name: "api", Script: "api", Dir: "services/api", Sources: []string{"**/*.go", "go.mod", "../../proto/*.proto"}},
+		{Name: "web", Script: "web", Sources: []string{"web/"}},
+		{Name: "lint", Script: "lint"},
+		{Name: "services", Steps: []string{"api", "web"}},
+		{Name: "docs", Steps: []string{"web"}},
+	}
+	tests := []struct {
+		name     string
+		task     string
+		changed  []string
+		expected string
+	}{
+		{
+			name:     "given a change to a source, should run the task",
+			task:     "services",
+			changed:  []string{"services/api/handlers/get.go"},
+			expected: "api",
+		},
+		{
+			name:     "given a change to a directory source, should run the task",
+			task:     "services",
+			changed:  []string{"web/index.html"},
+			expected: "web",
+		},
+		{
+			name:    "given no change to the sources of any step, should skip the task",
+			task:    "docs",
+			changed: []string{"services/api/go.mod"},
+		},
+		{
+			name:     "given a change outside of the directory of a task, should not match its sources",
+			task:     "services",
+			changed:  []string{"web/main.go"},
+			expected: "web",
+		},
+		{
+			name:     "given a source outside of the directory of a task, should match it",
+			task:     "api",
+			changed:  []string{"proto/api.proto"},
+			expected: "api",
+		},
+		{
+			name:     "given a task without sources, should always run",
+			task:     "lint",
+			expected: "lint",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var changed []string
+			for _, c := range tt.changed {
+				changed = append(changed, filepath.Join(dir, c))
+			}
+			runner, err := NewRunner(tasks, dir, WithChangedFiles(changed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			scriptRunner := &mockScriptRunner{}
+			runner.scriptRunner = scriptRunner
+			if err := runner.Run(context.Background(), tt.task, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(scriptRunner.scripts, ","); got != tt.expected {
+				t.Fatalf("expected scripts %q got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+type mockProvider struct {
+	events []string
+}
+
+func (p *mockProvider) Name() string { return "mock" }
+
+func (p *mockProvider) Group(w io.Writer, title string) func() {
+	p.events = append(p.events, "start "+title)
+	return func() { p.events = append(p.events, "end "+title) }
+}
+
+func TestRunOutputGroups(t *testing.T) {
+	provider := &mockProvider{}
+	runner, err := NewRunner(models.Tasks{
+		{Name: "setup", Script: "setup"},
+		{Name: "test", Script: "test", DependsOn: []string{"setup"}, Foreach: []string{"a", "b"}},
+	}, t.TempDir(), WithOutputGroups(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.scriptRunner = &mockScriptRunner{}
+	if err := runner.Run(context.Background(), "test", nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := "start setup,end setup,start test (a),end test (a),start test (b),end test (b)"
+	if got := strings.Join(provider.events, ","); got != expected {
+		t.Fatalf("expected %s got %s", expected, got)
+	}
+}
+
+type observerKey struct{}
+
+type mockObserver struct {
+	events []string
+}
+
+func (o *mockObserver) TaskStarted(ctx context.Context, task models.Task) context.Context {
+	parent, _ := ctx.Value(observerKey{}).(string)
+	o.events = append(o.events, "start "+task.Name+" parent="+parent)
+	return context.WithValue(ctx, observerKey{}, task.Name)
+}
+
+func (o *mockObserver) TaskFinished(ctx context.Context, task models.Task, err error) {
+	o.events = append(o.events, fmt.Sprintf("finish %s err=%v", task.Name, err))
+}
+
+func TestRunObserver(t *testing.T) {
+	observer := &mockObserver{}
+	runner, err := NewRunner(models.Tasks{
+		{Name: "dep", Script: "dep"},
+		{Name: "task", Script: "task", DependsOn: []string{"dep"}},
+	}, t.TempDir(), WithObserver(observer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.scriptRunner = &mockScriptRunner{}
+	err = runner.Run(context.Background(), "task", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"start task parent=",
+		"start dep parent=task",
+		"finish dep err=<nil>",
+		"finish task err=<nil>",
+	}
+	if strings.Join(observer.events, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected events %v got %v", expected, observer.events)
+	}
+}
+
+func TestRunExpansion(t *testing.T) {
+	tasks := models.Tasks{
+		{
+			Name:   "task",
+			Script: "somecmd",
+			Env:    []string{"BASE=${XC_TEST_UNSET:-app}", "FULL=$BASE/$$literal"},
+			Dir:    "${BASE}",
+		},
+	}
+	t.Run("given variables in env and dir, expand them", func(t *testing.T) {
+		dir := t.TempDir()
+		runner, err := NewRunner(tasks, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		if err = runner.Run(context.Background(), "task", nil); err != nil {
+			t.Fatal(err)
+		}
+		if !containsString(scriptRunner.env, "FULL=app/$literal") {
+			t.Fatalf("expected expanded env, got %v", scriptRunner.env)
+		}
+		if scriptRunner.dir != filepath.Join(dir, "app") {
+			t.Fatalf("expected expanded dir, got %s", scriptRunner.dir)
+		}
+	})
+	t.Run("given expansion is disabled, do not expand", func(t *testing.T) {
+		dir := t.TempDir()
+		runner, err := NewRunner(tasks, dir, WithoutExpansion())
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		if err = runner.Run(context.Background(), "task", nil); err != nil {
+			t.Fatal(err)
+		}
+		if !containsString(scriptRunner.env, "FULL=$BASE/$$literal") {
+			t.Fatalf("expected literal env, got %v", scriptRunner.env)
+		}
+		if scriptRunner.dir != filepath.Join(dir, "${BASE}") {
+			t.Fatalf("expected literal dir, got %s", scriptRunner.dir)
+		}
+	})
+}
+
+func TestRunResume(t *testing.T) {
+	dir := t.TempDir()
+	tasks := models.Tasks{
+		{Name: "test", Script: "test"},
+		{Name: "build", Script: "build"},
+		{Name: "publish", Script: "publish"},
+		{Name: "release", DependsOn: []string{"test", "build"}, Steps: []string{"publish", "test"}, Script: "release"},
+	}
+	newRunner := func(fail string, opts ...Option) (*Runner, *[]string) {
+		runner, err := NewRunner(tasks, dir, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var scripts []string
+		runner.scriptRunner = scriptRunnerFunc(func(script string, _ []string, _ string) error {
+			scripts = append(scripts, script)
+			if script == fail {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		return &runner, &scripts
+	}
+	first, _ := newRunner("publish")
+	if err := first.Run(context.Background(), "release", nil); err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	resumed, scripts := newRunner("", WithResume())
+	if err := resumed.Run(context.Background(), "release", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(*scripts, ","); got != "publish,test,release" {
+		t.Fatalf("expected scripts publish,test,release got %s", got)
+	}
+	if resumed.RunID() != first.RunID() {
+		t.Fatalf("expected run id %s got %s", first.RunID(), resumed.RunID())
+	}
+	again, scripts := newRunner("", WithResume())
+	if err := again.Run(context.Background(), "release", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(*scripts, ","); got != "test,build,publish,test,release" {
+		t.Fatalf("expected a successful run to remove the checkpoint, got scripts %s", got)
+	}
+}
+
+// concurrencyRecorder records the most scripts, of all scripts and of some scripts, that run at the same time.
+type concurrencyRecorder struct {
+	mu                     sync.Mutex
+	scripts                []string
+	running, max           int
+	grouped                map[string]bool
+	groupRunning, groupMax int
+}
+
+func (c *concurrencyRecorder) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	c.mu.Lock()
+	c.scripts = append(c.scripts, text)
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	if c.grouped[text] {
+		c.groupRunning++
+		if c.groupRunning > c.groupMax {
+			c.groupMax = c.groupRunning
+		}
+	}
+	c.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running--
+	if c.grouped[text] {
+		c.groupRunning--
+	}
+	if text == "fail" {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func TestRunJobs(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "migrate", Script: "migrate", ConcurrencyGroup: "database"},
+		{Name: "seed", Script: "seed", ConcurrencyGroup: "database"},
+		{Name: "build", Script: "build"},
+		{Name: "lint", Script: "lint"},
+		{Name: "setup", Script: "setup", RequiredBehaviour: models.RequiredBehaviourOnce},
+		{Name: "api", Script: "api", DependsOn: []string{"setup"}},
+		{Name: "web", Script: "web", DependsOn: []string{"setup"}},
+		{Name: "fail", Script: "fail"},
+	}
+	tests := []struct {
+		name         string
+		requires     []string
+		jobs         int
+		expectMax    int
+		expectErr    bool
+		expectScript int
+	}{
+		{
+			name:         "given one job, should run required tasks one at a time",
+			requires:     []string{"build", "lint", "migrate"},
+			jobs:         1,
+			expectMax:    1,
+			expectScript: 4,
+		},
+		{
+			name:         "given many jobs, should run required tasks in parallel",
+			requires:     []string{"build", "lint", "migrate"},
+			jobs:         4,
+			expectMax:    3,
+			expectScript: 4,
+		},
+		{
+			name:         "given fewer jobs than tasks, should limit the scripts running",
+			requires:     []string{"build", "lint", "migrate"},
+			jobs:         2,
+			expectMax:    2,
+			expectScript: 4,
+		},
+		{
+			name:         "given tasks in a concurrency group, should not run them in parallel",
+			requires:     []string{"migrate", "seed", "build"},
+			jobs:         4,
+			expectMax:    2,
+			expectScript: 4,
+		},
+		{
+			name:         "given a task required once by parallel tasks, should run it once",
+			requires:     []string{"api", "web"},
+			jobs:         4,
+			expectMax:    2,
+			expectScript: 4,
+		},
+		{
+			name:      "given a failing task, should fail",
+			requires:  []string{"fail", "build"},
+			jobs:      4,
+			expectMax: 2,
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ts := append(tasks[:len(tasks):len(tasks)], models.Task{Name: "all", Script: "all", DependsOn: tt.requires})
+			runner, err := NewRunner(ts, t.TempDir(), WithJobs(tt.jobs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := &concurrencyRecorder{grouped: map[string]bool{"migrate": true, "seed": true}}
+			runner.scriptRunner = recorder
+			err = runner.Run(context.Background(), "all", nil)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v got %v", tt.expectErr, err)
+			}
+			if recorder.max != tt.expectMax {
+				t.Fatalf("expected at most %d scripts running got %d", tt.expectMax, recorder.max)
+			}
+			if recorder.groupMax > 1 {
+				t.Fatalf("expected tasks in a concurrency group to run one at a time got %d", recorder.groupMax)
+			}
+			if !tt.expectErr && len(recorder.scripts) != tt.expectScript {
+				t.Fatalf("expected %d scripts got %v", tt.expectScript, recorder.scripts)
+			}
+		})
+	}
 }
@@ -0,0 +1,266 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+// sandbox restricts what the scripts of a task may access.
+type sandbox struct {
+	noNetwork bool
+	// allow are the only paths that may be written to, if not empty.
+	allow []string
+	// deny are paths that may be neither read nor written.
+	deny []string
+	// umask is the file mode creation mask of scripts, if not empty.
+	umask string
+	// user is who scripts run as, if not nil.
+	user *credential
+	// nice is added to the niceness of scripts, and ionice is their IO scheduling class if not empty.
+	// They are hints, ignored where they cannot be applied.
+	nice   int
+	ionice string
+}
+
+// credential is a user and group that scripts run as.
+type credential struct {
+	uid, gid int
+	// known is whether the user is in the user database, so that its supplementary groups can be set.
+	known bool
+}
+
+// lookupCredential returns the credential of a user name or uid[:gid].
+func lookupCredential(s string) (*credential, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("running as another user is not supported on windows")
+	}
+	name, group, hasGroup := strings.Cut(s, ":")
+	u, err := user.Lookup(name)
+	if err != nil {
+		if _, convErr := strconv.Atoi(name); convErr != nil {
+			return nil, fmt.Errorf("failed to find user %s: %w", name, err)
+		}
+		if u, err = user.LookupId(name); err != nil {
+			u = &user.User{Uid: name, Gid: name}
+		}
+	}
+	c := &credential{known: u.Username != ""}
+	if c.uid, err = strconv.Atoi(u.Uid); err != nil {
+		return nil, fmt.Errorf("invalid uid %s", u.Uid)
+	}
+	gid := u.Gid
+	if hasGroup {
+		gid = group
+		if g, err := user.LookupGroup(group); err == nil {
+			gid = g.Gid
+		}
+	}
+	if c.gid, err = strconv.Atoi(gid); err != nil {
+		return nil, fmt.Errorf("failed to find group %s", gid)
+	}
+	return c, nil
+}
+
+// empty reports whether s does not restrict scripts at all.
+func (s sandbox) empty() bool {
+	return !s.noNetwork && !s.restrictsPaths() && s.umask == "" && s.user == nil && s.nice == 0 && s.ionice == ""
+}
+
+// restrictsPaths reports whether s restricts access to the filesystem.
+func (s sandbox) restrictsPaths() bool {
+	return len(s.allow) > 0 || len(s.deny) > 0
+}
+
+// canOpen reports whether path may be opened with flag, path must be absolute.
+func (s sandbox) canOpen(path string, flag int) bool {
+	// Devices such as /dev/null and /dev/stderr are always writable.
+	dev := strings.HasPrefix(path, "/dev/")
+	path = resolvePath(path)
+	for _, d := range s.deny {
+		if within(d, path) {
+			return false
+		}
+	}
+	if len(s.allow) == 0 || flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return true
+	}
+	for _, a := range s.allow {
+		if within(a, path) {
+			return true
+		}
+	}
+	return dev
+}
+
+// within reports whether path is dir or inside it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath returns path with symlinks resolved, if it or its parent directory exists,
+// so that paths are compared as the sandbox sees them, e.g. /tmp is /private/tmp on macOS.
+func resolvePath(path string) string {
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		return p
+	}
+	if p, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(p, filepath.Base(path))
+	}
+	return path
+}
+
+// sandboxPaths returns paths relative to dir as absolute paths, ~ is the home directory.
+func sandboxPaths(paths []string, dir string) ([]string, error) {
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p == "~" || strings.HasPrefix(p, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand %s: %w", p, err)
+			}
+			p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resolvePath(abs))
+	}
+	return result, nil
+}
+
+type sandboxKey struct{}
+
+// withSandbox returns a context in which scripts run in s.
+func withSandbox(ctx context.Context, s sandbox) context.Context {
+	return context.WithValue(ctx, sandboxKey{}, s)
+}
+
+// withoutNetwork returns a context in which scripts run without network access.
+func withoutNetwork(ctx context.Context) context.Context {
+	s := sandboxFrom(ctx)
+	s.noNetwork = true
+	return withSandbox(ctx, s)
+}
+
+func sandboxFrom(ctx context.Context) sandbox {
+	s, _ := ctx.Value(sandboxKey{}).(sandbox)
+	return s
+}
+
+// networkDisabled reports whether scripts run with ctx must not have network access.
+func networkDisabled(ctx context.Context) bool {
+	return sandboxFrom(ctx).noNetwork
+}
+
+// sandboxArgs returns args prefixed with the command that runs them in the sandbox of ctx, if there is one.
+func sandboxArgs(ctx context.Context, args []string) ([]string, error) {
+	s := sandboxFrom(ctx)
+	if s.empty() {
+		return args, nil
+	}
+	// The scheduling of the command is set first, as the commands after it keep it.
+	prefix := schedulingPrefix(s)
+	if s.umask != "" {
+		// The umask is set by a shell, as it cannot be set for a single child process.
+		prefix = append(prefix, "/bin/sh", "-c", "umask "+s.umask+` && exec "$@"`, "sh")
+	}
+	if s.user != nil {
+		p, err := userPrefix(*s.user)
+		if err != nil {
+			return nil, err
+		}
+		prefix = append(prefix, p...)
+	}
+	var (
+		p   []string
+		err error
+	)
+	switch {
+	case s.restrictsPaths():
+		p, err = sandboxPrefix(s)
+	case s.noNetwork:
+		p, err = noNetworkPrefix()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(append(prefix, p...), args...), nil
+}
+
+// sandboxCmd changes cmd to run in the sandbox of ctx, if there is one.
+func sandboxCmd(ctx context.Context, cmd *exec.Cmd) error {
+	if sandboxFrom(ctx).empty() {
+		return nil
+	}
+	args, err := sandboxArgs(ctx, append([]string{cmd.Path}, cmd.Args[1:]...))
+	if err != nil {
+		return err
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to run in a sandbox: %w", err)
+	}
+	cmd.Path, cmd.Args = path, args
+	return nil
+}
+
+// sandboxChown gives the user of the sandbox of ctx, if there is one, ownership of a file xc created for a script.
+func sandboxChown(ctx context.Context, path string) error {
+	if u := sandboxFrom(ctx).user; u != nil {
+		return os.Lchown(path, u.uid, u.gid)
+	}
+	return nil
+}
+
+// sandboxOpenHandler applies the sandbox of the context of a script to the files opened by the built-in shell,
+// such as in redirections, which are not run by a command that can be sandboxed.
+// Files it creates are given the mode and owner they would have if created by the user and umask of the sandbox.
+func sandboxOpenHandler(next interp.OpenHandlerFunc) interp.OpenHandlerFunc {
+	return func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+		s := sandboxFrom(ctx)
+		if s.empty() {
+			return next(ctx, path, flag, perm)
+		}
+		abs := path
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(interp.HandlerCtx(ctx).Dir, path)
+		}
+		if s.restrictsPaths() && !s.canOpen(abs, flag) {
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrPermission}
+		}
+		_, statErr := os.Lstat(abs)
+		f, err := next(ctx, path, flag, perm)
+		if err != nil || flag&os.O_CREATE == 0 || !errors.Is(statErr, fs.ErrNotExist) {
+			return f, err
+		}
+		if s.umask != "" {
+			mask, _ := strconv.ParseUint(s.umask, 8, 32)
+			err = os.Chmod(abs, perm&^os.FileMode(mask))
+		}
+		if s.user != nil && err == nil {
+			err = os.Lchown(abs, s.user.uid, s.user.gid)
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+}
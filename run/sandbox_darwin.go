@@ -0,0 +1,54 @@
+//go:build darwin
+
+package run
+
+import (
+	"strconv"
+	"strings"
+)
+
+// noNetworkProfile is a sandbox profile that allows everything but network access.
+const noNetworkProfile = "(version 1)(allow default)(deny network*)"
+
+// noNetworkPrefix runs a command with sandbox-exec, denying network access.
+func noNetworkPrefix() ([]string, error) {
+	return []string{"/usr/bin/sandbox-exec", "-p", noNetworkProfile}, nil
+}
+
+// userPrefix runs a command as another user with sudo, which must not prompt for a password.
+func userPrefix(c credential) ([]string, error) {
+	return []string{"/usr/bin/sudo", "-n", "-u", "#" + strconv.Itoa(c.uid), "-g", "#" + strconv.Itoa(c.gid), "--"}, nil
+}
+
+// schedulingPrefix runs a command with the niceness of s, macOS has no IO scheduling classes so ionice is ignored.
+func schedulingPrefix(s sandbox) []string {
+	if s.nice == 0 {
+		return nil
+	}
+	return []string{"/usr/bin/nice", "-n", strconv.Itoa(s.nice)}
+}
+
+// sandboxPrefix runs a command with sandbox-exec, denying writes outside of the allowed paths
+// and access to denied paths.
+func sandboxPrefix(s sandbox) ([]string, error) {
+	var profile strings.Builder
+	profile.WriteString("(version 1)(allow default)")
+	if len(s.allow) > 0 {
+		profile.WriteString(`(deny file-write*)(allow file-write* (subpath "/dev")`)
+		for _, p := range s.allow {
+			profile.WriteString(" (subpath " + strconv.Quote(p) + ")")
+		}
+		profile.WriteString(")")
+	}
+	if len(s.deny) > 0 {
+		profile.WriteString("(deny file-read* file-write*")
+		for _, p := range s.deny {
+			profile.WriteString(" (subpath " + strconv.Quote(p) + ")")
+		}
+		profile.WriteString(")")
+	}
+	if s.noNetwork {
+		profile.WriteString("(deny network*)")
+	}
+	return []string{"/usr/bin/sandbox-exec", "-p", profile.String()}, nil
+}
@@ -0,0 +1,85 @@
+//go:build linux
+
+package run
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/joerdav/xc/models"
+)
+
+// noNetworkPrefix runs a command in new user and network namespaces, which have no network interfaces but loopback.
+func noNetworkPrefix() ([]string, error) {
+	if _, err := exec.LookPath("unshare"); err != nil {
+		return nil, fmt.Errorf("running without network requires unshare from util-linux: %w", err)
+	}
+	return []string{"unshare", "--user", "--map-root-user", "--net"}, nil
+}
+
+// userPrefix runs a command as another user with setpriv from util-linux, which requires xc to be privileged.
+func userPrefix(c credential) ([]string, error) {
+	if _, err := exec.LookPath("setpriv"); err != nil {
+		return nil, fmt.Errorf("running as another user requires setpriv from util-linux: %w", err)
+	}
+	groups := "--clear-groups"
+	if c.known {
+		groups = "--init-groups"
+	}
+	return []string{"setpriv", "--reuid=" + strconv.Itoa(c.uid), "--regid=" + strconv.Itoa(c.gid), groups, "--"}, nil
+}
+
+// schedulingPrefix runs a command with the niceness and IO scheduling class of s, ionice requires util-linux.
+func schedulingPrefix(s sandbox) []string {
+	var args []string
+	if s.ionice != "" {
+		if _, err := exec.LookPath("ionice"); err == nil {
+			class, level, _ := models.ParseIONice(s.ionice)
+			args = append(args, "ionice", "-c", strconv.Itoa(ioniceClasses[class]))
+			if level >= 0 {
+				args = append(args, "-n", strconv.Itoa(level))
+			}
+		}
+	}
+	if s.nice != 0 {
+		args = append(args, "nice", "-n", strconv.Itoa(s.nice))
+	}
+	return args
+}
+
+// ioniceClasses are the numbers ionice gives the IO scheduling classes.
+var ioniceClasses = map[string]int{models.IONiceRealtime: 1, models.IONiceBestEffort: 2, models.IONiceIdle: 3}
+
+// sandboxPrefix runs a command with bubblewrap, in a mount namespace in which the filesystem is read-only
+// but for the allowed paths, and denied paths are replaced with empty directories or files.
+func sandboxPrefix(s sandbox) ([]string, error) {
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		return nil, fmt.Errorf("restricting the paths of a task requires bwrap from bubblewrap: %w", err)
+	}
+	args := []string{"bwrap", "--die-with-parent"}
+	if len(s.allow) > 0 {
+		args = append(args, "--ro-bind", "/", "/", "--dev", "/dev")
+		for _, p := range s.allow {
+			args = append(args, "--bind-try", p, p)
+		}
+	} else {
+		args = append(args, "--bind", "/", "/", "--dev", "/dev")
+	}
+	for _, p := range s.deny {
+		fi, err := os.Stat(p)
+		switch {
+		case err != nil:
+			continue
+		case fi.IsDir():
+			args = append(args, "--tmpfs", p)
+		default:
+			args = append(args, "--ro-bind", os.DevNull, p)
+		}
+	}
+	if s.noNetwork {
+		args = append(args, "--unshare-net")
+	}
+	return append(args, "--"), nil
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package run
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestRunAsUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("running as another user is only tested as root")
+	}
+	if _, err := exec.LookPath("setpriv"); err != nil {
+		t.Skip("setpriv is not installed")
+	}
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{{
+		Name:   "build",
+		Script: "id -u > $XC_TMPDIR/uid\ncp $XC_TMPDIR/uid uid\necho app > redirected\n",
+		User:   "65534:65534",
+	}}
+	runner, err := NewRunner(tasks, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "build", nil); err != nil {
+		t.Fatal(err)
+	}
+	uid, err := os.ReadFile(filepath.Join(dir, "uid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(uid)) != "65534" {
+		t.Errorf("expected the script to run as 65534 got %s", uid)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "redirected"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && st.Uid != 65534 {
+		t.Errorf("expected redirected to be owned by 65534 got %d", st.Uid)
+	}
+}
+
+func TestRunNice(t *testing.T) {
+	if _, err := exec.LookPath("nice"); err != nil {
+		t.Skip("nice is not installed")
+	}
+	niceness, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Getpriority returns 20 minus the niceness on Linux.
+	expected := 20 - niceness + 5
+	if expected > 19 {
+		expected = 19
+	}
+	dir := t.TempDir()
+	tasks := models.Tasks{{Name: "watch", Script: "nice > nice\n", Nice: 5}}
+	runner, err := NewRunner(tasks, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "watch", nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "nice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(got)) != strconv.Itoa(expected) {
+		t.Fatalf("expected the script to run with niceness %d got %s", expected, got)
+	}
+}
+
+func TestSchedulingPrefix(t *testing.T) {
+	if _, err := exec.LookPath("ionice"); err != nil {
+		t.Skip("ionice is not installed")
+	}
+	got := schedulingPrefix(sandbox{nice: 10, ionice: "best-effort:7"})
+	expected := []string{"ionice", "-c", "2", "-n", "7", "nice", "-n", "10"}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected %v got %v", expected, got)
+	}
+	got = schedulingPrefix(sandbox{ionice: "idle"})
+	if strings.Join(got, " ") != "ionice -c 3" {
+		t.Fatalf("expected ionice -c 3 got %v", got)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+
+package run
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func noNetworkPrefix() ([]string, error) {
+	return nil, fmt.Errorf("running without network is not supported on %s", runtime.GOOS)
+}
+
+func userPrefix(credential) ([]string, error) {
+	return nil, fmt.Errorf("running as another user is not supported on %s", runtime.GOOS)
+}
+
+// schedulingPrefix ignores the niceness and IO scheduling class of s, as they are only hints.
+func schedulingPrefix(sandbox) []string {
+	return nil
+}
+
+func sandboxPrefix(sandbox) ([]string, error) {
+	return nil, fmt.Errorf("restricting the paths of a task is not supported on %s", runtime.GOOS)
+}
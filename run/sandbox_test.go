@@ -0,0 +1,154 @@
+package run
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestSandboxArgs(t *testing.T) {
+	args := []string{"go", "generate", "./..."}
+	got, err := sandboxArgs(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, args) {
+		t.Fatalf("expected args to be unchanged got %v", got)
+	}
+	if runtime.GOOS != "linux" {
+		t.Skip("sandbox prefix is only tested on linux")
+	}
+	if _, err = exec.LookPath("unshare"); err != nil {
+		t.Skip("unshare is not installed")
+	}
+	got, err = sandboxArgs(withoutNetwork(context.Background()), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"unshare", "--user", "--map-root-user", "--net", "go", "generate", "./..."}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v got %v", expected, got)
+	}
+}
+
+func TestHTTPWithoutNetwork(t *testing.T) {
+	err := newHTTPRunner().Execute(withoutNetwork(context.Background()), "GET http://localhost", nil, nil, "")
+	if err == nil {
+		t.Fatal("expected http blocks to fail without network access")
+	}
+}
+
+func TestSandboxCanOpen(t *testing.T) {
+	dir := t.TempDir()
+	s := sandbox{
+		allow: []string{resolvePath(filepath.Join(dir, "build"))},
+		deny:  []string{resolvePath(filepath.Join(dir, "build", "secrets")), resolvePath(filepath.Join(dir, ".env"))},
+	}
+	tests := []struct {
+		path   string
+		flag   int
+		expect bool
+	}{
+		{path: "README.md", flag: os.O_RDONLY, expect: true},
+		{path: "README.md", flag: os.O_WRONLY | os.O_TRUNC},
+		{path: "build", flag: os.O_RDONLY, expect: true},
+		{path: "build/app", flag: os.O_WRONLY | os.O_CREATE, expect: true},
+		{path: "build/../app", flag: os.O_RDWR},
+		{path: "build/secrets/key", flag: os.O_RDONLY},
+		{path: ".env", flag: os.O_RDONLY},
+		{path: "/dev/null", flag: os.O_WRONLY, expect: true},
+	}
+	for _, tt := range tests {
+		path := tt.path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if got := s.canOpen(path, tt.flag); got != tt.expect {
+			t.Errorf("canOpen(%s, %d) want=%v got=%v", tt.path, tt.flag, tt.expect, got)
+		}
+	}
+}
+
+func TestRunSandboxedPaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		task      models.Task
+		noSandbox bool
+		expectErr bool
+	}{
+		{
+			name: "given a write to an allowed path, should succeed",
+			task: models.Task{
+				Name: "build", Script: "echo app > build/app\necho log > $XC_TMPDIR/log\n", AllowPaths: []string{"build"},
+			},
+		},
+		{
+			name:      "given a write outside of the allowed paths, should fail",
+			task:      models.Task{Name: "build", Script: "echo app > app\n", AllowPaths: []string{"build"}},
+			expectErr: true,
+		},
+		{
+			name:      "given a read of a denied path, should fail",
+			task:      models.Task{Name: "build", Script: "read -r secret < .env\n", DenyPaths: []string{".env"}},
+			expectErr: true,
+		},
+		{
+			name:      "given no sandbox, should ignore the allowed paths",
+			task:      models.Task{Name: "build", Script: "echo app > app\n", AllowPaths: []string{"build"}},
+			noSandbox: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(dir, "build"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("secret\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			var opts []Option
+			if tt.noSandbox {
+				opts = append(opts, WithoutSandbox())
+			}
+			runner, err := NewRunner(models.Tasks{tt.task}, dir, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = runner.Run(context.Background(), "build", nil)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestRunUmask(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("umask is not supported on windows")
+	}
+	dir := t.TempDir()
+	tasks := models.Tasks{{Name: "build", Script: "echo app > redirected\ntouch touched\n", Umask: "077"}}
+	runner, err := NewRunner(tasks, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "build", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"redirected", "touched"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0o600 {
+			t.Errorf("%s: expected mode 0600 got %o", name, fi.Mode().Perm())
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+// scheduler limits how many scripts a Runner runs at once,
+// and stops tasks in the same concurrency group from running at the same time.
+//
+// When more scripts are waiting than there are free slots, the task with the highest priority
+// starts first, then the task that took longest in previous runs, so long tasks do not hold up the run.
+type scheduler struct {
+	mu        sync.Mutex
+	free      int
+	waiting   []*waiter
+	groups    map[string]chan struct{}
+	durations map[string]time.Duration
+	// path is where durations are saved, empty if they are not.
+	path string
+}
+
+type waiter struct {
+	priority int
+	duration time.Duration
+	ready    chan struct{}
+}
+
+// before reports whether w should start before o.
+func (w *waiter) before(o *waiter) bool {
+	if w.priority != o.priority {
+		return w.priority > o.priority
+	}
+	return w.duration > o.duration
+}
+
+// newScheduler returns a scheduler that runs up to jobs scripts at once.
+// The durations of scripts are recorded in the state directory of dir, unless dir is empty.
+func newScheduler(jobs int, dir string) *scheduler {
+	if jobs < 1 {
+		jobs = 1
+	}
+	s := &scheduler{
+		free:      jobs,
+		groups:    map[string]chan struct{}{},
+		durations: map[string]time.Duration{},
+	}
+	if dir == "" {
+		return s
+	}
+	s.path = durationsPath(dir)
+	s.durations = TaskDurations(dir)
+	return s
+}
+
+func durationsPath(dir string) string {
+	return filepath.Join(StateDir(dir), "durations.json")
+}
+
+// TaskDurations returns how long the script of each task in dir took the last time it ran.
+func TaskDurations(dir string) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	if b, err := os.ReadFile(durationsPath(dir)); err == nil {
+		// The durations are only informative, so if they cannot be read they are ignored.
+		_ = json.Unmarshal(b, &durations)
+	}
+	return durations
+}
+
+func (s *scheduler) group(name string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.groups[name]
+	if !ok {
+		g = make(chan struct{}, 1)
+		s.groups[name] = g
+	}
+	return g
+}
+
+// acquire blocks until the script of task can run, release must be called once it has finished.
+//
+// The concurrency group is acquired before a slot, so a task waiting for its group does not hold a slot.
+func (s *scheduler) acquire(ctx context.Context, task models.Task) (release func(), err error) {
+	var g chan struct{}
+	if task.ConcurrencyGroup != "" {
+		g = s.group(task.ConcurrencyGroup)
+		select {
+		case g <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err = s.acquireSlot(ctx, task); err != nil {
+		if g != nil {
+			<-g
+		}
+		return nil, err
+	}
+	start := time.Now()
+	return func() {
+		s.releaseSlot(task, time.Since(start))
+		if g != nil {
+			<-g
+		}
+	}, nil
+}
+
+// acquireExtra blocks until another script of a task that already holds a slot can run,
+// such as an item of a parallel foreach task, release must be called once it has finished.
+func (s *scheduler) acquireExtra(ctx context.Context, task models.Task) (release func(), err error) {
+	if err = s.acquireSlot(ctx, task); err != nil {
+		return nil, err
+	}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.next()
+	}, nil
+}
+
+func (s *scheduler) acquireSlot(ctx context.Context, task models.Task) error {
+	s.mu.Lock()
+	if s.free > 0 && len(s.waiting) == 0 {
+		s.free--
+		s.mu.Unlock()
+		return nil
+	}
+	w := &waiter{priority: task.Priority, duration: s.durations[task.Name], ready: make(chan struct{})}
+	s.waiting = append(s.waiting, w)
+	s.mu.Unlock()
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, o := range s.waiting {
+		if o == w {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			return ctx.Err()
+		}
+	}
+	// The slot was handed over as the context was cancelled, so pass it on.
+	s.next()
+	return ctx.Err()
+}
+
+func (s *scheduler) releaseSlot(task models.Task, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path != "" && task.Script != "" {
+		s.durations[task.Name] = d
+		s.save()
+	}
+	s.next()
+}
+
+// next starts the waiting task that should start first, or frees a slot if none are waiting.
+func (s *scheduler) next() {
+	if len(s.waiting) == 0 {
+		s.free++
+		return
+	}
+	first := 0
+	for i, w := range s.waiting {
+		if w.before(s.waiting[first]) {
+			first = i
+		}
+	}
+	w := s.waiting[first]
+	s.waiting = append(s.waiting[:first], s.waiting[first+1:]...)
+	close(w.ready)
+}
+
+func (s *scheduler) save() {
+	b, err := json.Marshal(s.durations)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(s.path, b, 0o644)
+}
@@ -0,0 +1,102 @@
+package run
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestSchedulerOrder(t *testing.T) {
+	s := newScheduler(2, t.TempDir())
+	s.durations = map[string]time.Duration{"slow": time.Minute, "fast": time.Second}
+	var releases []func()
+	for _, name := range []string{"first", "second"} {
+		release, err := s.acquire(context.Background(), models.Task{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+	waiting := []models.Task{
+		{Name: "fast"},
+		{Name: "low", Priority: models.PriorityLow},
+		{Name: "slow"},
+		{Name: "high", Priority: models.PriorityHigh},
+		{Name: "cancelled"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	for i, task := range waiting {
+		task := task
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			taskCtx := context.Background()
+			if task.Name == "cancelled" {
+				taskCtx = ctx
+			}
+			release, err := s.acquire(taskCtx, task)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			order = append(order, task.Name)
+			mu.Unlock()
+			release()
+		}()
+		// Wait for the task to queue so the order it is queued in is known.
+		for {
+			s.mu.Lock()
+			n := len(s.waiting)
+			s.mu.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	cancel()
+	releases[0]()
+	wg.Wait()
+	releases[1]()
+	expected := []string{"high", "slow", "fast", "low"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Fatalf("expected tasks to start in order %v got %v", expected, order)
+	}
+	if s.free != 2 {
+		t.Fatalf("expected 2 free slots got %d", s.free)
+	}
+}
+
+func TestSchedulerDurations(t *testing.T) {
+	dir := t.TempDir()
+	s := newScheduler(2, dir)
+	release, err := s.acquire(context.Background(), models.Task{Name: "build", Script: "go build"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, ok := newScheduler(2, dir).durations["build"]; !ok {
+		t.Fatal("expected the duration of build to be saved")
+	}
+	if _, ok := TaskDurations(dir)["build"]; !ok {
+		t.Fatal("expected the duration of build to be read")
+	}
+	s = newScheduler(1, "")
+	release, err = s.acquire(context.Background(), models.Task{Name: "test", Script: "go test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, ok := TaskDurations(dir)["test"]; ok {
+		t.Fatal("expected durations not to be saved without a directory")
+	}
+}
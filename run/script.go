@@ -0,0 +1,57 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// sourcer is implemented by ScriptRunners that change a script before running it,
+// Source returns the script as it is run with env.
+type sourcer interface {
+	Source(ctx context.Context, text string, env []string) (string, error)
+}
+
+// Script returns the script of the named task as it would be run with inputs: with a shebang and the shell options
+// for shell scripts, the package clause for go and the variables expanded for http requests.
+// Scripts in other languages are returned as they are.
+func (r *Runner) Script(name string, inputs []string) (string, error) {
+	task, ok := r.tasks.Get(name)
+	if !ok {
+		return "", fmt.Errorf("task %s not found", name)
+	}
+	if task.ParsingError != "" {
+		return "", &parsingError{task: name, err: task.ParsingError}
+	}
+	if task.Script == "" {
+		return "", fmt.Errorf("task %s has no script", name)
+	}
+	env, err := r.Environment(name, inputs)
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	if opts := task.ShellOpts; opts != nil {
+		ctx = withShellOpts(ctx, opts)
+	} else if r.shellOpts != nil {
+		ctx = withShellOpts(ctx, r.shellOpts)
+	}
+	s, ok := r.scriptRunnerFor(task.Language).(sourcer)
+	if !ok {
+		return task.Script, nil
+	}
+	return s.Source(ctx, task.Script, env)
+}
+
+// shebang returns the shebang line that runs a script with shell, such as bash -euo pipefail.
+func shebang(shell []string) string {
+	switch {
+	case filepath.IsAbs(shell[0]):
+		return "#!" + strings.Join(shell, " ") + "\n"
+	case len(shell) > 1:
+		// env only splits the arguments of a shebang with -S.
+		return "#!/usr/bin/env -S " + strings.Join(shell, " ") + "\n"
+	}
+	return "#!/usr/bin/env " + shell[0] + "\n"
+}
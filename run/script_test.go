@@ -0,0 +1,85 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		task     models.Task
+		inputs   []string
+		opts     []Option
+		expected string
+	}{
+		{
+			name:     "given a shell script, should add a shebang and the default options",
+			task:     models.Task{Script: "go test ./...\n"},
+			expected: "#!/usr/bin/env bash\nset -o errexit\nset -o xtrace\ngo test ./...\n",
+		},
+		{
+			name:     "given shell-opts, should use them",
+			task:     models.Task{Script: "#!/bin/bash\nfind . | wc -l\n", ShellOpts: []string{"errexit", "pipefail"}},
+			expected: "#!/bin/bash\nset -o errexit\nset -o pipefail\nfind . | wc -l\n",
+		},
+		{
+			name:     "given a configured shell, should use it as the shebang",
+			task:     models.Task{Script: "go test ./...\n"},
+			opts:     []Option{WithShell("bash -eu"), WithShellOpts([]string{"pipefail"})},
+			expected: "#!/usr/bin/env -S bash -eu\nset -o pipefail\ngo test ./...\n",
+		},
+		{
+			name:     "given another shebang, should return the script",
+			task:     models.Task{Script: "#!/usr/bin/env python\nprint(1)\n"},
+			expected: "#!/usr/bin/env python\nprint(1)\n",
+		},
+		{
+			name:     "given go, should add the package clause",
+			task:     models.Task{Script: "func main() {}\n", Language: "go"},
+			expected: "package main\n\nfunc main() {}\n",
+		},
+		{
+			name: "given http, should expand the request",
+			task: models.Task{
+				Script: "GET ${HOST}/items/${ID}\n", Language: "http",
+				Env: []string{"HOST=http://localhost"}, Inputs: []string{"ID"},
+			},
+			inputs:   []string{"7"},
+			expected: "GET http://localhost/items/7\n",
+		},
+		{
+			name:     "given sql, should return the statements",
+			task:     models.Task{Script: "select 1;\n", Language: "sql"},
+			expected: "select 1;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.task.Name = "task"
+			runner, err := NewRunner(models.Tasks{tt.task}, t.TempDir(), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			script, err := runner.Script("task", tt.inputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if script != tt.expected {
+				t.Fatalf("expected %q got %q", tt.expected, script)
+			}
+		})
+	}
+}
+
+func TestScriptNoScript(t *testing.T) {
+	tasks := models.Tasks{{Name: "all", DependsOn: []string{"build"}}, {Name: "build", Script: "go build"}}
+	runner, err := NewRunner(tasks, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = runner.Script("all", nil); err == nil {
+		t.Fatal("expected an error for a task without a script")
+	}
+}
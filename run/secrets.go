@@ -0,0 +1,154 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// SecretResolver resolves Env values that reference a secret held outside of the task file.
+type SecretResolver interface {
+	// Resolve returns the secret referenced by ref, commands it runs are run in dir, the directory of the task.
+	// ok will be false if ref is not a reference handled by the resolver.
+	Resolve(ctx context.Context, ref, dir string, env []string) (value string, ok bool, err error)
+}
+
+// SecretObserver is an Observer that is also sent the values of the secrets resolved for a task,
+// so that they can be masked in the output it keeps.
+type SecretObserver interface {
+	Observer
+	TaskSecrets(ctx context.Context, task models.Task, secrets []string)
+}
+
+// SecretResolvers is a chain of SecretResolver, the first one to handle a reference wins.
+type SecretResolvers []SecretResolver
+
+// Resolve implements SecretResolver.
+func (rs SecretResolvers) Resolve(ctx context.Context, ref, dir string, env []string) (string, bool, error) {
+	for _, r := range rs {
+		v, ok, err := r.Resolve(ctx, ref, dir, env)
+		if ok || err != nil {
+			return v, ok, err
+		}
+	}
+	return "", false, nil
+}
+
+// DefaultSecretResolvers returns the resolvers used when none are configured:
+//   - `op://vault/item/field` is read using the 1Password CLI.
+//   - `pass://path/to/secret` is read using pass, only the first line is used.
+//   - `$(command)` is replaced with the output of the command.
+func DefaultSecretResolvers() SecretResolvers {
+	return SecretResolvers{
+		prefixResolver{prefix: "op://", command: func(ref string) []string { return []string{"op", "read", ref} }},
+		prefixResolver{prefix: "pass://", command: func(ref string) []string {
+			return []string{"pass", "show", strings.TrimPrefix(ref, "pass://")}
+		}, firstLine: true},
+		commandResolver{},
+	}
+}
+
+type prefixResolver struct {
+	prefix    string
+	command   func(ref string) []string
+	firstLine bool
+}
+
+//nolint:gosec // the command is composed of a fixed binary and a reference from the task file
+func (r prefixResolver) Resolve(ctx context.Context, ref, dir string, env []string) (string, bool, error) {
+	if !strings.HasPrefix(ref, r.prefix) {
+		return "", false, nil
+	}
+	args := r.command(ref)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", true, fmt.Errorf("failed to resolve secret %s using %s: %w: %s",
+			ref, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	v := stdout.String()
+	if r.firstLine {
+		v, _, _ = strings.Cut(v, "\n")
+	}
+	return strings.TrimRight(v, "\r\n"), true, nil
+}
+
+type commandResolver struct{}
+
+func (commandResolver) Resolve(ctx context.Context, ref, dir string, env []string) (string, bool, error) {
+	if !strings.HasPrefix(ref, "$(") || !strings.HasSuffix(ref, ")") {
+		return "", false, nil
+	}
+	text := strings.TrimSuffix(strings.TrimPrefix(ref, "$("), ")")
+	file, err := syntax.NewParser().Parse(strings.NewReader(text), "")
+	if err != nil {
+		return "", true, fmt.Errorf("failed to parse secret command %s: %w", ref, err)
+	}
+	var stdout bytes.Buffer
+	runner, err := interp.New(
+		interp.Env(expand.ListEnviron(env...)),
+		interp.Dir(dir),
+		interp.StdIO(nil, &stdout, os.Stderr),
+	)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to compose secret command: %w", err)
+	}
+	if err = runner.Run(ctx, file); err != nil {
+		return "", true, fmt.Errorf("failed to resolve secret %s: %w", ref, err)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), true, nil
+}
+
+func (r *Runner) resolveSecrets(ctx context.Context, task models.Task, taskEnv, env []string) ([]string, error) {
+	// Resolving a secret runs a command, so a dry run keeps the references as they are.
+	if r.dryRun {
+		return taskEnv, nil
+	}
+	dir, err := r.getExecutionPath(task, env)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(taskEnv))
+	var secrets []string
+	for _, e := range taskEnv {
+		k, v, found := strings.Cut(e, "=")
+		if !found {
+			result = append(result, e)
+			continue
+		}
+		s, ok, err := r.secretResolver.Resolve(ctx, v, dir, env)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			e = k + "=" + s
+			secrets = append(secrets, s)
+		}
+		result = append(result, e)
+	}
+	r.notifySecrets(ctx, task, secrets)
+	return result, nil
+}
+
+func (r *Runner) notifySecrets(ctx context.Context, task models.Task, secrets []string) {
+	if len(secrets) == 0 {
+		return
+	}
+	for _, o := range r.observers {
+		if so, ok := o.(SecretObserver); ok {
+			so.TaskSecrets(ctx, task, secrets)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package run
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+// serviceReadyTimeout is how long a service has to become ready.
+const serviceReadyTimeout = 2 * time.Minute
+
+// healthcheckRetries is how many times in a row the healthcheck of a service must fail for it to be unhealthy.
+const healthcheckRetries = 3
+
+// serviceReadyInterval is how often the ready-when command of a service is run until it succeeds.
+const serviceReadyInterval = 500 * time.Millisecond
+
+// WithDetachedServices leaves the service tasks started by a run running when it finishes,
+// rather than stopping them.
+func WithDetachedServices() Option {
+	return func(r *Runner) {
+		r.detach = true
+	}
+}
+
+// service is a task running in the background.
+type service struct {
+	name   string
+	cancel context.CancelFunc
+	// exited receives the result of the script once it has exited.
+	exited chan error
+	// command runs the stop command of the service, if it has one.
+	command func(ctx context.Context) error
+	// ctx has the values the service runs with, it is never cancelled.
+	ctx context.Context
+}
+
+func (svc *service) String() string {
+	return fmt.Sprintf("service %q", svc.name)
+}
+
+// stop stops the service and waits for it to exit, returning the error it exited with.
+// A service with a stop command is given its stop timeout to exit once the command has run,
+// otherwise, or if it has not exited by then, its processes are sent its stop signal and killed after the timeout.
+func (svc *service) stop() error {
+	timeout := stopPolicyFrom(svc.ctx).timeout
+	if svc.command != nil {
+		ctx, cancel := context.WithTimeout(svc.ctx, timeout)
+		err := svc.command(ctx)
+		cancel()
+		if err != nil {
+			fmt.Printf("service %q stop command failed: %v\n", svc.name, err)
+		}
+		select {
+		case err = <-svc.exited:
+			svc.cancel()
+			return err
+		case <-time.After(timeout):
+		}
+	}
+	svc.cancel()
+	return <-svc.exited
+}
+
+// serviceStopPolicy returns how the processes of a service task are stopped, from its stop-signal and stop-timeout.
+func serviceStopPolicy(task models.Task) stopPolicy {
+	p := stopPolicyFrom(context.Background())
+	if sig, ok := stopSignals[task.StopSignal]; ok {
+		p.signal = sig
+	}
+	if task.StopTimeout > 0 {
+		p.timeout = task.StopTimeout
+	}
+	return p
+}
+
+// background is something a run starts in the background, such as a service, and stops once it has finished.
+type background interface {
+	fmt.Stringer
+	// stop stops it and waits for it to exit, returning the error it exited with.
+	stop() error
+}
+
+// services are the services started by a Runner, and the other things it starts in the background.
+type services struct {
+	mu      sync.Mutex
+	running []background
+	// started holds the keys claimed by things started once per run, such as docker compose services.
+	started map[string]bool
+}
+
+func (s *services) add(b background) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = append(s.running, b)
+}
+
+// claim returns the keys that have not been claimed since the services were last stopped or detached, claiming them.
+func (s *services) claim(keys []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started == nil {
+		s.started = map[string]bool{}
+	}
+	var claimed []string
+	for _, k := range keys {
+		if !s.started[k] {
+			s.started[k] = true
+			claimed = append(claimed, k)
+		}
+	}
+	return claimed
+}
+
+// stop stops every service, the last started first, and waits for them to exit.
+// A service starts once the services it requires are ready, so they are stopped in reverse dependency order.
+func (s *services) stop() {
+	s.mu.Lock()
+	running := s.running
+	s.running, s.started = nil, nil
+	s.mu.Unlock()
+	for i := len(running) - 1; i >= 0; i-- {
+		b := running[i]
+		fmt.Printf("stopping %s\n", b)
+		if err := b.stop(); err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Printf("%s exited: %v\n", b, err)
+		}
+	}
+}
+
+// detach forgets every service, leaving them running.
+func (s *services) detach() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.running {
+		fmt.Printf("%s left running\n", b)
+	}
+	s.running, s.started = nil, nil
+}
+
+// detachedContext has the values of its parent but is never cancelled,
+// so services outlive the task that started them.
+type detachedContext struct{ context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+type readyOutputKey struct{}
+
+// startService runs the script of a service task in the background, returning once it is ready.
+func (r *Runner) startService(ctx context.Context, task models.Task, env, inputs []string, dir string) error {
+	svc, ready, err := r.launch(ctx, task, env, inputs, dir)
+	if err != nil {
+		return err
+	}
+	r.services.add(svc)
+	fmt.Printf("service %q started, waiting for it to be ready\n", task.Name)
+	if err = waitReady(ctx, svc, ready); err != nil {
+		return err
+	}
+	fmt.Printf("service %q is ready\n", task.Name)
+	return nil
+}
+
+// launch runs the script of a service task in the background, ready is closed once it is ready.
+// The service runs until it exits or is cancelled, even if ctx is cancelled.
+func (r *Runner) launch(
+	ctx context.Context,
+	task models.Task,
+	env, inputs []string,
+	dir string,
+) (*service, <-chan struct{}, error) {
+	var readyWhen models.ReadyWhen
+	if task.ReadyWhen != "" {
+		var err error
+		if readyWhen, err = models.ParseReadyWhen(task.ReadyWhen); err != nil {
+			return nil, nil, fmt.Errorf("ready-when is invalid for %s: %w", task.Name, err)
+		}
+	}
+	valuesCtx := withStopPolicy(detachedContext{ctx}, serviceStopPolicy(task))
+	svcCtx, cancel := context.WithCancel(valuesCtx)
+	ready := make(chan struct{})
+	var pw *io.PipeWriter
+	if readyWhen.Pattern != nil {
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		svcCtx = context.WithValue(svcCtx, readyOutputKey{}, pw)
+		go func() {
+			sc := bufio.NewScanner(pr)
+			found := false
+			for sc.Scan() {
+				if !found && readyWhen.Pattern.MatchString(sc.Text()) {
+					found = true
+					close(ready)
+				}
+			}
+			// Output is read until the service exits, so writes never block.
+			_, _ = io.Copy(io.Discard, pr)
+		}()
+	}
+	svc := &service{name: task.Name, cancel: cancel, exited: make(chan error, 1), ctx: valuesCtx}
+	if task.Stop != "" {
+		svc.command = func(ctx context.Context) error {
+			return r.scriptRunner.Execute(ctx, task.Stop, env, nil, dir)
+		}
+	}
+	go func() {
+		err := r.execute(svcCtx, task, env, inputs, dir)
+		if pw != nil {
+			pw.Close()
+		}
+		svc.exited <- err
+	}()
+	switch {
+	case readyWhen.Command != "":
+		go r.pollReady(svcCtx, readyWhen.Command, env, dir, ready)
+	case readyWhen.Pattern == nil:
+		close(ready)
+	}
+	return svc, ready, nil
+}
+
+// waitReady waits for a launched service to be ready, returning an error if it exits first
+// or is not ready within serviceReadyTimeout.
+func waitReady(ctx context.Context, svc *service, ready <-chan struct{}) error {
+	ctx, cancel := context.WithTimeout(ctx, serviceReadyTimeout)
+	defer cancel()
+	select {
+	case <-ready:
+		return nil
+	case err := <-svc.exited:
+		// Those stopping the service still wait for it, the error is returned here instead.
+		svc.exited <- nil
+		if err == nil {
+			err = errors.New("exit status 0")
+		}
+		return fmt.Errorf("service %s exited before it was ready: %w", svc.name, err)
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("service %s was not ready after %s", svc.name, serviceReadyTimeout)
+		}
+		return ctx.Err()
+	}
+}
+
+// pollReady runs command until it succeeds, then closes ready.
+func (r *Runner) pollReady(ctx context.Context, command string, env []string, dir string, ready chan struct{}) {
+	quiet := context.WithValue(ctx, outputKey{}, output{stdout: io.Discard, stderr: io.Discard})
+	for {
+		if err := r.scriptRunner.Execute(quiet, command, env, nil, dir); err == nil {
+			close(ready)
+			return
+		}
+		select {
+		case <-time.After(serviceReadyInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// monitorHealth runs the healthcheck of a service every interval once it is ready, until ctx is done.
+// The returned channel is closed once the healthcheck has failed healthcheckRetries times in a row,
+// each run of it failing if it does not succeed within the interval.
+func (r *Runner) monitorHealth(
+	ctx context.Context,
+	hc models.Healthcheck,
+	env []string,
+	dir string,
+	ready <-chan struct{},
+) <-chan struct{} {
+	unhealthy := make(chan struct{})
+	go func() {
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return
+		}
+		quiet := context.WithValue(ctx, outputKey{}, output{stdout: io.Discard, stderr: io.Discard})
+		failures := 0
+		for {
+			select {
+			case <-time.After(hc.Interval):
+			case <-ctx.Done():
+				return
+			}
+			checkCtx, cancel := context.WithTimeout(quiet, hc.Interval)
+			err := r.scriptRunner.Execute(checkCtx, hc.Command, env, nil, dir)
+			cancel()
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err == nil:
+				failures = 0
+			default:
+				if failures++; failures >= healthcheckRetries {
+					close(unhealthy)
+					return
+				}
+			}
+		}
+	}()
+	return unhealthy
+}
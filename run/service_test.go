@@ -0,0 +1,77 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestRunService(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   models.Task
+		expectErr string
+	}{
+		{
+			name: "given a ready-when pattern, should run dependents once it matches",
+			service: models.Task{
+				Name: "db", Service: true, ReadyWhen: "/^ready on \\d+$/",
+				Script: "echo starting\necho ready on 5432\nsleep 30\n",
+			},
+		},
+		{
+			name:    "given a ready-when command, should run dependents once it succeeds",
+			service: models.Task{Name: "db", Service: true, ReadyWhen: "test -f db.pid", Script: "touch db.pid\nsleep 30\n"},
+		},
+		{
+			name:    "given no ready-when, should run dependents once it has started",
+			service: models.Task{Name: "db", Service: true, Script: "sleep 30\n"},
+		},
+		{
+			name:      "given a service that exits before it is ready, should fail",
+			service:   models.Task{Name: "db", Service: true, ReadyWhen: "/ready/", Script: "echo failed\nexit 3\n"},
+			expectErr: "service db exited before it was ready",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tasks := models.Tasks{
+				tt.service,
+				{Name: "migrate", Script: "touch migrated\n", DependsOn: []string{"db"}},
+				{Name: "test", Script: "touch tested\n", DependsOn: []string{"db", "migrate"}},
+			}
+			runner, err := NewRunner(tasks, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			err = runner.Run(context.Background(), "test", nil)
+			if tt.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+					t.Fatalf("expected error %q got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := time.Since(start); d > 10*time.Second {
+				t.Fatalf("expected the service to be stopped at the end of the run, took %s", d)
+			}
+			for _, f := range []string{"migrated", "tested"} {
+				if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+					t.Errorf("expected %s to have run: %v", f, err)
+				}
+			}
+			if len(runner.services.running) != 0 {
+				t.Errorf("expected no services running got %d", len(runner.services.running))
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package run
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+// WithShard makes the Runner run one of count shards of a run, numbered from 1, so a run can be split across machines.
+//
+// The leaf tasks of the run, those with a script that do not lead to other tasks with a script, are partitioned between
+// the shards, balancing the durations recorded in the state directory when there are any.
+// A task that is not a leaf only runs in a shard with all the leaf tasks it leads to, otherwise it is skipped,
+// but the tasks it requires and its steps are still run.
+// The partition only depends on the task names and recorded durations, so every shard must share the same durations.
+func WithShard(index, count int) Option {
+	return func(r *Runner) {
+		r.shard = [2]int{index, count}
+	}
+}
+
+// ParseShard parses a shard such as 2/5, the second of five.
+func ParseShard(s string) (index, count int, err error) {
+	i, n, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected <index>/<count> such as 2/5", s)
+	}
+	if index, err = strconv.Atoi(i); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected <index>/<count> such as 2/5", s)
+	}
+	if count, err = strconv.Atoi(n); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q, expected <index>/<count> such as 2/5", s)
+	}
+	if count < 1 || index < 1 || index > count {
+		return 0, 0, fmt.Errorf("invalid shard %q, the index must be between 1 and the count", s)
+	}
+	return index, count, nil
+}
+
+// shardTasks returns the names, in lower case, of the tasks in the run of the named task
+// that the shard of the Runner runs.
+func (r *Runner) shardTasks(name string) (map[string]bool, error) {
+	root, ok := r.tasks.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("task %s not found", name)
+	}
+	// leaves holds the leaf tasks each task leads to, including itself if it is a leaf.
+	leaves := map[string][]string{}
+	var walk func(task models.Task) []string
+	walk = func(task models.Task) []string {
+		key := strings.ToLower(task.Name)
+		if l, ok := leaves[key]; ok {
+			return l
+		}
+		entries := task.Steps
+		if !r.noDeps {
+			entries = append(task.DependsOn[:len(task.DependsOn):len(task.DependsOn)], entries...)
+		}
+		var l []string
+		for _, entry := range entries {
+			d, err := models.ParseDependency(entry)
+			if err != nil {
+				continue
+			}
+			if dt, ok := r.tasks.Get(d.Name); ok {
+				l = append(l, walk(dt)...)
+			}
+		}
+		// A task with a script is a leaf if the tasks it leads to have none.
+		if len(l) == 0 && task.Script != "" {
+			l = []string{key}
+		}
+		leaves[key] = l
+		return l
+	}
+	walk(root)
+	assigned := partition(leaves[strings.ToLower(root.Name)], r.shard[1], TaskDurations(r.dir))
+	selected := map[string]bool{}
+	for task, l := range leaves {
+		inShard := true
+		for _, leaf := range l {
+			inShard = inShard && assigned[leaf] == r.shard[0]
+		}
+		selected[task] = inShard
+	}
+	return selected, nil
+}
+
+// partition assigns each of leaves to one of count shards, numbered from 1.
+// The longest tasks are assigned first, each to the shard with the least work so far,
+// tasks that have not run before are assumed to take the mean duration of those that have.
+func partition(leaves []string, count int, durations map[string]time.Duration) map[string]int {
+	seen := map[string]bool{}
+	var names []string
+	var total time.Duration
+	var known int
+	for _, l := range leaves {
+		if seen[l] {
+			continue
+		}
+		seen[l] = true
+		names = append(names, l)
+	}
+	// Durations are recorded by task name, which may differ in case from the lower case names of leaves.
+	lower := map[string]time.Duration{}
+	for name, d := range durations {
+		lower[strings.ToLower(name)] = d
+	}
+	for _, n := range names {
+		if d, ok := lower[n]; ok {
+			total += d
+			known++
+		}
+	}
+	mean := time.Second
+	if known > 0 {
+		mean = total / time.Duration(known)
+	}
+	weight := func(n string) time.Duration {
+		if d, ok := lower[n]; ok {
+			return d
+		}
+		return mean
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if wi, wj := weight(names[i]), weight(names[j]); wi != wj {
+			return wi > wj
+		}
+		return names[i] < names[j]
+	})
+	load := make([]time.Duration, count)
+	assigned := map[string]int{}
+	for _, n := range names {
+		shard := 0
+		for i := range load {
+			if load[i] < load[shard] {
+				shard = i
+			}
+		}
+		load[shard] += weight(n)
+		assigned[n] = shard + 1
+	}
+	return assigned
+}
@@ -0,0 +1,79 @@
+package run
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestParseShard(t *testing.T) {
+	if i, n, err := ParseShard("2/5"); err != nil || i != 2 || n != 5 {
+		t.Fatalf("expected 2/5 got %d/%d %v", i, n, err)
+	}
+	for _, s := range []string{"2", "0/5", "6/5", "1/0", "a/b"} {
+		if _, _, err := ParseShard(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	leaves := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		name      string
+		durations map[string]time.Duration
+		expected  map[string]int
+	}{
+		{
+			name:     "given no durations, should assign tasks in turn",
+			expected: map[string]int{"a": 1, "b": 2, "c": 1, "d": 2, "e": 1},
+		},
+		{
+			name: "given durations, should balance them",
+			durations: map[string]time.Duration{
+				"a": 10 * time.Second, "b": 4 * time.Second, "c": 3 * time.Second, "D": 3 * time.Second,
+			},
+			// e is assumed to take the mean of 5s.
+			expected: map[string]int{"a": 1, "e": 2, "b": 2, "c": 2, "d": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partition(leaves, 2, tt.durations); !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %v got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunShard(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "test", DependsOn: []string{"unit", "integration"}, Steps: []string{"e2e"}},
+		{Name: "unit", Script: "unit"},
+		{Name: "integration", Script: "integration", DependsOn: []string{"db"}},
+		{Name: "db", Script: "db"},
+		{Name: "e2e", Script: "e2e"},
+	}
+	var all []string
+	for i := 1; i <= 2; i++ {
+		runner, err := NewRunner(tasks, t.TempDir(), WithShard(i, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scriptRunner := &mockScriptRunner{}
+		runner.scriptRunner = scriptRunner
+		if err = runner.Run(context.Background(), "test", nil); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, scriptRunner.scripts...)
+	}
+	sort.Strings(all)
+	if got := strings.Join(all, ","); got != "db,e2e,integration,unit" {
+		t.Fatalf("expected each script to run in one shard got %s", got)
+	}
+}
@@ -0,0 +1,86 @@
+package run
+
+import (
+	"context"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// defaultShellOpts are the options scripts run by the built-in shell have unless shell-opts is set.
+var defaultShellOpts = []string{"errexit", "xtrace"}
+
+// posixShells are shells that can be set in the front matter whose options can be set with `set -o`.
+var posixShells = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "mksh": true}
+
+type shellOptsKey struct{}
+
+// withShellOpts returns a context in which shell scripts run with opts.
+func withShellOpts(ctx context.Context, opts []string) context.Context {
+	return context.WithValue(ctx, shellOptsKey{}, opts)
+}
+
+// shellOpts returns the shell options of scripts run with ctx, ok is false if they have not been set.
+func shellOpts(ctx context.Context) (opts []string, ok bool) {
+	opts, ok = ctx.Value(shellOptsKey{}).([]string)
+	return opts, ok
+}
+
+// shellHeader returns the lines that set opts at the start of a script.
+func shellHeader(opts []string) string {
+	var b strings.Builder
+	for _, o := range opts {
+		b.WriteString("set -o " + o + "\n")
+	}
+	return b.String()
+}
+
+// hasShellOpt reports whether opts contains opt.
+func hasShellOpt(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// exitOnPipelineFailure works around the built-in shell not exiting when a pipeline fails with errexit and pipefail
+// set, by rewriting each pipeline whose failure should exit the script as `pipeline || exit $?`.
+// Pipelines in conditions, && and || lists and negated pipelines do not exit the script, as in bash.
+func exitOnPipelineFailure(file *syntax.File) {
+	skip := map[*syntax.Stmt]bool{}
+	mark := func(stmts ...*syntax.Stmt) {
+		for _, s := range stmts {
+			skip[s] = true
+		}
+	}
+	syntax.Walk(file, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.IfClause:
+			mark(n.Cond...)
+		case *syntax.WhileClause:
+			mark(n.Cond...)
+		case *syntax.BinaryCmd:
+			mark(n.X, n.Y)
+		case *syntax.Stmt:
+			b, ok := n.Cmd.(*syntax.BinaryCmd)
+			if !ok || (b.Op != syntax.Pipe && b.Op != syntax.PipeAll) || skip[n] || n.Negated || n.Background || n.Coprocess {
+				return true
+			}
+			pipeline := *n
+			n.Redirs = nil
+			n.Cmd = &syntax.BinaryCmd{Op: syntax.OrStmt, X: &pipeline, Y: exitStmt()}
+		}
+		return true
+	})
+}
+
+// exitStmt returns the statement `exit $?`.
+func exitStmt() *syntax.Stmt {
+	f, err := syntax.NewParser().Parse(strings.NewReader("exit $?"), "")
+	if err != nil {
+		panic(err)
+	}
+	return f.Stmts[0]
+}
@@ -0,0 +1,110 @@
+package run
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/joerdav/xc/interpolate"
+	_ "github.com/lib/pq"           // registers the postgres driver
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+)
+
+// sqlRunner runs scripts in code blocks tagged sql against the database in DATABASE_URL with database/sql.
+//
+// The statements of a block are run by a single call to the driver, which stops at the first failing statement.
+type sqlRunner struct {
+	open func(driver, dsn string) (*sql.DB, error)
+}
+
+func newSQLRunner() sqlRunner {
+	return sqlRunner{open: sql.Open}
+}
+
+func (s sqlRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	databaseURL, _ := interpolate.EnvLookup(env)("DATABASE_URL")
+	if databaseURL == "" {
+		return fmt.Errorf("sql blocks require DATABASE_URL to be set")
+	}
+	driver, dsn, err := sqlDataSource(databaseURL, dir)
+	if err != nil {
+		return err
+	}
+	if err = checkSQLSandbox(ctx, driver, dsn); err != nil {
+		return err
+	}
+	db, err := s.open(driver, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open DATABASE_URL: %w", err)
+	}
+	defer db.Close()
+	if _, err = db.ExecContext(ctx, text); err != nil {
+		return fmt.Errorf("sql block failed: %w", err)
+	}
+	return nil
+}
+
+// checkSQLSandbox returns an error if the sandbox of ctx does not allow the database to be used,
+// as xc connects to it itself rather than in a sandboxed process.
+func checkSQLSandbox(ctx context.Context, driver, dsn string) error {
+	s := sandboxFrom(ctx)
+	if driver != "sqlite3" {
+		if s.noNetwork {
+			return fmt.Errorf("sql blocks cannot connect to %s without network access", driver)
+		}
+		return nil
+	}
+	path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if !s.canOpen(path, os.O_RDWR) {
+		return fmt.Errorf("sql blocks cannot open %s in the sandbox of the task", path)
+	}
+	return nil
+}
+
+// sqlDataSource returns the database/sql driver and data source name of databaseURL.
+// Relative sqlite paths are relative to dir, the directory of the task.
+func sqlDataSource(databaseURL, dir string) (driver, dsn string, err error) {
+	scheme, rest, _ := strings.Cut(databaseURL, ":")
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return "postgres", databaseURL, nil
+	case "mysql":
+		u, err := url.Parse(databaseURL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+		}
+		cfg := mysql.NewConfig()
+		cfg.User = u.User.Username()
+		cfg.Passwd, _ = u.User.Password()
+		if u.Host != "" {
+			cfg.Net, cfg.Addr = "tcp", u.Host
+		}
+		cfg.DBName = strings.TrimPrefix(u.Path, "/")
+		// A block usually has several statements.
+		cfg.MultiStatements = true
+		dsn = cfg.FormatDSN()
+		if u.RawQuery != "" {
+			dsn += "&" + u.RawQuery
+		}
+		return "mysql", dsn, nil
+	case "sqlite", "sqlite3":
+		path, query, _ := strings.Cut(strings.TrimPrefix(rest, "//"), "?")
+		if path == "" {
+			return "", "", fmt.Errorf("invalid DATABASE_URL: sqlite url has no path")
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		dsn = "file:" + path
+		if query != "" {
+			dsn += "?" + query
+		}
+		return "sqlite3", dsn, nil
+	}
+	return "", "", fmt.Errorf("unsupported DATABASE_URL scheme %q, should be postgres, mysql or sqlite", scheme)
+}
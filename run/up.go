@@ -0,0 +1,262 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/terminal"
+)
+
+// serviceRestartDelay is how long `xc up` waits before restarting a service that has exited.
+const serviceRestartDelay = time.Second
+
+// Up runs the named service tasks, or every service task if names is empty, until ctx is cancelled.
+// The output of each service is prefixed with its name and services are restarted according to their restart attribute.
+//
+// A service starts once the services it requires are ready and its other required tasks have run.
+// Up returns once ctx is cancelled and every service has stopped, or once every service has exited.
+func (r *Runner) Up(ctx context.Context, names []string) error {
+	services, err := r.upServices(names)
+	if err != nil {
+		return err
+	}
+	defer r.services.stop()
+	states := map[string]*upState{}
+	width := 0
+	for _, t := range services {
+		states[t.Name] = &upState{ready: make(chan struct{}), done: make(chan struct{})}
+		if len(t.Name) > width {
+			width = len(t.Name)
+		}
+	}
+	color := terminal.Color(os.Stdout)
+	mu := &sync.Mutex{}
+	errs := make([]error, len(services))
+	var wg sync.WaitGroup
+	for i, t := range services {
+		wg.Add(1)
+		go func(i int, t models.Task) {
+			defer wg.Done()
+			defer close(states[t.Name].done)
+			style := prefixColors[i%len(prefixColors)]
+			if s, ok := terminal.Named(t.Color); ok {
+				style = s
+			}
+			prefix := servicePrefix(t.Name, width, style, color)
+			stdout, stderr := stdio(ctx)
+			pout, perr := newPrefixWriter(mu, stdout, prefix), newPrefixWriter(mu, stderr, prefix)
+			defer pout.flush()
+			defer perr.flush()
+			errs[i] = r.supervise(context.WithValue(ctx, outputKey{}, output{stdout: pout, stderr: perr}), t, states)
+		}(i, t)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// upState is the state of a service run by Up, ready is closed the first time it is ready
+// and done once it has stopped for good.
+type upState struct {
+	ready, done chan struct{}
+}
+
+// upServices returns the named service tasks, or every service task if names is empty.
+func (r *Runner) upServices(names []string) (models.Tasks, error) {
+	var services models.Tasks
+	if len(names) == 0 {
+		for _, t := range r.tasks {
+			if t.Service {
+				services = append(services, t)
+			}
+		}
+		if len(services) == 0 {
+			return nil, errors.New("no tasks have the attribute service: true")
+		}
+	} else {
+		for _, n := range names {
+			t, ok := r.tasks.Get(n)
+			if !ok {
+				return nil, fmt.Errorf("task %s not found", n)
+			}
+			if !t.Service {
+				return nil, fmt.Errorf("task %s is not a service", t.Name)
+			}
+			services = append(services, t)
+		}
+	}
+	// A service, or a task it requires, may have a parsing error.
+	for _, t := range services {
+		if err := r.ValidateDependencies(t.Name, []string{}); err != nil {
+			return nil, err
+		}
+	}
+	return services, nil
+}
+
+// supervise runs a service until ctx is cancelled, restarting it according to its restart policy.
+// Required services in states are waited for rather than run.
+func (r *Runner) supervise(ctx context.Context, task models.Task, states map[string]*upState) error {
+	env, dir, err := r.serviceEnv(ctx, task, states)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "service %q failed to start: %v\n", task.Name, err)
+		return err
+	}
+	var hc models.Healthcheck
+	if task.Healthcheck != "" {
+		if hc, err = models.ParseHealthcheck(task.Healthcheck); err != nil {
+			return fmt.Errorf("healthcheck is invalid for %s: %w", task.Name, err)
+		}
+	}
+	once := sync.Once{}
+	for {
+		svc, started, err := r.launch(ctx, task, env, nil, dir)
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-started
+			once.Do(func() { close(states[task.Name].ready) })
+		}()
+		healthCtx, stopHealth := context.WithCancel(ctx)
+		var unhealthy <-chan struct{}
+		if hc.Command != "" && task.Restart == models.RestartOnUnhealthy {
+			unhealthy = r.monitorHealth(healthCtx, hc, env, dir, started)
+		}
+		select {
+		case err = <-svc.exited:
+			svc.cancel()
+		case <-unhealthy:
+			fmt.Printf("service %q is unhealthy: restarting\n", task.Name)
+			if err = svc.stop(); err != nil && !errors.Is(err, context.Canceled) {
+				fmt.Printf("service %q exited: %v\n", task.Name, err)
+			}
+			stopHealth()
+			select {
+			case <-time.After(serviceRestartDelay):
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ctx.Done():
+			// A service is stopped once the services that require it have stopped, so they can shut down cleanly.
+			for _, d := range r.dependentServices(task.Name, states) {
+				<-states[d].done
+			}
+			if err = svc.stop(); err != nil && !errors.Is(err, context.Canceled) {
+				fmt.Printf("service %q exited: %v\n", task.Name, err)
+			}
+			stopHealth()
+			return nil
+		}
+		stopHealth()
+		switch {
+		case err != nil && task.Restart != models.RestartNo:
+			fmt.Printf("service %q exited: %v: restarting\n", task.Name, err)
+		case err == nil && task.Restart == models.RestartAlways:
+			fmt.Printf("service %q exited: restarting\n", task.Name)
+		case err != nil:
+			fmt.Printf("service %q exited: %v\n", task.Name, err)
+			return fmt.Errorf("service %s exited: %w", task.Name, err)
+		default:
+			fmt.Printf("service %q exited\n", task.Name)
+			return nil
+		}
+		select {
+		case <-time.After(serviceRestartDelay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// dependentServices returns the services in states that require the named service, directly or through other tasks.
+func (r *Runner) dependentServices(name string, states map[string]*upState) []string {
+	var dependents []string
+	for s := range states {
+		if t, ok := r.tasks.Get(s); ok && s != name && r.requiresTask(t, name, map[string]bool{}) {
+			dependents = append(dependents, s)
+		}
+	}
+	return dependents
+}
+
+// requiresTask reports whether task requires the named task, directly or through other tasks.
+func (r *Runner) requiresTask(task models.Task, name string, seen map[string]bool) bool {
+	for _, entry := range task.DependsOn {
+		d, err := models.ParseDependency(entry)
+		if err != nil {
+			continue
+		}
+		required, ok := r.tasks.Get(d.Name)
+		if !ok || seen[required.Name] {
+			continue
+		}
+		seen[required.Name] = true
+		if required.Name == name || r.requiresTask(required, name, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceEnv runs or waits for the tasks a service requires, then returns its environment and directory.
+func (r *Runner) serviceEnv(
+	ctx context.Context,
+	task models.Task,
+	states map[string]*upState,
+) (env []string, dir string, err error) {
+	if err = r.checkWhere(task); err != nil {
+		return nil, "", err
+	}
+	if err = r.checkTools(ctx, task); err != nil {
+		return nil, "", err
+	}
+	env = append(r.fileEnv[:len(r.fileEnv):len(r.fileEnv)], os.Environ()...)
+	taskEnv, err := r.expandEnv(r.taskEnv(task), nil, env)
+	if err != nil {
+		return nil, "", err
+	}
+	if taskEnv, err = r.resolveSecrets(ctx, task, taskEnv, env); err != nil {
+		return nil, "", err
+	}
+	env = append(env, taskEnv...)
+	inputs, err := getInputs(task, nil, env)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, entry := range task.DependsOn {
+		d, err := models.ParseDependency(entry)
+		if err != nil {
+			return nil, "", err
+		}
+		if required, ok := r.tasks.Get(d.Name); ok && states[required.Name] != nil {
+			// A service that was ready and has since stopped does not stop its dependents starting.
+			select {
+			case <-states[required.Name].ready:
+				continue
+			default:
+			}
+			select {
+			case <-states[required.Name].ready:
+				continue
+			case <-states[required.Name].done:
+				return nil, "", fmt.Errorf("service %s stopped before it was ready", required.Name)
+			case <-ctx.Done():
+				return nil, "", ctx.Err()
+			}
+		}
+		if err = r.runDependency(ctx, entry, env); err != nil {
+			return nil, "", err
+		}
+	}
+	env = append(env, inputs...)
+	if dir, err = r.getExecutionPath(task, env); err != nil {
+		return nil, "", err
+	}
+	return env, dir, r.composeUp(ctx, task, env, dir)
+}
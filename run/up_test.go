@@ -0,0 +1,193 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor waits until the output contains s, cancel is called once it does or after a timeout.
+func waitFor(t *testing.T, out *syncBuffer, s string, cancel context.CancelFunc) {
+	t.Helper()
+	defer cancel()
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(out.String(), s) {
+		if time.Now().After(deadline) {
+			t.Errorf("expected output to contain %q got:\n%s", s, out.String())
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUp(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "db", Service: true, ReadyWhen: "/ready/", Script: "echo db ready\nsleep 30\n"},
+		{
+			Name: "api", Service: true, ReadyWhen: "/listening/", Script: "echo api listening\nsleep 30\n",
+			DependsOn: []string{"db", "build"},
+		},
+		{Name: "build", Script: "echo building\n"},
+		{Name: "flaky", Service: true, Restart: models.RestartOnFailure, Script: "echo flaky started\nexit 1\n"},
+		{Name: "crash", Service: true, Script: "exit 2\n"},
+	}
+	t.Run("given services that require each other, should start them in order with prefixed output", func(t *testing.T) {
+		runner, err := NewRunner(tasks, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &syncBuffer{}
+		ctx := context.WithValue(context.Background(), outputKey{}, output{stdout: out, stderr: out})
+		ctx, cancel := context.WithCancel(ctx)
+		go waitFor(t, out, "api | api listening", cancel)
+		if err = runner.Up(ctx, []string{"db", "api"}); err != nil {
+			t.Fatal(err)
+		}
+		s := out.String()
+		if !strings.Contains(s, "db  | db ready\n") {
+			t.Errorf("expected prefixed db output got:\n%s", s)
+		}
+		if strings.Index(s, "db ready") > strings.Index(s, "api listening") {
+			t.Errorf("expected db to be ready before api started got:\n%s", s)
+		}
+	})
+	t.Run("given services that require each other, should stop them in reverse dependency order", func(t *testing.T) {
+		stopping := models.Tasks{
+			{
+				Name: "db", Service: true, Script: "echo db started\nsleep 30\n",
+				Stop: "echo db stopping", StopTimeout: 50 * time.Millisecond,
+			},
+			{
+				Name: "api", Service: true, Script: "echo api started\nsleep 30\n", DependsOn: []string{"migrate"},
+				Stop: "echo api stopping", StopTimeout: 50 * time.Millisecond,
+			},
+			{Name: "migrate", Script: "echo migrating\n", DependsOn: []string{"db"}},
+		}
+		runner, err := NewRunner(stopping, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &syncBuffer{}
+		ctx := context.WithValue(context.Background(), outputKey{}, output{stdout: out, stderr: out})
+		ctx, cancel := context.WithCancel(ctx)
+		go waitFor(t, out, "api | api started", cancel)
+		start := time.Now()
+		if err = runner.Up(ctx, []string{"db", "api"}); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d > 10*time.Second {
+			t.Errorf("expected the services to be stopped after their stop timeout took %s", d)
+		}
+		s := out.String()
+		api, db := strings.Index(s, "api | api stopping"), strings.Index(s, "db  | db stopping")
+		if api < 0 || db < 0 || api > db {
+			t.Errorf("expected api to be stopped before db got:\n%s", s)
+		}
+	})
+	t.Run("given a service that fails with restart on-failure, should restart it", func(t *testing.T) {
+		runner, err := NewRunner(tasks, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &syncBuffer{}
+		ctx := context.WithValue(context.Background(), outputKey{}, output{stdout: out, stderr: out})
+		ctx, cancel := context.WithCancel(ctx)
+		go func() {
+			deadline := time.Now().Add(10 * time.Second)
+			for strings.Count(out.String(), "| flaky started") < 2 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			cancel()
+		}()
+		if err = runner.Up(ctx, []string{"flaky"}); err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(out.String(), "| flaky started"); n < 2 {
+			t.Errorf("expected flaky to be restarted got %d runs", n)
+		}
+	})
+	t.Run("given a service that is unhealthy with restart on-unhealthy, should restart it", func(t *testing.T) {
+		wedged := models.Tasks{{
+			Name: "emulator", Service: true, Restart: models.RestartOnUnhealthy, Healthcheck: "`test -f healthy` every 10ms",
+			Script: "echo emulator started\nsleep 30\n", StopTimeout: 50 * time.Millisecond,
+		}}
+		runner, err := NewRunner(wedged, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &syncBuffer{}
+		ctx := context.WithValue(context.Background(), outputKey{}, output{stdout: out, stderr: out})
+		ctx, cancel := context.WithCancel(ctx)
+		go func() {
+			deadline := time.Now().Add(10 * time.Second)
+			for strings.Count(out.String(), "| emulator started") < 2 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			cancel()
+		}()
+		if err = runner.Up(ctx, []string{"emulator"}); err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(out.String(), "| emulator started"); n < 2 {
+			t.Errorf("expected emulator to be restarted got %d runs", n)
+		}
+	})
+	t.Run("given a service that fails without a restart policy, should fail", func(t *testing.T) {
+		runner, err := NewRunner(tasks, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = runner.Up(context.Background(), []string{"crash"}); err == nil {
+			t.Fatal("expected error got nil")
+		}
+	})
+	t.Run("given a task that is not a service, should fail", func(t *testing.T) {
+		runner, err := NewRunner(tasks, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = runner.Up(context.Background(), []string{"build"}); err == nil {
+			t.Fatal("expected error got nil")
+		}
+	})
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newPrefixWriter(&sync.Mutex{}, &buf, "db | ")
+	for _, s := range []string{"one\ntw", "o\n", "three"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "db | one\ndb | two\ndb | three\n"
+	if buf.String() != expected {
+		t.Errorf("want=%q got=%q", expected, buf.String())
+	}
+}
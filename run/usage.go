@@ -0,0 +1,92 @@
+package run
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+// Usage is the resources used by the processes a task started, and the processes they waited for.
+// Scripts run by the built-in shell only count the commands they start.
+type Usage struct {
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the largest resident set size in bytes of any of the processes.
+	MaxRSS int64
+	// ReadBytes and WriteBytes count the bytes read from and written to storage, as in /proc/<pid>/io.
+	// MaxRSS, ReadBytes and WriteBytes are 0 on windows.
+	ReadBytes  int64
+	WriteBytes int64
+}
+
+// CPUTime returns the user and system CPU time of u.
+func (u Usage) CPUTime() time.Duration {
+	return u.UserTime + u.SystemTime
+}
+
+// UsageObserver is an Observer that is also sent the resources used by each task that started a process,
+// before TaskFinished is called for it.
+type UsageObserver interface {
+	Observer
+	TaskUsage(ctx context.Context, task models.Task, usage Usage)
+}
+
+type usageKey struct{}
+
+// usageMeter adds up the usage of the processes of a task, which may run in parallel for the items of a foreach task.
+type usageMeter struct {
+	mu    sync.Mutex
+	used  bool
+	usage Usage
+}
+
+// withUsage returns a context in which the usage of the processes of a task is recorded, nil if r has no UsageObserver.
+func (r *Runner) withUsage(ctx context.Context) (context.Context, *usageMeter) {
+	for _, o := range r.observers {
+		if _, ok := o.(UsageObserver); ok {
+			m := &usageMeter{}
+			return context.WithValue(ctx, usageKey{}, m), m
+		}
+	}
+	return ctx, nil
+}
+
+// notifyUsage sends the usage recorded by m to r's UsageObservers, if any process was started.
+func (r *Runner) notifyUsage(ctx context.Context, task models.Task, m *usageMeter) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	used, u := m.used, m.usage
+	m.mu.Unlock()
+	if !used {
+		return
+	}
+	for _, o := range r.observers {
+		if uo, ok := o.(UsageObserver); ok {
+			uo.TaskUsage(ctx, task, u)
+		}
+	}
+}
+
+// addUsage records the usage of a process that has exited to the task run with ctx.
+func addUsage(ctx context.Context, ps *os.ProcessState) {
+	m, ok := ctx.Value(usageKey{}).(*usageMeter)
+	if !ok || ps == nil {
+		return
+	}
+	maxRSS, read, write := processUsage(ps)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.used = true
+	m.usage.UserTime += ps.UserTime()
+	m.usage.SystemTime += ps.SystemTime()
+	if maxRSS > m.usage.MaxRSS {
+		m.usage.MaxRSS = maxRSS
+	}
+	m.usage.ReadBytes += read
+	m.usage.WriteBytes += write
+}
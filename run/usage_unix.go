@@ -0,0 +1,25 @@
+//go:build !windows
+
+package run
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// processUsage returns the largest resident set size and the bytes read and written by a process
+// and those it waited for, from the rusage returned by wait4.
+func processUsage(ps *os.ProcessState) (maxRSS, read, write int64) {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0, 0, 0
+	}
+	maxRSS = int64(ru.Maxrss)
+	// ru_maxrss is in bytes on darwin and kilobytes elsewhere.
+	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+		maxRSS *= 1024
+	}
+	// Block operations are counted in 512 byte units, as are read_bytes and write_bytes in /proc/<pid>/io.
+	return maxRSS, int64(ru.Inblock) * 512, int64(ru.Oublock) * 512
+}
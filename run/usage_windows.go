@@ -0,0 +1,10 @@
+//go:build windows
+
+package run
+
+import "os"
+
+// processUsage returns 0 on windows, where only the CPU time of a process is recorded.
+func processUsage(*os.ProcessState) (maxRSS, read, write int64) {
+	return 0, 0, 0
+}
@@ -0,0 +1,269 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+// Reason explains whether a task would run and why.
+type Reason struct {
+	Task string
+	// Depth is 0 for the task that is run and one more than the task that requires it for the others.
+	Depth int
+	// Run is false if the task would be skipped.
+	Run bool
+	// Because lists what decides whether the task runs, in the order the Runner checks it.
+	Because []string
+}
+
+// Why explains whether each task in a run of the named task with inputs would run, without running any of them:
+// which task requires it, whether it is affected by the changed files, whether it ran already,
+// succeeded in the run being resumed or has its outputs in the cache.
+// Tasks are listed before the tasks they require, which are not listed if the task requiring them is skipped,
+// unless it is only skipped because WithOnly did not select it.
+func (r *Runner) Why(ctx context.Context, name string, inputs []string) ([]Reason, error) {
+	if err := r.ValidateDependencies(name, []string{}); err != nil {
+		return nil, err
+	}
+	if err := r.validateFrom(name); err != nil {
+		return nil, err
+	}
+	if r.shard[1] > 0 {
+		selected, err := r.shardTasks(name)
+		if err != nil {
+			return nil, err
+		}
+		r.shardSelected = selected
+		defer func() { r.shardSelected = nil }()
+	}
+	w := &why{r: r, seen: map[string]bool{}}
+	if r.resume && !r.dryRun {
+		cp, found, err := loadCheckpoint(checkpointPath(r.dir, name, inputs))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			w.checkpoint = cp
+		}
+	}
+	err := w.explain(ctx, name, inputs, nil, "it is the task being run", 0)
+	return w.reasons, err
+}
+
+type why struct {
+	r          *Runner
+	checkpoint *checkpoint
+	seen       map[string]bool
+	reasons    []Reason
+	// fromReached is set once the task a run starts from with WithFrom has been reached.
+	fromReached bool
+}
+
+func (w *why) explain(ctx context.Context, name string, inputs, with []string, by string, depth int) error {
+	r := w.r
+	task, ok := r.tasks.Get(name)
+	if !ok {
+		return fmt.Errorf("task %s not found", name)
+	}
+	reason := Reason{Task: task.Name, Depth: depth, Run: true, Because: []string{by}}
+	i := len(w.reasons)
+	w.reasons = append(w.reasons, reason)
+	defer func() { w.reasons[i] = reason }()
+	if r.from != "" && !w.fromReached && !strings.EqualFold(task.Name, r.from) && !r.leadsTo(task, r.from) {
+		reason.Run = false
+		reason.Because = append(reason.Because, fmt.Sprintf("it runs before %s, which -from starts the run at", r.from))
+		return nil
+	}
+	if r.changedFiles != nil {
+		reason.Run = w.affected(task, &reason)
+		if !reason.Run {
+			return nil
+		}
+	}
+	if !r.selected(task) {
+		reason.Run = false
+		if r.only != nil && !r.only[strings.ToLower(task.Name)] {
+			reason.Because = append(reason.Because,
+				"it is not selected by -only, but the tasks it requires and its steps still run")
+		} else {
+			reason.Because = append(reason.Because, fmt.Sprintf(
+				"it is not in shard %d/%d, but the tasks it requires and its steps still run", r.shard[0], r.shard[1]))
+		}
+		env, _, err := r.environment(ctx, task, with)
+		if err != nil {
+			return err
+		}
+		return w.explainDependencies(ctx, task, env, &reason, depth)
+	}
+	key := models.Dependency{Name: task.Name, Env: with}.Node()
+	switch {
+	case w.seen[key] && task.Service:
+		reason.Run = false
+		reason.Because = append(reason.Because, "it is a service and was started already")
+		return nil
+	case w.seen[key] && task.RequiredBehaviour == models.RequiredBehaviourOnce:
+		reason.Run = false
+		reason.Because = append(reason.Because, "it ran already and its run attribute is once")
+		return nil
+	case w.seen[key]:
+		reason.Because = append(reason.Because, "it ran already, but its run attribute is always")
+	}
+	w.seen[key] = true
+	if w.checkpoint != nil && w.checkpoint.skip(taskKey(task, inputs, with)) {
+		reason.Run = false
+		reason.Because = append(reason.Because, fmt.Sprintf("it succeeded in run %s, which is resumed", w.checkpoint.RunID))
+		return nil
+	}
+	env, taskEnv, err := r.environment(ctx, task, with)
+	if err != nil {
+		return err
+	}
+	inp, err := getInputs(task, inputs, env)
+	if err != nil {
+		return err
+	}
+	switch {
+	case task.Script == "":
+		reason.Because = append(reason.Because, "it has no script, so only the tasks it requires run")
+	case r.cacheable(task):
+		dir, err := r.getExecutionPath(task, append(env, inp...))
+		if err != nil {
+			return err
+		}
+		sources, err := sourceHashes(task, dir, !r.noGitignore)
+		if err != nil {
+			return err
+		}
+		k := cacheKey(task, cacheEnv(taskEnv, with, inp), sources)
+		rc, hit, err := r.cache.Get(ctx, k)
+		if err != nil {
+			return err
+		}
+		if hit {
+			rc.Close()
+			reason.Run = false
+			reason.Because = append(reason.Because, fmt.Sprintf("its outputs would be restored from the cache entry %s", k[:12]))
+		} else {
+			reason.Because = append(reason.Because,
+				fmt.Sprintf("the cache has no entry %s for its script, env, inputs and sources", k[:12]))
+		}
+	case r.cache != nil:
+		reason.Because = append(reason.Because,
+			"it is not cached, as only tasks with sources and assert-outputs attributes are")
+	}
+	if r.explain && task.Script != "" && len(task.Sources) > 0 {
+		because, err := w.explainSources(task, inputs, with, append(env, inp...))
+		if err != nil {
+			return err
+		}
+		reason.Because = append(reason.Because, because...)
+	}
+	return w.explainDependencies(ctx, task, env, &reason, depth)
+}
+
+// explainSources lists the sources of a task added, removed or modified since its last successful run.
+func (w *why) explainSources(task models.Task, inputs, with, env []string) ([]string, error) {
+	dir, err := w.r.getExecutionPath(task, env)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := sourceHashes(task, dir, !w.r.noGitignore)
+	if err != nil {
+		return nil, err
+	}
+	changes, recorded, err := w.r.sourceChanges(task, inputs, with, sources)
+	if err != nil {
+		return nil, err
+	}
+	if !recorded {
+		return []string{"it has no sources recorded from a successful run"}, nil
+	}
+	if len(changes) == 0 {
+		return []string{"its sources are unchanged since its last successful run"}, nil
+	}
+	because := make([]string, len(changes))
+	for i, c := range changes {
+		because[i] = "since its last successful run, " + c.format(false)
+	}
+	return because, nil
+}
+
+// explainDependencies explains the required tasks and steps of a task,
+// noting on reason if -no-deps or -from skips the required tasks.
+func (w *why) explainDependencies(
+	ctx context.Context,
+	task models.Task,
+	env []string,
+	reason *Reason,
+	depth int,
+) error {
+	from := w.r.from != "" && !w.fromReached && strings.EqualFold(task.Name, w.r.from)
+	if from {
+		w.fromReached = true
+	}
+	switch {
+	case w.r.noDeps && len(task.DependsOn) > 0:
+		reason.Because = append(reason.Because, "the tasks it requires are skipped by -no-deps")
+	case from && len(task.DependsOn) > 0:
+		reason.Because = append(reason.Because, "the tasks it requires are skipped, as -from starts the run at it")
+	default:
+		for _, entry := range task.DependsOn {
+			if err := w.explainDependency(ctx, entry, env, "required by "+task.Name, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	for n, entry := range task.Steps {
+		if err := w.explainDependency(ctx, entry, env, fmt.Sprintf("step %d of %s", n+1, task.Name), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *why) explainDependency(ctx context.Context, entry string, env []string, by string, depth int) error {
+	d, err := models.ParseDependency(entry)
+	if err != nil {
+		return err
+	}
+	with, err := w.r.expandEnv(d.Env, nil, env)
+	if err != nil {
+		return err
+	}
+	return w.explain(ctx, d.Name, d.Inputs, with, by, depth)
+}
+
+// affected reports whether a task is affected by the changed files, adding why to reason.
+func (w *why) affected(task models.Task, reason *Reason) bool {
+	r := w.r
+	if len(task.Sources) == 0 && task.Script != "" {
+		reason.Because = append(reason.Because, "it has no sources, so it is affected by any change")
+		return true
+	}
+	if changed := r.changedSources(task); len(changed) > 0 {
+		if len(changed) > 3 {
+			changed = append(changed[:3], fmt.Sprintf("and %d more", len(changed)-3))
+		}
+		reason.Because = append(reason.Because, "its sources changed: "+strings.Join(changed, ", "))
+		return true
+	}
+	for _, entry := range append(task.DependsOn[:len(task.DependsOn):len(task.DependsOn)], task.Steps...) {
+		d, err := models.ParseDependency(entry)
+		if err != nil {
+			continue
+		}
+		if dt, ok := r.tasks.Get(d.Name); ok && r.affected(dt) {
+			reason.Because = append(reason.Because, fmt.Sprintf("it runs %s, which is affected by changes", dt.Name))
+			return true
+		}
+	}
+	if len(task.Sources) == 0 {
+		reason.Because = append(reason.Because, "it has no script and runs no task affected by changes")
+	} else {
+		reason.Because = append(reason.Because, "no changed file matches its sources: "+strings.Join(task.Sources, ", "))
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/joerdav/xc/cache"
+	"github.com/joerdav/xc/models"
+)
+
+func TestWhy(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{
+		{Name: "release", DependsOn: []string{"build", "docs"}, Steps: []string{"build"}},
+		{Name: "build", Script: "go build", Sources: []string{"*.go"}, RequiredBehaviour: models.RequiredBehaviourOnce},
+		{Name: "docs", Script: "hugo", Sources: []string{"doc/"}},
+	}
+	runner, err := NewRunner(tasks, dir, WithChangedFiles([]string{filepath.Join(dir, "main.go")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons, err := runner.Why(context.Background(), "release", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Reason{
+		{Task: "release", Run: true, Because: []string{
+			"it is the task being run",
+			"it runs build, which is affected by changes",
+			"it has no script, so only the tasks it requires run",
+		}},
+		{Task: "build", Depth: 1, Run: true, Because: []string{"required by release", "its sources changed: main.go"}},
+		{Task: "docs", Depth: 1, Because: []string{"required by release", "no changed file matches its sources: doc/"}},
+		{Task: "build", Depth: 1, Because: []string{
+			"step 1 of release", "its sources changed: main.go", "it ran already and its run attribute is once",
+		}},
+	}
+	if !reflect.DeepEqual(reasons, expected) {
+		t.Fatalf("expected %+v got %+v", expected, reasons)
+	}
+}
+
+func TestWhyCache(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("input"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{{
+		Name:          "build",
+		Script:        "cp in.txt out.txt",
+		Sources:       []string{"*.txt"},
+		AssertOutputs: []models.OutputAssertion{{Path: "out.txt", NonEmpty: true}},
+	}}
+	b := cache.Dir(t.TempDir())
+	runner, err := NewRunner(tasks, dir, WithCache(b, cache.ModeUpload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons, err := runner.Why(context.Background(), "build", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reasons) != 1 || !reasons[0].Run {
+		t.Fatalf("expected build to run before it is cached got %+v", reasons)
+	}
+	if err = runner.Run(context.Background(), "build", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(filepath.Join(dir, "out.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if reasons, err = runner.Why(context.Background(), "build", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(reasons) != 1 || reasons[0].Run {
+		t.Fatalf("expected build to be restored from the cache got %+v", reasons)
+	}
+}
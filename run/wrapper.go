@@ -0,0 +1,80 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// wrapperRunner runs scripts inside the environment of a wrapper command, such as `nix develop -c`,
+// for tasks with the wrapper attribute, so every script of a task runs in one standard environment.
+//
+// The script, with the shebang and shell options it would have natively, is run by its interpreter as the last
+// arguments of the wrapper. Shell scripts are passed with -c, so wrappers that run them elsewhere, such as in a
+// container, do not need to see the files of xc. Scripts with another interpreter are written to a temporary file.
+type wrapperRunner struct {
+	wrapper   []string
+	next      ScriptRunner
+	cmdRunner func(*exec.Cmd) error
+}
+
+func newWrapperRunner(wrapper []string, next ScriptRunner) wrapperRunner {
+	return wrapperRunner{wrapper: wrapper, next: next, cmdRunner: cmdShebangRunner}
+}
+
+// wrapperShells are the interpreters that run a script given with -c.
+var wrapperShells = map[string]bool{"sh": true, "bash": true, "dash": true, "zsh": true, "ksh": true}
+
+//nolint:gosec // accept that command is being executed here from outside of xc
+func (w wrapperRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	if _, err := exec.LookPath(w.wrapper[0]); err != nil {
+		return fmt.Errorf("the task runs inside %s, which is not installed", strings.Join(w.wrapper, " "))
+	}
+	source := text
+	if s, ok := w.next.(sourcer); ok {
+		var err error
+		if source, err = s.Source(ctx, text, env); err != nil {
+			return err
+		}
+	}
+	interpreter := []string{"/bin/sh"}
+	if line, rest, _ := strings.Cut(source, "\n"); strings.HasPrefix(line, "#!") {
+		interpreter, source = strings.Fields(strings.TrimPrefix(line, "#!")), rest
+	}
+	if len(interpreter) == 0 {
+		return errors.New("the script has an empty shebang")
+	}
+	name, arg0 := filepath.Base(interpreter[0]), interpreter[0]
+	if name == "env" && len(interpreter) > 1 {
+		name, arg0 = interpreter[1], interpreter[1]
+	}
+	cmdArgs := append(w.wrapper[1:len(w.wrapper):len(w.wrapper)], interpreter...)
+	if wrapperShells[name] {
+		// The script is given a $0 as it would have running from a file.
+		cmdArgs = append(cmdArgs, "-c", source, arg0)
+	} else {
+		f, err := os.CreateTemp("", "xc_wrapper_")
+		if err != nil {
+			return fmt.Errorf("failed to create execution file")
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(source)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write execution file")
+		}
+		cmdArgs = append(cmdArgs, f.Name())
+	}
+	cmd := exec.CommandContext(ctx, w.wrapper[0], append(cmdArgs, args...)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = stdin(ctx)
+	cmd.Stdout, cmd.Stderr = stdio(ctx)
+	return w.cmdRunner(withProcessGroup(cmd))
+}
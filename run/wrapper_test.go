@@ -0,0 +1,59 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestWrapperRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake wrapper is a shell script")
+	}
+	dir := t.TempDir()
+	// The fake wrapper marks the run as wrapped, then runs the command after develop -c.
+	fake := filepath.Join(t.TempDir(), "nix")
+	script := "#!/bin/sh\n[ \"$1\" = develop ] && [ \"$2\" = -c ] || exit 90\nshift 2\nexport WRAPPED=yes\nexec \"$@\"\n"
+	if err := os.WriteFile(fake, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{
+		{Name: "wrapped", Script: "echo \"$GREETING $WRAPPED $0\" > wrapped.txt\n", Env: []string{"GREETING=hello"}},
+		{Name: "shebang", Script: "#!/bin/bash\necho \"$WRAPPED\" > shebang.txt\n"},
+		{Name: "unwrapped", Script: "echo \"${WRAPPED:-no}\" > unwrapped.txt\n", Wrapper: []string{}},
+	}
+	runner, err := NewRunner(tasks, dir, WithWrapper([]string{fake, "develop", "-c"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		if err = runner.Run(context.Background(), task.Name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[string]string{
+		"wrapped.txt":   "hello yes bash\n",
+		"shebang.txt":   "yes\n",
+		"unwrapped.txt": "no\n",
+	}
+	for f, content := range expected {
+		if b, err := os.ReadFile(filepath.Join(dir, f)); err != nil || string(b) != content {
+			t.Errorf("expected %s to be %q got %q %v", f, content, b, err)
+		}
+	}
+}
+
+func TestWrapperRunnerNotInstalled(t *testing.T) {
+	tasks := models.Tasks{{Name: "build", Script: "echo build\n", Wrapper: []string{"xc-missing-wrapper", "-c"}}}
+	runner, err := NewRunner(tasks, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runner.Run(context.Background(), "build", nil); err == nil {
+		t.Fatal("expected an error when the wrapper is not installed")
+	}
+}
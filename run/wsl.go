@@ -0,0 +1,118 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// wslRunner runs scripts inside the Windows Subsystem for Linux, for tasks with the wsl attribute on Windows.
+//
+// The script, with the shebang and shell options it would have natively, is written to a temporary file
+// and run by its interpreter in WSL, in the task directory translated to its Linux form.
+// The variables set by xc and the task are shared with WSL through WSLENV, with paths translated.
+type wslRunner struct {
+	// command is wsl.exe, the command that runs a program in WSL.
+	command   string
+	next      ScriptRunner
+	cmdRunner func(*exec.Cmd) error
+}
+
+func newWSLRunner(next ScriptRunner) wslRunner {
+	return wslRunner{command: "wsl.exe", next: next, cmdRunner: cmdShebangRunner}
+}
+
+// wslPathVars are the variables set by xc that hold Windows paths, translated when they are shared with WSL.
+var wslPathVars = map[string]bool{"XC_TMPDIR": true, "XC_STATE_DIR": true}
+
+//nolint:gosec // accept that command is being executed here from outside of xc
+func (w wslRunner) Execute(ctx context.Context, text string, env []string, args []string, dir string) error {
+	if _, err := exec.LookPath(w.command); err != nil {
+		return errors.New("the task runs in WSL, which is not installed, see https://learn.microsoft.com/windows/wsl/install")
+	}
+	source := text
+	if s, ok := w.next.(sourcer); ok {
+		var err error
+		if source, err = s.Source(ctx, text, env); err != nil {
+			return err
+		}
+	}
+	// Scripts written on Windows may have CRLF line endings, which Linux shells do not accept.
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	interpreter := []string{"/bin/sh"}
+	if line, rest, _ := strings.Cut(source, "\n"); strings.HasPrefix(line, "#!") {
+		interpreter, source = strings.Fields(strings.TrimPrefix(line, "#!")), rest
+	}
+	f, err := os.CreateTemp("", "xc_wsl_")
+	if err != nil {
+		return fmt.Errorf("failed to create execution file")
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(source)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write execution file")
+	}
+	cmdArgs := append([]string{"--cd", dir, "--exec"}, interpreter...)
+	cmdArgs = append(append(cmdArgs, wslPath(f.Name())), args...)
+	cmd := exec.CommandContext(ctx, w.command, cmdArgs...)
+	cmd.Dir = dir
+	cmd.Env = append(env[:len(env):len(env)], "WSLENV="+wslEnv(env))
+	cmd.Stdin = stdin(ctx)
+	cmd.Stdout, cmd.Stderr = stdio(ctx)
+	return w.cmdRunner(withProcessGroup(cmd))
+}
+
+// wslEnv returns the value of WSLENV that shares the variables of env that are not inherited from xc with WSL,
+// such as those set by the task and its inputs, after those already in WSLENV.
+func wslEnv(env []string) string {
+	inherited := map[string]string{}
+	for _, e := range os.Environ() {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			inherited[strings.ToUpper(k)] = v
+		}
+	}
+	var shared []string
+	seen := map[string]bool{}
+	for i := len(env) - 1; i >= 0; i-- {
+		k, v, ok := strings.Cut(env[i], "=")
+		key := strings.ToUpper(k)
+		if !ok || seen[key] || key == "WSLENV" {
+			continue
+		}
+		seen[key] = true
+		if prev, ok := inherited[key]; ok && prev == v && !wslPathVars[k] {
+			continue
+		}
+		if wslPathVars[k] {
+			k += "/p"
+		}
+		shared = append(shared, k)
+	}
+	for i, j := 0, len(shared)-1; i < j; i, j = i+1, j-1 {
+		shared[i], shared[j] = shared[j], shared[i]
+	}
+	if prev := os.Getenv("WSLENV"); prev != "" {
+		shared = append([]string{prev}, shared...)
+	}
+	return strings.Join(shared, ":")
+}
+
+// wslPath returns the Linux form of a Windows path in WSL, such as /mnt/c/Users for C:\Users.
+// Paths without a drive letter are returned with their separators replaced.
+func wslPath(p string) string {
+	p = strings.ReplaceAll(p, `\`, "/")
+	if len(p) >= 2 && p[1] == ':' && isDriveLetter(p[0]) {
+		return "/mnt/" + strings.ToLower(p[:1]) + p[2:]
+	}
+	return p
+}
+
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
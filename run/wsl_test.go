@@ -0,0 +1,80 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestWSLPath(t *testing.T) {
+	tests := map[string]string{
+		`C:\Users\me\project`: "/mnt/c/Users/me/project",
+		`d:/build`:            "/mnt/d/build",
+		`relative\dir`:        "relative/dir",
+		"/tmp/xc_wsl_1":       "/tmp/xc_wsl_1",
+	}
+	for in, expected := range tests {
+		if got := wslPath(in); got != expected {
+			t.Errorf("wslPath(%q) = %q, want %q", in, got, expected)
+		}
+	}
+}
+
+func TestWSLRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake wsl.exe is a shell script")
+	}
+	dir := t.TempDir()
+	// The fake wsl.exe runs the command after --exec in the directory after --cd.
+	fake := filepath.Join(t.TempDir(), "wsl.exe")
+	script := "#!/bin/sh\n[ \"$1\" = --cd ] && [ \"$3\" = --exec ] || exit 90\ncd \"$2\" && shift 3 && exec \"$@\"\n"
+	if err := os.WriteFile(fake, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tasks := models.Tasks{
+		{
+			Name:   "wsl",
+			Script: "echo \"$GREETING from $XC_TASK_NAME\" > out.txt\r\necho \"$WSLENV\" > wslenv.txt\r\n",
+			Env:    []string{"GREETING=hello"},
+			WSL:    true,
+		},
+		{Name: "native", Script: "echo native > native.txt\n"},
+	}
+	var wrapped int
+	for _, name := range []string{"wsl", "native"} {
+		runner, err := NewRunner(tasks, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runner.wsl = func(next ScriptRunner) ScriptRunner {
+			wrapped++
+			w := newWSLRunner(next)
+			w.command = fake
+			return w
+		}
+		if err = runner.Run(context.Background(), name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wrapped != 1 {
+		t.Fatalf("expected only the wsl task to run in WSL, ran %d", wrapped)
+	}
+	// Windows line endings are removed, which would otherwise be part of the output.
+	if b, err := os.ReadFile(filepath.Join(dir, "out.txt")); err != nil || string(b) != "hello from wsl\n" {
+		t.Fatalf("expected the script to run with the task env got %q %v", b, err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "wslenv.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"GREETING", "XC_TASK_NAME", "XC_TMPDIR/p"} {
+		if !strings.Contains(":"+strings.TrimSpace(string(b))+":", ":"+expected+":") {
+			t.Errorf("expected WSLENV to share %s got %s", expected, b)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package schedule parses cron expressions used by the `schedule` attribute.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+//
+// Expressions have five fields: minute, hour, day of month, month and day of week.
+// Each field accepts `*`, values, ranges (`1-5`), lists (`1,3`) and steps (`*/5`).
+// Months and days of the week can be written as names (`jan`, `mon`).
+// The descriptors @yearly, @monthly, @weekly, @daily and @hourly are also accepted.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record if the day fields were unrestricted,
+	// when both are restricted a time matching either field matches.
+	domStar, dowStar bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: []string{
+		"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec",
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: []string{
+		"sun", "mon", "tue", "wed", "thu", "fri", "sat",
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression.
+func Parse(expr string) (s Schedule, err error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+	fs := strings.Fields(expr)
+	if len(fs) != 5 {
+		return s, fmt.Errorf("schedule %q should have 5 fields, got %d", expr, len(fs))
+	}
+	if s.minute, _, err = minuteField.parse(fs[0]); err != nil {
+		return
+	}
+	if s.hour, _, err = hourField.parse(fs[1]); err != nil {
+		return
+	}
+	if s.dom, s.domStar, err = domField.parse(fs[2]); err != nil {
+		return
+	}
+	if s.month, _, err = monthField.parse(fs[3]); err != nil {
+		return
+	}
+	if s.dow, s.dowStar, err = dowField.parse(fs[4]); err != nil {
+		return
+	}
+	// Sunday can be written as 0 or 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return
+}
+
+func (f field) parse(expr string) (bits uint64, star bool, err error) {
+	for _, part := range strings.Split(expr, ",") {
+		b, st, err := f.parsePart(part)
+		if err != nil {
+			return 0, false, err
+		}
+		bits |= b
+		star = star || st
+	}
+	return bits, star, nil
+}
+
+func (f field) parsePart(part string) (bits uint64, star bool, err error) {
+	rng, stepText, hasStep := strings.Cut(part, "/")
+	step := 1
+	if hasStep {
+		step, err = strconv.Atoi(stepText)
+		if err != nil || step < 1 {
+			return 0, false, fmt.Errorf("invalid step %q in %s field", stepText, f.name)
+		}
+	}
+	low, high := f.min, f.max
+	switch {
+	case rng == "*":
+		star = !hasStep
+	case strings.Contains(rng, "-"):
+		l, h, _ := strings.Cut(rng, "-")
+		if low, err = f.value(l); err != nil {
+			return
+		}
+		if high, err = f.value(h); err != nil {
+			return
+		}
+	default:
+		if low, err = f.value(rng); err != nil {
+			return
+		}
+		high = low
+		if hasStep {
+			high = f.max
+		}
+	}
+	if low > high {
+		return 0, false, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+	}
+	for i := low; i <= high; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits, star, nil
+}
+
+func (f field) value(s string) (int, error) {
+	for i, n := range f.names {
+		if strings.EqualFold(s, n) {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, should be %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// Matches reports whether t, to the minute, is a time the Schedule should run.
+func (s Schedule) Matches(t time.Time) bool {
+	return has(s.minute, t.Minute()) && has(s.hour, t.Hour()) &&
+		has(s.month, int(t.Month())) && s.dayMatches(t)
+}
+
+// maxSearch bounds Next for schedules that can never match, such as the 30th of February.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Next returns the first time after t that the Schedule should run.
+// A zero time is returned if the Schedule never runs.
+func (s Schedule) Next(t time.Time) time.Time {
+	end := t.Add(maxSearch)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(end) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		expectErr bool
+	}{
+		{name: "given every minute, should parse", in: "* * * * *"},
+		{name: "given steps, should parse", in: "*/5 * * * *"},
+		{name: "given ranges and lists, should parse", in: "0,30 9-17 * * 1-5"},
+		{name: "given names, should parse", in: "0 0 1 jan,jul sun"},
+		{name: "given a descriptor, should parse", in: "@daily"},
+		{name: "given too few fields, should fail", in: "* * * *", expectErr: true},
+		{name: "given an out of range value, should fail", in: "60 * * * *", expectErr: true},
+		{name: "given an inverted range, should fail", in: "* 5-1 * * *", expectErr: true},
+		{name: "given an invalid step, should fail", in: "*/0 * * * *", expectErr: true},
+		{name: "given an unknown name, should fail", in: "* * * foo *", expectErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.in)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2023, time.March, 15, 10, 7, 30, 0, time.UTC) // a Wednesday
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2023, time.March, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2023, time.March, 15, 10, 10, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2023, time.March, 16, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * sat", time.Date(2023, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2023, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		// Either day field can match when both are restricted.
+		{"0 0 20 * mon", time.Date(2023, time.March, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(from); !got.Equal(tt.expected) {
+			t.Errorf("%s: expected %v got %v", tt.expr, tt.expected, got)
+		}
+		if !tt.expected.IsZero() && !s.Matches(tt.expected) {
+			t.Errorf("%s: expected %v to match", tt.expr, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Package search finds tasks by their name, description and script.
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/models"
+)
+
+// Match is a task that matches a query.
+type Match struct {
+	File string
+	Line int
+	Task string
+	// Text is the matching line of the task, it is empty if the name of the task matched.
+	Text string
+	// score ranks name matches, lower is better.
+	score int
+}
+
+// File returns the matches for query in the tasks parsed from a file, src is the content of the file.
+//
+// Task names are matched fuzzily, so `dpl` matches `deploy`,
+// other lines of a task are matched if they contain the query.
+// Matching is case insensitive.
+func File(path string, src []byte, tasks models.Tasks, query string) []Match {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	var matches []Match
+	for _, t := range tasks {
+		if score, ok := fuzzy(query, strings.ToLower(t.Name)); ok {
+			matches = append(matches, Match{File: path, Line: t.Line, Task: t.Name, score: score})
+			continue
+		}
+		for i, l := range section(lines, t.Line) {
+			if strings.Contains(strings.ToLower(l), query) {
+				matches = append(matches, Match{File: path, Line: t.Line + 1 + i, Task: t.Name, Text: strings.TrimSpace(l)})
+			}
+		}
+	}
+	return matches
+}
+
+// section returns the lines after the heading at line, up to the next heading outside of a code block.
+func section(lines []string, line int) []string {
+	if line < 1 || line > len(lines) {
+		return nil
+	}
+	rest := lines[line:]
+	inCode := false
+	for i, l := range rest {
+		t := strings.TrimSpace(l)
+		if strings.HasPrefix(t, "```") {
+			inCode = !inCode
+		}
+		if !inCode && strings.HasPrefix(t, "#") {
+			return rest[:i]
+		}
+	}
+	return rest
+}
+
+// fuzzy reports whether the characters of query appear in order in s.
+// The score is 0 for an exact match, 1 for a prefix, 2 for a substring
+// and greater for characters spread further apart.
+func fuzzy(query, s string) (score int, ok bool) {
+	switch {
+	case s == query:
+		return 0, true
+	case strings.HasPrefix(s, query):
+		return 1, true
+	case strings.Contains(s, query):
+		return 2, true
+	}
+	gaps, last := 0, -1
+	for _, r := range query {
+		i := strings.IndexRune(s[last+1:], r)
+		if i < 0 {
+			return 0, false
+		}
+		if last >= 0 && i > 0 {
+			gaps++
+		}
+		last += i + 1
+	}
+	return 3 + gaps, true
+}
+
+// Sort orders matches with name matches first, best first, followed by other matches in file order.
+func Sort(matches []Match) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if (a.Text == "") != (b.Text == "") {
+			return a.Text == ""
+		}
+		if a.Text == "" && a.score != b.score {
+			return a.score < b.score
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
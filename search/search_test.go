@@ -0,0 +1,156 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+const src = `# Project
+
+## Tasks
+
+### deploy
+
+Deploy the service to production.
+
+` + "```" + `
+# Push the image first.
+docker push app
+kubectl apply -f deploy.yaml
+` + "```" + `
+
+### build-image
+
+Requires: test
+
+` + "```" + `
+docker build -t app .
+` + "```" + `
+
+### test
+
+` + "```" + `
+go test ./...
+` + "```" + `
+
+## Notes
+
+Docker is required.
+`
+
+var tasks = models.Tasks{
+	{Name: "deploy", Line: 5},
+	{Name: "build-image", Line: 15},
+	{Name: "test", Line: 23},
+}
+
+func format(matches []Match) string {
+	var s []string
+	for _, m := range matches {
+		s = append(s, fmt.Sprintf("%s:%d:%s:%s", m.File, m.Line, m.Task, m.Text))
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		query  string
+		expect string
+	}{
+		{
+			query:  "dpl",
+			expect: "README.md:5:deploy:",
+		},
+		{
+			query:  "Test",
+			expect: "README.md:23:test:\nREADME.md:17:build-image:Requires: test",
+		},
+		{
+			query:  "docker",
+			expect: "README.md:11:deploy:docker push app\nREADME.md:20:build-image:docker build -t app .",
+		},
+		{
+			query:  "image",
+			expect: "README.md:15:build-image:\nREADME.md:10:deploy:# Push the image first.",
+		},
+		{
+			query:  "production",
+			expect: "README.md:7:deploy:Deploy the service to production.",
+		},
+		{
+			query: "  ",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.query, func(t *testing.T) {
+			matches := File("README.md", []byte(src), tasks, tt.query)
+			Sort(matches)
+			if got := format(matches); got != tt.expect {
+				t.Fatalf("expected\n%s\ngot\n%s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	tests := []struct {
+		query, s string
+		score    int
+		ok       bool
+	}{
+		{"test", "test", 0, true},
+		{"te", "test", 1, true},
+		{"es", "test", 2, true},
+		{"bim", "build-image", 5, true},
+		{"tset", "test", 0, false},
+	}
+	for _, tt := range tests {
+		score, ok := fuzzy(tt.query, tt.s)
+		if score != tt.score || ok != tt.ok {
+			t.Errorf("fuzzy(%q, %q)=%d,%v want=%d,%v", tt.query, tt.s, score, ok, tt.score, tt.ok)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"build", "build", 0},
+		{"biuld", "build", 2},
+		{"buld", "build", 1},
+		{"test", "tests", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if d := distance(tt.a, tt.b); d != tt.distance {
+			t.Errorf("distance(%q, %q)=%d want=%d", tt.a, tt.b, d, tt.distance)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	names := []string{"build", "build-image", "test", "deploy", "Lint"}
+	tests := []struct {
+		name   string
+		expect []string
+	}{
+		{"biuld", []string{"build"}},
+		{"tset", []string{"test"}},
+		{"lnt", []string{"Lint"}},
+		{"build-imag", []string{"build-image"}},
+		{"release", nil},
+	}
+	for _, tt := range tests {
+		got := Suggest(tt.name, names)
+		if strings.Join(got, ",") != strings.Join(tt.expect, ",") {
+			t.Errorf("Suggest(%q)=%v want=%v", tt.name, got, tt.expect)
+		}
+	}
+}
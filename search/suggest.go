@@ -0,0 +1,67 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// Suggest returns the names closest to name by edit distance, ignoring case, best first.
+// Names further than a third of the length of name, or 2 edits if more, are not suggested.
+func Suggest(name string, names []string) []string {
+	name = strings.ToLower(name)
+	max := len(name) / 3
+	if max < 2 {
+		max = 2
+	}
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	var suggestions []suggestion
+	for _, n := range names {
+		if d := distance(name, strings.ToLower(n)); d <= max {
+			suggestions = append(suggestions, suggestion{n, d})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+	result := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		result[i] = s.name
+	}
+	return result
+}
+
+// distance returns the Levenshtein distance between a and b,
+// the number of characters inserted, deleted or substituted to change a into b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,154 @@
+// Package stats summarises a set of tasks to help maintain large task files.
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/joerdav/xc/graph"
+	"github.com/joerdav/xc/models"
+)
+
+// maxChains is the number of dependency chains reported.
+const maxChains = 3
+
+// Stats summarises a set of tasks.
+type Stats struct {
+	Tasks, WithScript, WithoutScript int
+	// AverageScriptLines is the mean number of lines of the tasks with a script.
+	AverageScriptLines float64
+	// LongestChains are the longest sequences of tasks where each task runs the next, longest first.
+	LongestChains [][]string
+	// NoDescription are the tasks without a description.
+	NoDescription []string
+	// Orphans are the tasks that no other task requires or runs as a step.
+	Orphans []string
+}
+
+// Compute returns the Stats of tasks.
+func Compute(tasks models.Tasks) (s Stats, err error) {
+	g, err := graph.New(tasks, "")
+	if err != nil {
+		return s, err
+	}
+	s.Tasks = len(tasks)
+	lines := 0
+	for _, t := range tasks {
+		if t.Script == "" {
+			s.WithoutScript++
+		} else {
+			s.WithScript++
+			lines += strings.Count(strings.TrimSuffix(t.Script, "\n"), "\n") + 1
+		}
+		if len(t.Description) == 0 {
+			s.NoDescription = append(s.NoDescription, t.Name)
+		}
+	}
+	if s.WithScript > 0 {
+		s.AverageScriptLines = float64(lines) / float64(s.WithScript)
+	}
+	s.Orphans = orphans(tasks)
+	s.LongestChains = longestChains(g)
+	return s, nil
+}
+
+// orphans returns the tasks that are not in the requires or steps of another task.
+func orphans(tasks models.Tasks) []string {
+	required := map[string]bool{}
+	for _, t := range tasks {
+		for _, entry := range append(t.DependsOn[:len(t.DependsOn):len(t.DependsOn)], t.Steps...) {
+			d, err := models.ParseDependency(entry)
+			if err != nil {
+				continue
+			}
+			if dt, ok := tasks.Get(d.Name); ok && dt.Name != t.Name {
+				required[dt.Name] = true
+			}
+		}
+	}
+	var result []string
+	for _, t := range tasks {
+		if !required[t.Name] {
+			result = append(result, t.Name)
+		}
+	}
+	return result
+}
+
+// longestChains returns the longest chain starting at each node, keeping the longest maxChains
+// that are not part of a longer chain that was kept.
+func longestChains(g graph.Graph) [][]string {
+	memo := map[string][]string{}
+	visiting := map[string]bool{}
+	var longest func(node string) []string
+	longest = func(node string) []string {
+		if c, ok := memo[node]; ok {
+			return c
+		}
+		// A cycle is reported when tasks are run, it is cut here.
+		if visiting[node] {
+			return []string{node}
+		}
+		visiting[node] = true
+		var best []string
+		for _, e := range g.Children(node) {
+			if c := longest(e.To); len(c) > len(best) {
+				best = c
+			}
+		}
+		visiting[node] = false
+		memo[node] = append([]string{node}, best...)
+		return memo[node]
+	}
+	var chains [][]string
+	for _, n := range g.Nodes {
+		if c := longest(n); len(c) > 1 {
+			chains = append(chains, c)
+		}
+	}
+	sort.SliceStable(chains, func(i, j int) bool { return len(chains[i]) > len(chains[j]) })
+	var result [][]string
+	covered := map[string]bool{}
+	for _, c := range chains {
+		if len(result) == maxChains {
+			break
+		}
+		if covered[c[0]] {
+			continue
+		}
+		for _, n := range c {
+			covered[n] = true
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// Write writes s in a human readable format.
+func (s Stats) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tasks: %d\n", s.Tasks)
+	fmt.Fprintf(&b, "  with a script: %d\n", s.WithScript)
+	fmt.Fprintf(&b, "  without a script: %d\n", s.WithoutScript)
+	fmt.Fprintf(&b, "Average script length: %.1f lines\n", s.AverageScriptLines)
+	fmt.Fprintf(&b, "Longest dependency chains:\n")
+	if len(s.LongestChains) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, c := range s.LongestChains {
+		fmt.Fprintf(&b, "  %s (%d)\n", strings.Join(c, " -> "), len(c))
+	}
+	writeList(&b, "Tasks without a description", s.NoDescription)
+	writeList(&b, "Tasks never required", s.Orphans)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeList(b *strings.Builder, title string, names []string) {
+	fmt.Fprintf(b, "%s: %d\n", title, len(names))
+	if len(names) > 0 {
+		fmt.Fprintf(b, "  %s\n", strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,48 @@
+package stats
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestStats(t *testing.T) {
+	tasks := models.Tasks{
+		{Name: "release", Description: []string{"Release."}, Steps: []string{"build linux", "publish"}},
+		{Name: "build", Description: []string{"Build."}, DependsOn: []string{"test"}, Script: "go build\n"},
+		{Name: "test", Script: "go vet ./...\ngo test ./...\n"},
+		{Name: "publish", DependsOn: []string{"test"}, Script: "./publish.sh\n"},
+		{Name: "fmt", Description: []string{"Format."}, Script: "gofmt -w .\n"},
+	}
+	s, err := Compute(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Tasks: 5
+  with a script: 4
+  without a script: 1
+Average script length: 1.2 lines
+Longest dependency chains:
+  release -> build -> test (3)
+  publish -> test (2)
+Tasks without a description: 2
+  test, publish
+Tasks never required: 2
+  release, fmt
+`
+	if buf.String() != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
+
+func TestStatsUnknownTask(t *testing.T) {
+	_, err := Compute(models.Tasks{{Name: "a", DependsOn: []string{"missing"}}})
+	if err == nil {
+		t.Fatal("expected error got nil")
+	}
+}
@@ -0,0 +1,239 @@
+package terminal
+
+import (
+	"path"
+	"strings"
+	"unicode"
+)
+
+// Styles of the tokens of highlighted code.
+const (
+	commentStyle  = Dim
+	stringStyle   = Green
+	keywordStyle  = Magenta
+	numberStyle   = Cyan
+	variableStyle = Yellow
+)
+
+// lexer describes the tokens of a language, enough to highlight it.
+type lexer struct {
+	comments []string
+	quotes   string
+	keywords map[string]bool
+	// variables is whether $NAME and ${NAME} are variables, as in shell scripts.
+	variables bool
+	// foldCase is whether keywords are case-insensitive.
+	foldCase bool
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var shellLexer = lexer{
+	comments: []string{"#"},
+	quotes:   `"'`,
+	keywords: words("if then else elif fi for while until do done case esac in function return exit export " +
+		"local set unset shift break continue source"),
+	variables: true,
+}
+
+var lexers = map[string]lexer{
+	"":      shellLexer,
+	"sh":    shellLexer,
+	"bash":  shellLexer,
+	"zsh":   shellLexer,
+	"shell": shellLexer,
+	"go": {
+		comments: []string{"//"},
+		quotes:   "\"'`",
+		keywords: words("break case chan const continue default defer else fallthrough for func go goto if " +
+			"import interface map package range return select struct switch type var nil true false"),
+	},
+	"sql": {
+		comments: []string{"--"},
+		quotes:   `'"`,
+		keywords: words("select from where insert into values update set delete create alter drop table index " +
+			"view and or not null is in as on join left right inner outer group by order having limit offset " +
+			"distinct primary key references default begin commit rollback"),
+		foldCase: true,
+	},
+	"python": {
+		comments: []string{"#"},
+		quotes:   `"'`,
+		keywords: words("and as assert async await break class continue def del elif else except finally for " +
+			"from global if import in is lambda nonlocal not or pass raise return try while with yield " +
+			"None True False"),
+	},
+	"javascript": {
+		comments: []string{"//"},
+		quotes:   "\"'`",
+		keywords: words("async await break case catch class const continue default delete do else export extends " +
+			"finally for function if import in instanceof let new of return switch this throw try typeof var void " +
+			"while yield null undefined true false"),
+	},
+}
+
+func init() {
+	lexers["py"] = lexers["python"]
+	lexers["python3"] = lexers["python"]
+	lexers["node"] = lexers["javascript"]
+	lexers["js"] = lexers["javascript"]
+	lexers["ts"] = lexers["javascript"]
+	lexers["typescript"] = lexers["javascript"]
+}
+
+// Highlight returns code with ANSI colors for the language of its code block, such as sh, go or sql.
+// Code without a language is highlighted for the interpreter in its shebang, or as a shell script.
+// Code in a language that is not recognised is returned unchanged.
+func Highlight(code, language string) string {
+	if language == "" {
+		language = shebangLanguage(code)
+	}
+	l, ok := lexers[strings.ToLower(language)]
+	if !ok {
+		return code
+	}
+	return l.highlight(code)
+}
+
+// shebangLanguage returns the name of the interpreter in the shebang of a script, such as python3.
+func shebangLanguage(code string) string {
+	line, _, _ := strings.Cut(code, "\n")
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) > 1 && path.Base(fields[0]) == "env" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return path.Base(fields[0])
+}
+
+func (l lexer) highlight(code string) string {
+	var b strings.Builder
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		if l.comment(rest) && (!l.variables || i == 0 || unicode.IsSpace(rune(code[i-1]))) {
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			b.WriteString(commentStyle.Paint(rest[:n]))
+			i += n
+			continue
+		}
+		switch ch := rest[0]; {
+		case strings.IndexByte(l.quotes, ch) >= 0:
+			n := quoted(rest)
+			b.WriteString(stringStyle.Paint(rest[:n]))
+			i += n
+		case l.variables && ch == '$' && len(rest) > 1:
+			n := variable(rest)
+			b.WriteString(variableStyle.Paint(rest[:n]))
+			i += n
+		case isWordStart(ch):
+			n := 1
+			for n < len(rest) && isWord(rest[n]) {
+				n++
+			}
+			word := rest[:n]
+			if l.foldCase {
+				word = strings.ToLower(word)
+			}
+			if l.keywords[word] && !l.partOfWord(code, i, n) {
+				b.WriteString(keywordStyle.Paint(rest[:n]))
+			} else {
+				b.WriteString(rest[:n])
+			}
+			i += n
+		case ch >= '0' && ch <= '9':
+			n := 1
+			for n < len(rest) && (isWord(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			b.WriteString(numberStyle.Paint(rest[:n]))
+			i += n
+		default:
+			b.WriteByte(ch)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// comment reports whether a comment starts at the beginning of s.
+func (l lexer) comment(s string) bool {
+	for _, c := range l.comments {
+		if strings.HasPrefix(s, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// partOfWord reports whether the word code[i:i+n] is part of a longer name in a shell script, such as a flag or path.
+func (l lexer) partOfWord(code string, i, n int) bool {
+	if !l.variables {
+		return false
+	}
+	isPart := func(c byte) bool { return c == '-' || c == '/' || c == '.' || c == '=' }
+	return (i > 0 && isPart(code[i-1])) || (i+n < len(code) && isPart(code[i+n]))
+}
+
+// quoted returns the length of the string starting with a quote at the beginning of s,
+// up to the end of the line if it is not terminated.
+func quoted(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if q != '\'' {
+				i++
+			}
+		case q:
+			return i + 1
+		case '\n':
+			if q != '`' {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+// variable returns the length of the variable starting with $ at the beginning of s.
+func variable(s string) int {
+	if s[1] == '{' {
+		if n := strings.IndexByte(s, '}'); n > 0 {
+			return n + 1
+		}
+		return len(s)
+	}
+	if !isWord(s[1]) && !strings.ContainsRune("@*#?$!", rune(s[1])) {
+		return 1
+	}
+	if !isWord(s[1]) || unicode.IsDigit(rune(s[1])) {
+		return 2
+	}
+	n := 1
+	for n < len(s) && isWord(s[n]) {
+		n++
+	}
+	return n
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isWord(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,138 @@
+package terminal
+
+import (
+	"strings"
+)
+
+// Styles of rendered markdown.
+const (
+	emphasisStyle = Italic
+	strongStyle   = Bold
+	codeStyle     = Cyan
+	linkStyle     = Blue
+	urlStyle      = Dim
+)
+
+// Markdown returns a line of markdown, such as a line of the description of a task, as it should be displayed
+// in a terminal. Headings, emphasis and inline code are styled if color is true, links are followed by their URL
+// and bullet list items start with a bullet. The markdown syntax is removed whether or not color is true.
+func Markdown(line string, color bool) string {
+	r := markdownRenderer{color: color}
+	t := strings.TrimSpace(line)
+	if level := len(t) - len(strings.TrimLeft(t, "#")); level > 0 && level <= 6 && strings.HasPrefix(t[level:], " ") {
+		return r.paint(strongStyle, r.inline(strings.TrimSpace(t[level:])))
+	}
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if item, ok := strings.CutPrefix(t, marker); ok {
+			return "• " + r.inline(item)
+		}
+	}
+	if quote, ok := strings.CutPrefix(t, ">"); ok {
+		return "│ " + r.inline(strings.TrimSpace(quote))
+	}
+	return r.inline(t)
+}
+
+type markdownRenderer struct {
+	color bool
+}
+
+func (r markdownRenderer) paint(s Style, text string) string {
+	if !r.color {
+		return text
+	}
+	return s.Paint(text)
+}
+
+// inline renders the emphasis, code spans and links in s.
+func (r markdownRenderer) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		if n, text := r.span(s, i); n > 0 {
+			b.WriteString(text)
+			i += n
+			continue
+		}
+		if rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_{}[]()#+-.!<>|~", rune(rest[1])) {
+			b.WriteByte(rest[1])
+			i += 2
+			continue
+		}
+		b.WriteByte(rest[0])
+		i++
+	}
+	return b.String()
+}
+
+// span renders the span starting at s[i] if there is one, returning its length in s.
+func (r markdownRenderer) span(s string, i int) (int, string) {
+	rest := s[i:]
+	switch rest[0] {
+	case '`':
+		ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+		end := strings.Index(rest[ticks:], rest[:ticks])
+		if end < 0 {
+			return 0, ""
+		}
+		code := strings.TrimSpace(rest[ticks : ticks+end])
+		return 2*ticks + end, r.paint(codeStyle, code)
+	case '*', '_':
+		if rest[0] == '_' && i > 0 && isWord(s[i-1]) {
+			// Underscores inside words, such as snake_case, are not emphasis.
+			return 0, ""
+		}
+		for _, delim := range []string{rest[:1] + rest[:1], rest[:1]} {
+			if !strings.HasPrefix(rest, delim) || len(rest) <= len(delim) || rest[len(delim)] == ' ' {
+				continue
+			}
+			end := strings.Index(rest[len(delim):], delim)
+			if end <= 0 || rest[len(delim)+end-1] == ' ' {
+				continue
+			}
+			style := emphasisStyle
+			if len(delim) == 2 {
+				style = strongStyle
+			}
+			return 2*len(delim) + end, r.paint(style, r.inline(rest[len(delim):len(delim)+end]))
+		}
+	case '!':
+		if n, text, _ := link(rest[1:]); n > 0 {
+			return n + 1, text
+		}
+	case '[':
+		n, text, url := link(rest)
+		if n == 0 {
+			return 0, ""
+		}
+		text = r.inline(text)
+		if text == url || url == "" {
+			return n, r.paint(linkStyle, text)
+		}
+		return n, r.paint(linkStyle, text) + " " + r.paint(urlStyle, "("+url+")")
+	case '<':
+		end := strings.IndexByte(rest, '>')
+		if end < 0 || !strings.Contains(rest[:end], "://") || strings.ContainsAny(rest[1:end], " <") {
+			return 0, ""
+		}
+		return end + 1, r.paint(linkStyle, rest[1:end])
+	}
+	return 0, ""
+}
+
+// link parses a link such as [text](url) at the start of s, returning its length.
+func link(s string) (n int, text, url string) {
+	if !strings.HasPrefix(s, "[") {
+		return 0, "", ""
+	}
+	closing := strings.Index(s, "](")
+	if closing < 0 {
+		return 0, "", ""
+	}
+	end := strings.IndexByte(s[closing:], ')')
+	if end < 0 {
+		return 0, "", ""
+	}
+	url, _, _ = strings.Cut(s[closing+2:closing+end], " ")
+	return closing + end + 1, s[1:closing], url
+}
@@ -0,0 +1,93 @@
+// Package terminal formats output for terminals, coloring it unless NO_COLOR is set,
+// color has been disabled with -no-color, output is accessible or the output is not a terminal.
+package terminal
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var colorDisabled, accessible atomic.Bool
+
+// DisableColor stops output from being colored, as if NO_COLOR were set.
+func DisableColor() {
+	colorDisabled.Store(true)
+}
+
+// EnableAccessible makes output accessible, as if XC_ACCESSIBLE were set.
+func EnableAccessible() {
+	accessible.Store(true)
+}
+
+// Accessible reports whether output should be friendly to screen readers, because -accessible was given
+// or XC_ACCESSIBLE is true. Accessible output is not colored, leaves out icons
+// and reports the progress of a run in plain lines of text.
+func Accessible() bool {
+	if accessible.Load() {
+		return true
+	}
+	on, err := strconv.ParseBool(os.Getenv("XC_ACCESSIBLE"))
+	return err == nil && on
+}
+
+// Color reports whether output written to f should be colored.
+func Color(f *os.File) bool {
+	if colorDisabled.Load() || os.Getenv("NO_COLOR") != "" || Accessible() {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Style is an ANSI escape sequence that changes how text is displayed.
+type Style string
+
+const (
+	Reset   Style = "\x1b[0m"
+	Bold    Style = "\x1b[1m"
+	Dim     Style = "\x1b[2m"
+	Italic  Style = "\x1b[3m"
+	Red     Style = "\x1b[31m"
+	Green   Style = "\x1b[32m"
+	Yellow  Style = "\x1b[33m"
+	Blue    Style = "\x1b[34m"
+	Magenta Style = "\x1b[35m"
+	Cyan    Style = "\x1b[36m"
+)
+
+// colorNames are the colors that can be named by the color attribute of a task.
+var colorNames = map[string]Style{
+	"red":     Red,
+	"green":   Green,
+	"yellow":  Yellow,
+	"blue":    Blue,
+	"magenta": Magenta,
+	"cyan":    Cyan,
+}
+
+// Named returns the style of a color named by the color attribute of a task, such as red, ok is false if it is unknown.
+func Named(name string) (s Style, ok bool) {
+	s, ok = colorNames[strings.ToLower(name)]
+	return s, ok
+}
+
+// ColorNames returns the names of the colors accepted by Named, sorted.
+func ColorNames() []string {
+	names := make([]string, 0, len(colorNames))
+	for name := range colorNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Paint returns text displayed in s.
+func (s Style) Paint(text string) string {
+	if text == "" {
+		return text
+	}
+	return string(s) + text + string(Reset)
+}
@@ -0,0 +1,170 @@
+package terminal
+
+import (
+	"os"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		language string
+		expected string
+	}{
+		{
+			name:     "given a shell script, should highlight keywords, strings, variables and comments",
+			code:     "# build\nif [ -n \"$GOOS\" ]; then echo 'cross' $1; fi\n",
+			language: "sh",
+			expected: Dim.Paint("# build") + "\n" + Magenta.Paint("if") + " [ -n " + Green.Paint(`"$GOOS"`) + " ]; " +
+				Magenta.Paint("then") + " echo " + Green.Paint("'cross'") + " " + Yellow.Paint("$1") + "; " +
+				Magenta.Paint("fi") + "\n",
+		},
+		{
+			name:     "given a script without a language, should highlight it as shell",
+			code:     "echo ${HOME}#not-a-comment",
+			expected: "echo " + Yellow.Paint("${HOME}") + "#not-a-comment",
+		},
+		{
+			name:     "given keywords in flags and paths, should not highlight them",
+			code:     "go test -run ./for/done",
+			language: "bash",
+			expected: "go test -run ./for/done",
+		},
+		{
+			name:     "given sql, should highlight keywords case-insensitively",
+			code:     "SELECT id FROM users -- all\nWHERE age > 21;",
+			language: "SQL",
+			expected: Magenta.Paint("SELECT") + " id " + Magenta.Paint("FROM") + " users " + Dim.Paint("-- all") + "\n" +
+				Magenta.Paint("WHERE") + " age > " + Cyan.Paint("21") + ";",
+		},
+		{
+			name:     "given go, should highlight keywords and raw strings",
+			code:     "func main() { println(`hi`) } // done",
+			language: "go",
+			expected: Magenta.Paint("func") + " main() { println(" + Green.Paint("`hi`") + ") } " + Dim.Paint("// done"),
+		},
+		{
+			name:     "given a shebang, should highlight for its interpreter",
+			code:     "#!/usr/bin/env python3\nprint(None)",
+			expected: Dim.Paint("#!/usr/bin/env python3") + "\nprint(" + Magenta.Paint("None") + ")",
+		},
+		{
+			name:     "given an unknown language, should not highlight",
+			code:     "GET http://localhost # not a comment",
+			language: "http",
+			expected: "GET http://localhost # not a comment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.code, tt.language); got != tt.expected {
+				t.Errorf("want=%q\ngot= %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		color    bool
+		expected string
+	}{
+		{
+			name:  "given emphasis and code, should style them",
+			line:  "Builds **every** binary with *cgo* off, see `go build`.",
+			color: true,
+			expected: "Builds " + Bold.Paint("every") + " binary with " + Italic.Paint("cgo") + " off, see " +
+				Cyan.Paint("go build") + ".",
+		},
+		{
+			name:     "given no color, should remove the syntax",
+			line:     "Builds __every__ binary with _cgo_ off, see ``go build``.",
+			expected: "Builds every binary with cgo off, see go build.",
+		},
+		{
+			name:     "given a link, should show its url",
+			line:     "See [the docs](https://xcfile.dev) or <https://github.com>.",
+			expected: "See the docs (https://xcfile.dev) or https://github.com.",
+		},
+		{
+			name:     "given a link in color, should style the text and url",
+			line:     "[docs](https://xcfile.dev)",
+			color:    true,
+			expected: Blue.Paint("docs") + " " + Dim.Paint("(https://xcfile.dev)"),
+		},
+		{
+			name:     "given a bullet list item, should show a bullet",
+			line:     "- runs `make`",
+			expected: "• runs make",
+		},
+		{
+			name:     "given a heading, should make it bold",
+			line:     "#### Notes",
+			color:    true,
+			expected: Bold.Paint("Notes"),
+		},
+		{
+			name:     "given markers that are not emphasis, should leave them",
+			line:     `Reads snake_case_names, 2 * 3 * 4 and \*literal\*.`,
+			expected: "Reads snake_case_names, 2 * 3 * 4 and *literal*.",
+		},
+		{
+			name:     "given an unclosed code span, should leave it",
+			line:     "Runs `go",
+			expected: "Runs `go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Markdown(tt.line, tt.color); got != tt.expected {
+				t.Errorf("want=%q\ngot= %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if Color(f) {
+		t.Error("expected a file not to be colored")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if Color(os.Stdout) {
+		t.Error("expected NO_COLOR to disable color")
+	}
+}
+
+func TestAccessible(t *testing.T) {
+	// /dev/null is a character device, so it is colored like a terminal.
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	t.Setenv("NO_COLOR", "")
+	for _, tt := range []struct {
+		env      string
+		expected bool
+	}{{"", false}, {"false", false}, {"1", true}, {"true", true}} {
+		t.Setenv("XC_ACCESSIBLE", tt.env)
+		if got := Accessible(); got != tt.expected {
+			t.Errorf("XC_ACCESSIBLE=%q: expected Accessible()=%v got %v", tt.env, tt.expected, got)
+		}
+		if tt.expected && Color(devNull) {
+			t.Errorf("XC_ACCESSIBLE=%q: expected accessible output not to be colored", tt.env)
+		}
+	}
+	t.Cleanup(func() { accessible.Store(false) })
+	t.Setenv("XC_ACCESSIBLE", "")
+	EnableAccessible()
+	if !Accessible() {
+		t.Error("expected EnableAccessible to make output accessible")
+	}
+}
@@ -0,0 +1,186 @@
+// Package tools checks that the binaries required by a task are installed,
+// used by the `requires-tools` attribute.
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Requirement is a binary that must be installed, optionally with a version constraint.
+type Requirement struct {
+	Name string
+	// Op is one of >=, >, =, <=, < or empty if any version is accepted.
+	Op      string
+	Version string
+}
+
+func (r Requirement) String() string {
+	return r.Name + r.Op + r.Version
+}
+
+var requirementRe = regexp.MustCompile(`^([^\s<>=]+)\s*(?:(>=|<=|>|<|==?)\s*(\d+(?:\.\d+)*))?$`)
+
+// Parse parses a requirement such as `docker`, `node>=18` or `go>=1.20`.
+func Parse(s string) (r Requirement, err error) {
+	m := requirementRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return r, fmt.Errorf("invalid tool requirement %q, should be name or name>=version", s)
+	}
+	r.Name, r.Op, r.Version = m[1], m[2], m[3]
+	if r.Op == "==" {
+		r.Op = "="
+	}
+	return r, nil
+}
+
+// Checker checks requirements, the version of each binary is only probed once.
+type Checker struct {
+	// LookPath finds a binary, the default is exec.LookPath.
+	LookPath func(name string) (string, error)
+	// Probe returns the output of a binary that contains its version,
+	// the default runs the binary with --version, version and -version until one succeeds.
+	Probe func(ctx context.Context, path string) (string, error)
+
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+// NewChecker returns a Checker that looks for binaries on PATH.
+func NewChecker() *Checker {
+	return &Checker{LookPath: exec.LookPath, Probe: probe}
+}
+
+func probe(ctx context.Context, path string) (string, error) {
+	var err error
+	for _, arg := range []string{"--version", "version", "-version"} {
+		var out []byte
+		//nolint:gosec // accept that command is being executed here from outside of xc
+		out, err = exec.CommandContext(ctx, path, arg).CombinedOutput()
+		if err == nil {
+			return string(out), nil
+		}
+	}
+	return "", err
+}
+
+var versionRe = regexp.MustCompile(`\d+(?:\.\d+)+|\d+`)
+
+// Check returns an error describing every requirement that is not met.
+func (c *Checker) Check(ctx context.Context, reqs []Requirement) error {
+	var errs []error
+	for _, r := range reqs {
+		if err := c.check(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Checker) check(ctx context.Context, r Requirement) error {
+	path, err := c.LookPath(r.Name)
+	if err != nil {
+		return fmt.Errorf("%s was not found, install %s", r.Name, r.wanted())
+	}
+	if r.Op == "" {
+		return nil
+	}
+	version, err := c.version(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to find the version of %s: %w", r.Name, err)
+	}
+	if !satisfies(Compare(version, r.Version), r.Op) {
+		return fmt.Errorf("%s %s is installed, install %s", r.Name, version, r.wanted())
+	}
+	return nil
+}
+
+// wanted describes the versions that satisfy r.
+func (r Requirement) wanted() string {
+	switch r.Op {
+	case ">=":
+		return fmt.Sprintf("%s %s or later", r.Name, r.Version)
+	case ">":
+		return fmt.Sprintf("a version of %s newer than %s", r.Name, r.Version)
+	case "<=":
+		return fmt.Sprintf("%s %s or earlier", r.Name, r.Version)
+	case "<":
+		return fmt.Sprintf("a version of %s older than %s", r.Name, r.Version)
+	case "=":
+		return fmt.Sprintf("%s %s", r.Name, r.Version)
+	}
+	return r.Name
+}
+
+// Version returns the version of the binary name found on PATH.
+func (c *Checker) Version(ctx context.Context, name string) (string, error) {
+	path, err := c.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s was not found", name)
+	}
+	return c.version(ctx, path)
+}
+
+func (c *Checker) version(ctx context.Context, path string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.versions[path]; ok {
+		return v, nil
+	}
+	out, err := c.Probe(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	v := versionRe.FindString(out)
+	if v == "" {
+		return "", fmt.Errorf("no version in %q", strings.TrimSpace(out))
+	}
+	if c.versions == nil {
+		c.versions = map[string]string{}
+	}
+	c.versions[path] = v
+	return v, nil
+}
+
+func satisfies(cmp int, op string) bool {
+	switch op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "=":
+		return cmp == 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	}
+	return true
+}
+
+// Compare compares two dotted versions numerically, missing parts are treated as 0.
+// A constraint of 24 is compared against only the first part of the installed version,
+// so 24.0.7 equals 24.
+func Compare(installed, constraint string) int {
+	a, b := strings.Split(installed, "."), strings.Split(constraint, ".")
+	for i := range b {
+		var x, y int
+		if i < len(a) {
+			x, _ = strconv.Atoi(a[i])
+		}
+		y, _ = strconv.Atoi(b[i])
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
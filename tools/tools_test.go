@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in          string
+		expect      Requirement
+		expectError bool
+	}{
+		{in: "docker", expect: Requirement{Name: "docker"}},
+		{in: "docker>=24", expect: Requirement{Name: "docker", Op: ">=", Version: "24"}},
+		{in: " node >= 18.2 ", expect: Requirement{Name: "node", Op: ">=", Version: "18.2"}},
+		{in: "go==1.20", expect: Requirement{Name: "go", Op: "=", Version: "1.20"}},
+		{in: "terraform<2", expect: Requirement{Name: "terraform", Op: "<", Version: "2"}},
+		{in: "node>=latest", expectError: true},
+		{in: "", expectError: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.in, func(t *testing.T) {
+			r, err := Parse(tt.in)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error got %v", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r != tt.expect {
+				t.Fatalf("expected %v got %v", tt.expect, r)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		installed, constraint string
+		expect                int
+	}{
+		{"24.0.7", "24", 0},
+		{"24.0.7", "23", 1},
+		{"20.10.7", "24", -1},
+		{"1.21.3", "1.20", 1},
+		{"1.9", "1.20", -1},
+		{"18", "18.2", -1},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.installed, tt.constraint); got != tt.expect {
+			t.Errorf("Compare(%q, %q)=%d want=%d", tt.installed, tt.constraint, got, tt.expect)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	probes := 0
+	c := &Checker{
+		LookPath: func(name string) (string, error) {
+			if name == "missing" {
+				return "", errors.New("not found")
+			}
+			return "/bin/" + name, nil
+		},
+		Probe: func(ctx context.Context, path string) (string, error) {
+			probes++
+			switch path {
+			case "/bin/docker":
+				return "Docker version 20.10.7, build f0df350", nil
+			case "/bin/node":
+				return "v18.16.0\n", nil
+			}
+			return "", errors.New("unknown")
+		},
+	}
+	tests := []struct {
+		name   string
+		reqs   string
+		expect string
+	}{
+		{name: "given installed tools, should pass", reqs: "node>=18,docker,node<19"},
+		{name: "given a missing tool, should fail", reqs: "missing", expect: "missing was not found, install missing"},
+		{
+			name:   "given an old version, should fail",
+			reqs:   "docker>=24",
+			expect: "docker 20.10.7 is installed, install docker 24 or later",
+		},
+		{
+			name: "given several failures, should report all",
+			reqs: "missing>=2,node>18",
+			expect: "missing was not found, install missing 2 or later\n" +
+				"node 18.16.0 is installed, install a version of node newer than 18",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var reqs []Requirement
+			for _, s := range strings.Split(tt.reqs, ",") {
+				r, err := Parse(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reqs = append(reqs, r)
+			}
+			err := c.Check(context.Background(), reqs)
+			if tt.expect == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expect {
+				t.Fatalf("expected error %q got %v", tt.expect, err)
+			}
+		})
+	}
+	if probes != 2 {
+		t.Fatalf("expected each tool to be probed once got %d probes", probes)
+	}
+	if v, err := c.Version(context.Background(), "node"); err != nil || v != "18.16.0" || probes != 2 {
+		t.Fatalf("expected the probed version of node got %q, %v after %d probes", v, err, probes)
+	}
+	if _, err := c.Version(context.Background(), "missing"); err == nil {
+		t.Fatal("expected an error for a missing tool")
+	}
+}
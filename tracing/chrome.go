@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+	"github.com/joerdav/xc/run"
+)
+
+// ChromeTrace is a run.Observer that records a timeline of a run in the Trace Event Format,
+// which chrome://tracing and Perfetto open, so tasks running in parallel and idle gaps are easy to see.
+//
+// Each task is a slice on a lane, a thread of the trace. A task nests under the task that required it
+// when that task is not already waiting for another, otherwise it takes the first free lane,
+// so tasks running at the same time are on separate lanes.
+type ChromeTrace struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	start time.Time
+	name  string
+	// lanes holds the stack of the tasks running on each lane, the last of them nested in the others.
+	lanes  [][]*chromeSlice
+	events []chromeEvent
+}
+
+var (
+	_ run.SkipObserver  = &ChromeTrace{}
+	_ run.CacheObserver = &ChromeTrace{}
+	_ run.UsageObserver = &ChromeTrace{}
+)
+
+type chromeSlice struct {
+	lane  int
+	start time.Time
+	args  map[string]any
+}
+
+// chromeEvent is an event of the Trace Event Format, with times in microseconds since the start of the run.
+type chromeEvent struct {
+	Name     string         `json:"name"`
+	Category string         `json:"cat,omitempty"`
+	Phase    string         `json:"ph"`
+	Time     int64          `json:"ts"`
+	Duration *int64         `json:"dur,omitempty"`
+	PID      int            `json:"pid"`
+	TID      int            `json:"tid"`
+	Scope    string         `json:"s,omitempty"`
+	Args     map[string]any `json:"args,omitempty"`
+}
+
+type chromeSliceKey struct{}
+
+// NewChromeTrace returns a ChromeTrace of a run of a task with inputs.
+func NewChromeTrace(task string, inputs []string) *ChromeTrace {
+	c := &ChromeTrace{now: time.Now, name: strings.Join(append([]string{"xc", task}, inputs...), " ")}
+	c.start = c.now()
+	return c
+}
+
+func (c *ChromeTrace) micros(t time.Time) int64 {
+	return t.Sub(c.start).Microseconds()
+}
+
+// lane returns the lane of a task required by parent, which may be nil. c.mu must be held.
+func (c *ChromeTrace) lane(parent *chromeSlice) int {
+	if parent != nil {
+		if stack := c.lanes[parent.lane]; len(stack) > 0 && stack[len(stack)-1] == parent {
+			return parent.lane
+		}
+	}
+	for i, stack := range c.lanes {
+		if len(stack) == 0 {
+			return i
+		}
+	}
+	c.lanes = append(c.lanes, nil)
+	return len(c.lanes) - 1
+}
+
+// TaskStarted starts a slice for the task.
+func (c *ChromeTrace) TaskStarted(ctx context.Context, task models.Task) context.Context {
+	parent, _ := ctx.Value(chromeSliceKey{}).(*chromeSlice)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &chromeSlice{start: c.now(), args: map[string]any{}}
+	s.lane = c.lane(parent)
+	c.lanes[s.lane] = append(c.lanes[s.lane], s)
+	if task.Dir != "" {
+		s.args["dir"] = task.Dir
+	}
+	return context.WithValue(ctx, chromeSliceKey{}, s)
+}
+
+// TaskFinished ends the slice of the task.
+func (c *ChromeTrace) TaskFinished(ctx context.Context, task models.Task, err error) {
+	s, ok := ctx.Value(chromeSliceKey{}).(*chromeSlice)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A service is stopped after the tasks started after it, so it may not be the last task on its lane.
+	stack := c.lanes[s.lane]
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == s {
+			c.lanes[s.lane] = append(stack[:i:i], stack[i+1:]...)
+			break
+		}
+	}
+	s.args["success"] = err == nil
+	if err != nil {
+		s.args["error"] = err.Error()
+	}
+	d := c.now().Sub(s.start).Microseconds()
+	c.events = append(c.events, chromeEvent{
+		Name:     task.Name,
+		Category: "task",
+		Phase:    "X",
+		Time:     c.micros(s.start),
+		Duration: &d,
+		PID:      1,
+		TID:      s.lane + 1,
+		Args:     s.args,
+	})
+}
+
+// TaskSkipped adds an instant event for the task, on the lane of the task that required it.
+func (c *ChromeTrace) TaskSkipped(ctx context.Context, task models.Task, reason string) {
+	parent, _ := ctx.Value(chromeSliceKey{}).(*chromeSlice)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tid := 1
+	if parent != nil {
+		tid = parent.lane + 1
+	}
+	c.events = append(c.events, chromeEvent{
+		Name:     task.Name + " (skipped)",
+		Category: "skipped",
+		Phase:    "i",
+		Time:     c.micros(c.now()),
+		PID:      1,
+		TID:      tid,
+		Scope:    "t",
+		Args:     map[string]any{"reason": reason},
+	})
+}
+
+// TaskCached records whether the outputs of the task were restored from the cache.
+func (c *ChromeTrace) TaskCached(ctx context.Context, _ models.Task, hit bool) {
+	s, ok := ctx.Value(chromeSliceKey{}).(*chromeSlice)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.args["cache"] = "miss"
+	if hit {
+		s.args["cache"] = "hit"
+	}
+}
+
+// TaskUsage records the CPU time and largest resident set size of the processes of the task.
+func (c *ChromeTrace) TaskUsage(ctx context.Context, _ models.Task, u run.Usage) {
+	s, ok := ctx.Value(chromeSliceKey{}).(*chromeSlice)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.args["cpuSeconds"] = u.CPUTime().Seconds()
+	if u.MaxRSS > 0 {
+		s.args["maxRssBytes"] = u.MaxRSS
+	}
+}
+
+// WriteFile writes the trace as JSON to path, with the events in the order they started.
+func (c *ChromeTrace) WriteFile(path string) error {
+	c.mu.Lock()
+	events := append([]chromeEvent{}, c.events...)
+	lanes := len(c.lanes)
+	c.mu.Unlock()
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+	meta := []chromeEvent{{Name: "process_name", Phase: "M", PID: 1, Args: map[string]any{"name": c.name}}}
+	for i := 0; i < lanes || i == 0; i++ {
+		meta = append(meta, chromeEvent{
+			Name: "thread_name", Phase: "M", PID: 1, TID: i + 1, Args: map[string]any{"name": fmt.Sprintf("lane %d", i+1)},
+		})
+	}
+	b, err := json.Marshal(struct {
+		TraceEvents     []chromeEvent `json:"traceEvents"`
+		DisplayTimeUnit string        `json:"displayTimeUnit"`
+	}{append(meta, events...), "ms"})
+	if err != nil {
+		return fmt.Errorf("failed to encode trace: %w", err)
+	}
+	if err = os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write trace: %w", err)
+	}
+	return nil
+}
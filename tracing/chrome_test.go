@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestChromeTrace(t *testing.T) {
+	c := NewChromeTrace("release", []string{"v1"})
+	now := c.start
+	c.now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	release := models.Task{Name: "release"}
+	build := models.Task{Name: "build"}
+	test := models.Task{Name: "test"}
+	lint := models.Task{Name: "lint"}
+	ctx := c.TaskStarted(context.Background(), release)
+	// build and test run in parallel, lint runs after build in its lane.
+	buildCtx := c.TaskStarted(ctx, build)
+	testCtx := c.TaskStarted(ctx, test)
+	c.TaskFinished(buildCtx, build, nil)
+	lintCtx := c.TaskStarted(ctx, lint)
+	c.TaskSkipped(lintCtx, models.Task{Name: "setup"}, "ran already")
+	c.TaskFinished(lintCtx, lint, nil)
+	c.TaskFinished(testCtx, test, errors.New("exit status 1"))
+	c.TaskFinished(ctx, release, errors.New("exit status 1"))
+
+	path := filepath.Join(t.TempDir(), "trace.json")
+	if err := c.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []chromeEvent `json:"traceEvents"`
+	}
+	if err = json.Unmarshal(b, &trace); err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name  string
+		phase string
+		tid   int
+		ts    int64
+		dur   int64
+	}{
+		{"process_name", "M", 0, 0, 0},
+		{"thread_name", "M", 1, 0, 0},
+		{"thread_name", "M", 2, 0, 0},
+		{"release", "X", 1, 1000, 8000},
+		{"build", "X", 1, 2000, 2000},
+		{"test", "X", 2, 3000, 5000},
+		{"lint", "X", 1, 5000, 2000},
+		{"setup (skipped)", "i", 1, 6000, 0},
+	}
+	if len(trace.TraceEvents) != len(expected) {
+		t.Fatalf("expected %d events got %d: %s", len(expected), len(trace.TraceEvents), b)
+	}
+	for i, e := range expected {
+		got := trace.TraceEvents[i]
+		if got.Name != e.name || got.Phase != e.phase || got.TID != e.tid || got.Time != e.ts {
+			t.Fatalf("expected event %d to be %+v got %+v", i, e, got)
+		}
+		if e.phase == "X" && (got.Duration == nil || *got.Duration != e.dur) {
+			t.Fatalf("expected %s to last %dus got %v", e.name, e.dur, got.Duration)
+		}
+	}
+	if trace.TraceEvents[0].Args["name"] != "xc release v1" {
+		t.Fatalf("expected the process to be named after the run got %v", trace.TraceEvents[0].Args)
+	}
+	if test := trace.TraceEvents[5]; test.Args["success"] != false || test.Args["error"] != "exit status 1" {
+		t.Fatalf("expected test to have failed got %v", test.Args)
+	}
+}
@@ -0,0 +1,274 @@
+// Package tracing records a trace of a task graph and exports it using OTLP over HTTP with JSON encoding.
+//
+// Exporting is configured with the standard OpenTelemetry environment variables:
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS
+// and OTEL_SERVICE_NAME.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joerdav/xc/models"
+)
+
+const (
+	spanKindInternal = 1
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+type spanKey struct{}
+
+type span struct {
+	id, parentID string
+	name         string
+	start, end   time.Time
+	attributes   map[string]string
+	err          error
+}
+
+// Tracer records a span for each task that runs, with the spans of required tasks
+// being children of the span of the task that required them.
+type Tracer struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	client   *http.Client
+	traceID  string
+	parentID string
+	mu       sync.Mutex
+	spans    []*span
+}
+
+// FromEnv returns a Tracer configured by the OpenTelemetry environment variables,
+// ok is false if no OTLP endpoint is configured.
+//
+// If TRACEPARENT is set the trace continues the W3C trace context it contains.
+func FromEnv() (t *Tracer, ok bool) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil, false
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "xc"
+	}
+	t = New(endpoint, service)
+	t.headers = parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if traceID, parentID, ok := parseTraceParent(os.Getenv("TRACEPARENT")); ok {
+		t.traceID, t.parentID = traceID, parentID
+	}
+	return t, true
+}
+
+// New returns a Tracer that exports to the OTLP traces endpoint.
+func New(endpoint, service string) *Tracer {
+	return &Tracer{
+		endpoint: endpoint,
+		service:  service,
+		headers:  map[string]string{},
+		client:   &http.Client{Timeout: 10 * time.Second},
+		traceID:  randomHex(16),
+	}
+}
+
+// parseHeaders parses OTEL_EXPORTER_OTLP_HEADERS, a list of url encoded key=value pairs.
+func parseHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, h := range strings.Split(s, ",") {
+		k, v, found := strings.Cut(h, "=")
+		if !found {
+			continue
+		}
+		if dv, err := url.QueryUnescape(v); err == nil {
+			v = dv
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers
+}
+
+// parseTraceParent parses a W3C traceparent header value.
+func parseTraceParent(s string) (traceID, parentID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return strings.Repeat("0", n*2-1) + "1"
+	}
+	return hex.EncodeToString(b)
+}
+
+// TaskStarted starts a span for the task, as a child of the span in ctx.
+func (t *Tracer) TaskStarted(ctx context.Context, task models.Task) context.Context {
+	s := &span{
+		id:       randomHex(8),
+		parentID: t.parentID,
+		name:     task.Name,
+		start:    time.Now(),
+		attributes: map[string]string{
+			"xc.task.name": task.Name,
+		},
+	}
+	if task.Dir != "" {
+		s.attributes["xc.task.dir"] = task.Dir
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.parentID = parent.id
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// TaskFinished ends the span of the task.
+func (t *Tracer) TaskFinished(ctx context.Context, _ models.Task, err error) {
+	s, ok := ctx.Value(spanKey{}).(*span)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s.end = time.Now()
+	s.err = err
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (t *Tracer) request() exportRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]otlpSpan, 0, len(t.spans))
+	for _, s := range t.spans {
+		end := s.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		o := otlpSpan{
+			TraceID:           t.traceID,
+			SpanID:            s.id,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(s.start),
+			EndTimeUnixNano:   unixNano(end),
+			Status:            status{Code: statusCodeOK},
+		}
+		for k, v := range s.attributes {
+			o.Attributes = append(o.Attributes, keyValue{Key: k, Value: anyValue{StringValue: v}})
+		}
+		if s.err != nil {
+			o.Status = status{Code: statusCodeError, Message: s.err.Error()}
+		}
+		spans = append(spans, o)
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: []keyValue{
+			{Key: "service.name", Value: anyValue{StringValue: t.service}},
+		}},
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: "github.com/joerdav/xc"},
+			Spans: spans,
+		}},
+	}}}
+}
+
+// Flush exports the recorded spans.
+func (t *Tracer) Flush(ctx context.Context) error {
+	body, err := json.Marshal(t.request())
+	if err != nil {
+		return fmt.Errorf("failed to encode trace: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to export trace: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export trace: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to export trace: %s", res.Status)
+	}
+	return nil
+}
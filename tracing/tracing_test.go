@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joerdav/xc/models"
+)
+
+func TestTracer(t *testing.T) {
+	var received exportRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token")
+	t.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	tracer, ok := FromEnv()
+	if !ok {
+		t.Fatal("expected tracer to be configured")
+	}
+	ctx := tracer.TaskStarted(context.Background(), models.Task{Name: "build"})
+	depCtx := tracer.TaskStarted(ctx, models.Task{Name: "generate"})
+	tracer.TaskFinished(depCtx, models.Task{Name: "generate"}, errors.New("failed"))
+	tracer.TaskFinished(ctx, models.Task{Name: "build"}, nil)
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "Bearer token" {
+		t.Fatalf("expected header from OTEL_EXPORTER_OTLP_HEADERS, got %q", auth)
+	}
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans got %d", len(spans))
+	}
+	build, generate := spans[0], spans[1]
+	if build.TraceID != "0af7651916cd43dd8448eb211c80319c" || generate.TraceID != build.TraceID {
+		t.Fatalf("expected spans to continue TRACEPARENT, got %s and %s", build.TraceID, generate.TraceID)
+	}
+	if build.ParentSpanID != "b7ad6b7169203331" {
+		t.Fatalf("expected root span parent from TRACEPARENT, got %q", build.ParentSpanID)
+	}
+	if generate.ParentSpanID != build.SpanID {
+		t.Fatalf("expected dependency span to be a child of %s, got %s", build.SpanID, generate.ParentSpanID)
+	}
+	if build.Status.Code != statusCodeOK || generate.Status.Code != statusCodeError {
+		t.Fatalf("unexpected statuses %v %v", build.Status, generate.Status)
+	}
+}
+
+func TestFromEnvNotConfigured(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if _, ok := FromEnv(); ok {
+		t.Fatal("expected tracer not to be configured")
+	}
+}